@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"voltgpt/internal/db"
+	"voltgpt/internal/hasher"
+)
+
+const hashesUsage = `usage:
+  voltgpt hashes export [-db voltgpt.db] [-format jsonl|csv] [-guild ID] [-o FILE]
+  voltgpt hashes import [-db voltgpt.db] [-format jsonl|csv] FILE
+  voltgpt hashes compact [-db voltgpt.db]`
+
+// runHashes runs the hashes subcommand, which moves image and video hashes between
+// instances and shrinks the stored message JSON.
+func runHashes(args []string) error {
+	if len(args) == 0 || !slices.Contains([]string{"export", "import", "compact"}, args[0]) {
+		return errors.New(hashesUsage)
+	}
+
+	flags := flag.NewFlagSet("hashes "+args[0], flag.ContinueOnError)
+	dbPath := flags.String("db", "voltgpt.db", "database file")
+	format := flags.String("format", "", "jsonl or csv (images only), by default taken from the file extension")
+	guildID := flags.String("guild", "", "export only this guild")
+	output := flags.String("o", "", "export to this file instead of stdout")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	db.Open(*dbPath)
+	defer db.Close()
+	hasher.Init(db.DB)
+
+	switch args[0] {
+	case "export":
+		w := io.Writer(os.Stdout)
+		if *output != "" {
+			f, err := os.Create(*output)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+		n, err := hasher.Export(w, hashFormat(*format, *output), *guildID)
+		if err != nil {
+			return err
+		}
+		log.Printf("Exported %d hashes", n)
+	case "import":
+		if flags.NArg() != 1 {
+			return errors.New(hashesUsage)
+		}
+		f, err := os.Open(flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		n, err := hasher.Import(f, hashFormat(*format, flags.Arg(0)))
+		if err != nil {
+			return err
+		}
+		log.Printf("Imported %d hashes", n)
+	case "compact":
+		rows, saved, err := hasher.Compact()
+		if err != nil {
+			return err
+		}
+		if _, err := db.DB.Exec("VACUUM"); err != nil {
+			return fmt.Errorf("vacuum: %w", err)
+		}
+		log.Printf("Compacted %d messages, saving %d KB", rows, saved>>10)
+	}
+	return nil
+}
+
+// hashFormat returns format, or the format the file name suggests.
+func hashFormat(format, file string) string {
+	if format != "" {
+		return format
+	}
+	if strings.EqualFold(filepath.Ext(file), ".csv") {
+		return hasher.FormatCSV
+	}
+	return hasher.FormatJSONL
+}
@@ -4,6 +4,8 @@ package gemini
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
@@ -15,6 +17,7 @@ import (
 
 	"voltgpt/internal/config"
 	"voltgpt/internal/discord"
+	"voltgpt/internal/media"
 	"voltgpt/internal/utility"
 )
 
@@ -148,16 +151,19 @@ func (s *Streamer) Flush() {
 }
 
 // StreamMessageResponse streams the response from Gemini to Discord.
-func StreamMessageResponse(ctx context.Context, s *discordgo.Session, c *genai.Client, m *discordgo.Message, history []*genai.Content, backgroundFacts string) error {
+func StreamMessageResponse(ctx context.Context, s *discordgo.Session, c *genai.Client, m *discordgo.Message, history []*genai.Content, backgroundFacts string, reply *discordgo.Message) (retErr error) {
 	if err := ctx.Err(); err != nil {
 		return err
 	}
+	if len(history) == 0 {
+		return fmt.Errorf("no messages to send")
+	}
 
 	// Configure the model
 	modelName := "gemini-3.1-pro-preview"
 
 	// Handle "Thinking..." message
-	msg, err := discord.SendMessage(s, m, "Thinking...")
+	msg, err := discord.SendOrEditMessage(s, m, reply, "Thinking...")
 	if err != nil {
 		return fmt.Errorf("failed to send message: %v", err)
 	}
@@ -165,27 +171,29 @@ func StreamMessageResponse(ctx context.Context, s *discordgo.Session, c *genai.C
 	// Setup streaming
 	streamer := NewStreamer(s, msg)
 	streamer.Start()
-	defer streamer.Stop()
+	defer func() {
+		streamer.Stop()
+		if retErr != nil && !errors.Is(retErr, context.Canceled) {
+			if _, err := discord.EditMessage(s, streamer.Message, "⚠️ Something went wrong while generating this response."); err != nil {
+				retErr = errors.Join(retErr, fmt.Errorf("set Discord error state: %w", err))
+			}
+		}
+		if retErr != nil {
+			retErr = &discord.ReplyError{Message: streamer.Message, Streamed: streamer.HasVisibleOutput(), Err: retErr}
+		}
+	}()
 
-	// Modify system message
-	systemMessageText := config.SystemMessage
-	systemMessageText = strings.ReplaceAll(systemMessageText, "{TIME}", time.Now().Format("2006-01-02 15:04:05"))
 	channel, err := s.Channel(m.ChannelID)
 	if err != nil {
 		channel = &discordgo.Channel{
 			Name: "Unknown",
 		}
 	}
-	systemMessageText = strings.ReplaceAll(systemMessageText, "{CHANNEL}", channel.Name)
-	systemMessageText = strings.ReplaceAll(systemMessageText, "{BACKGROUND_FACTS}", backgroundFacts)
+	systemMessageText := config.SystemInstructions(time.Now(), channel.Name, backgroundFacts)
 
 	// Create system content
 	systemInstruction := genai.NewContentFromText(systemMessageText, genai.RoleModel)
 
-	if len(history) == 0 {
-		return fmt.Errorf("no messages to send")
-	}
-
 	t := float32(1)
 	config := &genai.GenerateContentConfig{
 		SystemInstruction: systemInstruction,
@@ -245,7 +253,7 @@ func contentToString(c *genai.Content) string {
 	return sb.String()
 }
 
-func PrependReplyMessages(s *discordgo.Session, c *genai.Client, originMember *discordgo.Member, message *discordgo.Message, cache []*discordgo.Message, chatMessages *[]*genai.Content) {
+func PrependReplyMessages(ctx context.Context, s *discordgo.Session, c *genai.Client, originMember *discordgo.Member, message *discordgo.Message, cache []*discordgo.Message, chatMessages *[]*genai.Content) {
 	reference := utility.GetReferencedMessage(s, message, cache)
 	if reference == nil {
 		return
@@ -276,15 +284,15 @@ func PrependReplyMessages(s *discordgo.Session, c *genai.Client, originMember *d
 		replyContent.Text = fmt.Sprintf("<user name=\"%s\"> %s </user>", reply.Author.Username, replyContent.Text)
 	}
 
-	newMsg := CreateContent(c, role, replyContent)
+	newMsg := CreateContent(ctx, c, role, replyContent)
 	*chatMessages = append([]*genai.Content{newMsg}, *chatMessages...)
 
 	if reply.Type == discordgo.MessageTypeReply {
-		PrependReplyMessages(s, c, originMember, reference, cache, chatMessages)
+		PrependReplyMessages(ctx, s, c, originMember, reference, cache, chatMessages)
 	}
 }
 
-func CreateContent(c *genai.Client, role string, content config.RequestContent) *genai.Content {
+func CreateContent(ctx context.Context, c *genai.Client, role string, content config.RequestContent) *genai.Content {
 	parts := []*genai.Part{}
 	if content.Text != "" {
 		parts = append(parts, &genai.Part{Text: content.Text})
@@ -339,7 +347,7 @@ func CreateContent(c *genai.Client, role string, content config.RequestContent)
 		})
 	}
 
-	for _, pdfURL := range content.PDFs {
+	for _, pdfURL := range content.PDFs[:min(len(content.PDFs), utility.MaxPDFsPerMessage)] {
 		data, err := utility.DownloadBytes(pdfURL)
 		if err != nil {
 			continue
@@ -353,8 +361,29 @@ func CreateContent(c *genai.Client, role string, content config.RequestContent)
 	}
 
 	for _, ytURL := range content.YTURLs {
-		part := genai.NewPartFromURI(ytURL, "video/mp4")
-		parts = append(parts, part)
+		// Gemini reads YouTube links natively; other sites go through yt-dlp.
+		if utility.IsYTURL(ytURL) {
+			parts = append(parts, genai.NewPartFromURI(ytURL, "video/mp4"))
+			continue
+		}
+		video, err := media.Ingest(ctx, ytURL)
+		if err != nil {
+			log.Printf("gemini: skip linked video %s: %v", ytURL, err)
+			continue
+		}
+		parts = append(parts, genai.NewPartFromText(video.PromptText()))
+		for _, frame := range video.Frames {
+			data, err := base64.StdEncoding.DecodeString(frame)
+			if err != nil {
+				continue
+			}
+			parts = append(parts, &genai.Part{
+				InlineData: &genai.Blob{
+					Data:     data,
+					MIMEType: "image/jpeg",
+				},
+			})
+		}
 	}
 
 	return &genai.Content{
@@ -19,7 +19,7 @@ func TestStreamMessageResponse_CanceledContext(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
 
-	err := StreamMessageResponse(ctx, nil, nil, nil, nil, "")
+	err := StreamMessageResponse(ctx, nil, nil, nil, nil, "", nil)
 	if !errors.Is(err, context.Canceled) {
 		t.Fatalf("StreamMessageResponse() error = %v, want context.Canceled", err)
 	}
@@ -75,7 +75,7 @@ func minimalPNG(t *testing.T) []byte {
 }
 
 func TestCreateContent_TextOnly(t *testing.T) {
-	got := CreateContent(nil, "user", config.RequestContent{Text: "hello world"})
+	got := CreateContent(context.Background(), nil, "user", config.RequestContent{Text: "hello world"})
 	if got.Role != "user" {
 		t.Errorf("role: got %q, want %q", got.Role, "user")
 	}
@@ -95,7 +95,7 @@ func TestCreateContent_ImageURL(t *testing.T) {
 	}))
 	defer srv.Close()
 
-	got := CreateContent(nil, "user", config.RequestContent{
+	got := CreateContent(context.Background(), nil, "user", config.RequestContent{
 		Images: []string{srv.URL + "/test.png"},
 	})
 	if len(got.Parts) != 1 {
@@ -117,7 +117,7 @@ func TestCreateContent_ThoughtSignature(t *testing.T) {
 	defer srv.Close()
 
 	// thought_signature.png with role="model" → ThoughtSignature part, not InlineData.
-	got := CreateContent(nil, "model", config.RequestContent{
+	got := CreateContent(context.Background(), nil, "model", config.RequestContent{
 		Images: []string{srv.URL + "/thought_signature.png"},
 	})
 	if len(got.Parts) != 1 {
@@ -130,7 +130,7 @@ func TestCreateContent_ThoughtSignature(t *testing.T) {
 
 func TestCreateContent_YouTubeURL(t *testing.T) {
 	ytURL := "https://www.youtube.com/watch?v=testID"
-	got := CreateContent(nil, "user", config.RequestContent{
+	got := CreateContent(context.Background(), nil, "user", config.RequestContent{
 		YTURLs: []string{ytURL},
 	})
 	if len(got.Parts) != 1 {
@@ -146,7 +146,7 @@ func TestCreateContent_YouTubeURL(t *testing.T) {
 
 func TestCreateContent_BadImageURL_Skipped(t *testing.T) {
 	// An unreachable URL should be silently skipped — no panic, no part.
-	got := CreateContent(nil, "user", config.RequestContent{
+	got := CreateContent(context.Background(), nil, "user", config.RequestContent{
 		Images: []string{"http://localhost:0/bad.png"},
 	})
 	if len(got.Parts) != 0 {
@@ -194,3 +194,10 @@ func TestStreamer_DoneChannelIsBuffered(t *testing.T) {
 		t.Fatal("done channel is unbuffered: send would block without a receiver")
 	}
 }
+
+func TestStreamMessageResponse_EmptyHistory(t *testing.T) {
+	err := StreamMessageResponse(context.Background(), nil, nil, nil, nil, "", nil)
+	if err == nil || err.Error() != "no messages to send" {
+		t.Fatalf("StreamMessageResponse() error = %v, want no messages to send", err)
+	}
+}
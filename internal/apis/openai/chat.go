@@ -24,6 +24,7 @@ import (
 	"voltgpt/internal/config"
 	"voltgpt/internal/db"
 	"voltgpt/internal/discord"
+	"voltgpt/internal/media"
 	"voltgpt/internal/utility"
 )
 
@@ -59,7 +60,36 @@ func GetClient() (*oa.Client, error) {
 }
 
 func chatBaseURL() string {
-	baseURL := strings.TrimSpace(os.Getenv("OPENAI_BASE"))
+	return normalizeBaseURL(os.Getenv("OPENAI_BASE"))
+}
+
+// MemoryBaseURL is the endpoint memory's model calls go to: MEMORY_OPENAI_BASE_URL,
+// or the chat endpoint when it is unset. It is empty for api.openai.com.
+func MemoryBaseURL() string {
+	if baseURL := MemoryOwnBaseURL(); baseURL != "" {
+		return baseURL
+	}
+	return chatBaseURL()
+}
+
+// MemoryOwnBaseURL is MEMORY_OPENAI_BASE_URL without the fallback to the chat
+// endpoint, for requests that shouldn't reach the chat proxy.
+func MemoryOwnBaseURL() string {
+	return normalizeBaseURL(os.Getenv("MEMORY_OPENAI_BASE_URL"))
+}
+
+// MemoryEmbeddingBaseURL is the endpoint memory embeddings go to:
+// MEMORY_EMBEDDING_BASE_URL, or MemoryOwnBaseURL when it is unset. Both are
+// normalized like the chat endpoint, so one local server URL works for all.
+func MemoryEmbeddingBaseURL() string {
+	if baseURL := normalizeBaseURL(os.Getenv("MEMORY_EMBEDDING_BASE_URL")); baseURL != "" {
+		return baseURL
+	}
+	return MemoryOwnBaseURL()
+}
+
+func normalizeBaseURL(baseURL string) string {
+	baseURL = strings.TrimSpace(baseURL)
 	if baseURL == "" {
 		return ""
 	}
@@ -79,15 +109,27 @@ func safeBaseURLForLog(baseURL string) string {
 	return parsed.Scheme + "://" + parsed.Host
 }
 
+// GetMemoryClient returns the client for memory's model calls. A token is
+// optional when a base URL is set, since local servers usually don't check it.
 func GetMemoryClient() (*oa.Client, error) {
 	sharedMemoryClientOnce.Do(func() {
 		token := strings.TrimSpace(os.Getenv("MEMORY_OPENAI_TOKEN"))
-		if token == "" {
+		baseURL := MemoryBaseURL()
+		if token == "" && baseURL == "" {
 			sharedMemoryClientErr = fmt.Errorf("MEMORY_OPENAI_TOKEN is not set")
 			return
 		}
+		if token == "" {
+			token = "local"
+		}
+
+		opts := []option.RequestOption{option.WithAPIKey(token)}
+		if baseURL != "" {
+			opts = append(opts, option.WithBaseURL(baseURL))
+			log.Printf("openai: memory client using base URL %s", safeBaseURLForLog(baseURL))
+		}
 
-		client := oa.NewClient(option.WithAPIKey(token))
+		client := oa.NewClient(opts...)
 		sharedMemoryClient = &client
 	})
 	return sharedMemoryClient, sharedMemoryClientErr
@@ -237,7 +279,7 @@ func builtInTools() []responses.ToolUnionParam {
 	}
 }
 
-func StreamMessageResponse(ctx context.Context, s *discordgo.Session, c *oa.Client, m *discordgo.Message, input []responses.ResponseInputItemUnionParam, previousResponseID, backgroundFacts string) (retErr error) {
+func StreamMessageResponse(ctx context.Context, s *discordgo.Session, c *oa.Client, m *discordgo.Message, input []responses.ResponseInputItemUnionParam, previousResponseID, backgroundFacts string, reply *discordgo.Message) (retErr error) {
 	if err := ctx.Err(); err != nil {
 		return err
 	}
@@ -245,7 +287,7 @@ func StreamMessageResponse(ctx context.Context, s *discordgo.Session, c *oa.Clie
 		return fmt.Errorf("no messages to send")
 	}
 
-	msg, err := discord.SendMessage(s, m, "Thinking...")
+	msg, err := discord.SendOrEditMessage(s, m, reply, "Thinking...")
 	if err != nil {
 		return fmt.Errorf("failed to send message: %w", err)
 	}
@@ -262,6 +304,9 @@ func StreamMessageResponse(ctx context.Context, s *discordgo.Session, c *oa.Clie
 				retErr = errors.Join(retErr, fmt.Errorf("set Discord error state: %w", err))
 			}
 		}
+		if retErr != nil {
+			retErr = &discord.ReplyError{Message: streamer.Message, Streamed: streamer.HasVisibleOutput(), Err: retErr}
+		}
 	}()
 
 	channel, err := s.Channel(m.ChannelID)
@@ -269,18 +314,11 @@ func StreamMessageResponse(ctx context.Context, s *discordgo.Session, c *oa.Clie
 		channel = &discordgo.Channel{Name: "Unknown"}
 	}
 
-	contextText := fmt.Sprintf(
-		"\n\n# [Ephemeral context for this turn only]\nCurrent time: %s\nChannel: %s\nRelevant memory/context:\n```xml\n%s\n```",
-		time.Now().Format("2006-01-02 15:04:05"),
-		channel.Name,
-		backgroundFacts,
-	)
-
 	params := responses.ResponseNewParams{
 		Input: responses.ResponseNewParamsInputUnion{
 			OfInputItemList: responses.ResponseInputParam(input),
 		},
-		Instructions:      oa.String(config.SystemMessage + contextText),
+		Instructions:      oa.String(config.SystemInstructions(time.Now(), channel.Name, backgroundFacts)),
 		Metadata:          ResponseMetadata("chat"),
 		Model:             responses.ChatModel(chatModel),
 		Store:             oa.Bool(true),
@@ -291,37 +329,40 @@ func StreamMessageResponse(ctx context.Context, s *discordgo.Session, c *oa.Clie
 		ToolChoice: responses.ResponseNewParamsToolChoiceUnion{
 			OfToolChoiceMode: param.NewOpt(responses.ToolChoiceOptionsAuto),
 		},
-		Tools:          builtInTools(),
+		Tools:          append(builtInTools(), functionTools()...),
 		PromptCacheKey: oa.String("discord:" + m.ChannelID),
 	}
 	if previousResponseID != "" {
 		params.PreviousResponseID = oa.String(previousResponseID)
 	}
 
-	stream := c.Responses.NewStreaming(ctx, params)
+	toolContext := ToolContext{Session: s, Message: m}
 
 	var responseID string
-	for stream.Next() {
-		event := stream.Current()
+	for round := 0; ; round++ {
+		if round == maxToolRounds {
+			// Out of tool budget: make the model answer with what it has.
+			params.ToolChoice = responses.ResponseNewParamsToolChoiceUnion{
+				OfToolChoiceMode: param.NewOpt(responses.ToolChoiceOptionsNone),
+			}
+		}
 
-		switch e := event.AsAny().(type) {
-		case responses.ResponseTextDeltaEvent:
-			streamer.Update(e.Delta)
-		case responses.ResponseRefusalDeltaEvent:
-			streamer.Update(e.Delta)
-		case responses.ResponseCompletedEvent:
-			responseID = e.Response.ID
-		case responses.ResponseErrorEvent:
-			return fmt.Errorf("openai response error: %s", e.Message)
-		case responses.ResponseFailedEvent:
-			return fmt.Errorf("openai response failed: status=%s", e.Response.Status)
+		id, calls, err := streamResponse(ctx, c, params, streamer)
+		if err != nil {
+			return err
 		}
-	}
-	if err := stream.Err(); err != nil {
-		if ctxErr := ctx.Err(); ctxErr != nil {
-			return ctxErr
+		responseID = id
+		if len(calls) == 0 || round == maxToolRounds {
+			break
+		}
+		if responseID == "" {
+			return fmt.Errorf("missing response ID from OpenAI stream")
+		}
+
+		params.PreviousResponseID = oa.String(responseID)
+		params.Input = responses.ResponseNewParamsInputUnion{
+			OfInputItemList: responses.ResponseInputParam(runToolCalls(ctx, toolContext, calls)),
 		}
-		return fmt.Errorf("stream error: %w", err)
 	}
 
 	if err := streamer.Stop(); err != nil {
@@ -348,6 +389,43 @@ func StreamMessageResponse(ctx context.Context, s *discordgo.Session, c *oa.Clie
 	return nil
 }
 
+// streamResponse runs one streamed request, forwarding text to the streamer.
+// It returns the response ID and any function calls the model asked for.
+func streamResponse(ctx context.Context, c *oa.Client, params responses.ResponseNewParams, streamer *streamer) (string, []responses.ResponseFunctionToolCall, error) {
+	stream := c.Responses.NewStreaming(ctx, params)
+
+	var responseID string
+	var calls []responses.ResponseFunctionToolCall
+	for stream.Next() {
+		event := stream.Current()
+
+		switch e := event.AsAny().(type) {
+		case responses.ResponseTextDeltaEvent:
+			streamer.Update(e.Delta)
+		case responses.ResponseRefusalDeltaEvent:
+			streamer.Update(e.Delta)
+		case responses.ResponseOutputItemDoneEvent:
+			if e.Item.Type == "function_call" {
+				calls = append(calls, e.Item.AsFunctionCall())
+			}
+		case responses.ResponseCompletedEvent:
+			responseID = e.Response.ID
+		case responses.ResponseErrorEvent:
+			return "", nil, fmt.Errorf("openai response error: %s", e.Message)
+		case responses.ResponseFailedEvent:
+			return "", nil, fmt.Errorf("openai response failed: status=%s", e.Response.Status)
+		}
+	}
+	if err := stream.Err(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return "", nil, ctxErr
+		}
+		return "", nil, fmt.Errorf("stream error: %w", err)
+	}
+
+	return responseID, calls, nil
+}
+
 func LookupResponseID(discordMsgID string) (string, error) {
 	if db.DB == nil {
 		return "", fmt.Errorf("database is not initialized")
@@ -382,7 +460,7 @@ func StoreResponseID(discordMsgID, openaiResponseID string) error {
 	return err
 }
 
-func PrependReplyMessages(s *discordgo.Session, _ *discordgo.Member, message *discordgo.Message, cache []*discordgo.Message, chatMessages *[]responses.ResponseInputItemUnionParam) {
+func PrependReplyMessages(ctx context.Context, s *discordgo.Session, _ *discordgo.Member, message *discordgo.Message, cache []*discordgo.Message, chatMessages *[]responses.ResponseInputItemUnionParam) {
 	reference := utility.GetReferencedMessage(s, message, cache)
 	if reference == nil {
 		return
@@ -390,7 +468,7 @@ func PrependReplyMessages(s *discordgo.Session, _ *discordgo.Member, message *di
 
 	reply := utility.CleanMessage(s, reference)
 	reply.Content = utility.ResolveMentions(reply.Content, reply.Mentions)
-	images, videos, _, _ := utility.GetMessageMediaURL(reply)
+	images, videos, pdfs, ytURLs := utility.GetMessageMediaURL(reply)
 
 	replyContent := config.RequestContent{
 		Text: strings.TrimSpace(fmt.Sprintf("%s%s%s",
@@ -400,6 +478,8 @@ func PrependReplyMessages(s *discordgo.Session, _ *discordgo.Member, message *di
 		)),
 		Images: images,
 		Videos: videos,
+		PDFs:   pdfs,
+		YTURLs: ytURLs,
 	}
 
 	role := "user"
@@ -409,17 +489,16 @@ func PrependReplyMessages(s *discordgo.Session, _ *discordgo.Member, message *di
 		replyContent.Text = fmt.Sprintf("<user name=\"%s\"> %s </user>", reply.Author.Username, replyContent.Text)
 	}
 
-	newMsg := CreateContent(role, replyContent)
+	newMsg := CreateContent(ctx, role, replyContent)
 	*chatMessages = append([]responses.ResponseInputItemUnionParam{newMsg}, *chatMessages...)
 
 	if reply.Type == discordgo.MessageTypeReply {
-		PrependReplyMessages(s, nil, reference, cache, chatMessages)
+		PrependReplyMessages(ctx, s, nil, reference, cache, chatMessages)
 	}
 }
 
-func CreateContent(role string, content config.RequestContent) responses.ResponseInputItemUnionParam {
+func CreateContent(ctx context.Context, role string, content config.RequestContent) responses.ResponseInputItemUnionParam {
 	var parts responses.ResponseInputMessageContentListParam
-	// PDFs are intentionally omitted because this OpenAI chat path does not support them.
 
 	if strings.TrimSpace(content.Text) != "" {
 		parts = append(parts, responses.ResponseInputContentParamOfInputText(content.Text))
@@ -445,6 +524,30 @@ func CreateContent(role string, content config.RequestContent) responses.Respons
 				parts = append(parts, dataURLImagePart("image/png", frame))
 			}
 		}
+
+		for _, pdfURL := range content.PDFs[:min(len(content.PDFs), utility.MaxPDFsPerMessage)] {
+			pdf, err := utility.PDFToContent(pdfURL)
+			if err != nil {
+				log.Printf("openai: skip pdf %s: %v", pdfURL, err)
+				continue
+			}
+			parts = append(parts, responses.ResponseInputContentParamOfInputText(pdf.PromptText()))
+			for _, page := range pdf.Images {
+				parts = append(parts, dataURLImagePart("image/png", page))
+			}
+		}
+
+		for _, videoURL := range content.YTURLs {
+			video, err := media.Ingest(ctx, videoURL)
+			if err != nil {
+				log.Printf("openai: skip linked video %s: %v", videoURL, err)
+				continue
+			}
+			parts = append(parts, responses.ResponseInputContentParamOfInputText(video.PromptText()))
+			for _, frame := range video.Frames {
+				parts = append(parts, dataURLImagePart("image/jpeg", frame))
+			}
+		}
 	}
 
 	if len(parts) == 0 {
@@ -24,7 +24,7 @@ func TestStreamMessageResponse_CanceledContext(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
 
-	err := StreamMessageResponse(ctx, nil, nil, nil, nil, "", "")
+	err := StreamMessageResponse(ctx, nil, nil, nil, nil, "", "", nil)
 	if !errors.Is(err, context.Canceled) {
 		t.Fatalf("StreamMessageResponse() error = %v, want context.Canceled", err)
 	}
@@ -37,6 +37,24 @@ func TestSafeBaseURLForLog_RedactsCredentialsAndQuery(t *testing.T) {
 	}
 }
 
+func TestMemoryBaseURL_FallsBackToChatBase(t *testing.T) {
+	t.Setenv("MEMORY_OPENAI_BASE_URL", "")
+	t.Setenv("OPENAI_BASE", "")
+	if got := MemoryBaseURL(); got != "" {
+		t.Fatalf("MemoryBaseURL() = %q, want empty", got)
+	}
+
+	t.Setenv("OPENAI_BASE", "https://proxy.example.com")
+	if got := MemoryBaseURL(); got != "https://proxy.example.com/v1/" {
+		t.Fatalf("MemoryBaseURL() = %q, want the chat base", got)
+	}
+
+	t.Setenv("MEMORY_OPENAI_BASE_URL", "http://localhost:11434/v1/")
+	if got := MemoryBaseURL(); got != "http://localhost:11434/v1/" {
+		t.Fatalf("MemoryBaseURL() = %q, want the memory base", got)
+	}
+}
+
 func TestStreamer_StopWaitsForTicker(t *testing.T) {
 	s := newStreamer(nil, nil)
 	s.Start()
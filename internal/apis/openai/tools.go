@@ -0,0 +1,119 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+
+	oa "github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/responses"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxToolRounds caps how many times a single chat turn may go back to the
+// model with tool results before it is forced to answer.
+const maxToolRounds = 5
+
+// ToolContext carries the Discord state a tool call was made from.
+type ToolContext struct {
+	Session *discordgo.Session
+	Message *discordgo.Message
+}
+
+// ToolHandler executes a tool call. args holds the raw JSON arguments chosen by
+// the model; the returned string is sent back to the model as the call output.
+type ToolHandler func(ctx context.Context, tc ToolContext, args json.RawMessage) (string, error)
+
+// Tool is a local function the chat model may call.
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  map[string]any
+	Handler     ToolHandler
+}
+
+var toolRegistry = struct {
+	sync.RWMutex
+	tools []Tool
+}{}
+
+// RegisterTool adds a tool to the chat loop, replacing any tool with the same name.
+func RegisterTool(t Tool) {
+	toolRegistry.Lock()
+	defer toolRegistry.Unlock()
+
+	for idx, existing := range toolRegistry.tools {
+		if existing.Name == t.Name {
+			toolRegistry.tools[idx] = t
+			return
+		}
+	}
+	toolRegistry.tools = append(toolRegistry.tools, t)
+}
+
+func lookupTool(name string) (Tool, bool) {
+	toolRegistry.RLock()
+	defer toolRegistry.RUnlock()
+
+	for _, t := range toolRegistry.tools {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return Tool{}, false
+}
+
+func functionTools() []responses.ToolUnionParam {
+	toolRegistry.RLock()
+	defer toolRegistry.RUnlock()
+
+	tools := make([]responses.ToolUnionParam, 0, len(toolRegistry.tools))
+	for _, t := range toolRegistry.tools {
+		parameters := t.Parameters
+		if parameters == nil {
+			parameters = map[string]any{"type": "object", "properties": map[string]any{}}
+		}
+		tool := responses.ToolParamOfFunction(t.Name, parameters, false)
+		tool.OfFunction.Description = oa.String(t.Description)
+		tools = append(tools, tool)
+	}
+	return tools
+}
+
+// runToolCalls executes each function call and returns the outputs to send
+// back to the model. Failures are reported to the model rather than aborting
+// the turn so it can recover or explain.
+func runToolCalls(ctx context.Context, tc ToolContext, calls []responses.ResponseFunctionToolCall) []responses.ResponseInputItemUnionParam {
+	outputs := make([]responses.ResponseInputItemUnionParam, 0, len(calls))
+	for _, call := range calls {
+		outputs = append(outputs, responses.ResponseInputItemParamOfFunctionCallOutput(call.CallID, runToolCall(ctx, tc, call)))
+	}
+	return outputs
+}
+
+func runToolCall(ctx context.Context, tc ToolContext, call responses.ResponseFunctionToolCall) string {
+	tool, ok := lookupTool(call.Name)
+	if !ok {
+		return toolError(fmt.Errorf("unknown tool %q", call.Name))
+	}
+
+	args := json.RawMessage(call.Arguments)
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+
+	output, err := tool.Handler(ctx, tc, args)
+	if err != nil {
+		log.Printf("openai: tool %s failed: %v", call.Name, err)
+		return toolError(err)
+	}
+	return output
+}
+
+func toolError(err error) string {
+	b, _ := json.Marshal(map[string]string{"error": err.Error()})
+	return string(b)
+}
@@ -0,0 +1,125 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go/v3/responses"
+)
+
+func resetTools(t *testing.T) {
+	t.Helper()
+	toolRegistry.Lock()
+	saved := toolRegistry.tools
+	toolRegistry.tools = nil
+	toolRegistry.Unlock()
+
+	t.Cleanup(func() {
+		toolRegistry.Lock()
+		toolRegistry.tools = saved
+		toolRegistry.Unlock()
+	})
+}
+
+func TestRegisterToolReplacesByName(t *testing.T) {
+	resetTools(t)
+
+	RegisterTool(Tool{Name: "echo", Description: "first"})
+	RegisterTool(Tool{Name: "other"})
+	RegisterTool(Tool{Name: "echo", Description: "second"})
+
+	tools := functionTools()
+	if len(tools) != 2 {
+		t.Fatalf("functionTools() returned %d tools, want 2", len(tools))
+	}
+	if got := tools[0].OfFunction.Description.Value; got != "second" {
+		t.Errorf("description = %q, want %q", got, "second")
+	}
+	if tools[1].OfFunction.Parameters["type"] != "object" {
+		t.Errorf("default parameters = %v, want object schema", tools[1].OfFunction.Parameters)
+	}
+}
+
+func TestRunToolCall(t *testing.T) {
+	resetTools(t)
+
+	RegisterTool(Tool{
+		Name: "echo",
+		Handler: func(ctx context.Context, tc ToolContext, args json.RawMessage) (string, error) {
+			return string(args), nil
+		},
+	})
+	RegisterTool(Tool{
+		Name: "broken",
+		Handler: func(ctx context.Context, tc ToolContext, args json.RawMessage) (string, error) {
+			return "", errors.New("boom")
+		},
+	})
+
+	tests := []struct {
+		name string
+		call responses.ResponseFunctionToolCall
+		want string
+	}{
+		{
+			name: "passes arguments",
+			call: responses.ResponseFunctionToolCall{Name: "echo", Arguments: `{"a":1}`},
+			want: `{"a":1}`,
+		},
+		{
+			name: "empty arguments become an object",
+			call: responses.ResponseFunctionToolCall{Name: "echo"},
+			want: `{}`,
+		},
+		{
+			name: "handler error is reported",
+			call: responses.ResponseFunctionToolCall{Name: "broken"},
+			want: `{"error":"boom"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runToolCall(context.Background(), ToolContext{}, tt.call); got != tt.want {
+				t.Errorf("runToolCall() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunToolCallUnknownTool(t *testing.T) {
+	resetTools(t)
+
+	got := runToolCall(context.Background(), ToolContext{}, responses.ResponseFunctionToolCall{Name: "missing"})
+	if !strings.Contains(got, `unknown tool \"missing\"`) {
+		t.Errorf("runToolCall() = %q, want unknown tool error", got)
+	}
+}
+
+func TestRunToolCallsKeepsCallIDs(t *testing.T) {
+	resetTools(t)
+
+	RegisterTool(Tool{
+		Name: "echo",
+		Handler: func(ctx context.Context, tc ToolContext, args json.RawMessage) (string, error) {
+			return "ok", nil
+		},
+	})
+
+	outputs := runToolCalls(context.Background(), ToolContext{}, []responses.ResponseFunctionToolCall{
+		{Name: "echo", CallID: "call_1"},
+		{Name: "echo", CallID: "call_2"},
+	})
+	if len(outputs) != 2 {
+		t.Fatalf("runToolCalls() returned %d outputs, want 2", len(outputs))
+	}
+	for idx, want := range []string{"call_1", "call_2"} {
+		out := outputs[idx].OfFunctionCallOutput
+		if out == nil || out.CallID != want || out.Output.OfString.Value != "ok" {
+			t.Errorf("output[%d] = %+v, want call %s with output ok", idx, out, want)
+		}
+	}
+}
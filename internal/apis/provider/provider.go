@@ -0,0 +1,157 @@
+// Package provider selects the chat backend that answers a Discord message and
+// falls back to the other backend when the first one fails.
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/openai/openai-go/v3/responses"
+	"google.golang.org/genai"
+
+	"voltgpt/internal/apis/gemini"
+	openaiapi "voltgpt/internal/apis/openai"
+	"voltgpt/internal/config"
+	"voltgpt/internal/discord"
+)
+
+const (
+	OpenAI = "openai"
+	Gemini = "gemini"
+
+	// Default is used when neither the guild nor the channel picked a backend.
+	Default = OpenAI
+)
+
+// Names lists every selectable backend.
+var Names = []string{OpenAI, Gemini}
+
+// Request is a backend-neutral description of one chat turn.
+type Request struct {
+	Session *discordgo.Session
+	// Message is the cleaned message that mentioned the bot.
+	Message *discordgo.Message
+	// Content is the current turn, already wrapped in its <user> tag.
+	Content config.RequestContent
+	// Cache holds recent channel messages used to walk reply chains.
+	Cache   []*discordgo.Message
+	IsReply bool
+	// BackgroundFacts is the rendered memory context injected into the instructions.
+	BackgroundFacts string
+	// Reply is an existing bot message to stream into instead of posting a
+	// new one; the fallback reuses the placeholder the primary left behind.
+	Reply *discordgo.Message
+}
+
+// Provider streams a reply to a Discord message.
+type Provider interface {
+	Name() string
+	StreamMessageResponse(ctx context.Context, req Request) error
+}
+
+var providers = map[string]Provider{
+	OpenAI: openaiProvider{},
+	Gemini: geminiProvider{},
+}
+
+// Valid reports whether name is a known backend.
+func Valid(name string) bool {
+	_, ok := providers[name]
+	return ok
+}
+
+// Resolve returns the backend for name followed by the fallback to use if it fails.
+func Resolve(name string) (Provider, Provider) {
+	primary, ok := providers[name]
+	if !ok {
+		primary = providers[Default]
+	}
+	for _, n := range Names {
+		if n != primary.Name() {
+			return primary, providers[n]
+		}
+	}
+	return primary, nil
+}
+
+// StreamMessageResponse answers req with the named backend, retrying once with
+// the other backend if the first one fails before showing any of its reply.
+// Cancellation and timeouts are not retried.
+func StreamMessageResponse(ctx context.Context, name string, req Request) error {
+	primary, fallback := Resolve(name)
+	return streamWithFallback(ctx, primary, fallback, req)
+}
+
+func streamWithFallback(ctx context.Context, primary, fallback Provider, req Request) error {
+	err := primary.StreamMessageResponse(ctx, req)
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || fallback == nil {
+		return err
+	}
+	var replyErr *discord.ReplyError
+	if errors.As(err, &replyErr) {
+		if replyErr.Streamed {
+			return err
+		}
+		req.Reply = replyErr.Message
+	}
+
+	log.Printf("provider: %s failed, falling back to %s: %v", primary.Name(), fallback.Name(), err)
+	if fallbackErr := fallback.StreamMessageResponse(ctx, req); fallbackErr != nil {
+		return errors.Join(
+			fmt.Errorf("%s: %w", primary.Name(), err),
+			fmt.Errorf("%s: %w", fallback.Name(), fallbackErr),
+		)
+	}
+	return nil
+}
+
+type openaiProvider struct{}
+
+func (openaiProvider) Name() string { return OpenAI }
+
+func (openaiProvider) StreamMessageResponse(ctx context.Context, req Request) error {
+	c, err := openaiapi.GetClient()
+	if err != nil {
+		return err
+	}
+
+	m := req.Message
+	input := []responses.ResponseInputItemUnionParam{openaiapi.CreateContent(ctx, "user", req.Content)}
+
+	var previousResponseID string
+	if req.IsReply {
+		if m.MessageReference != nil {
+			previousResponseID, err = openaiapi.LookupResponseID(m.MessageReference.MessageID)
+			if err != nil {
+				log.Printf("openai: lookup response id for %s: %v", m.MessageReference.MessageID, err)
+			}
+		}
+		if previousResponseID == "" {
+			openaiapi.PrependReplyMessages(ctx, req.Session, m.Member, m, req.Cache, &input)
+		}
+	}
+
+	return openaiapi.StreamMessageResponse(ctx, req.Session, c, m, input, previousResponseID, req.BackgroundFacts, req.Reply)
+}
+
+type geminiProvider struct{}
+
+func (geminiProvider) Name() string { return Gemini }
+
+func (geminiProvider) StreamMessageResponse(ctx context.Context, req Request) error {
+	c, err := gemini.GetClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	m := req.Message
+	history := []*genai.Content{gemini.CreateContent(ctx, c, "user", req.Content)}
+	if req.IsReply {
+		gemini.PrependReplyMessages(ctx, req.Session, c, m.Member, m, req.Cache, &history)
+	}
+
+	return gemini.StreamMessageResponse(ctx, req.Session, c, m, history, req.BackgroundFacts, req.Reply)
+}
@@ -0,0 +1,124 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+
+	"voltgpt/internal/discord"
+)
+
+type fakeProvider struct {
+	name  string
+	err   error
+	calls int
+	reply *discordgo.Message
+}
+
+func (f *fakeProvider) Name() string { return f.name }
+
+func (f *fakeProvider) StreamMessageResponse(ctx context.Context, req Request) error {
+	f.calls++
+	f.reply = req.Reply
+	return f.err
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name         string
+		wantPrimary  string
+		wantFallback string
+	}{
+		{name: "", wantPrimary: OpenAI, wantFallback: Gemini},
+		{name: "unknown", wantPrimary: OpenAI, wantFallback: Gemini},
+		{name: OpenAI, wantPrimary: OpenAI, wantFallback: Gemini},
+		{name: Gemini, wantPrimary: Gemini, wantFallback: OpenAI},
+	}
+
+	for _, tt := range tests {
+		primary, fallback := Resolve(tt.name)
+		if primary.Name() != tt.wantPrimary || fallback.Name() != tt.wantFallback {
+			t.Errorf("Resolve(%q) = (%s, %s), want (%s, %s)", tt.name, primary.Name(), fallback.Name(), tt.wantPrimary, tt.wantFallback)
+		}
+	}
+}
+
+func TestStreamWithFallbackPrimarySucceeds(t *testing.T) {
+	primary := &fakeProvider{name: "a"}
+	fallback := &fakeProvider{name: "b"}
+
+	if err := streamWithFallback(context.Background(), primary, fallback, Request{}); err != nil {
+		t.Fatalf("streamWithFallback() error = %v", err)
+	}
+	if fallback.calls != 0 {
+		t.Errorf("fallback called %d times, want 0", fallback.calls)
+	}
+}
+
+func TestStreamWithFallbackUsesFallback(t *testing.T) {
+	primary := &fakeProvider{name: "a", err: errors.New("down")}
+	fallback := &fakeProvider{name: "b"}
+
+	if err := streamWithFallback(context.Background(), primary, fallback, Request{}); err != nil {
+		t.Fatalf("streamWithFallback() error = %v", err)
+	}
+	if fallback.calls != 1 {
+		t.Errorf("fallback called %d times, want 1", fallback.calls)
+	}
+}
+
+func TestStreamWithFallbackBothFail(t *testing.T) {
+	primaryErr := errors.New("down")
+	fallbackErr := errors.New("also down")
+	primary := &fakeProvider{name: "a", err: primaryErr}
+	fallback := &fakeProvider{name: "b", err: fallbackErr}
+
+	err := streamWithFallback(context.Background(), primary, fallback, Request{})
+	if !errors.Is(err, primaryErr) || !errors.Is(err, fallbackErr) {
+		t.Fatalf("streamWithFallback() error = %v, want both errors", err)
+	}
+}
+
+func TestStreamWithFallbackSkipsOnCancel(t *testing.T) {
+	for _, cause := range []error{context.Canceled, context.DeadlineExceeded} {
+		primary := &fakeProvider{name: "a", err: cause}
+		fallback := &fakeProvider{name: "b"}
+
+		err := streamWithFallback(context.Background(), primary, fallback, Request{})
+		if !errors.Is(err, cause) {
+			t.Fatalf("streamWithFallback() error = %v, want %v", err, cause)
+		}
+		if fallback.calls != 0 {
+			t.Errorf("fallback called %d times after %v, want 0", fallback.calls, cause)
+		}
+	}
+}
+
+func TestStreamWithFallbackReusesPlaceholder(t *testing.T) {
+	placeholder := &discordgo.Message{ID: "msg-1"}
+	primary := &fakeProvider{name: "a", err: &discord.ReplyError{Message: placeholder, Err: errors.New("down")}}
+	fallback := &fakeProvider{name: "b"}
+
+	if err := streamWithFallback(context.Background(), primary, fallback, Request{}); err != nil {
+		t.Fatalf("streamWithFallback() error = %v", err)
+	}
+	if fallback.calls != 1 || fallback.reply != placeholder {
+		t.Errorf("fallback calls = %d reply = %v, want one call into the primary's placeholder", fallback.calls, fallback.reply)
+	}
+}
+
+func TestStreamWithFallbackSkipsAfterStreamedOutput(t *testing.T) {
+	primaryErr := &discord.ReplyError{Message: &discordgo.Message{ID: "msg-1"}, Streamed: true, Err: errors.New("stream cut")}
+	primary := &fakeProvider{name: "a", err: primaryErr}
+	fallback := &fakeProvider{name: "b"}
+
+	err := streamWithFallback(context.Background(), primary, fallback, Request{})
+	if !errors.Is(err, primaryErr) {
+		t.Fatalf("streamWithFallback() error = %v, want the primary error", err)
+	}
+	if fallback.calls != 0 {
+		t.Errorf("fallback called %d times after streamed output, want 0", fallback.calls)
+	}
+}
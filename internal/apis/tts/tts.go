@@ -0,0 +1,227 @@
+// Package tts turns message text into speech. Synthesis goes through a
+// Backend; the default one talks to any OpenAI-compatible /audio/speech
+// endpoint, so a local server can stand in for OpenAI by setting TTS_BASE_URL.
+package tts
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+
+	oa "github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/option"
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+const (
+	// MaxChunkChars is the most text sent in a single synthesis request. The
+	// OpenAI speech endpoint rejects inputs over 4096 characters.
+	MaxChunkChars = 4000
+	// MaxTextChars caps the total text read out for one message.
+	MaxTextChars = 20000
+
+	defaultModel = oa.SpeechModelGPT4oMiniTTS
+	defaultVoice = "alloy"
+)
+
+// Backend synthesizes a single chunk of text into MP3 audio.
+type Backend interface {
+	Synthesize(ctx context.Context, text string) ([]byte, error)
+}
+
+var (
+	backend   Backend
+	backendMu sync.Mutex
+)
+
+// SetBackend replaces the speech backend, e.g. with a different provider.
+// Passing nil restores the OpenAI-compatible default.
+func SetBackend(b Backend) {
+	backendMu.Lock()
+	defer backendMu.Unlock()
+	backend = b
+}
+
+func getBackend() (Backend, error) {
+	backendMu.Lock()
+	defer backendMu.Unlock()
+	if backend == nil {
+		b, err := newOpenAIBackend()
+		if err != nil {
+			return nil, err
+		}
+		backend = b
+	}
+	return backend, nil
+}
+
+type openAIBackend struct {
+	client oa.Client
+	model  string
+	voice  string
+}
+
+// newOpenAIBackend reads TTS_TOKEN (falling back to OPENAI_TOKEN), TTS_BASE_URL,
+// TTS_MODEL and TTS_VOICE. A token is optional when a base URL is set, since
+// local servers usually don't check it.
+func newOpenAIBackend() (Backend, error) {
+	token := strings.TrimSpace(os.Getenv("TTS_TOKEN"))
+	if token == "" {
+		token = strings.TrimSpace(os.Getenv("OPENAI_TOKEN"))
+	}
+	baseURL := strings.TrimSpace(os.Getenv("TTS_BASE_URL"))
+	if token == "" && baseURL == "" {
+		return nil, fmt.Errorf("neither TTS_TOKEN, OPENAI_TOKEN nor TTS_BASE_URL is set")
+	}
+	if token == "" {
+		token = "local"
+	}
+
+	opts := []option.RequestOption{option.WithAPIKey(token)}
+	if baseURL != "" {
+		opts = append(opts, option.WithBaseURL(strings.TrimRight(baseURL, "/")+"/"))
+	}
+
+	return openAIBackend{
+		client: oa.NewClient(opts...),
+		model:  envOr("TTS_MODEL", defaultModel),
+		voice:  envOr("TTS_VOICE", defaultVoice),
+	}, nil
+}
+
+func (b openAIBackend) Synthesize(ctx context.Context, text string) ([]byte, error) {
+	resp, err := b.client.Audio.Speech.New(ctx, oa.AudioSpeechNewParams{
+		Input:          text,
+		Model:          b.model,
+		Voice:          oa.AudioSpeechNewParamsVoiceUnion{OfString: oa.String(b.voice)},
+		ResponseFormat: oa.AudioSpeechNewParamsResponseFormatMP3,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return io.ReadAll(resp.Body)
+}
+
+// Speak synthesizes text into a single MP3, splitting long text into chunks
+// and joining the results with ffmpeg.
+func Speak(ctx context.Context, text string) ([]byte, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, errors.New("nothing to read out")
+	}
+	if runes := []rune(text); len(runes) > MaxTextChars {
+		text = string(runes[:MaxTextChars])
+	}
+
+	b, err := getBackend()
+	if err != nil {
+		return nil, err
+	}
+
+	var parts [][]byte
+	for _, chunk := range SplitText(text, MaxChunkChars) {
+		audio, err := b.Synthesize(ctx, chunk)
+		if err != nil {
+			return nil, fmt.Errorf("failed to synthesize chunk %d: %w", len(parts)+1, err)
+		}
+		parts = append(parts, audio)
+	}
+
+	if len(parts) == 1 {
+		return parts[0], nil
+	}
+	return concatMP3(parts)
+}
+
+// SplitText breaks text into chunks of at most limit characters, preferring
+// paragraph, then sentence, then word boundaries.
+func SplitText(text string, limit int) []string {
+	var chunks []string
+	runes := []rune(strings.TrimSpace(text))
+	for len(runes) > limit {
+		cut := splitPoint(runes[:limit])
+		chunk := strings.TrimSpace(string(runes[:cut]))
+		if chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		runes = []rune(strings.TrimSpace(string(runes[cut:])))
+	}
+	if len(runes) > 0 {
+		chunks = append(chunks, string(runes))
+	}
+	return chunks
+}
+
+// splitPoint returns where to cut window, which is assumed to be full. It only
+// considers the back half so chunks don't become tiny.
+func splitPoint(window []rune) int {
+	half := len(window) / 2
+	for i := len(window) - 1; i > half; i-- {
+		if window[i] == '\n' {
+			return i + 1
+		}
+	}
+	for i := len(window) - 1; i > half; i-- {
+		if strings.ContainsRune(".!?", window[i-1]) && unicode.IsSpace(window[i]) {
+			return i
+		}
+	}
+	for i := len(window) - 1; i > half; i-- {
+		if unicode.IsSpace(window[i]) {
+			return i
+		}
+	}
+	return len(window)
+}
+
+func concatMP3(parts [][]byte) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "tts_*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var list strings.Builder
+	for i, part := range parts {
+		name := filepath.Join(dir, fmt.Sprintf("part-%03d.mp3", i))
+		if err := os.WriteFile(name, part, 0o600); err != nil {
+			return nil, fmt.Errorf("failed to write audio chunk: %w", err)
+		}
+		fmt.Fprintf(&list, "file '%s'\n", name)
+	}
+	listPath := filepath.Join(dir, "list.txt")
+	if err := os.WriteFile(listPath, []byte(list.String()), 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write concat list: %w", err)
+	}
+
+	outPath := filepath.Join(dir, "out.mp3")
+	stderr := bytes.NewBuffer(nil)
+	err = ffmpeg.Input(listPath, ffmpeg.KwArgs{"f": "concat", "safe": 0}).
+		Output(outPath, ffmpeg.KwArgs{"c": "copy"}).
+		GlobalArgs("-hide_banner", "-loglevel", "error").
+		OverWriteOutput().
+		WithErrorOutput(stderr).
+		Silent(true).
+		Run()
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg concat: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return os.ReadFile(outPath)
+}
+
+func envOr(key, fallback string) string {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,119 @@
+package tts
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+type fakeBackend struct {
+	inputs []string
+	audio  []byte
+	err    error
+}
+
+func (f *fakeBackend) Synthesize(_ context.Context, text string) ([]byte, error) {
+	f.inputs = append(f.inputs, text)
+	return f.audio, f.err
+}
+
+func useBackend(t *testing.T, b Backend) {
+	t.Helper()
+	SetBackend(b)
+	t.Cleanup(func() { SetBackend(nil) })
+}
+
+func TestSplitText(t *testing.T) {
+	t.Run("short text is one chunk", func(t *testing.T) {
+		got := SplitText("  hello there  ", 100)
+		if len(got) != 1 || got[0] != "hello there" {
+			t.Errorf("SplitText() = %q, want [\"hello there\"]", got)
+		}
+	})
+
+	t.Run("prefers sentence boundaries", func(t *testing.T) {
+		got := SplitText("First sentence here. Second sentence here.", 30)
+		want := []string{"First sentence here.", "Second sentence here."}
+		if strings.Join(got, "|") != strings.Join(want, "|") {
+			t.Errorf("SplitText() = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("hard cut without spaces", func(t *testing.T) {
+		got := SplitText(strings.Repeat("a", 25), 10)
+		if len(got) != 3 || len(got[0]) != 10 || len(got[2]) != 5 {
+			t.Errorf("SplitText() = %q, want 10/10/5 chunks", got)
+		}
+	})
+
+	t.Run("chunks stay within the limit", func(t *testing.T) {
+		text := strings.Repeat("word ", 2000)
+		for _, chunk := range SplitText(text, MaxChunkChars) {
+			if n := len([]rune(chunk)); n > MaxChunkChars {
+				t.Errorf("chunk has %d chars, limit is %d", n, MaxChunkChars)
+			}
+		}
+	})
+}
+
+func TestSpeakSingleChunk(t *testing.T) {
+	fake := &fakeBackend{audio: []byte("mp3")}
+	useBackend(t, fake)
+
+	got, err := Speak(context.Background(), "hello")
+	if err != nil {
+		t.Fatalf("Speak() error: %v", err)
+	}
+	if string(got) != "mp3" {
+		t.Errorf("Speak() = %q, want backend audio", got)
+	}
+	if len(fake.inputs) != 1 || fake.inputs[0] != "hello" {
+		t.Errorf("backend inputs = %q, want [\"hello\"]", fake.inputs)
+	}
+}
+
+func TestSpeakEmptyText(t *testing.T) {
+	useBackend(t, &fakeBackend{})
+
+	if _, err := Speak(context.Background(), "   "); err == nil {
+		t.Error("Speak() error = nil, want error for empty text")
+	}
+}
+
+func TestSpeakBackendError(t *testing.T) {
+	useBackend(t, &fakeBackend{err: errors.New("boom")})
+
+	_, err := Speak(context.Background(), "hello")
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("Speak() error = %v, want backend error", err)
+	}
+}
+
+func TestSpeakConcatenatesChunks(t *testing.T) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		t.Skipf("ffmpeg not installed, skipping: %v", err)
+	}
+
+	// The concat step needs real MP3 data, so the fake returns a short silent clip.
+	silence, err := exec.Command("ffmpeg", "-hide_banner", "-loglevel", "error",
+		"-f", "lavfi", "-i", "anullsrc=r=24000:cl=mono", "-t", "0.2", "-f", "mp3", "pipe:1").Output()
+	if err != nil {
+		t.Skipf("could not generate test audio: %v", err)
+	}
+
+	fake := &fakeBackend{audio: silence}
+	useBackend(t, fake)
+
+	got, err := Speak(context.Background(), strings.Repeat("word ", MaxChunkChars/2))
+	if err != nil {
+		t.Fatalf("Speak() error: %v", err)
+	}
+	if len(fake.inputs) < 2 {
+		t.Fatalf("backend called %d times, want several chunks", len(fake.inputs))
+	}
+	if len(got) <= len(silence) {
+		t.Errorf("concatenated audio is %d bytes, want more than one %d byte chunk", len(got), len(silence))
+	}
+}
@@ -4,6 +4,11 @@ import (
 	"github.com/bwmarrin/discordgo"
 )
 
+// capabilityPermission hides commands that need a bot capability from members
+// without Manage Server. Guilds can grant them to the roles holding the
+// capability through the server's integration settings.
+var capabilityPermission int64 = discordgo.PermissionManageGuild
+
 var (
 	writePermission int64 = discordgo.PermissionSendMessages
 	// adminPermission int64   = discordgo.PermissionAdministrator
@@ -102,7 +107,7 @@ var (
 		{
 			Name:                     "hash_server",
 			Description:              "Hash all the images and videos in the server",
-			DefaultMemberPermissions: &writePermission,
+			DefaultMemberPermissions: &capabilityPermission,
 			DMPermission:             &dmPermission,
 			Options: []*discordgo.ApplicationCommandOption{
 				{
@@ -125,6 +130,40 @@ var (
 				},
 			},
 		},
+		{
+			Name:                     "snail_leaderboard",
+			Description:              "Rank the server's most frequent reposters and most reposted images",
+			DefaultMemberPermissions: &writePermission,
+			DMPermission:             &dmPermission,
+		},
+		{
+			Name:                     "find_image",
+			Description:              "Find the stored images in the server closest to an image",
+			DefaultMemberPermissions: &writePermission,
+			DMPermission:             &dmPermission,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionAttachment,
+					Name:        "image",
+					Description: "Image or video to search for",
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "url",
+					Description: "Link to an image or video to search for",
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "count",
+					Description: "How many matches to show, 10 by default",
+					Required:    false,
+					MinValue:    &integerMin,
+					MaxValue:    25,
+				},
+			},
+		},
 		{
 			Name:                     "wheel_status",
 			Description:              "Movie wheel",
@@ -143,7 +182,7 @@ var (
 		{
 			Name:                     "wheel_add",
 			Description:              "Add a user to the wheel",
-			DefaultMemberPermissions: &writePermission,
+			DefaultMemberPermissions: &capabilityPermission,
 			DMPermission:             &dmPermission,
 			Options: []*discordgo.ApplicationCommandOption{
 				{
@@ -163,7 +202,7 @@ var (
 		{
 			Name:                     "insert_bet",
 			Description:              "Add a bet to a round",
-			DefaultMemberPermissions: &writePermission,
+			DefaultMemberPermissions: &capabilityPermission,
 			DMPermission:             &dmPermission,
 			Options: []*discordgo.ApplicationCommandOption{
 				{
@@ -195,7 +234,7 @@ var (
 		{
 			Name:                     "reset_wheel",
 			Description:              "Reset the wheel",
-			DefaultMemberPermissions: &writePermission,
+			DefaultMemberPermissions: &capabilityPermission,
 			DMPermission:             &dmPermission,
 			Options: []*discordgo.ApplicationCommandOption{
 				{
@@ -221,7 +260,7 @@ var (
 		{
 			Name:                     "memory_admin_view",
 			Description:              "View a user's guild-scoped memory profile (admin only)",
-			DefaultMemberPermissions: &writePermission,
+			DefaultMemberPermissions: &capabilityPermission,
 			DMPermission:             &dmPermission,
 			Options: []*discordgo.ApplicationCommandOption{
 				{
@@ -235,7 +274,7 @@ var (
 		{
 			Name:                     "memory_admin_delete",
 			Description:              "Delete guild-scoped memory (admin only)",
-			DefaultMemberPermissions: &writePermission,
+			DefaultMemberPermissions: &capabilityPermission,
 			DMPermission:             &dmPermission,
 			Options: []*discordgo.ApplicationCommandOption{
 				{
@@ -249,7 +288,7 @@ var (
 		{
 			Name:                     "memory_admin_dirty",
 			Description:              "Mark cached guild-scoped profiles dirty (admin only)",
-			DefaultMemberPermissions: &writePermission,
+			DefaultMemberPermissions: &capabilityPermission,
 			DMPermission:             &dmPermission,
 			Options: []*discordgo.ApplicationCommandOption{
 				{
@@ -266,10 +305,92 @@ var (
 			DefaultMemberPermissions: &writePermission,
 			DMPermission:             &dmPermission,
 		},
+		{
+			Name:                     "memory_forget",
+			Description:              "Delete one fact from your memory profile",
+			DefaultMemberPermissions: &writePermission,
+			DMPermission:             &dmPermission,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "fact",
+					Description: "ID of the fact, as shown by /memory_self",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Name:                     "memory_dispute",
+			Description:              "Mark a fact in your memory profile as wrong",
+			DefaultMemberPermissions: &writePermission,
+			DMPermission:             &dmPermission,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "fact",
+					Description: "ID of the fact, as shown by /memory_self",
+					Required:    true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "correction",
+					Description: "What is true instead, pinned in its place",
+					Required:    false,
+				},
+			},
+		},
+		{
+			Name:                     "memory_pin",
+			Description:              "Add a fact about yourself that the bot always remembers",
+			DefaultMemberPermissions: &writePermission,
+			DMPermission:             &dmPermission,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "section",
+					Description: "Profile section to add the fact to",
+					Required:    true,
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "Bio", Value: "bio"},
+						{Name: "Interests", Value: "interests"},
+						{Name: "Skills", Value: "skills"},
+						{Name: "Opinions", Value: "opinions"},
+						{Name: "Relationships", Value: "relationships"},
+						{Name: "Other", Value: "other"},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "fact",
+					Description: "The fact, in a short sentence",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Name:                     "memory_opt_out",
+			Description:              "Stop or resume the bot remembering your messages",
+			DefaultMemberPermissions: &writePermission,
+			DMPermission:             &dmPermission,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionBoolean,
+					Name:        "enabled",
+					Description: "True to opt out, false to opt back in (leave empty to show)",
+					Required:    false,
+				},
+			},
+		},
+		{
+			Name:                     "memory_export",
+			Description:              "Get a DM with everything the bot stores about you",
+			DefaultMemberPermissions: &writePermission,
+			DMPermission:             &dmPermission,
+		},
 		{
 			Name:                     "memory_admin_digest",
 			Description:              "Show recent conversation notes and topic digests (admin only)",
-			DefaultMemberPermissions: &writePermission,
+			DefaultMemberPermissions: &capabilityPermission,
 			DMPermission:             &dmPermission,
 		},
 		{
@@ -298,5 +419,135 @@ var (
 			DefaultMemberPermissions: &writePermission,
 			DMPermission:             &dmPermission,
 		},
+		{
+			Name:                     "timezone",
+			Description:              "Set the timezone your reminder times are read in",
+			DefaultMemberPermissions: &writePermission,
+			DMPermission:             &dmPermission,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "zone",
+					Description: "IANA zone like Europe/Berlin or an abbreviation like CET (leave empty to show, \"clear\" to reset)",
+					Required:    false,
+				},
+			},
+		},
+		{
+			Name:                     "settings",
+			Description:              "View or change the bot settings for this server (admin only)",
+			DefaultMemberPermissions: &capabilityPermission,
+			DMPermission:             &dmPermission,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionBoolean,
+					Name:        "memory",
+					Description: "Remember conversations in this server (off by default)",
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionBoolean,
+					Name:        "hashing",
+					Description: "Automatically hash posted images and videos",
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionChannel,
+					Name:        "blacklist_channel",
+					Description: "Stop remembering messages in a channel",
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionChannel,
+					Name:        "unblacklist_channel",
+					Description: "Resume remembering messages in a channel",
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionRole,
+					Name:        "add_admin_role",
+					Description: "Allow a role to use admin commands",
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionRole,
+					Name:        "remove_admin_role",
+					Description: "Revoke admin commands from a role",
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "chat_provider",
+					Description: "Chat backend for the server, or for provider_channel if set",
+					Required:    false,
+					Choices:     ChatProviderChoices,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionChannel,
+					Name:        "provider_channel",
+					Description: "Apply chat_provider to this channel only",
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "snail_callouts",
+					Description: "Call out reposted images and videos in snail_channel (or this channel)",
+					Required:    false,
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "Off", Value: "off"},
+						{Name: "React", Value: "react"},
+						{Name: "Reply", Value: "reply"},
+						{Name: "React and reply", Value: "both"},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "snail_threshold",
+					Description: "Largest hash distance called out as a repost (default 8)",
+					Required:    false,
+					MinValue:    &integerMin,
+					MaxValue:    32,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionChannel,
+					Name:        "snail_channel",
+					Description: "Apply the snail options to this channel instead of the current one",
+					Required:    false,
+				},
+			},
+		},
+		{
+			Name:                     "permissions",
+			Description:              "Grant or revoke bot capabilities for a role (admin only)",
+			DefaultMemberPermissions: &capabilityPermission,
+			DMPermission:             &dmPermission,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "capability",
+					Description: "Capability to grant or revoke",
+					Required:    false,
+					Choices:     CapabilityChoices,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionRole,
+					Name:        "role",
+					Description: "Role to grant the capability to",
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionBoolean,
+					Name:        "revoke",
+					Description: "Revoke instead of grant",
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionBoolean,
+					Name:        "audit",
+					Description: "Show the most recent privileged actions",
+					Required:    false,
+				},
+			},
+		},
 	}
 )
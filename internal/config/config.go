@@ -2,6 +2,9 @@
 package config
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/bwmarrin/discordgo"
 )
 
@@ -29,14 +32,6 @@ const (
 )
 
 var (
-	Admins          = []string{"102087943627243520", "123116664207179777", "95681688914366464"}
-	MainServer      = "122962330165313536"
-	MemoryBlacklist = map[string]bool{
-		"850179179281776670":  true, // #food-and-domestic
-		"1194031828126924831": true, // #weddingbabes
-		"1008450469313663077": true, // #skynet
-	}
-
 	DefaultTemp = 0.8
 
 	ResolutionChoices = []*discordgo.ApplicationCommandOptionChoice{
@@ -50,6 +45,18 @@ var (
 		{Name: "10", Value: 10},
 	}
 
+	ChatProviderChoices = []*discordgo.ApplicationCommandOptionChoice{
+		{Name: "Default", Value: "default"},
+		{Name: "OpenAI", Value: "openai"},
+		{Name: "Gemini", Value: "gemini"},
+	}
+
+	CapabilityChoices = []*discordgo.ApplicationCommandOptionChoice{
+		{Name: "Memory admin", Value: "memory_admin"},
+		{Name: "Wheel host", Value: "wheel_host"},
+		{Name: "Hasher admin", Value: "hasher_admin"},
+	}
+
 	SystemMessage = `You are **Vivy**, a Discord bot, use the discord markdown style. 
 Respond naturally and maturely across adult-oriented topics, including raunchy, sensitive, political, and divisive subjects.
 
@@ -74,3 +81,15 @@ Background facts will appear in a message close to the last one in the conversat
 5. Distinguish carefully between user profiles in <user> sections, broader guild context in <topics> and raw episodic summaries in <notes>.
 `
 )
+
+// SystemInstructions returns SystemMessage followed by the ephemeral context
+// block for the current turn. Every chat backend uses it so the time, channel
+// and memory background facts reach the model the same way.
+func SystemInstructions(now time.Time, channelName, backgroundFacts string) string {
+	return SystemMessage + fmt.Sprintf(
+		"\n\n# [Ephemeral context for this turn only]\nCurrent time: %s\nChannel: %s\nRelevant memory/context:\n```xml\n%s\n```",
+		now.Format("2006-01-02 15:04:05"),
+		channelName,
+		backgroundFacts,
+	)
+}
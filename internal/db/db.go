@@ -5,7 +5,6 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
-	"strings"
 	"sync/atomic"
 
 	sqlite_vec "github.com/asg017/sqlite-vec-go-bindings/cgo"
@@ -74,9 +73,59 @@ func createTables() {
 
 	tables := []string{
 		`CREATE TABLE IF NOT EXISTS image_hashes (
-			hash TEXT PRIMARY KEY,
+			guild_id TEXT NOT NULL DEFAULT '',
+			hash TEXT NOT NULL,
+			channel_id TEXT NOT NULL DEFAULT '',
+			message_json TEXT NOT NULL,
+			canonical_hash TEXT NOT NULL DEFAULT '',
+			PRIMARY KEY (guild_id, hash)
+		)`,
+		`CREATE TABLE IF NOT EXISTS image_hash_variants (
+			guild_id TEXT NOT NULL DEFAULT '',
+			hash TEXT NOT NULL,
+			algorithm TEXT NOT NULL,
+			value TEXT NOT NULL,
+			PRIMARY KEY (guild_id, hash, algorithm)
+		)`,
+		`CREATE TABLE IF NOT EXISTS image_hash_sightings (
+			guild_id TEXT NOT NULL DEFAULT '',
+			hash TEXT NOT NULL,
+			message_id TEXT NOT NULL,
+			channel_id TEXT NOT NULL DEFAULT '',
+			author_id TEXT NOT NULL DEFAULT '',
+			author_name TEXT NOT NULL DEFAULT '',
+			posted_at INTEGER NOT NULL,
+			PRIMARY KEY (guild_id, hash, message_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS video_hashes (
+			key TEXT PRIMARY KEY,
+			duration REAL NOT NULL,
+			step REAL NOT NULL,
+			frames TEXT NOT NULL,
 			message_json TEXT NOT NULL
 		)`,
+		`CREATE TABLE IF NOT EXISTS hash_jobs (
+			id                INTEGER PRIMARY KEY AUTOINCREMENT,
+			guild_id          TEXT    NOT NULL,
+			requested_by      TEXT    NOT NULL DEFAULT '',
+			status            TEXT    NOT NULL,
+			until             INTEGER NOT NULL DEFAULT 0,
+			status_channel_id TEXT    NOT NULL DEFAULT '',
+			status_message_id TEXT    NOT NULL DEFAULT '',
+			messages          INTEGER NOT NULL DEFAULT 0,
+			hashes            INTEGER NOT NULL DEFAULT 0,
+			failures          INTEGER NOT NULL DEFAULT 0,
+			created_at        INTEGER NOT NULL DEFAULT (unixepoch()),
+			finished_at       INTEGER
+		)`,
+		`CREATE TABLE IF NOT EXISTS hash_job_channels (
+			job_id     INTEGER NOT NULL REFERENCES hash_jobs(id) ON DELETE CASCADE,
+			channel_id TEXT    NOT NULL,
+			cursor     TEXT    NOT NULL DEFAULT '',
+			done       INTEGER NOT NULL DEFAULT 0,
+			messages   INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (job_id, channel_id)
+		)`,
 		`CREATE TABLE IF NOT EXISTS game_state (
 			id INTEGER PRIMARY KEY CHECK (id = 1),
 			data TEXT NOT NULL
@@ -116,6 +165,16 @@ func createTables() {
 			last_full_rebuild_at DATETIME,
 			PRIMARY KEY (guild_id, user_id)
 		)`,
+		`CREATE TABLE IF NOT EXISTS profile_fact_overrides (
+			id         INTEGER PRIMARY KEY AUTOINCREMENT,
+			guild_id   TEXT NOT NULL,
+			user_id    INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			kind       TEXT NOT NULL,
+			section    TEXT NOT NULL,
+			text       TEXT NOT NULL,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (guild_id, user_id, kind, text)
+		)`,
 		`CREATE TABLE IF NOT EXISTS interaction_notes (
 			id              INTEGER PRIMARY KEY AUTOINCREMENT,
 			guild_id        TEXT NOT NULL,
@@ -139,6 +198,13 @@ func createTables() {
 			started_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
 			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
 		)`,
+		`CREATE TABLE IF NOT EXISTS memory_embedding_state (
+			id                INTEGER PRIMARY KEY CHECK (id = 1),
+			model             TEXT NOT NULL,
+			dimensions        INTEGER NOT NULL,
+			target_model      TEXT NOT NULL DEFAULT '',
+			target_dimensions INTEGER NOT NULL DEFAULT 0
+		)`,
 		`CREATE TABLE IF NOT EXISTS memory_job_runs (
 			guild_id    TEXT NOT NULL,
 			job_date    DATE NOT NULL,
@@ -148,6 +214,55 @@ func createTables() {
 			finished_at DATETIME,
 			PRIMARY KEY (guild_id, job_date, phase)
 		)`,
+		`CREATE TABLE IF NOT EXISTS guild_settings (
+			guild_id             TEXT PRIMARY KEY,
+			memory_enabled       INTEGER NOT NULL DEFAULT 0,
+			hashing_enabled      INTEGER NOT NULL DEFAULT 1,
+			blacklisted_channels TEXT NOT NULL DEFAULT '[]',
+			admin_roles          TEXT NOT NULL DEFAULT '[]'
+		)`,
+		`CREATE TABLE IF NOT EXISTS channel_settings (
+			channel_id    TEXT PRIMARY KEY,
+			guild_id      TEXT NOT NULL,
+			chat_provider TEXT NOT NULL DEFAULT ''
+		)`,
+		`CREATE TABLE IF NOT EXISTS role_capabilities (
+			guild_id   TEXT NOT NULL,
+			role_id    TEXT NOT NULL,
+			capability TEXT NOT NULL,
+			PRIMARY KEY (guild_id, role_id, capability)
+		)`,
+		`CREATE TABLE IF NOT EXISTS audit_log (
+			id         INTEGER PRIMARY KEY AUTOINCREMENT,
+			guild_id   TEXT    NOT NULL,
+			user_id    TEXT    NOT NULL,
+			action     TEXT    NOT NULL,
+			details    TEXT    NOT NULL DEFAULT '',
+			created_at INTEGER NOT NULL DEFAULT (unixepoch())
+		)`,
+		`CREATE TABLE IF NOT EXISTS media_ingest (
+			cache_key  TEXT PRIMARY KEY,
+			url        TEXT    NOT NULL,
+			title      TEXT    NOT NULL DEFAULT '',
+			uploader   TEXT    NOT NULL DEFAULT '',
+			duration   REAL    NOT NULL DEFAULT 0,
+			transcript TEXT    NOT NULL DEFAULT '',
+			frames     TEXT    NOT NULL DEFAULT '[]',
+			created_at INTEGER NOT NULL DEFAULT (unixepoch())
+		)`,
+		`CREATE TABLE IF NOT EXISTS media_cache (
+			url_key      TEXT PRIMARY KEY,
+			content_hash TEXT    NOT NULL,
+			size         INTEGER NOT NULL,
+			fetched_at   INTEGER NOT NULL,
+			accessed_at  INTEGER NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_media_cache_accessed
+			ON media_cache(accessed_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_media_cache_content
+			ON media_cache(content_hash)`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_log_guild
+			ON audit_log(guild_id, id)`,
 		`CREATE INDEX IF NOT EXISTS idx_notes_guild_type_date
 			ON interaction_notes(guild_id, note_type, note_date, created_at)`,
 		`CREATE INDEX IF NOT EXISTS idx_notes_guild_channel_created
@@ -164,29 +279,248 @@ func createTables() {
 		}
 	}
 
+	// Table rebuilds come before ensureColumns, which adds the newer columns
+	// to whatever they left behind.
+	migrateHashNamespaces()
+	ensureColumns()
+	backfillVideoNamespaces()
+	backfillHashSightings()
 	ensureVecNotesTable()
+	ensureNotesSearchIndex()
 }
 
-func ensureVecNotesTable() {
-	const createVecNotesSQL = `CREATE VIRTUAL TABLE vec_notes USING vec0(note_id INTEGER PRIMARY KEY, embedding float[1536] distance_metric=cosine)`
-
-	var sqlText string
-	err := DB.QueryRow("SELECT sql FROM sqlite_master WHERE type='table' AND name='vec_notes'").Scan(&sqlText)
-	switch err {
-	case nil:
-		if strings.Contains(strings.ToLower(sqlText), "float[1536]") {
-			return
+// addedColumns lists columns introduced after their table first shipped. They
+// are added in place so existing databases keep their rows.
+var addedColumns = []struct {
+	table      string
+	column     string
+	definition string
+}{
+	{"guild_settings", "chat_provider", "TEXT NOT NULL DEFAULT ''"},
+	{"reminders", "recurrence", "TEXT NOT NULL DEFAULT ''"},
+	{"users", "timezone", "TEXT NOT NULL DEFAULT ''"},
+	{"reminders", "fired", "INTEGER NOT NULL DEFAULT 0"},
+	{"video_hashes", "guild_id", "TEXT NOT NULL DEFAULT ''"},
+	{"video_hashes", "channel_id", "TEXT NOT NULL DEFAULT ''"},
+	{"channel_settings", "snail_mode", "TEXT NOT NULL DEFAULT ''"},
+	{"channel_settings", "snail_threshold", "INTEGER NOT NULL DEFAULT 0"},
+	{"users", "memory_opt_out", "INTEGER NOT NULL DEFAULT 0"},
+	{"image_hashes", "canonical_hash", "TEXT NOT NULL DEFAULT ''"},
+	{"video_hashes", "canonical_key", "TEXT NOT NULL DEFAULT ''"},
+}
+
+func ensureColumns() {
+	for _, c := range addedColumns {
+		exists, err := columnExists(c.table, c.column)
+		if err != nil {
+			log.Fatalf("Failed to inspect %s columns: %v", c.table, err)
+		}
+		if exists {
+			continue
+		}
+		if _, err := DB.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", c.table, c.column, c.definition)); err != nil {
+			log.Fatalf("Failed to add %s.%s: %v", c.table, c.column, err)
 		}
-		log.Printf("Migrating vec_notes to 1536-dimensional embeddings; clearing incompatible stored vectors")
-		if _, err := DB.Exec("DROP TABLE vec_notes"); err != nil {
-			log.Fatalf("Failed to drop vec_notes table: %v", err)
+	}
+}
+
+func columnExists(table, column string) (bool, error) {
+	rows, err := DB.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// migrateHashNamespaces moves image hashes that were keyed by hash alone into
+// per-guild namespaces. The guild and channel come from the stored message
+// JSON; messages fetched over REST carry no guild_id and keep an empty guild
+// until hasher.ResolveGuilds looks their channel up.
+func migrateHashNamespaces() {
+	scoped, err := columnExists("image_hashes", "guild_id")
+	if err != nil {
+		log.Fatalf("Failed to inspect image_hashes columns: %v", err)
+	}
+	if !scoped {
+		log.Printf("Migrating image_hashes to per-guild namespaces")
+		rebuildTable("image_hashes", `CREATE TABLE image_hashes_scoped (
+			guild_id TEXT NOT NULL DEFAULT '',
+			hash TEXT NOT NULL,
+			channel_id TEXT NOT NULL DEFAULT '',
+			message_json TEXT NOT NULL,
+			canonical_hash TEXT NOT NULL DEFAULT '',
+			PRIMARY KEY (guild_id, hash)
+		)`, `INSERT INTO image_hashes_scoped (guild_id, hash, channel_id, message_json)
+			SELECT COALESCE(json_extract(message_json, '$.guild_id'), ''), hash,
+				COALESCE(json_extract(message_json, '$.channel_id'), ''), message_json
+			FROM image_hashes`)
+	}
+
+	scoped, err = columnExists("image_hash_variants", "guild_id")
+	if err != nil {
+		log.Fatalf("Failed to inspect image_hash_variants columns: %v", err)
+	}
+	if !scoped {
+		rebuildTable("image_hash_variants", `CREATE TABLE image_hash_variants_scoped (
+			guild_id TEXT NOT NULL DEFAULT '',
+			hash TEXT NOT NULL,
+			algorithm TEXT NOT NULL,
+			value TEXT NOT NULL,
+			PRIMARY KEY (guild_id, hash, algorithm)
+		)`, `INSERT INTO image_hash_variants_scoped (guild_id, hash, algorithm, value)
+			SELECT COALESCE((SELECT h.guild_id FROM image_hashes h WHERE h.hash = v.hash LIMIT 1), ''),
+				v.hash, v.algorithm, v.value
+			FROM image_hash_variants v`)
+	}
+}
+
+// backfillVideoNamespaces fills in the guild and channel of video hashes stored
+// before they had columns of their own, from the stored message JSON.
+func backfillVideoNamespaces() {
+	_, err := DB.Exec(`UPDATE video_hashes SET
+			guild_id = COALESCE(json_extract(message_json, '$.guild_id'), ''),
+			channel_id = COALESCE(json_extract(message_json, '$.channel_id'), '')
+		WHERE channel_id = ''`)
+	if err != nil {
+		log.Fatalf("Failed to backfill video_hashes namespaces: %v", err)
+	}
+}
+
+// backfillHashSightings seeds image_hash_sightings with the one message each
+// image_hashes row kept, the first time the table is empty.
+func backfillHashSightings() {
+	_, err := DB.Exec(`INSERT OR IGNORE INTO image_hash_sightings
+			(guild_id, hash, message_id, channel_id, author_id, author_name, posted_at)
+		SELECT guild_id, hash,
+			COALESCE(json_extract(message_json, '$.id'), ''),
+			channel_id,
+			COALESCE(json_extract(message_json, '$.author.id'), ''),
+			COALESCE(json_extract(message_json, '$.author.username'), ''),
+			COALESCE(unixepoch(json_extract(message_json, '$.timestamp')), 0)
+		FROM image_hashes
+		WHERE NOT EXISTS (SELECT 1 FROM image_hash_sightings)`)
+	if err != nil {
+		log.Fatalf("Failed to backfill image_hash_sightings: %v", err)
+	}
+}
+
+// rebuildTable replaces table with a copy made by create and fill, which must
+// target a table named table + "_scoped".
+func rebuildTable(table, create, fill string) {
+	tx, err := DB.Begin()
+	if err != nil {
+		log.Fatalf("Failed to migrate %s: %v", table, err)
+	}
+	for _, stmt := range []string{
+		create,
+		fill,
+		"DROP TABLE " + table,
+		fmt.Sprintf("ALTER TABLE %s_scoped RENAME TO %s", table, table),
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			tx.Rollback()
+			log.Fatalf("Failed to migrate %s: %v", table, err)
 		}
-	case sql.ErrNoRows:
-	default:
-		log.Fatalf("Failed to check vec_notes table: %v", err)
 	}
+	if err := tx.Commit(); err != nil {
+		log.Fatalf("Failed to migrate %s: %v", table, err)
+	}
+}
+
+// ensureVecNotesTable creates the note vector index at the default embedding
+// size. An existing index is kept whatever its size: memory re-embeds the
+// notes in the background when the configured embedder changes.
+func ensureVecNotesTable() {
+	const createVecNotesSQL = `CREATE VIRTUAL TABLE IF NOT EXISTS vec_notes USING vec0(note_id INTEGER PRIMARY KEY, embedding float[1536] distance_metric=cosine)`
 
 	if _, err := DB.Exec(createVecNotesSQL); err != nil {
 		log.Fatalf("Failed to create vec_notes table: %v", err)
 	}
 }
+
+// notesSearchTriggers keep interaction_notes_fts in step with
+// interaction_notes.
+var notesSearchTriggers = []struct{ name, create string }{
+	{"interaction_notes_fts_insert", `CREATE TRIGGER IF NOT EXISTS interaction_notes_fts_insert AFTER INSERT ON interaction_notes BEGIN
+		INSERT INTO interaction_notes_fts(rowid, title, summary) VALUES (new.id, new.title, new.summary);
+	END`},
+	{"interaction_notes_fts_delete", `CREATE TRIGGER IF NOT EXISTS interaction_notes_fts_delete AFTER DELETE ON interaction_notes BEGIN
+		INSERT INTO interaction_notes_fts(interaction_notes_fts, rowid, title, summary) VALUES ('delete', old.id, old.title, old.summary);
+	END`},
+	{"interaction_notes_fts_update", `CREATE TRIGGER IF NOT EXISTS interaction_notes_fts_update AFTER UPDATE OF title, summary ON interaction_notes BEGIN
+		INSERT INTO interaction_notes_fts(interaction_notes_fts, rowid, title, summary) VALUES ('delete', old.id, old.title, old.summary);
+		INSERT INTO interaction_notes_fts(rowid, title, summary) VALUES (new.id, new.title, new.summary);
+	END`},
+}
+
+// ensureNotesSearchIndex keeps an FTS5 index over interaction_notes titles and
+// summaries in step with the table through triggers. FTS5 is only compiled
+// into the SQLite driver with the sqlite_fts5 build tag; without it memory
+// search matches words with LIKE instead. A database first opened by an FTS5
+// build can be opened by one without: its triggers are dropped, since every
+// note write would fail on them, and the next FTS5 build restores them and
+// rebuilds the stale index.
+func ensureNotesSearchIndex() {
+	var hasFTS5 bool
+	if err := DB.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&hasFTS5); err != nil {
+		log.Fatalf("Failed to check for FTS5: %v", err)
+	}
+	var tables, triggers int
+	err := DB.QueryRow(`SELECT
+			(SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'interaction_notes_fts'),
+			(SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name LIKE 'interaction\_notes\_fts\_%' ESCAPE '\')`,
+	).Scan(&tables, &triggers)
+	if err != nil {
+		log.Fatalf("Failed to check interaction_notes_fts table: %v", err)
+	}
+
+	if !hasFTS5 {
+		for _, trigger := range notesSearchTriggers {
+			if _, err := DB.Exec("DROP TRIGGER IF EXISTS " + trigger.name); err != nil {
+				log.Fatalf("Failed to drop %s: %v", trigger.name, err)
+			}
+		}
+		log.Printf("SQLite was built without FTS5; memory search falls back to LIKE matching (build with -tags sqlite_fts5 for the full-text index)")
+		return
+	}
+	if tables > 0 && triggers == len(notesSearchTriggers) {
+		return
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		log.Fatalf("Failed to create interaction_notes_fts: %v", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`CREATE VIRTUAL TABLE IF NOT EXISTS interaction_notes_fts USING fts5(
+		title, summary, content='interaction_notes', content_rowid='id', tokenize='unicode61 remove_diacritics 2'
+	)`)
+	if err != nil {
+		log.Fatalf("Failed to create interaction_notes_fts: %v", err)
+	}
+	for _, trigger := range notesSearchTriggers {
+		if _, err := tx.Exec(trigger.create); err != nil {
+			log.Fatalf("Failed to create %s: %v", trigger.name, err)
+		}
+	}
+	// Index the notes written before the index existed or while its
+	// triggers were missing.
+	if _, err := tx.Exec(`INSERT INTO interaction_notes_fts(interaction_notes_fts) VALUES ('rebuild')`); err != nil {
+		log.Fatalf("Failed to rebuild interaction_notes_fts: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Fatalf("Failed to create interaction_notes_fts: %v", err)
+	}
+}
@@ -1,6 +1,10 @@
 package db
 
-import "testing"
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
 
 func TestCreateMemoryV2Tables(t *testing.T) {
 	Open(":memory:")
@@ -188,3 +192,244 @@ func TestOpenMemoryIsolatedAcrossCalls(t *testing.T) {
 		t.Fatalf("expected reopened in-memory DB to be empty, got %d matching rows", count)
 	}
 }
+
+func TestEnsureColumnsMigratesExistingTable(t *testing.T) {
+	Open(":memory:")
+	defer Close()
+
+	if _, err := DB.Exec("DROP TABLE guild_settings"); err != nil {
+		t.Fatalf("drop guild_settings: %v", err)
+	}
+	if _, err := DB.Exec("CREATE TABLE guild_settings (guild_id TEXT PRIMARY KEY)"); err != nil {
+		t.Fatalf("create legacy guild_settings: %v", err)
+	}
+	if _, err := DB.Exec("INSERT INTO guild_settings (guild_id) VALUES ('g1')"); err != nil {
+		t.Fatalf("insert legacy row: %v", err)
+	}
+
+	ensureColumns()
+
+	var provider string
+	if err := DB.QueryRow("SELECT chat_provider FROM guild_settings WHERE guild_id = 'g1'").Scan(&provider); err != nil {
+		t.Fatalf("chat_provider column not added: %v", err)
+	}
+	if provider != "" {
+		t.Errorf("chat_provider = %q, want empty default", provider)
+	}
+
+	// A second pass must be a no-op rather than a duplicate-column error.
+	ensureColumns()
+}
+
+func TestMigrateHashNamespaces(t *testing.T) {
+	Open(":memory:")
+	defer Close()
+
+	for _, stmt := range []string{
+		"DROP TABLE image_hashes",
+		"DROP TABLE image_hash_variants",
+		"CREATE TABLE image_hashes (hash TEXT PRIMARY KEY, message_json TEXT NOT NULL)",
+		"CREATE TABLE image_hash_variants (hash TEXT NOT NULL, algorithm TEXT NOT NULL, value TEXT NOT NULL, PRIMARY KEY (hash, algorithm))",
+		`INSERT INTO image_hashes VALUES ('h1', '{"id":"m1","channel_id":"c1","guild_id":"g1"}')`,
+		`INSERT INTO image_hashes VALUES ('h2', '{"id":"m2","channel_id":"c2"}')`,
+		`INSERT INTO image_hash_variants VALUES ('h1', 'difference', 'd:00')`,
+		`INSERT INTO video_hashes (key, duration, step, frames, message_json) VALUES ('m3/a.mp4', 1, 1, '[]', '{"id":"m3","channel_id":"c3","guild_id":"g3"}')`,
+	} {
+		if _, err := DB.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+
+	migrateHashNamespaces()
+	ensureColumns()
+	backfillVideoNamespaces()
+
+	// Columns added after the tables first shipped survive the rebuild.
+	if ok, err := columnExists("image_hashes", "canonical_hash"); err != nil || !ok {
+		t.Errorf("image_hashes.canonical_hash exists = %v (err=%v), want true", ok, err)
+	}
+
+	var guildID, channelID string
+	if err := DB.QueryRow("SELECT guild_id, channel_id FROM image_hashes WHERE hash = 'h1'").Scan(&guildID, &channelID); err != nil {
+		t.Fatalf("query migrated hash: %v", err)
+	}
+	if guildID != "g1" || channelID != "c1" {
+		t.Errorf("h1 namespace = %q/%q, want g1/c1", guildID, channelID)
+	}
+	if err := DB.QueryRow("SELECT guild_id, channel_id FROM image_hashes WHERE hash = 'h2'").Scan(&guildID, &channelID); err != nil {
+		t.Fatalf("query migrated hash: %v", err)
+	}
+	if guildID != "" || channelID != "c2" {
+		t.Errorf("h2 namespace = %q/%q, want unresolved guild in c2", guildID, channelID)
+	}
+	if err := DB.QueryRow("SELECT guild_id FROM image_hash_variants WHERE hash = 'h1'").Scan(&guildID); err != nil || guildID != "g1" {
+		t.Errorf("variant guild = %q (err=%v), want g1", guildID, err)
+	}
+	if err := DB.QueryRow("SELECT guild_id FROM video_hashes WHERE key = 'm3/a.mp4'").Scan(&guildID); err != nil || guildID != "g3" {
+		t.Errorf("video guild = %q (err=%v), want g3", guildID, err)
+	}
+
+	// The same hash may now exist once per guild.
+	if _, err := DB.Exec(`INSERT INTO image_hashes (guild_id, hash, message_json) VALUES ('g2', 'h1', '{}')`); err != nil {
+		t.Errorf("insert same hash in another guild: %v", err)
+	}
+
+	// A second pass must leave migrated tables alone.
+	migrateHashNamespaces()
+}
+
+func TestOpenUpgradesBaselineDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "baseline.db")
+	legacy, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("open baseline database: %v", err)
+	}
+	// The hash and user tables as the first release created them.
+	for _, stmt := range []string{
+		"CREATE TABLE image_hashes (hash TEXT PRIMARY KEY, message_json TEXT NOT NULL)",
+		`CREATE TABLE users (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			discord_id TEXT NOT NULL UNIQUE,
+			username TEXT NOT NULL,
+			display_name TEXT NOT NULL DEFAULT '',
+			preferred_name TEXT NOT NULL DEFAULT ''
+		)`,
+		`CREATE TABLE reminders (
+			id         INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id    TEXT    NOT NULL,
+			channel_id TEXT    NOT NULL,
+			guild_id   TEXT    NOT NULL,
+			message    TEXT    NOT NULL,
+			images     TEXT,
+			fire_at    INTEGER NOT NULL,
+			created_at INTEGER NOT NULL DEFAULT (unixepoch())
+		)`,
+		`INSERT INTO image_hashes VALUES ('h1', '{"id":"m1","channel_id":"c1","guild_id":"g1","timestamp":"2024-05-01T12:00:00Z"}')`,
+	} {
+		if _, err := legacy.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	legacy.Close()
+
+	Open(path)
+	defer Close()
+
+	// The columns the hasher loads on startup.
+	var guildID, hash, channelID, msgJSON, canonical string
+	err = DB.QueryRow("SELECT guild_id, hash, channel_id, message_json, canonical_hash FROM image_hashes").
+		Scan(&guildID, &hash, &channelID, &msgJSON, &canonical)
+	if err != nil {
+		t.Fatalf("load upgraded image_hashes: %v", err)
+	}
+	if guildID != "g1" || hash != "h1" || channelID != "c1" || canonical != "" {
+		t.Errorf("upgraded hash = %s/%s/%s canonical %q, want g1/h1/c1 with no canonical", guildID, hash, channelID, canonical)
+	}
+	if _, err := DB.Exec("SELECT timezone, memory_opt_out FROM users"); err != nil {
+		t.Errorf("users columns not added: %v", err)
+	}
+	if _, err := DB.Exec("SELECT recurrence, fired FROM reminders"); err != nil {
+		t.Errorf("reminders columns not added: %v", err)
+	}
+	var sightings int
+	DB.QueryRow("SELECT COUNT(*) FROM image_hash_sightings WHERE guild_id = 'g1' AND hash = 'h1'").Scan(&sightings)
+	if sightings != 1 {
+		t.Errorf("sightings after upgrade = %d, want 1 backfilled", sightings)
+	}
+}
+
+func TestBackfillHashSightings(t *testing.T) {
+	Open(":memory:")
+	defer Close()
+
+	_, err := DB.Exec(`INSERT INTO image_hashes (guild_id, hash, channel_id, message_json) VALUES
+		('g1', 'h1', 'c1', '{"id":"m1","author":{"id":"u1","username":"alice"},"timestamp":"2024-05-01T12:00:00Z"}')`)
+	if err != nil {
+		t.Fatalf("insert hash: %v", err)
+	}
+
+	backfillHashSightings()
+
+	var messageID, authorName string
+	var postedAt int64
+	err = DB.QueryRow("SELECT message_id, author_name, posted_at FROM image_hash_sightings WHERE guild_id = 'g1' AND hash = 'h1'").
+		Scan(&messageID, &authorName, &postedAt)
+	if err != nil {
+		t.Fatalf("query sighting: %v", err)
+	}
+	if messageID != "m1" || authorName != "alice" || postedAt != 1714564800 {
+		t.Errorf("sighting = %s/%s/%d, want m1/alice/1714564800", messageID, authorName, postedAt)
+	}
+
+	// Once sightings exist, later hashes are recorded by the hasher instead.
+	DB.Exec(`INSERT INTO image_hashes (guild_id, hash, channel_id, message_json) VALUES ('g1', 'h2', 'c1', '{"id":"m2"}')`)
+	backfillHashSightings()
+	var n int
+	DB.QueryRow("SELECT COUNT(*) FROM image_hash_sightings").Scan(&n)
+	if n != 1 {
+		t.Errorf("sightings after second backfill = %d, want 1", n)
+	}
+}
+
+func TestNotesSearchIndexFollowsFTS5Support(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.db")
+	Open(path)
+	defer Close()
+
+	var hasFTS5 bool
+	if err := DB.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&hasFTS5); err != nil {
+		t.Fatalf("check FTS5: %v", err)
+	}
+	insertNote := func(title string) {
+		t.Helper()
+		_, err := DB.Exec(`INSERT INTO interaction_notes (guild_id, note_type, title, summary, note_date)
+			VALUES ('guild-1', 'conversation', ?, 'Summary', '2026-02-25')`, title)
+		if err != nil {
+			t.Fatalf("insert note: %v", err)
+		}
+	}
+	countTriggers := func() int {
+		t.Helper()
+		var n int
+		if err := DB.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name LIKE 'interaction_notes_fts_%'").Scan(&n); err != nil {
+			t.Fatalf("count triggers: %v", err)
+		}
+		return n
+	}
+
+	if hasFTS5 {
+		// A build without FTS5 dropped the triggers and wrote a note.
+		for _, trigger := range notesSearchTriggers {
+			if _, err := DB.Exec("DROP TRIGGER " + trigger.name); err != nil {
+				t.Fatalf("drop %s: %v", trigger.name, err)
+			}
+		}
+		insertNote("Project Quetzal kickoff")
+
+		Open(path)
+		if n := countTriggers(); n != len(notesSearchTriggers) {
+			t.Errorf("triggers after reopening = %d, want %d", n, len(notesSearchTriggers))
+		}
+		var matches int
+		if err := DB.QueryRow("SELECT COUNT(*) FROM interaction_notes_fts WHERE interaction_notes_fts MATCH 'quetzal'").Scan(&matches); err != nil || matches != 1 {
+			t.Errorf("index matches = %d, %v; want the note written without triggers", matches, err)
+		}
+		return
+	}
+
+	// Leave the triggers an FTS5 build would have created.
+	if _, err := DB.Exec("CREATE TABLE interaction_notes_fts (title, summary, interaction_notes_fts)"); err != nil {
+		t.Fatalf("create stand-in index: %v", err)
+	}
+	for _, trigger := range notesSearchTriggers {
+		if _, err := DB.Exec(trigger.create); err != nil {
+			t.Fatalf("create %s: %v", trigger.name, err)
+		}
+	}
+
+	Open(path)
+	if n := countTriggers(); n != 0 {
+		t.Errorf("triggers after reopening without FTS5 = %d, want 0", n)
+	}
+	insertNote("Lunch plans")
+}
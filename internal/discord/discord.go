@@ -156,3 +156,24 @@ func DeferEphemeralResponse(s *discordgo.Session, i *discordgo.InteractionCreate
 		log.Println(err)
 	}
 }
+
+// SendOrEditMessage posts content in reply to m, or edits reply to show it
+// when a reply message already exists.
+func SendOrEditMessage(s *discordgo.Session, m *discordgo.Message, reply *discordgo.Message, content string) (*discordgo.Message, error) {
+	if reply != nil {
+		return EditMessage(s, reply, content)
+	}
+	return SendMessage(s, m, content)
+}
+
+// ReplyError is returned by a chat backend that failed after posting its reply
+// message. Streamed reports whether any of the reply's text reached Discord.
+type ReplyError struct {
+	Message  *discordgo.Message
+	Streamed bool
+	Err      error
+}
+
+func (e *ReplyError) Error() string { return e.Err.Error() }
+
+func (e *ReplyError) Unwrap() error { return e.Err }
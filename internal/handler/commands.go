@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"log"
@@ -10,13 +11,17 @@ import (
 	"sync"
 	"time"
 
+	"voltgpt/internal/apis/provider"
+	"voltgpt/internal/apis/tts"
 	wave "voltgpt/internal/apis/wavespeed"
 	"voltgpt/internal/config"
 	"voltgpt/internal/discord"
 	"voltgpt/internal/gamble"
 	"voltgpt/internal/hasher"
 	"voltgpt/internal/memory"
+	"voltgpt/internal/permissions"
 	"voltgpt/internal/reminder"
+	"voltgpt/internal/settings"
 	"voltgpt/internal/utility"
 
 	"github.com/bwmarrin/discordgo"
@@ -350,7 +355,6 @@ var Commands = map[string]func(ctx context.Context, s *discordgo.Session, i *dis
 		var channels []*discordgo.Channel
 		var threads bool
 		var endDate time.Time
-		msgCount, hashCount := 0, 0
 
 		for _, option := range i.ApplicationCommandData().Options {
 			if option.Name == "channel" {
@@ -365,7 +369,7 @@ var Commands = map[string]func(ctx context.Context, s *discordgo.Session, i *dis
 			}
 		}
 
-		if !utility.IsAdmin(i.Interaction.Member.User.ID) {
+		if !permissions.Has(i.GuildID, i.Interaction.Member, permissions.HasherAdmin) {
 			_, err := discord.SendFollowup(s, i, "Only admins can use this command!")
 			if err != nil {
 				log.Println(err)
@@ -373,10 +377,21 @@ var Commands = map[string]func(ctx context.Context, s *discordgo.Session, i *dis
 			return
 		}
 
-		hashedStatus, _ := discord.SendFollowup(s, i, "Hashing messages...")
-		fetchedStatus, _ := discord.SendMessage(s, hashedStatus, "Fetching channels...")
+		if job, ok := hasher.RunningJob(i.GuildID); ok {
+			_, err := discord.SendFollowup(s, i, fmt.Sprintf("Hash job #%d is still running, cancel it first.", job.ID))
+			if err != nil {
+				log.Println(err)
+			}
+			return
+		}
 
-		messageChannel := make(chan []*discordgo.Message) // create a channel containing messages
+		permissions.Audit(i.GuildID, i.Interaction.Member.User.ID, "hash_server", fmt.Sprintf("channels=%d threads=%t until=%s", len(channels), threads, endDate.Format("2006/01/02")))
+
+		status, err := discord.SendFollowup(s, i, "Fetching channels...")
+		if err != nil {
+			log.Println(err)
+			return
+		}
 
 		if channels == nil {
 			allChannels, err := s.GuildChannels(i.GuildID)
@@ -394,41 +409,67 @@ var Commands = map[string]func(ctx context.Context, s *discordgo.Session, i *dis
 				}
 			}
 		}
+		if threads {
+			channels = utility.WithThreads(s, channels)
+		}
 
-		go utility.GetAllServerMessages(s, fetchedStatus, channels, threads, endDate, messageChannel)
+		channelIDs := make([]string, len(channels))
+		for idx, channel := range channels {
+			channelIDs[idx] = channel.ID
+		}
 
-		var wg sync.WaitGroup
-		var countMu sync.Mutex
-		for messages := range messageChannel {
-			wg.Add(1)
-			go func(messages []*discordgo.Message) {
-				defer wg.Done()
-				localMsg := len(messages)
-				localHash := 0
-				for _, message := range messages {
-					if utility.HasImageURL(message) || utility.HasVideoURL(message) {
-						options := hasher.HashOptions{Store: true}
-						_, count := hasher.HashAttachments(message, options)
-						localHash += count
-					}
-				}
-				countMu.Lock()
-				msgCount += localMsg
-				hashCount += localHash
-				currentMsg := msgCount
-				currentHash := hashCount
-				countMu.Unlock()
-				// format time to yyyy/mm/dd
-				_, err := discord.EditMessage(s, hashedStatus, fmt.Sprintf("Status: ongoing\nThreads included: %t\nHashing until: %s\nMessages processed: %d\nHashes: %d", threads, endDate.Format("2006/01/02"), currentMsg, currentHash))
-				if err != nil {
-					log.Println(err)
-				}
-			}(messages)
+		job, err := hasher.StartJob(ctx, s, i.GuildID, i.Interaction.Member.User.ID, channelIDs, endDate, status)
+		if err != nil {
+			log.Println(err)
+			_, err = discord.EditMessage(s, status, "Failed to start hash job: "+err.Error())
+			if err != nil {
+				log.Println(err)
+			}
+			return
 		}
 
-		wg.Wait()
+		content := hasher.FormatJob(job)
+		components := hasher.JobComponents(job)
+		_, err = s.ChannelMessageEditComplex(&discordgo.MessageEdit{
+			ID:         status.ID,
+			Channel:    status.ChannelID,
+			Content:    &content,
+			Components: &components,
+		})
+		if err != nil {
+			log.Println(err)
+		}
+	},
+	"TTS": func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+		log.Printf("Received interaction: %s by %s", i.ApplicationCommandData().Name, i.Interaction.Member.User.Username)
+		discord.DeferResponse(s, i)
 
-		_, err := discord.EditMessage(s, hashedStatus, fmt.Sprintf("Status: done\n Threads included: %t\nHashing until: %s\nMessages processed: %d\nHashes: %d", threads, endDate.Format("2006/01/02"), msgCount, hashCount))
+		message := i.ApplicationCommandData().Resolved.Messages[i.ApplicationCommandData().TargetID]
+		text := utility.ResolveMentions(message.Content, message.Mentions)
+		if strings.TrimSpace(text) == "" {
+			_, err := discord.SendFollowup(s, i, "That message has no text to read out.")
+			if err != nil {
+				log.Println(err)
+			}
+			return
+		}
+
+		audio, err := tts.Speak(ctx, text)
+		if err != nil {
+			log.Println(err)
+			_, err = discord.SendFollowup(s, i, "Failed to generate speech: "+err.Error())
+			if err != nil {
+				log.Println(err)
+			}
+			return
+		}
+
+		files := []*discordgo.File{{
+			Name:        fmt.Sprintf("tts-%s.mp3", message.ID),
+			ContentType: "audio/mpeg",
+			Reader:      bytes.NewReader(audio),
+		}}
+		_, err = discord.SendFollowupFile(s, i, "", files)
 		if err != nil {
 			log.Println(err)
 		}
@@ -468,7 +509,7 @@ var Commands = map[string]func(ctx context.Context, s *discordgo.Session, i *dis
 		var count int
 
 		if utility.HasImageURL(message) || utility.HasVideoURL(message) {
-			options := hasher.HashOptions{Store: true}
+			options := hasher.HashOptions{Store: true, GuildID: i.GuildID}
 			_, count = hasher.HashAttachments(message, options)
 		}
 		_, err := discord.SendFollowup(s, i, fmt.Sprintf("Hashed: %d", count))
@@ -476,6 +517,70 @@ var Commands = map[string]func(ctx context.Context, s *discordgo.Session, i *dis
 			log.Println(err)
 		}
 	},
+	"snail_leaderboard": func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+		log.Printf("Received interaction: %s by %s", i.ApplicationCommandData().Name, i.Interaction.Member.User.Username)
+		discord.DeferResponse(s, i)
+
+		embed, err := snailLeaderboardEmbed(i.GuildID)
+		if err != nil {
+			log.Println(err)
+			_, err = discord.SendFollowup(s, i, fmt.Sprintf("Error: %v", err))
+			if err != nil {
+				log.Println(err)
+			}
+			return
+		}
+		_, err = s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
+			Embeds: []*discordgo.MessageEmbed{embed},
+			Flags:  1 << 12,
+		})
+		if err != nil {
+			log.Println(err)
+		}
+	},
+	"find_image": func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+		log.Printf("Received interaction: %s by %s", i.ApplicationCommandData().Name, i.Interaction.Member.User.Username)
+		discord.DeferResponse(s, i)
+
+		var mediaURL string
+		count := findImageDefaultCount
+		for _, option := range i.ApplicationCommandData().Options {
+			switch option.Name {
+			case "image":
+				if val, ok := option.Value.(string); ok {
+					if att, exists := i.ApplicationCommandData().Resolved.Attachments[val]; exists {
+						mediaURL = att.URL
+					}
+				}
+			case "url":
+				if mediaURL == "" {
+					mediaURL = strings.TrimSpace(option.StringValue())
+				}
+			case "count":
+				count = min(int(option.IntValue()), findImageMaxCount)
+			}
+		}
+		if mediaURL == "" {
+			_, err := discord.SendFollowup(s, i, "Attach an image or give a link to one.")
+			if err != nil {
+				log.Println(err)
+			}
+			return
+		}
+
+		matches, err := hasher.FindImage(i.GuildID, mediaURL, count)
+		if err != nil {
+			log.Println(err)
+			_, err = discord.SendFollowup(s, i, fmt.Sprintf("Error: %v", err))
+			if err != nil {
+				log.Println(err)
+			}
+			return
+		}
+		if err := sendFindImagePage(s, i, matches); err != nil {
+			log.Println(err)
+		}
+	},
 	"wheel_status": func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
 		log.Printf("Received interaction: %s by %s", i.ApplicationCommandData().Name, i.Interaction.Member.User.Username)
 		discord.DeferResponse(s, i)
@@ -529,7 +634,7 @@ var Commands = map[string]func(ctx context.Context, s *discordgo.Session, i *dis
 			}
 		}
 
-		if !utility.IsAdmin(i.Interaction.Member.User.ID) {
+		if !permissions.Has(i.GuildID, i.Interaction.Member, permissions.WheelHost) {
 			_, err := discord.SendFollowup(s, i, "Only admins can add players to the wheel!")
 			if err != nil {
 				log.Println(err)
@@ -548,6 +653,7 @@ var Commands = map[string]func(ctx context.Context, s *discordgo.Session, i *dis
 			gamble.GameState.AddWheelOption(player)
 			message = fmt.Sprintf("Added %s to the wheel!", player.User.DisplayName())
 		}
+		permissions.Audit(i.GuildID, i.Interaction.Member.User.ID, "wheel_add", message)
 
 		_, err := discord.SendFollowup(s, i, message)
 		if err != nil {
@@ -576,7 +682,7 @@ var Commands = map[string]func(ctx context.Context, s *discordgo.Session, i *dis
 			}
 		}
 
-		if !utility.IsAdmin(i.Interaction.Member.User.ID) {
+		if !permissions.Has(i.GuildID, i.Interaction.Member, permissions.WheelHost) {
 			_, err := discord.SendFollowup(s, i, "Only admins can use this command!")
 			if err != nil {
 				log.Println(err)
@@ -619,6 +725,7 @@ var Commands = map[string]func(ctx context.Context, s *discordgo.Session, i *dis
 			message = fmt.Sprintf("Added bet on %s, by %s for %d on round %d", onPlayer.User.DisplayName(), byPlayer.User.DisplayName(), amount, round)
 
 		}
+		permissions.Audit(i.GuildID, i.Interaction.Member.User.ID, "insert_bet", message)
 
 		_, err := discord.SendFollowup(s, i, message)
 		if err != nil {
@@ -629,7 +736,7 @@ var Commands = map[string]func(ctx context.Context, s *discordgo.Session, i *dis
 		log.Printf("Recieved interaction: %s by %s", i.ApplicationCommandData().Name, i.Interaction.Member.User.Username)
 		discord.DeferEphemeralResponse(s, i)
 
-		if !utility.IsAdmin(i.Interaction.Member.User.ID) {
+		if !permissions.Has(i.GuildID, i.Interaction.Member, permissions.WheelHost) {
 			_, err := discord.SendFollowup(s, i, "Only admins can use this command!")
 			if err != nil {
 				log.Println(err)
@@ -654,6 +761,7 @@ var Commands = map[string]func(ctx context.Context, s *discordgo.Session, i *dis
 		} else {
 			gamble.GameState.ResetWheel()
 		}
+		permissions.Audit(i.GuildID, i.Interaction.Member.User.ID, "reset_wheel", message)
 
 		_, err := discord.SendFollowup(s, i, message)
 		if err != nil {
@@ -664,7 +772,7 @@ var Commands = map[string]func(ctx context.Context, s *discordgo.Session, i *dis
 		log.Printf("Received interaction: %s by %s", i.ApplicationCommandData().Name, i.Interaction.Member.User.Username)
 		discord.DeferEphemeralResponse(s, i)
 
-		if !utility.IsAdmin(i.Interaction.Member.User.ID) {
+		if !permissions.Has(i.GuildID, i.Interaction.Member, permissions.MemoryAdmin) {
 			_, err := discord.SendFollowup(s, i, "Only admins can use this command!")
 			if err != nil {
 				log.Println(err)
@@ -687,6 +795,8 @@ var Commands = map[string]func(ctx context.Context, s *discordgo.Session, i *dis
 			return
 		}
 
+		permissions.Audit(i.GuildID, i.Interaction.Member.User.ID, "memory_admin_view", "user="+user.ID)
+
 		profile, err := memory.GetGuildUserProfile(i.GuildID, user.ID)
 		if err != nil {
 			_, err := discord.SendFollowup(s, i, fmt.Sprintf("Error: %v", err))
@@ -727,7 +837,7 @@ var Commands = map[string]func(ctx context.Context, s *discordgo.Session, i *dis
 		log.Printf("Received interaction: %s by %s", i.ApplicationCommandData().Name, i.Interaction.Member.User.Username)
 		discord.DeferEphemeralResponse(s, i)
 
-		if !utility.IsAdmin(i.Interaction.Member.User.ID) {
+		if !permissions.Has(i.GuildID, i.Interaction.Member, permissions.MemoryAdmin) {
 			_, err := discord.SendFollowup(s, i, "Only admins can use this command!")
 			if err != nil {
 				log.Println(err)
@@ -753,14 +863,15 @@ var Commands = map[string]func(ctx context.Context, s *discordgo.Session, i *dis
 			err = memory.DeleteAllGuildMemory(i.GuildID)
 			message = fmt.Sprintf("Deleted %d note(s) of guild-scoped memory.", count)
 		}
-
 		if err != nil {
+			permissions.Audit(i.GuildID, i.Interaction.Member.User.ID, "memory_admin_delete", fmt.Sprintf("failed: %v", err))
 			_, err := discord.SendFollowup(s, i, fmt.Sprintf("Error: %v", err))
 			if err != nil {
 				log.Println(err)
 			}
 			return
 		}
+		permissions.Audit(i.GuildID, i.Interaction.Member.User.ID, "memory_admin_delete", message)
 
 		_, err = discord.SendFollowup(s, i, message)
 		if err != nil {
@@ -771,7 +882,7 @@ var Commands = map[string]func(ctx context.Context, s *discordgo.Session, i *dis
 		log.Printf("Received interaction: %s by %s", i.ApplicationCommandData().Name, i.Interaction.Member.User.Username)
 		discord.DeferEphemeralResponse(s, i)
 
-		if !utility.IsAdmin(i.Interaction.Member.User.ID) {
+		if !permissions.Has(i.GuildID, i.Interaction.Member, permissions.MemoryAdmin) {
 			_, err := discord.SendFollowup(s, i, "Only admins can use this command!")
 			if err != nil {
 				log.Println(err)
@@ -799,12 +910,14 @@ var Commands = map[string]func(ctx context.Context, s *discordgo.Session, i *dis
 			message = fmt.Sprintf("Marked %d cached guild-scoped profile(s) dirty.", count)
 		}
 		if err != nil {
+			permissions.Audit(i.GuildID, i.Interaction.Member.User.ID, "memory_admin_dirty", fmt.Sprintf("failed: %v", err))
 			_, err := discord.SendFollowup(s, i, fmt.Sprintf("Error: %v", err))
 			if err != nil {
 				log.Println(err)
 			}
 			return
 		}
+		permissions.Audit(i.GuildID, i.Interaction.Member.User.ID, "memory_admin_dirty", message)
 
 		_, err = discord.SendFollowup(s, i, message)
 		if err != nil {
@@ -826,7 +939,10 @@ var Commands = map[string]func(ctx context.Context, s *discordgo.Session, i *dis
 
 		message := ""
 		if profile != nil {
-			message = memory.RenderProfileMarkdown(profile, i.Interaction.Member.User.Username)
+			message = memory.RenderProfileMarkdownWithIDs(profile, i.Interaction.Member.User.Username)
+			if len(message)+len(memoryFactHint) <= 2000 {
+				message += memoryFactHint
+			}
 		} else {
 			notes, err := memory.GetRecentConversationNotesForUser(i.GuildID, i.Interaction.Member.User.ID, 3)
 			if err != nil {
@@ -851,11 +967,107 @@ var Commands = map[string]func(ctx context.Context, s *discordgo.Session, i *dis
 			log.Println(err)
 		}
 	},
+	"memory_forget": func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+		log.Printf("Received interaction: %s by %s", i.ApplicationCommandData().Name, i.Interaction.Member.User.Username)
+		discord.DeferEphemeralResponse(s, i)
+
+		var factID string
+		for _, option := range i.ApplicationCommandData().Options {
+			if option.Name == "fact" {
+				factID = strings.TrimSpace(option.StringValue())
+			}
+		}
+
+		_, err := discord.SendFollowup(s, i, forgetMemoryFact(i.GuildID, i.Interaction.Member.User.ID, factID))
+		if err != nil {
+			log.Println(err)
+		}
+	},
+	"memory_dispute": func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+		log.Printf("Received interaction: %s by %s", i.ApplicationCommandData().Name, i.Interaction.Member.User.Username)
+		discord.DeferEphemeralResponse(s, i)
+
+		var factID, correction string
+		for _, option := range i.ApplicationCommandData().Options {
+			switch option.Name {
+			case "fact":
+				factID = strings.TrimSpace(option.StringValue())
+			case "correction":
+				correction = strings.TrimSpace(option.StringValue())
+			}
+		}
+
+		_, err := discord.SendFollowup(s, i, disputeMemoryFact(i.GuildID, i.Interaction.Member.User.ID, factID, correction))
+		if err != nil {
+			log.Println(err)
+		}
+	},
+	"memory_pin": func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+		log.Printf("Received interaction: %s by %s", i.ApplicationCommandData().Name, i.Interaction.Member.User.Username)
+		discord.DeferEphemeralResponse(s, i)
+
+		var section, text string
+		for _, option := range i.ApplicationCommandData().Options {
+			switch option.Name {
+			case "section":
+				section = option.StringValue()
+			case "fact":
+				text = strings.TrimSpace(option.StringValue())
+			}
+		}
+
+		user := i.Interaction.Member.User
+		_, err := discord.SendFollowup(s, i, pinMemoryFact(i.GuildID, user.ID, user.Username, user.GlobalName, section, text))
+		if err != nil {
+			log.Println(err)
+		}
+	},
+	"memory_opt_out": func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+		log.Printf("Received interaction: %s by %s", i.ApplicationCommandData().Name, i.Interaction.Member.User.Username)
+		discord.DeferEphemeralResponse(s, i)
+
+		var optOut *bool
+		for _, option := range i.ApplicationCommandData().Options {
+			if option.Name == "enabled" {
+				value := option.BoolValue()
+				optOut = &value
+			}
+		}
+
+		user := i.Interaction.Member.User
+		message, err := applyMemoryOptOut(user.ID, user.Username, optOut)
+		if err != nil {
+			message = fmt.Sprintf("Error: %v", err)
+		}
+		_, err = discord.SendFollowup(s, i, message)
+		if err != nil {
+			log.Println(err)
+		}
+	},
+	"memory_export": func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+		log.Printf("Received interaction: %s by %s", i.ApplicationCommandData().Name, i.Interaction.Member.User.Username)
+		discord.DeferEphemeralResponse(s, i)
+
+		user := i.Interaction.Member.User
+		message := "Sent you a DM with your data."
+		data, err := buildMemoryExport(user.ID, time.Now())
+		if err != nil {
+			message = fmt.Sprintf("Error: %v", err)
+		} else if err := sendMemoryExport(s, user, data); err != nil {
+			log.Printf("memory_export: failed to DM %s: %v", user.ID, err)
+			message = "I couldn't DM you. Allow direct messages from server members and try again."
+		}
+
+		_, err = discord.SendFollowup(s, i, message)
+		if err != nil {
+			log.Println(err)
+		}
+	},
 	"memory_admin_digest": func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
 		log.Printf("Received interaction: %s by %s", i.ApplicationCommandData().Name, i.Interaction.Member.User.Username)
 		discord.DeferResponse(s, i)
 
-		if !utility.IsAdmin(i.Interaction.Member.User.ID) {
+		if !permissions.Has(i.GuildID, i.Interaction.Member, permissions.MemoryAdmin) {
 			_, err := discord.SendFollowup(s, i, "Only admins can use this command!")
 			if err != nil {
 				log.Println(err)
@@ -863,6 +1075,8 @@ var Commands = map[string]func(ctx context.Context, s *discordgo.Session, i *dis
 			return
 		}
 
+		permissions.Audit(i.GuildID, i.Interaction.Member.User.ID, "memory_admin_digest", "")
+
 		if err := sendMemoryDigestPage(s, i, 1); err != nil {
 			log.Println(err)
 		}
@@ -883,7 +1097,7 @@ var Commands = map[string]func(ctx context.Context, s *discordgo.Session, i *dis
 		}
 
 		// Non-admins cannot target other users
-		if targetUser != nil && targetUser.ID != i.Interaction.Member.User.ID && !utility.IsAdmin(i.Interaction.Member.User.ID) {
+		if targetUser != nil && targetUser.ID != i.Interaction.Member.User.ID && !permissions.Has(i.GuildID, i.Interaction.Member, permissions.MemoryAdmin) {
 			_, err := discord.SendFollowup(s, i, "Only admins can set names for other users!")
 			if err != nil {
 				log.Println(err)
@@ -895,6 +1109,9 @@ var Commands = map[string]func(ctx context.Context, s *discordgo.Session, i *dis
 		if targetUser == nil {
 			targetUser = i.Interaction.Member.User
 		}
+		if targetUser.ID != i.Interaction.Member.User.ID {
+			permissions.Audit(i.GuildID, i.Interaction.Member.User.ID, "memory_setname", fmt.Sprintf("user=%s name=%q", targetUser.ID, name))
+		}
 
 		// Clear preferred name when no name is provided
 		if name == "" {
@@ -927,6 +1144,27 @@ var Commands = map[string]func(ctx context.Context, s *discordgo.Session, i *dis
 			log.Println(err)
 		}
 	},
+	"timezone": func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+		log.Printf("Received interaction: %s by %s", i.ApplicationCommandData().Name, i.Interaction.Member.User.Username)
+		discord.DeferEphemeralResponse(s, i)
+
+		var zone string
+		for _, option := range i.ApplicationCommandData().Options {
+			if option.Name == "zone" {
+				zone = option.StringValue()
+			}
+		}
+
+		user := i.Interaction.Member.User
+		msg, err := applyTimezoneOption(user.ID, user.Username, zone, time.Now())
+		if err != nil {
+			msg = fmt.Sprintf("Error: %v", err)
+		}
+		_, err = discord.SendFollowup(s, i, msg)
+		if err != nil {
+			log.Println(err)
+		}
+	},
 	"reminders": func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
 		log.Printf("Received interaction: %s by %s", i.ApplicationCommandData().Name, i.Interaction.Member.User.Username)
 
@@ -960,6 +1198,7 @@ var Commands = map[string]func(ctx context.Context, s *discordgo.Session, i *dis
 		}
 
 		const maxOptions = 25
+		loc := userLocation(i.Interaction.Member.User.ID)
 		var sb strings.Builder
 		sb.WriteString("**Your reminders:**\n")
 		displayed := reminders
@@ -972,7 +1211,11 @@ var Commands = map[string]func(ctx context.Context, s *discordgo.Session, i *dis
 			if len(r.Images) > 0 {
 				imageNote = fmt.Sprintf(" [%d image(s)]", len(r.Images))
 			}
-			sb.WriteString(fmt.Sprintf("%d. <t:%d:R> — %s%s\n", idx+1, r.FireAt, r.Message, imageNote))
+			repeatNote := ""
+			if r.Rule != nil {
+				repeatNote = fmt.Sprintf(" (%s)", r.Rule)
+			}
+			sb.WriteString(fmt.Sprintf("%d. <t:%d:R> · %s%s — %s%s\n", idx+1, r.FireAt, formatLocalTime(time.Unix(r.FireAt, 0), loc), repeatNote, r.Message, imageNote))
 
 			label := fmt.Sprintf("%d. %s", idx+1, r.Message)
 			if len(label) > 100 {
@@ -993,11 +1236,20 @@ var Commands = map[string]func(ctx context.Context, s *discordgo.Session, i *dis
 				Content: sb.String(),
 				Flags:   discordgo.MessageFlagsEphemeral,
 				Components: []discordgo.MessageComponent{
+					discordgo.ActionsRow{
+						Components: []discordgo.MessageComponent{
+							discordgo.SelectMenu{
+								CustomID:    "reminder_edit",
+								Placeholder: "Edit a reminder…",
+								Options:     options,
+							},
+						},
+					},
 					discordgo.ActionsRow{
 						Components: []discordgo.MessageComponent{
 							discordgo.SelectMenu{
 								CustomID:    "reminder",
-								Placeholder: "Delete a reminder…",
+								Placeholder: "Cancel a reminder…",
 								Options:     options,
 							},
 						},
@@ -1009,4 +1261,186 @@ var Commands = map[string]func(ctx context.Context, s *discordgo.Session, i *dis
 			log.Println(err)
 		}
 	},
+	"settings": func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+		log.Printf("Received interaction: %s by %s", i.ApplicationCommandData().Name, i.Interaction.Member.User.Username)
+		discord.DeferEphemeralResponse(s, i)
+
+		if !settings.IsAdmin(i.GuildID, i.Interaction.Member) {
+			_, err := discord.SendFollowup(s, i, "Only admins can use this command!")
+			if err != nil {
+				log.Println(err)
+			}
+			return
+		}
+
+		options := i.ApplicationCommandData().Options
+		guildSettings := settings.Get(i.GuildID)
+		changed := applySettingsOptions(&guildSettings, options)
+
+		snailChannel := snailChannelOption(i.ChannelID, options)
+		snailWatch := settings.SnailWatchFor(snailChannel)
+		snailChanged, err := applySnailOptions(&snailWatch, options)
+		if err != nil {
+			_, err := discord.SendFollowup(s, i, fmt.Sprintf("Error: %v", err))
+			if err != nil {
+				log.Println(err)
+			}
+			return
+		}
+
+		if chatProvider, channelID, ok := providerOptions(options); ok {
+			if chatProvider != "" && !provider.Valid(chatProvider) {
+				_, err := discord.SendFollowup(s, i, fmt.Sprintf("Unknown chat provider %q.", chatProvider))
+				if err != nil {
+					log.Println(err)
+				}
+				return
+			}
+			if channelID == "" {
+				guildSettings.ChatProvider = chatProvider
+				changed = true
+			} else if err := settings.SetChannelProvider(i.GuildID, channelID, chatProvider); err != nil {
+				log.Println(err)
+				_, err = discord.SendFollowup(s, i, "Failed to save settings.")
+				if err != nil {
+					log.Println(err)
+				}
+				return
+			} else {
+				permissions.Audit(i.GuildID, i.Interaction.Member.User.ID, "settings_channel_provider", fmt.Sprintf("channel=%s provider=%q", channelID, chatProvider))
+			}
+		}
+
+		if snailChanged {
+			if err := settings.SetSnailWatch(i.GuildID, snailChannel, snailWatch); err != nil {
+				log.Println(err)
+				_, err = discord.SendFollowup(s, i, "Failed to save settings.")
+				if err != nil {
+					log.Println(err)
+				}
+				return
+			}
+			permissions.Audit(i.GuildID, i.Interaction.Member.User.ID, "settings_snail_callouts", fmt.Sprintf("channel=%s watch=%s", snailChannel, snailWatch))
+		}
+
+		channelProviders, err := settings.ChannelProviders(i.GuildID)
+		if err != nil {
+			log.Println(err)
+		}
+		snailWatches, err := settings.SnailWatches(i.GuildID)
+		if err != nil {
+			log.Println(err)
+		}
+
+		if changed {
+			if err := settings.Save(guildSettings); err != nil {
+				log.Println(err)
+				_, err = discord.SendFollowup(s, i, "Failed to save settings.")
+				if err != nil {
+					log.Println(err)
+				}
+				return
+			}
+			permissions.Audit(i.GuildID, i.Interaction.Member.User.ID, "settings", renderGuildSettings(guildSettings, nil))
+		}
+
+		_, err = discord.SendFollowup(s, i, renderGuildSettings(guildSettings, channelProviders)+renderSnailWatches(snailWatches))
+		if err != nil {
+			log.Println(err)
+		}
+	},
+	"permissions": func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+		log.Printf("Received interaction: %s by %s", i.ApplicationCommandData().Name, i.Interaction.Member.User.Username)
+		discord.DeferEphemeralResponse(s, i)
+
+		if !settings.IsAdmin(i.GuildID, i.Interaction.Member) {
+			_, err := discord.SendFollowup(s, i, "Only admins can use this command!")
+			if err != nil {
+				log.Println(err)
+			}
+			return
+		}
+
+		var capability permissions.Capability
+		var roleID string
+		var revoke, audit bool
+		for _, option := range i.ApplicationCommandData().Options {
+			if option.Name == "capability" {
+				capability = permissions.Capability(option.StringValue())
+			}
+			if option.Name == "role" {
+				roleID = optionID(option)
+			}
+			if option.Name == "revoke" {
+				revoke = option.BoolValue()
+			}
+			if option.Name == "audit" {
+				audit = option.BoolValue()
+			}
+		}
+
+		var sb strings.Builder
+		if capability != "" || roleID != "" {
+			if !permissions.Valid(capability) || roleID == "" {
+				_, err := discord.SendFollowup(s, i, "Please provide both a capability and a role.")
+				if err != nil {
+					log.Println(err)
+				}
+				return
+			}
+
+			var err error
+			if revoke {
+				var removed bool
+				removed, err = permissions.Revoke(i.GuildID, roleID, capability)
+				if err == nil && removed {
+					sb.WriteString(fmt.Sprintf("Revoked `%s` from <@&%s>.\n\n", capability, roleID))
+				} else if err == nil {
+					sb.WriteString(fmt.Sprintf("<@&%s> did not have `%s`.\n\n", roleID, capability))
+				}
+			} else {
+				err = permissions.Grant(i.GuildID, roleID, capability)
+				if err == nil {
+					sb.WriteString(fmt.Sprintf("Granted `%s` to <@&%s>.\n\n", capability, roleID))
+				}
+			}
+			if err != nil {
+				log.Println(err)
+				_, err = discord.SendFollowup(s, i, "Failed to update permissions.")
+				if err != nil {
+					log.Println(err)
+				}
+				return
+			}
+
+			action := "permissions_grant"
+			if revoke {
+				action = "permissions_revoke"
+			}
+			permissions.Audit(i.GuildID, i.Interaction.Member.User.ID, action, fmt.Sprintf("role=%s capability=%s", roleID, capability))
+		}
+
+		grants, err := permissions.Grants(i.GuildID)
+		if err != nil {
+			log.Println(err)
+		}
+		sb.WriteString(renderCapabilityGrants(grants))
+
+		if audit {
+			entries, err := permissions.RecentAudit(i.GuildID, auditLogDisplayLimit)
+			if err != nil {
+				log.Println(err)
+			}
+			sb.WriteString("\n" + renderAuditLog(entries))
+		}
+
+		message := sb.String()
+		if len(message) > 2000 {
+			message = message[:1997] + "..."
+		}
+		_, err = discord.SendFollowup(s, i, message)
+		if err != nil {
+			log.Println(err)
+		}
+	},
 }
@@ -2,14 +2,18 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"strconv"
 	"strings"
+	"time"
 
 	"voltgpt/internal/discord"
 	"voltgpt/internal/gamble"
+	"voltgpt/internal/hasher"
+	"voltgpt/internal/permissions"
 	"voltgpt/internal/reminder"
-	"voltgpt/internal/utility"
 
 	"github.com/bwmarrin/discordgo"
 )
@@ -123,7 +127,7 @@ var Components = map[string]func(s *discordgo.Session, i *discordgo.InteractionC
 	},
 	"button_winner": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
 		log.Printf("Received interaction: %s by %s", i.MessageComponentData().CustomID, i.Interaction.Member.User.Username)
-		if !utility.IsAdmin(i.Interaction.Member.User.ID) {
+		if !permissions.Has(i.GuildID, i.Interaction.Member, permissions.WheelHost) {
 			discord.DeferEphemeralResponse(s, i)
 			_, err := discord.SendFollowup(s, i, "Only admins can pick winners!")
 			if err != nil {
@@ -211,6 +215,11 @@ var Components = map[string]func(s *discordgo.Session, i *discordgo.InteractionC
 			return
 		}
 		if action == "winner" {
+			if !permissions.Has(i.GuildID, i.Interaction.Member, permissions.WheelHost) {
+				gamble.Mu.Unlock()
+				discord.UpdateResponse(s, i, "Only admins can pick winners!")
+				return
+			}
 			member, err := s.GuildMember(i.GuildID, selectedUser[0])
 			if err != nil || member == nil {
 				gamble.Mu.Unlock()
@@ -235,6 +244,7 @@ var Components = map[string]func(s *discordgo.Session, i *discordgo.InteractionC
 			}
 
 			gamble.GameState.Rounds[round].SetWinner(player)
+			permissions.Audit(i.GuildID, i.Interaction.Member.User.ID, "wheel_winner", fmt.Sprintf("round=%d winner=%s", targetRound, member.User.ID))
 			edit := buildGambleStatusMessageEditLocked(i.ChannelID, targetMessageID, targetRound)
 			gamble.Mu.Unlock()
 			err = discord.UpdateResponse(s, i, "Set winner!")
@@ -266,13 +276,116 @@ var Components = map[string]func(s *discordgo.Session, i *discordgo.InteractionC
 		}
 
 		if reminder.Delete(id) {
-			discord.UpdateResponse(s, i, "✅ Reminder deleted!")
+			discord.UpdateResponse(s, i, "✅ Reminder cancelled!")
 		} else {
 			discord.UpdateResponse(s, i, "Reminder not found (it may have already fired).")
 		}
 	},
+	"reminder_edit": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+		log.Printf("Received interaction: %s by %s", i.MessageComponentData().CustomID, i.Interaction.Member.User.Username)
+
+		values := i.MessageComponentData().Values
+		if len(values) == 0 {
+			discord.UpdateResponse(s, i, "No reminder selected.")
+			return
+		}
+		id, err := strconv.ParseInt(values[0], 10, 64)
+		if err != nil {
+			discord.UpdateResponse(s, i, "Invalid reminder ID.")
+			return
+		}
+
+		r, problem := ownedReminder(id, i.Interaction.Member.User.ID)
+		if problem != "" {
+			discord.UpdateResponse(s, i, problem)
+			return
+		}
+		if err := s.InteractionRespond(i.Interaction, reminderEditModal(r)); err != nil {
+			log.Println(err)
+		}
+	},
+	"reminder_snooze": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+		log.Printf("Received interaction: %s by %s", i.MessageComponentData().CustomID, i.Interaction.Member.User.Username)
+
+		userID := i.Interaction.Member.User.ID
+		id, option := parseReminderControlID(i.MessageComponentData().CustomID)
+		until, ok := reminder.SnoozeUntil(option, time.Now().In(userLocation(userID)))
+		if id == 0 || !ok {
+			respondEphemeral(s, i, "Invalid snooze option.")
+			return
+		}
+		if _, problem := ownedReminder(id, userID); problem != "" {
+			respondEphemeral(s, i, problem)
+			return
+		}
+
+		if err := reminder.Snooze(id, until); err != nil {
+			log.Println(err)
+			respondEphemeral(s, i, "Couldn't snooze the reminder. Please try again later.")
+			return
+		}
+		if err := closeFiredReminder(s, i, fmt.Sprintf("⏰ Snoozed until <t:%d:f>", until.Unix())); err != nil {
+			log.Println(err)
+		}
+	},
+	"reminder_done": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+		log.Printf("Received interaction: %s by %s", i.MessageComponentData().CustomID, i.Interaction.Member.User.Username)
+
+		id, _ := parseReminderControlID(i.MessageComponentData().CustomID)
+		if _, problem := ownedReminder(id, i.Interaction.Member.User.ID); problem != "" {
+			respondEphemeral(s, i, problem)
+			return
+		}
+
+		if err := reminder.Done(id); err != nil {
+			log.Println(err)
+			respondEphemeral(s, i, "Couldn't update the reminder. Please try again later.")
+			return
+		}
+		if err := closeFiredReminder(s, i, "✅ Done"); err != nil {
+			log.Println(err)
+		}
+	},
+	"hash_cancel": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+		log.Printf("Received interaction: %s by %s", i.MessageComponentData().CustomID, i.Interaction.Member.User.Username)
+		if !permissions.Has(i.GuildID, i.Interaction.Member, permissions.HasherAdmin) {
+			respondEphemeral(s, i, "Only admins can cancel hash jobs!")
+			return
+		}
+
+		id, err := strconv.ParseInt(strings.TrimPrefix(i.MessageComponentData().CustomID, "hash_cancel-"), 10, 64)
+		if err != nil {
+			respondEphemeral(s, i, "Invalid hash job.")
+			return
+		}
+		if err := hasher.CancelJob(i.GuildID, id); err != nil {
+			if !errors.Is(err, hasher.ErrJobNotRunning) {
+				log.Println(err)
+			}
+			respondEphemeral(s, i, "Couldn't cancel the hash job: "+err.Error())
+			return
+		}
+		permissions.Audit(i.GuildID, i.Interaction.Member.User.ID, "hash_cancel", fmt.Sprintf("job=%d", id))
+
+		job, err := hasher.GetJob(id)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		components := hasher.JobComponents(job)
+		err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseUpdateMessage,
+			Data: &discordgo.InteractionResponseData{
+				Content:    hasher.FormatJob(job),
+				Components: components,
+			},
+		})
+		if err != nil {
+			log.Println(err)
+		}
+	},
 	"memorydigest": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
-		if !utility.IsAdmin(i.Interaction.Member.User.ID) {
+		if !permissions.Has(i.GuildID, i.Interaction.Member, permissions.MemoryAdmin) {
 			discord.DeferEphemeralResponse(s, i)
 			_, err := discord.SendFollowup(s, i, "Only admins can use this command!")
 			if err != nil {
@@ -285,4 +398,9 @@ var Components = map[string]func(s *discordgo.Session, i *discordgo.InteractionC
 			log.Println(err)
 		}
 	},
+	"findimage": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+		if err := updateFindImagePage(s, i, parseFindImagePage(i.MessageComponentData().CustomID)); err != nil {
+			log.Println(err)
+		}
+	},
 }
@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"voltgpt/internal/hasher"
+	"voltgpt/internal/utility"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	findImagePageSize = 5
+	// findImageDefaultCount and findImageMaxCount bound how many matches a
+	// search returns.
+	findImageDefaultCount = 10
+	findImageMaxCount     = 25
+	// findImageSearchTTL is how long a search's page buttons keep working.
+	findImageSearchTTL = 30 * time.Minute
+)
+
+// findImageSearches keeps each search's matches by the ID of the message
+// showing them, so its buttons can page through them without hashing again.
+var findImageSearches = struct {
+	sync.Mutex
+	results map[string]findImageSearch
+}{
+	results: make(map[string]findImageSearch),
+}
+
+type findImageSearch struct {
+	matches []hasher.ImageMatch
+	expires time.Time
+}
+
+func saveFindImageSearch(messageID string, matches []hasher.ImageMatch, now time.Time) {
+	findImageSearches.Lock()
+	defer findImageSearches.Unlock()
+	for id, search := range findImageSearches.results {
+		if now.After(search.expires) {
+			delete(findImageSearches.results, id)
+		}
+	}
+	findImageSearches.results[messageID] = findImageSearch{matches: matches, expires: now.Add(findImageSearchTTL)}
+}
+
+func loadFindImageSearch(messageID string, now time.Time) ([]hasher.ImageMatch, bool) {
+	findImageSearches.Lock()
+	defer findImageSearches.Unlock()
+	search, ok := findImageSearches.results[messageID]
+	if !ok || now.After(search.expires) {
+		return nil, false
+	}
+	return search.matches, true
+}
+
+func sendFindImagePage(s *discordgo.Session, i *discordgo.InteractionCreate, matches []hasher.ImageMatch) error {
+	embeds, components := buildFindImagePage(i.GuildID, matches, 1)
+	msg, err := s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
+		Embeds:     embeds,
+		Components: components,
+		Flags:      1 << 12,
+	})
+	if err != nil {
+		return err
+	}
+	saveFindImageSearch(msg.ID, matches, time.Now())
+	return nil
+}
+
+func updateFindImagePage(s *discordgo.Session, i *discordgo.InteractionCreate, page int) error {
+	matches, ok := loadFindImageSearch(i.Message.ID, time.Now())
+	if !ok {
+		return respondEphemeral(s, i, "This search has expired, run /find_image again.")
+	}
+	embeds, components := buildFindImagePage(i.GuildID, matches, page)
+	return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:     embeds,
+			Components: components,
+		},
+	})
+}
+
+// buildFindImagePage renders one page of matches as an embed each, with the
+// matched post's first image as the thumbnail.
+func buildFindImagePage(guildID string, matches []hasher.ImageMatch, page int) ([]*discordgo.MessageEmbed, []discordgo.MessageComponent) {
+	if len(matches) == 0 {
+		return []*discordgo.MessageEmbed{{
+			Title:       "Image search",
+			Description: "No similar images have been stored in this server.",
+		}}, nil
+	}
+
+	totalPages := (len(matches) + findImagePageSize - 1) / findImagePageSize
+	page = min(max(page, 1), totalPages)
+	start := (page - 1) * findImagePageSize
+	end := min(start+findImagePageSize, len(matches))
+
+	embeds := make([]*discordgo.MessageEmbed, 0, end-start)
+	for n, match := range matches[start:end] {
+		msg := match.Message
+		mirrored := ""
+		if match.Mirrored {
+			mirrored = ", mirrored"
+		}
+		author := "unknown"
+		if msg.Author != nil {
+			author = msg.Author.Username
+		}
+		embed := &discordgo.MessageEmbed{
+			Title:       fmt.Sprintf("#%d · %dd (%.0f%%%s)", start+n+1, match.Distance, match.Confidence*100, mirrored),
+			URL:         utility.LinkFromIMessage(guildID, msg),
+			Description: fmt.Sprintf("Posted by %s on %s", author, msg.Timestamp.UTC().Format("2006-01-02")),
+			Color:       0x2b2d31,
+		}
+		if images, _, _, _ := utility.GetMessageMediaURL(msg); len(images) > 0 {
+			embed.Thumbnail = &discordgo.MessageEmbedThumbnail{URL: images[0]}
+		}
+		embeds = append(embeds, embed)
+	}
+	embeds[len(embeds)-1].Footer = &discordgo.MessageEmbedFooter{
+		Text: fmt.Sprintf("Page %d/%d • %d matches", page, totalPages, len(matches)),
+	}
+
+	return embeds, buildFindImageComponents(page, totalPages)
+}
+
+func buildFindImageComponents(page, totalPages int) []discordgo.MessageComponent {
+	if totalPages <= 1 {
+		return nil
+	}
+
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				&discordgo.Button{
+					CustomID: fmt.Sprintf("findimage-%d", page-1),
+					Label:    "Previous",
+					Style:    discordgo.SecondaryButton,
+					Disabled: page <= 1,
+				},
+				&discordgo.Button{
+					CustomID: fmt.Sprintf("findimage-%d", page+1),
+					Label:    "Next",
+					Style:    discordgo.PrimaryButton,
+					Disabled: page >= totalPages,
+				},
+			},
+		},
+	}
+}
+
+func parseFindImagePage(customID string) int {
+	_, raw, _ := strings.Cut(customID, "-")
+	page, err := strconv.Atoi(raw)
+	if err != nil {
+		return 1
+	}
+	return page
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"voltgpt/internal/hasher"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func findImageMatches(n int) []hasher.ImageMatch {
+	matches := make([]hasher.ImageMatch, n)
+	for i := range matches {
+		matches[i] = hasher.ImageMatch{
+			Distance:   i,
+			Confidence: 1 - float64(i)/100,
+			Mirrored:   i == 1,
+			Message: &discordgo.Message{
+				ID:          fmt.Sprint(100 + i),
+				ChannelID:   "ch",
+				Timestamp:   time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+				Author:      &discordgo.User{Username: "alice"},
+				Attachments: []*discordgo.MessageAttachment{{URL: fmt.Sprintf("https://cdn.example/%d.png", i), Width: 10, Height: 10}},
+			},
+		}
+	}
+	return matches
+}
+
+func TestBuildFindImagePage(t *testing.T) {
+	matches := findImageMatches(7)
+
+	embeds, components := buildFindImagePage("g", matches, 1)
+	if len(embeds) != findImagePageSize {
+		t.Fatalf("len(embeds) = %d, want %d", len(embeds), findImagePageSize)
+	}
+	if embeds[1].Title != "#2 · 1d (99%, mirrored)" {
+		t.Errorf("second title = %q", embeds[1].Title)
+	}
+	if embeds[0].URL != "https://discord.com/channels/g/ch/100" {
+		t.Errorf("first link = %q", embeds[0].URL)
+	}
+	if embeds[0].Thumbnail == nil || embeds[0].Thumbnail.URL != "https://cdn.example/0.png" {
+		t.Errorf("first thumbnail = %+v, want the matched image", embeds[0].Thumbnail)
+	}
+	if footer := embeds[len(embeds)-1].Footer; footer == nil || footer.Text != "Page 1/2 • 7 matches" {
+		t.Errorf("footer = %+v", footer)
+	}
+	buttons := components[0].(discordgo.ActionsRow).Components
+	if !buttons[0].(*discordgo.Button).Disabled || buttons[1].(*discordgo.Button).Disabled {
+		t.Error("first page buttons: want Previous disabled and Next enabled")
+	}
+
+	embeds, _ = buildFindImagePage("g", matches, 9)
+	if len(embeds) != 2 || !strings.HasPrefix(embeds[0].Title, "#6 ") {
+		t.Errorf("last page = %d embeds starting %q, want #6 and #7", len(embeds), embeds[0].Title)
+	}
+
+	embeds, components = buildFindImagePage("g", nil, 1)
+	if len(embeds) != 1 || components != nil || !strings.Contains(embeds[0].Description, "No similar images") {
+		t.Errorf("empty page = %+v, %v", embeds[0], components)
+	}
+}
+
+func TestFindImageSearchExpires(t *testing.T) {
+	now := time.Now()
+	saveFindImageSearch("m1", findImageMatches(2), now)
+
+	if matches, ok := loadFindImageSearch("m1", now.Add(time.Minute)); !ok || len(matches) != 2 {
+		t.Errorf("loadFindImageSearch = %d, %t; want the saved matches", len(matches), ok)
+	}
+	if _, ok := loadFindImageSearch("m1", now.Add(findImageSearchTTL+time.Minute)); ok {
+		t.Error("loadFindImageSearch found an expired search")
+	}
+
+	saveFindImageSearch("m2", nil, now.Add(findImageSearchTTL+time.Minute))
+	if _, ok := findImageSearches.results["m1"]; ok {
+		t.Error("expired search was not pruned")
+	}
+}
+
+func TestParseFindImagePage(t *testing.T) {
+	if got := parseFindImagePage("findimage-3"); got != 3 {
+		t.Errorf("parseFindImagePage valid = %d, want 3", got)
+	}
+	if got := parseFindImagePage("findimage-nope"); got != 1 {
+		t.Errorf("parseFindImagePage invalid = %d, want 1", got)
+	}
+}
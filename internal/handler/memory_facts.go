@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+
+	"voltgpt/internal/memory"
+)
+
+// memoryFactHint follows the profile /memory_self shows its owner.
+const memoryFactHint = "\n\n-# Use /memory_forget or /memory_dispute with a fact's ID to remove it, or /memory_pin to add your own."
+
+// memoryFactErrors are the user mistakes whose message is the reply.
+var memoryFactErrors = []error{
+	memory.ErrFactNotFound,
+	memory.ErrTooManyPinned,
+	memory.ErrInvalidSection,
+	memory.ErrEmptyFact,
+	memory.ErrFactTooLong,
+	memory.ErrFactAlreadyPinned,
+}
+
+// memoryFactReply turns err into reply text: the message of a user mistake, or
+// a generic error otherwise.
+func memoryFactReply(err error) string {
+	for _, known := range memoryFactErrors {
+		if errors.Is(err, known) {
+			return fmt.Sprintf("Couldn't update your memory: %v.", err)
+		}
+	}
+	return fmt.Sprintf("Error: %v", err)
+}
+
+// forgetMemoryFact deletes one of the user's profile facts, or unpins it, and
+// returns the reply text.
+func forgetMemoryFact(guildID, discordID, factID string) string {
+	fact, err := memory.DeleteProfileFact(guildID, discordID, factID)
+	if err != nil {
+		return memoryFactReply(err)
+	}
+	if fact.Pinned {
+		return fmt.Sprintf("Unpinned: %s", fact.Text)
+	}
+	return fmt.Sprintf("Forgot: %s\nIt won't be added back from your conversations.", fact.Text)
+}
+
+// disputeMemoryFact removes a profile fact the user says is wrong and pins
+// their correction, returning the reply text.
+func disputeMemoryFact(guildID, discordID, factID, correction string) string {
+	fact, err := memory.DisputeProfileFact(guildID, discordID, factID, correction)
+	if err != nil {
+		return memoryFactReply(err)
+	}
+	if correction == "" {
+		return fmt.Sprintf("Marked as wrong: %s", fact.Text)
+	}
+	return fmt.Sprintf("Marked as wrong: %s\nPinned instead: %s", fact.Text, correction)
+}
+
+// pinMemoryFact adds a fact the user wrote to their profile and returns the
+// reply text.
+func pinMemoryFact(guildID, discordID, username, displayName, section, text string) string {
+	fact, err := memory.PinProfileFact(guildID, discordID, username, displayName, section, text)
+	if err != nil {
+		return memoryFactReply(err)
+	}
+	return fmt.Sprintf("Pinned `%s`: %s", memory.FactID(fact.Text), fact.Text)
+}
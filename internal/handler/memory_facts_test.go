@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+
+	"voltgpt/internal/memory"
+)
+
+func TestMemoryFactReplies(t *testing.T) {
+	setupReminderDB(t)
+	setupMemoryDB(t)
+
+	msg := pinMemoryFact("g", "user-1", "alice", "Alice", "bio", "Works as a nurse.")
+	if !strings.Contains(msg, memory.FactID("Works as a nurse.")) {
+		t.Errorf("pin reply = %q, want the fact ID", msg)
+	}
+	if msg := pinMemoryFact("g", "user-1", "alice", "Alice", "bio", "  "); !strings.Contains(msg, "the fact is empty") {
+		t.Errorf("empty pin reply = %q, want the user error", msg)
+	}
+
+	if msg := forgetMemoryFact("g", "user-1", "000000"); !strings.Contains(msg, "no fact with that ID") {
+		t.Errorf("unknown fact reply = %q, want not found", msg)
+	}
+	if msg := disputeMemoryFact("g", "user-1", memory.FactID("Works as a nurse."), "Works as a doctor."); !strings.Contains(msg, "Pinned instead: Works as a doctor.") {
+		t.Errorf("dispute reply = %q, want the correction", msg)
+	}
+	if msg := forgetMemoryFact("g", "user-1", memory.FactID("Works as a doctor.")); !strings.HasPrefix(msg, "Unpinned:") {
+		t.Errorf("forget pinned reply = %q, want unpinned", msg)
+	}
+}
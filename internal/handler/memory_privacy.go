@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"voltgpt/internal/memory"
+	"voltgpt/internal/reminder"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// memoryExport is the archive /memory_export sends.
+type memoryExport struct {
+	ExportedAt time.Time          `json:"exported_at"`
+	Memory     *memory.UserExport `json:"memory"`
+	Reminders  []exportedReminder `json:"reminders"`
+}
+
+// exportedReminder is a pending reminder; attached images are listed by name
+// only to keep the archive small enough to send.
+type exportedReminder struct {
+	ID         int64     `json:"id"`
+	GuildID    string    `json:"guild_id"`
+	ChannelID  string    `json:"channel_id"`
+	Message    string    `json:"message"`
+	FireAt     time.Time `json:"fire_at"`
+	Recurrence string    `json:"recurrence,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	Images     []string  `json:"images,omitempty"`
+}
+
+// buildMemoryExport returns the JSON archive of what the bot stores about the
+// user: their memory profiles, the notes they took part in and their
+// reminders.
+func buildMemoryExport(discordID string, now time.Time) ([]byte, error) {
+	stored, err := memory.ExportUserData(discordID)
+	if err != nil {
+		return nil, err
+	}
+	reminders, err := reminder.GetUserReminders(discordID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := memoryExport{ExportedAt: now.UTC(), Memory: stored, Reminders: []exportedReminder{}}
+	for _, r := range reminders {
+		exported := exportedReminder{
+			ID:        r.ID,
+			GuildID:   r.GuildID,
+			ChannelID: r.ChannelID,
+			Message:   r.Message,
+			FireAt:    time.Unix(r.FireAt, 0).UTC(),
+			CreatedAt: time.Unix(r.CreatedAt, 0).UTC(),
+		}
+		if r.Rule != nil {
+			exported.Recurrence = r.Rule.String()
+		}
+		for _, img := range r.Images {
+			exported.Images = append(exported.Images, img.Filename)
+		}
+		export.Reminders = append(export.Reminders, exported)
+	}
+	return json.MarshalIndent(export, "", "  ")
+}
+
+// sendMemoryExport DMs the archive to the user as a JSON file.
+func sendMemoryExport(s *discordgo.Session, user *discordgo.User, data []byte) error {
+	channel, err := s.UserChannelCreate(user.ID)
+	if err != nil {
+		return err
+	}
+	_, err = s.ChannelMessageSendComplex(channel.ID, &discordgo.MessageSend{
+		Content: "Here is everything I have stored about you.",
+		Files: []*discordgo.File{{
+			Name:        fmt.Sprintf("memory-export-%s.json", user.ID),
+			ContentType: "application/json",
+			Reader:      bytes.NewReader(data),
+		}},
+	})
+	return err
+}
+
+// applyMemoryOptOut stores the user's opt-out choice and returns the reply
+// text. A nil optOut reports the current setting.
+func applyMemoryOptOut(discordID, username string, optOut *bool) (string, error) {
+	if optOut == nil {
+		if memory.IsMemoryOptedOut(discordID) {
+			return "You have opted out of memory: I don't remember your messages or use what I stored about you.", nil
+		}
+		return "Memory is on for you. Use `/memory_opt_out enabled:true` to stop me remembering your messages.", nil
+	}
+
+	if err := memory.SetMemoryOptOut(discordID, username, *optOut); err != nil {
+		return "", err
+	}
+	if *optOut {
+		return "You have opted out of memory. I won't remember your messages or use your profile, in any server.\n" +
+			"What I already stored is kept until deleted; use /memory_export to get a copy.", nil
+	}
+	return "Memory is back on for you.", nil
+}
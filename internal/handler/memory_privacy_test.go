@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"voltgpt/internal/memory"
+	"voltgpt/internal/reminder"
+)
+
+func TestBuildMemoryExport(t *testing.T) {
+	setupReminderDB(t)
+	setupMemoryDB(t)
+
+	now := time.Now()
+	if err := reminder.Add("user-1", "ch", "g", "water the plants", []reminder.Image{{Filename: "plant.png", Data: "AAAA"}}, now.Add(time.Hour)); err != nil {
+		t.Fatalf("reminder.Add: %v", err)
+	}
+	if _, err := memory.PinProfileFact("g", "user-1", "alice", "Alice", "interests", "Keeps houseplants."); err != nil {
+		t.Fatalf("PinProfileFact: %v", err)
+	}
+
+	data, err := buildMemoryExport("user-1", now)
+	if err != nil {
+		t.Fatalf("buildMemoryExport: %v", err)
+	}
+	var export memoryExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		t.Fatalf("export is not JSON: %v", err)
+	}
+	if len(export.Reminders) != 1 || export.Reminders[0].Message != "water the plants" || export.Reminders[0].Images[0] != "plant.png" {
+		t.Errorf("reminders = %+v, want the plant reminder", export.Reminders)
+	}
+	if strings.Contains(string(data), "AAAA") {
+		t.Error("export includes reminder image data")
+	}
+	if export.Memory == nil || len(export.Memory.Profiles) != 1 || export.Memory.Profiles[0].Interests[0].Text != "Keeps houseplants." {
+		t.Errorf("memory = %+v, want the pinned fact", export.Memory)
+	}
+}
+
+func TestApplyMemoryOptOut(t *testing.T) {
+	setupReminderDB(t)
+	setupMemoryDB(t)
+
+	if msg, _ := applyMemoryOptOut("user-1", "alice", nil); !strings.Contains(msg, "Memory is on") {
+		t.Errorf("status = %q, want on", msg)
+	}
+	optOut := true
+	if msg, err := applyMemoryOptOut("user-1", "alice", &optOut); err != nil || !strings.Contains(msg, "opted out") {
+		t.Errorf("opt out = %q, %v", msg, err)
+	}
+	if !memory.IsMemoryOptedOut("user-1") {
+		t.Error("opt-out was not stored")
+	}
+	if msg, _ := applyMemoryOptOut("user-1", "alice", nil); !strings.Contains(msg, "You have opted out") {
+		t.Errorf("status = %q, want opted out", msg)
+	}
+}
@@ -10,21 +10,24 @@ import (
 	"strings"
 	"time"
 
-	openaiapi "voltgpt/internal/apis/openai"
+	"voltgpt/internal/apis/provider"
 	"voltgpt/internal/config"
 	"voltgpt/internal/discord"
 	"voltgpt/internal/hasher"
 	"voltgpt/internal/memory"
 	"voltgpt/internal/reminder"
+	"voltgpt/internal/settings"
 	"voltgpt/internal/utility"
 
 	"github.com/bwmarrin/discordgo"
-	"github.com/openai/openai-go/v3/responses"
 )
 
 func HandleMessage(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate) {
+	guildSettings := settings.Get(m.Message.GuildID)
+	snailWatch := settings.SnailWatchFor(m.Message.ChannelID)
+
 	// Delay 3 seconds to allow embeds to load
-	if m.Message.GuildID == config.MainServer {
+	if guildSettings.HashingEnabled || snailWatch.Enabled() {
 		go func() {
 			select {
 			case <-time.After(3 * time.Second):
@@ -37,8 +40,14 @@ func HandleMessage(ctx context.Context, s *discordgo.Session, m *discordgo.Messa
 			}
 
 			if utility.HasImageURL(fetchedMessage) || utility.HasVideoURL(fetchedMessage) {
-				options := hasher.HashOptions{Store: true}
-				hasher.HashAttachments(fetchedMessage, options)
+				// A checked post is stored by the check itself, so it's only
+				// fingerprinted once.
+				if snailWatch.Enabled() && !m.Author.Bot {
+					calloutSnails(s, m.GuildID, fetchedMessage, snailWatch, guildSettings.HashingEnabled)
+				} else if guildSettings.HashingEnabled {
+					options := hasher.HashOptions{Store: true, GuildID: m.GuildID, IgnoreURLs: hasher.StickerURLs}
+					hasher.HashAttachments(fetchedMessage, options)
+				}
 			}
 		}()
 	}
@@ -55,20 +64,12 @@ func HandleMessage(ctx context.Context, s *discordgo.Session, m *discordgo.Messa
 	isBotDirected := utility.IsBotDirectedMessage(m.Message, botUserID, nil)
 
 	skipMemory := utility.ShouldSkipMemory(m.Content)
-	if !skipMemory && !isBotDirected && !config.MemoryBlacklist[m.ChannelID] && m.Message.GuildID == config.MainServer {
+	if !skipMemory && !isBotDirected && guildSettings.MemoryAllowed(m.ChannelID) {
 		captureText := utility.ResolveMentions(m.Content, m.Mentions)
 		go memory.BufferMessage(m.ChannelID, m.GuildID, m.Author.ID, m.Author.Username, m.Author.GlobalName, captureText, m.ID)
 	}
 
-	c, err := openaiapi.GetClient()
-	if err != nil {
-		discord.LogSendErrorMessage(s, m.Message, err.Error())
-		return
-	}
-
-	var chatMessages []responses.ResponseInputItemUnionParam
 	var cache []*discordgo.Message
-	var previousResponseID string
 	var isReply bool
 
 	if m.Type == discordgo.MessageTypeReply && isMentioned {
@@ -88,7 +89,7 @@ func HandleMessage(ctx context.Context, s *discordgo.Session, m *discordgo.Messa
 		return
 	}
 
-	images, videos, _, _ := utility.GetMessageMediaURL(m.Message)
+	images, videos, pdfs, ytURLs := utility.GetMessageMediaURL(m.Message)
 
 	content := config.RequestContent{
 		Text: strings.TrimSpace(fmt.Sprintf("<user name=\"%s\"> %s %s %s </user>",
@@ -99,26 +100,14 @@ func HandleMessage(ctx context.Context, s *discordgo.Session, m *discordgo.Messa
 		)),
 		Images: images,
 		Videos: videos,
+		PDFs:   pdfs,
+		YTURLs: ytURLs,
 	}
 
 	if skipMemory {
 		content.Text = strings.ReplaceAll(content.Text, "🚫", "")
 	}
 
-	chatMessages = append(chatMessages, openaiapi.CreateContent("user", content))
-
-	if isReply {
-		if m.Message.MessageReference != nil {
-			previousResponseID, err = openaiapi.LookupResponseID(m.Message.MessageReference.MessageID)
-			if err != nil {
-				log.Printf("openai: lookup response id for %s: %v", m.Message.MessageReference.MessageID, err)
-			}
-		}
-		if previousResponseID == "" {
-			openaiapi.PrependReplyMessages(s, m.Message.Member, m.Message, cache, &chatMessages)
-		}
-	}
-
 	users := map[string]string{m.Author.ID: m.Author.Username}
 	if isReply {
 		maps.Copy(users, utility.ReplyChainUsers(s, m.Message, cache))
@@ -131,7 +120,7 @@ func HandleMessage(ctx context.Context, s *discordgo.Session, m *discordgo.Messa
 		mentionedUsers[mention.ID] = mention.Username
 	}
 	var backgroundFacts string
-	if !skipMemory && m.Message.GuildID == config.MainServer {
+	if !skipMemory && guildSettings.MemoryEnabled {
 		query := strings.TrimSpace(strings.Join([]string{
 			utility.AttachmentText(m.Message),
 			utility.EmbedText(m.Message),
@@ -146,7 +135,14 @@ func HandleMessage(ctx context.Context, s *discordgo.Session, m *discordgo.Messa
 		})
 	}
 
-	err = openaiapi.StreamMessageResponse(ctx, s, c, m.Message, chatMessages, previousResponseID, backgroundFacts)
+	err := provider.StreamMessageResponse(ctx, settings.ChatProvider(m.GuildID, m.ChannelID), provider.Request{
+		Session:         s,
+		Message:         m.Message,
+		Content:         content,
+		Cache:           cache,
+		IsReply:         isReply,
+		BackgroundFacts: backgroundFacts,
+	})
 	if err != nil && !errors.Is(err, context.Canceled) {
 		discord.LogSendErrorMessage(s, m.Message, err.Error())
 	}
@@ -155,9 +151,9 @@ func HandleMessage(ctx context.Context, s *discordgo.Session, m *discordgo.Messa
 // handleReminder parses and stores a reminder from a Discord message.
 func handleReminder(s *discordgo.Session, m *discordgo.Message, triggerLen int) {
 	after := strings.TrimSpace(m.Content[triggerLen:])
-	fireAt, msg, err := reminder.ParseTime(after, time.Now().UTC())
+	fireAt, rule, msg, err := reminder.ParseSchedule(after, time.Now().In(userLocation(m.Author.ID)))
 	if err != nil {
-		discord.SendMessage(s, m, "Couldn't parse reminder time - try:\n- remind me **in** 2h30m do the thing (relative offset) \n- remind me **at** 16:30 CET do the thing (absolute time)")
+		discord.SendMessage(s, m, "Couldn't parse reminder time - try:\n- remind me **in** 2h30m do the thing (relative offset) \n- remind me **at** 16:30 CET do the thing (absolute time)\n- remind me **every** weekday at 09:00 CET do the thing (recurring)")
 		return
 	}
 
@@ -176,12 +172,20 @@ func handleReminder(s *discordgo.Session, m *discordgo.Message, triggerLen int)
 		}
 	}
 
-	if err := reminder.Add(m.Author.ID, m.ChannelID, m.GuildID, msg, images, fireAt); err != nil {
+	if rule != nil {
+		err = reminder.AddRecurring(m.Author.ID, m.ChannelID, m.GuildID, msg, images, fireAt, *rule)
+	} else {
+		err = reminder.Add(m.Author.ID, m.ChannelID, m.GuildID, msg, images, fireAt)
+	}
+	if err != nil {
 		discord.SendMessage(s, m, fmt.Sprintf("Couldn't save reminder: %v", err))
 		return
 	}
 
 	reply := fmt.Sprintf("<@%s> I'll remind you <t:%d:R>: %s", m.Author.ID, fireAt.Unix(), msg)
+	if rule != nil {
+		reply = fmt.Sprintf("<@%s> I'll remind you %s, starting <t:%d:R>: %s", m.Author.ID, rule, fireAt.Unix(), msg)
+	}
 	if _, err := discord.SendMessage(s, m, reply); err != nil {
 		log.Println(err)
 	}
@@ -3,6 +3,7 @@ package handler
 import (
 	"fmt"
 	"log"
+	"time"
 
 	"voltgpt/internal/discord"
 	"voltgpt/internal/gamble"
@@ -143,4 +144,26 @@ var Modals = map[string]func(s *discordgo.Session, i *discordgo.InteractionCreat
 			log.Println(err)
 		}
 	},
+	"modal_reminder": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+		log.Printf("Received interaction: %s by %s", i.ModalSubmitData().CustomID, i.Interaction.Member.User.Username)
+
+		userID := i.Interaction.Member.User.ID
+		id, _ := parseReminderControlID(i.ModalSubmitData().CustomID)
+		r, problem := ownedReminder(id, userID)
+		if problem != "" {
+			discord.UpdateResponse(s, i, problem)
+			return
+		}
+
+		values := modalTextValues(i.ModalSubmitData().Components)
+		reply, err := editReminder(r, values["when"], values["message"], time.Now().In(userLocation(userID)))
+		if err != nil {
+			log.Println(err)
+			reply = "Couldn't save the reminder. Please try again later."
+		}
+		err = discord.UpdateResponse(s, i, reply)
+		if err != nil {
+			log.Println(err)
+		}
+	},
 }
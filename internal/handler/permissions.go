@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+
+	"voltgpt/internal/permissions"
+)
+
+const auditLogDisplayLimit = 10
+
+func renderCapabilityGrants(grants map[permissions.Capability][]string) string {
+	var sb strings.Builder
+	sb.WriteString("**Capabilities:**\n")
+	for _, c := range permissions.Capabilities {
+		roles := grants[c]
+		if len(roles) == 0 {
+			sb.WriteString(fmt.Sprintf("`%s`: admins only\n", c))
+			continue
+		}
+		mentions := make([]string, len(roles))
+		for idx, id := range roles {
+			mentions[idx] = "<@&" + id + ">"
+		}
+		sb.WriteString(fmt.Sprintf("`%s`: %s\n", c, strings.Join(mentions, ", ")))
+	}
+	return sb.String()
+}
+
+func renderAuditLog(entries []permissions.AuditEntry) string {
+	if len(entries) == 0 {
+		return "**Recent privileged actions:** none"
+	}
+
+	var sb strings.Builder
+	sb.WriteString("**Recent privileged actions:**\n")
+	for _, e := range entries {
+		line := fmt.Sprintf("<t:%d:R> <@%s> `%s`", e.CreatedAt, e.UserID, e.Action)
+		if details := truncateForEmbed(strings.ReplaceAll(e.Details, "\n", " "), 120); details != "" {
+			line += " — " + details
+		}
+		sb.WriteString(line + "\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+
+	"voltgpt/internal/permissions"
+)
+
+func TestRenderCapabilityGrants(t *testing.T) {
+	got := renderCapabilityGrants(map[permissions.Capability][]string{
+		permissions.WheelHost: {"1", "2"},
+	})
+
+	for _, want := range []string{"`wheel_host`: <@&1>, <@&2>", "`memory_admin`: admins only", "`hasher_admin`: admins only"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("renderCapabilityGrants() missing %q in:\n%s", want, got)
+		}
+	}
+}
+
+func TestRenderAuditLog(t *testing.T) {
+	if got := renderAuditLog(nil); !strings.Contains(got, "none") {
+		t.Errorf("renderAuditLog(nil) = %q, want none", got)
+	}
+
+	got := renderAuditLog([]permissions.AuditEntry{
+		{UserID: "42", Action: "reset_wheel", Details: "Wheel\nreset!", CreatedAt: 1700000000},
+	})
+	for _, want := range []string{"<t:1700000000:R>", "<@42>", "`reset_wheel`", "Wheel reset!"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("renderAuditLog() missing %q in:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"voltgpt/internal/reminder"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// parseReminderControlID splits a reminder button or modal custom ID such as
+// "reminder_snooze-42-1h" into the reminder ID and the optional trailing
+// argument.
+func parseReminderControlID(customID string) (int64, string) {
+	parts := strings.SplitN(customID, "-", 3)
+	if len(parts) < 2 {
+		return 0, ""
+	}
+	id, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, ""
+	}
+	if len(parts) == 3 {
+		return id, parts[2]
+	}
+	return id, ""
+}
+
+// ownedReminder loads a reminder and checks that userID created it.
+func ownedReminder(id int64, userID string) (reminder.Reminder, string) {
+	r, err := reminder.Get(id)
+	if err != nil {
+		return reminder.Reminder{}, "Reminder not found (it may have been deleted)."
+	}
+	if r.UserID != userID {
+		return reminder.Reminder{}, "Only the person who set this reminder can change it."
+	}
+	return r, ""
+}
+
+// editReminder applies the edit modal's fields to r. An empty when keeps the
+// current schedule and an empty message keeps the current text.
+func editReminder(r reminder.Reminder, when, message string, now time.Time) (string, error) {
+	message = strings.TrimSpace(message)
+	if message == "" {
+		message = r.Message
+	}
+
+	fireAt := time.Unix(r.FireAt, 0)
+	rule := r.Rule
+	if when = strings.TrimSpace(when); when != "" {
+		t, newRule, rest, err := reminder.ParseSchedule(when, now)
+		if err != nil {
+			return fmt.Sprintf("Couldn't parse %q: %v", when, err), nil
+		}
+		if rest != "" {
+			return fmt.Sprintf("Couldn't parse %q: unexpected text %q after the time.", when, rest), nil
+		}
+		fireAt, rule = t, newRule
+	}
+	if !fireAt.After(now) {
+		return "That time is in the past.", nil
+	}
+
+	if err := reminder.Update(r.ID, message, fireAt, rule); err != nil {
+		return "", err
+	}
+
+	reply := fmt.Sprintf("✅ Reminder updated: <t:%d:R> · %s", fireAt.Unix(), formatLocalTime(fireAt, now.Location()))
+	if rule != nil {
+		reply += fmt.Sprintf(" (%s)", rule)
+	}
+	return reply + " — " + message, nil
+}
+
+// reminderEditModal builds the modal opened from the /reminders edit menu.
+func reminderEditModal(r reminder.Reminder) *discordgo.InteractionResponse {
+	return &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseModal,
+		Data: &discordgo.InteractionResponseData{
+			CustomID: fmt.Sprintf("modal_reminder-%d", r.ID),
+			Title:    "Edit reminder",
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.TextInput{
+							CustomID:    "when",
+							Label:       "New time (leave empty to keep)",
+							Style:       discordgo.TextInputShort,
+							Placeholder: "in 2h, at 16:30, every weekday at 09:00",
+							Required:    false,
+							MaxLength:   100,
+						},
+					},
+				},
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.TextInput{
+							CustomID:  "message",
+							Label:     "Message",
+							Style:     discordgo.TextInputParagraph,
+							Value:     r.Message,
+							Required:  false,
+							MaxLength: 1000,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// modalTextValues collects the text inputs of a submitted modal by custom ID.
+func modalTextValues(components []discordgo.MessageComponent) map[string]string {
+	values := make(map[string]string)
+	for _, c := range components {
+		row, ok := c.(*discordgo.ActionsRow)
+		if !ok {
+			continue
+		}
+		for _, rc := range row.Components {
+			if input, ok := rc.(*discordgo.TextInput); ok {
+				values[input.CustomID] = input.Value
+			}
+		}
+	}
+	return values
+}
+
+// respondEphemeral replies to an interaction with a message only the clicker sees.
+func respondEphemeral(s *discordgo.Session, i *discordgo.InteractionCreate, content string) error {
+	return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+// closeFiredReminder strips the buttons from a sent reminder and appends note.
+func closeFiredReminder(s *discordgo.Session, i *discordgo.InteractionCreate, note string) error {
+	content := note
+	if i.Message != nil && i.Message.Content != "" {
+		content = i.Message.Content + "\n-# " + note
+	}
+	return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    content,
+			Components: []discordgo.MessageComponent{},
+		},
+	})
+}
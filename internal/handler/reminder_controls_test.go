@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"voltgpt/internal/reminder"
+)
+
+func TestParseReminderControlID(t *testing.T) {
+	tests := []struct {
+		customID string
+		id       int64
+		arg      string
+	}{
+		{"reminder_snooze-42-1h", 42, "1h"},
+		{"reminder_done-7", 7, ""},
+		{"modal_reminder-13", 13, ""},
+		{"reminder_done-abc", 0, ""},
+		{"reminder_done", 0, ""},
+	}
+	for _, tt := range tests {
+		id, arg := parseReminderControlID(tt.customID)
+		if id != tt.id || arg != tt.arg {
+			t.Errorf("parseReminderControlID(%q) = %d, %q; want %d, %q", tt.customID, id, arg, tt.id, tt.arg)
+		}
+	}
+}
+
+func TestEditReminder(t *testing.T) {
+	setupReminderDB(t)
+
+	fireAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	reminder.Add("user-1", "chan-1", "guild-1", "water plants", nil, fireAt)
+	reminders, _ := reminder.GetUserReminders("user-1")
+	r := reminders[0]
+
+	if _, problem := ownedReminder(r.ID, "user-2"); problem == "" {
+		t.Error("ownedReminder() allowed another user to edit")
+	}
+
+	// Empty fields keep the current values.
+	if _, err := editReminder(r, "", "  ", time.Now()); err != nil {
+		t.Fatalf("editReminder() error: %v", err)
+	}
+	got, _ := reminder.Get(r.ID)
+	if got.Message != "water plants" || got.FireAt != fireAt.Unix() {
+		t.Errorf("after empty edit = %+v, want unchanged", got)
+	}
+
+	reply, err := editReminder(got, "every day at 08:00", "water all plants", time.Now())
+	if err != nil {
+		t.Fatalf("editReminder() error: %v", err)
+	}
+	got, _ = reminder.Get(r.ID)
+	if got.Message != "water all plants" || got.Rule == nil || !strings.Contains(reply, "every day") {
+		t.Errorf("after edit = %+v, reply %q; want daily reminder", got, reply)
+	}
+
+	reply, _ = editReminder(got, "at 08:00 extra words", "", time.Now())
+	if !strings.Contains(reply, "Couldn't parse") {
+		t.Errorf("editReminder() with trailing text = %q, want parse error", reply)
+	}
+}
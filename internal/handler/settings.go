@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"voltgpt/internal/apis/provider"
+	"voltgpt/internal/settings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// applySettingsOptions updates g with the options passed to /settings and
+// reports whether anything was changed.
+func applySettingsOptions(g *settings.Guild, options []*discordgo.ApplicationCommandInteractionDataOption) bool {
+	changed := false
+	for _, option := range options {
+		switch option.Name {
+		case "memory":
+			g.MemoryEnabled = option.BoolValue()
+		case "hashing":
+			g.HashingEnabled = option.BoolValue()
+		case "blacklist_channel":
+			id := optionID(option)
+			if !slices.Contains(g.BlacklistedChannels, id) {
+				g.BlacklistedChannels = append(g.BlacklistedChannels, id)
+			}
+		case "unblacklist_channel":
+			id := optionID(option)
+			g.BlacklistedChannels = slices.DeleteFunc(g.BlacklistedChannels, func(c string) bool { return c == id })
+		case "add_admin_role":
+			id := optionID(option)
+			if !slices.Contains(g.AdminRoles, id) {
+				g.AdminRoles = append(g.AdminRoles, id)
+			}
+		case "remove_admin_role":
+			id := optionID(option)
+			g.AdminRoles = slices.DeleteFunc(g.AdminRoles, func(r string) bool { return r == id })
+		default:
+			continue
+		}
+		changed = true
+	}
+	return changed
+}
+
+// providerOptions extracts the chat backend choice from /settings options.
+// channelID is empty when the choice applies to the whole guild, and name is
+// empty when the choice resets to the default.
+func providerOptions(options []*discordgo.ApplicationCommandInteractionDataOption) (name, channelID string, ok bool) {
+	for _, option := range options {
+		switch option.Name {
+		case "chat_provider":
+			name = option.StringValue()
+			ok = true
+		case "provider_channel":
+			channelID = optionID(option)
+		}
+	}
+	if name == "default" {
+		name = ""
+	}
+	return name, channelID, ok
+}
+
+// applySnailOptions updates w with the snail callout options passed to
+// /settings and reports whether anything was changed. A threshold is refused
+// for a channel whose callouts stay off, since nothing would use it.
+func applySnailOptions(w *settings.SnailWatch, options []*discordgo.ApplicationCommandInteractionDataOption) (bool, error) {
+	changed, threshold := false, false
+	for _, option := range options {
+		switch option.Name {
+		case "snail_callouts":
+			w.Mode = option.StringValue()
+			if w.Mode == "off" {
+				w.Mode = settings.SnailOff
+			}
+		case "snail_threshold":
+			w.Threshold = int(option.IntValue())
+			threshold = true
+		default:
+			continue
+		}
+		changed = true
+	}
+	if threshold && !w.Enabled() {
+		return false, fmt.Errorf("snail callouts are off in that channel; set snail_callouts along with snail_threshold")
+	}
+	return changed, nil
+}
+
+// snailChannelOption returns the channel the snail options apply to:
+// snail_channel if given, otherwise the current one.
+func snailChannelOption(current string, options []*discordgo.ApplicationCommandInteractionDataOption) string {
+	for _, option := range options {
+		if option.Name == "snail_channel" {
+			return optionID(option)
+		}
+	}
+	return current
+}
+
+// optionID returns the snowflake carried by a channel or role option without
+// resolving it through the session.
+func optionID(option *discordgo.ApplicationCommandInteractionDataOption) string {
+	id, _ := option.Value.(string)
+	return id
+}
+
+func renderGuildSettings(g settings.Guild, channelProviders map[string]string) string {
+	var sb strings.Builder
+	sb.WriteString("**Server settings:**\n")
+	sb.WriteString(fmt.Sprintf("Memory: %s\n", enabledLabel(g.MemoryEnabled)))
+	sb.WriteString(fmt.Sprintf("Hashing: %s\n", enabledLabel(g.HashingEnabled)))
+
+	chatProvider := g.ChatProvider
+	if chatProvider == "" {
+		chatProvider = provider.Default + " (default)"
+	}
+	sb.WriteString(fmt.Sprintf("Chat provider: %s\n", chatProvider))
+	if len(channelProviders) > 0 {
+		channels := slices.Sorted(maps.Keys(channelProviders))
+		overrides := make([]string, len(channels))
+		for idx, id := range channels {
+			overrides[idx] = fmt.Sprintf("<#%s> → %s", id, channelProviders[id])
+		}
+		sb.WriteString("Channel chat providers: " + strings.Join(overrides, ", ") + "\n")
+	}
+
+	sb.WriteString("Memory blacklisted channels: ")
+	if len(g.BlacklistedChannels) == 0 {
+		sb.WriteString("none\n")
+	} else {
+		mentions := make([]string, len(g.BlacklistedChannels))
+		for idx, id := range g.BlacklistedChannels {
+			mentions[idx] = "<#" + id + ">"
+		}
+		sb.WriteString(strings.Join(mentions, ", ") + "\n")
+	}
+
+	sb.WriteString("Admin roles: ")
+	if len(g.AdminRoles) == 0 {
+		sb.WriteString("none (Administrator permission only)")
+	} else {
+		mentions := make([]string, len(g.AdminRoles))
+		for idx, id := range g.AdminRoles {
+			mentions[idx] = "<@&" + id + ">"
+		}
+		sb.WriteString(strings.Join(mentions, ", "))
+	}
+
+	return sb.String()
+}
+
+// renderSnailWatches lists the channels with snail callouts for /settings.
+func renderSnailWatches(watches map[string]settings.SnailWatch) string {
+	if len(watches) == 0 {
+		return "\nSnail callouts: off"
+	}
+	channels := slices.Sorted(maps.Keys(watches))
+	entries := make([]string, len(channels))
+	for idx, id := range channels {
+		entries[idx] = fmt.Sprintf("<#%s> → %s", id, watches[id])
+	}
+	return "\nSnail callouts: " + strings.Join(entries, ", ")
+}
+
+func enabledLabel(enabled bool) string {
+	if enabled {
+		return "enabled"
+	}
+	return "disabled"
+}
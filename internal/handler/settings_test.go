@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+
+	"voltgpt/internal/settings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestApplySettingsOptions(t *testing.T) {
+	g := settings.Defaults("guild-1")
+	g.BlacklistedChannels = []string{"chan-old"}
+
+	changed := applySettingsOptions(&g, []*discordgo.ApplicationCommandInteractionDataOption{
+		{Name: "memory", Type: discordgo.ApplicationCommandOptionBoolean, Value: true},
+		{Name: "blacklist_channel", Type: discordgo.ApplicationCommandOptionChannel, Value: "chan-new"},
+		{Name: "unblacklist_channel", Type: discordgo.ApplicationCommandOptionChannel, Value: "chan-old"},
+		{Name: "add_admin_role", Type: discordgo.ApplicationCommandOptionRole, Value: "role-1"},
+		{Name: "add_admin_role", Type: discordgo.ApplicationCommandOptionRole, Value: "role-1"},
+	})
+
+	if !changed {
+		t.Fatal("applySettingsOptions() = false, want true")
+	}
+	if !g.MemoryEnabled {
+		t.Error("MemoryEnabled = false, want true")
+	}
+	if len(g.BlacklistedChannels) != 1 || g.BlacklistedChannels[0] != "chan-new" {
+		t.Errorf("BlacklistedChannels = %v, want [chan-new]", g.BlacklistedChannels)
+	}
+	if len(g.AdminRoles) != 1 || g.AdminRoles[0] != "role-1" {
+		t.Errorf("AdminRoles = %v, want [role-1]", g.AdminRoles)
+	}
+}
+
+func TestApplySettingsOptionsNoOptions(t *testing.T) {
+	g := settings.Defaults("guild-1")
+	if applySettingsOptions(&g, nil) {
+		t.Error("applySettingsOptions(nil) = true, want false")
+	}
+}
+
+func TestRenderGuildSettings(t *testing.T) {
+	g := settings.Defaults("guild-1")
+	g.HashingEnabled = false
+	g.BlacklistedChannels = []string{"123"}
+
+	got := renderGuildSettings(g, map[string]string{"456": "gemini"})
+	for _, want := range []string{"Memory: disabled", "Hashing: disabled", "<#123>", "Administrator permission only", "Chat provider: openai (default)", "<#456> → gemini"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("renderGuildSettings() missing %q in:\n%s", want, got)
+		}
+	}
+}
+
+func TestProviderOptions(t *testing.T) {
+	tests := []struct {
+		name        string
+		options     []*discordgo.ApplicationCommandInteractionDataOption
+		wantName    string
+		wantChannel string
+		wantOK      bool
+	}{
+		{
+			name:    "no provider option",
+			options: []*discordgo.ApplicationCommandInteractionDataOption{{Name: "memory", Type: discordgo.ApplicationCommandOptionBoolean, Value: true}},
+		},
+		{
+			name: "guild provider",
+			options: []*discordgo.ApplicationCommandInteractionDataOption{
+				{Name: "chat_provider", Type: discordgo.ApplicationCommandOptionString, Value: "gemini"},
+			},
+			wantName: "gemini",
+			wantOK:   true,
+		},
+		{
+			name: "channel reset to default",
+			options: []*discordgo.ApplicationCommandInteractionDataOption{
+				{Name: "provider_channel", Type: discordgo.ApplicationCommandOptionChannel, Value: "chan-1"},
+				{Name: "chat_provider", Type: discordgo.ApplicationCommandOptionString, Value: "default"},
+			},
+			wantChannel: "chan-1",
+			wantOK:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, channel, ok := providerOptions(tt.options)
+			if name != tt.wantName || channel != tt.wantChannel || ok != tt.wantOK {
+				t.Errorf("providerOptions() = (%q, %q, %v), want (%q, %q, %v)", name, channel, ok, tt.wantName, tt.wantChannel, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestApplySnailOptions(t *testing.T) {
+	w := settings.SnailWatch{Mode: settings.SnailReact, Threshold: 4}
+	options := []*discordgo.ApplicationCommandInteractionDataOption{
+		{Name: "memory", Type: discordgo.ApplicationCommandOptionBoolean, Value: true},
+		{Name: "snail_threshold", Type: discordgo.ApplicationCommandOptionInteger, Value: float64(6)},
+	}
+	if changed, err := applySnailOptions(&w, options); !changed || err != nil || w.Mode != settings.SnailReact || w.Threshold != 6 {
+		t.Errorf("applySnailOptions() = %+v, %v; want react with threshold 6", w, err)
+	}
+
+	off := []*discordgo.ApplicationCommandInteractionDataOption{
+		{Name: "snail_callouts", Type: discordgo.ApplicationCommandOptionString, Value: "off"},
+	}
+	if changed, err := applySnailOptions(&w, off); !changed || err != nil || w.Enabled() {
+		t.Errorf("applySnailOptions(off) = %+v, %v; want callouts off", w, err)
+	}
+
+	if changed, _ := applySnailOptions(&w, options[:1]); changed {
+		t.Error("applySnailOptions() without snail options = true, want false")
+	}
+
+	// A threshold alone would be pruned with the channel's empty row.
+	if changed, err := applySnailOptions(&settings.SnailWatch{}, options); changed || err == nil {
+		t.Errorf("applySnailOptions(threshold without callouts) = %v, %v; want an error", changed, err)
+	}
+}
+
+func TestSnailChannelOption(t *testing.T) {
+	if got := snailChannelOption("current", nil); got != "current" {
+		t.Errorf("snailChannelOption() = %q, want the current channel", got)
+	}
+	options := []*discordgo.ApplicationCommandInteractionDataOption{
+		{Name: "snail_channel", Type: discordgo.ApplicationCommandOptionChannel, Value: "789"},
+	}
+	if got := snailChannelOption("current", options); got != "789" {
+		t.Errorf("snailChannelOption() = %q, want 789", got)
+	}
+}
+
+func TestRenderSnailWatches(t *testing.T) {
+	if got := renderSnailWatches(nil); got != "\nSnail callouts: off" {
+		t.Errorf("renderSnailWatches(nil) = %q", got)
+	}
+	got := renderSnailWatches(map[string]settings.SnailWatch{
+		"2": {Mode: settings.SnailBoth},
+		"1": {Mode: settings.SnailReact, Threshold: 4},
+	})
+	if got != "\nSnail callouts: <#1> → react (≤4d), <#2> → both (≤8d)" {
+		t.Errorf("renderSnailWatches() = %q", got)
+	}
+}
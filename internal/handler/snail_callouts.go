@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"voltgpt/internal/hasher"
+	"voltgpt/internal/settings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	snailEmoji = "🐌"
+	// snailCalloutCooldown is the minimum gap between callouts in a channel,
+	// so a burst of reposts doesn't turn into a burst of replies.
+	snailCalloutCooldown = 30 * time.Second
+	// maxCalloutLength keeps callout replies within one Discord message.
+	maxCalloutLength = 2000
+)
+
+// calloutLimiter allows one callout per channel per cooldown.
+type calloutLimiter struct {
+	mu       sync.Mutex
+	cooldown time.Duration
+	last     map[string]time.Time
+}
+
+var snailCallouts = &calloutLimiter{cooldown: snailCalloutCooldown, last: map[string]time.Time{}}
+
+// ready reports whether the channel is out of its cooldown at now, without
+// starting a new one.
+func (l *calloutLimiter) ready(channelID string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	last, ok := l.last[channelID]
+	return !ok || now.Sub(last) >= l.cooldown
+}
+
+// allow reports whether a callout may be sent in the channel at now, and if so
+// starts its cooldown.
+func (l *calloutLimiter) allow(channelID string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if last, ok := l.last[channelID]; ok && now.Sub(last) < l.cooldown {
+		return false
+	}
+	l.last[channelID] = now
+	return true
+}
+
+// calloutSnails checks a new post against the guild's earlier media and, when
+// it's a repost, reacts and/or replies as the channel's watch setting says.
+// Posts in a channel still in its cooldown are not checked at all. With store
+// the post's media is stored as well, checked or not.
+func calloutSnails(s *discordgo.Session, guildID string, message *discordgo.Message, watch settings.SnailWatch, store bool) {
+	options := hasher.HashOptions{
+		Store:      store,
+		Threshold:  watch.MaxDistance(),
+		GuildID:    guildID,
+		IgnoreURLs: hasher.StickerURLs,
+	}
+	if !snailCallouts.ready(message.ChannelID, time.Now()) {
+		if store {
+			hasher.HashAttachments(message, options)
+		}
+		return
+	}
+	content, _ := hasher.FindSnails(guildID, message, options)
+	if content == "" || !snailCallouts.allow(message.ChannelID, time.Now()) {
+		return
+	}
+
+	if watch.React() {
+		if err := s.MessageReactionAdd(message.ChannelID, message.ID, snailEmoji); err != nil {
+			log.Println(err)
+		}
+	}
+	if watch.Reply() {
+		_, err := s.ChannelMessageSendComplex(message.ChannelID, &discordgo.MessageSend{
+			Content:         calloutText(content),
+			Reference:       message.Reference(),
+			AllowedMentions: &discordgo.MessageAllowedMentions{},
+			Flags:           discordgo.MessageFlagsSuppressEmbeds,
+		})
+		if err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// calloutText trims FindSnails output to whole lines that fit in one message.
+func calloutText(content string) string {
+	var sb strings.Builder
+	for line := range strings.Lines(content) {
+		if sb.Len()+len(line) > maxCalloutLength {
+			break
+		}
+		sb.WriteString(line)
+	}
+	return strings.TrimSpace(sb.String())
+}
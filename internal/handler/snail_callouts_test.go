@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCalloutLimiter(t *testing.T) {
+	l := &calloutLimiter{cooldown: time.Minute, last: map[string]time.Time{}}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	if !l.ready("ch", now) || !l.ready("ch", now) {
+		t.Fatal("ready() started a cooldown")
+	}
+	if !l.allow("ch", now) {
+		t.Fatal("first callout was blocked")
+	}
+	if l.ready("ch", now.Add(30*time.Second)) {
+		t.Error("channel in its cooldown is ready")
+	}
+	if l.allow("ch", now.Add(30*time.Second)) {
+		t.Error("callout within the cooldown was allowed")
+	}
+	if !l.allow("other", now.Add(30*time.Second)) {
+		t.Error("cooldown leaked into another channel")
+	}
+	if !l.allow("ch", now.Add(time.Minute)) {
+		t.Error("callout after the cooldown was blocked")
+	}
+}
+
+func TestCalloutText(t *testing.T) {
+	line := strings.Repeat("x", 900) + "\n"
+	got := calloutText(line + line + line)
+	if len(got) > maxCalloutLength || strings.Count(got, "\n") != 1 {
+		t.Errorf("calloutText() kept %d chars over %d lines, want two whole lines", len(got), strings.Count(got, "\n")+1)
+	}
+	if got := calloutText("5d (90%): Snail of alice!\n"); got != "5d (90%): Snail of alice!" {
+		t.Errorf("calloutText() = %q", got)
+	}
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+
+	"voltgpt/internal/hasher"
+	"voltgpt/internal/utility"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// snailLeaderboardSize is how many reposters and images the leaderboard lists.
+const snailLeaderboardSize = 10
+
+func snailLeaderboardEmbed(guildID string) (*discordgo.MessageEmbed, error) {
+	reposters, err := hasher.TopReposters(guildID, snailLeaderboardSize)
+	if err != nil {
+		return nil, err
+	}
+	images, err := hasher.MostReposted(guildID, snailLeaderboardSize)
+	if err != nil {
+		return nil, err
+	}
+	return renderSnailLeaderboard(guildID, reposters, images), nil
+}
+
+func renderSnailLeaderboard(guildID string, reposters []hasher.Reposter, images []hasher.RepostedImage) *discordgo.MessageEmbed {
+	var snails strings.Builder
+	for n, r := range reposters {
+		fmt.Fprintf(&snails, "%d. %s: %s\n", n+1, r.AuthorName, plural(r.Reposts, "repost"))
+	}
+	if snails.Len() == 0 {
+		snails.WriteString("No reposts yet.")
+	}
+
+	var reposted strings.Builder
+	for n, img := range images {
+		first := &discordgo.Message{ID: img.First.MessageID, ChannelID: img.First.ChannelID}
+		fmt.Fprintf(&reposted, "%d. %s, first posted by %s on %s: %s\n", n+1, plural(img.Reposts, "repost"),
+			img.First.AuthorName, img.First.Timestamp.Format("2006-01-02"), utility.LinkFromIMessage(guildID, first))
+	}
+	if reposted.Len() == 0 {
+		reposted.WriteString("No reposted images yet.")
+	}
+
+	return &discordgo.MessageEmbed{
+		Title: "🐌 Snail leaderboard",
+		Color: 0x2b2d31,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Top reposters", Value: snails.String()},
+			{Name: "Most reposted images", Value: reposted.String()},
+		},
+	}
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
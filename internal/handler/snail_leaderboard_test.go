@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"voltgpt/internal/hasher"
+)
+
+func TestRenderSnailLeaderboard(t *testing.T) {
+	reposters := []hasher.Reposter{
+		{AuthorID: "1", AuthorName: "bob", Reposts: 3},
+		{AuthorID: "2", AuthorName: "carol", Reposts: 1},
+	}
+	images := []hasher.RepostedImage{{
+		Hash:    "a:00",
+		Reposts: 4,
+		First: hasher.Sighting{
+			MessageID:  "m1",
+			ChannelID:  "c1",
+			AuthorName: "alice",
+			Timestamp:  time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		},
+	}}
+
+	embed := renderSnailLeaderboard("g1", reposters, images)
+	if len(embed.Fields) != 2 {
+		t.Fatalf("embed has %d fields, want 2", len(embed.Fields))
+	}
+	if got := embed.Fields[0].Value; got != "1. bob: 3 reposts\n2. carol: 1 repost\n" {
+		t.Errorf("reposters field = %q", got)
+	}
+	want := "1. 4 reposts, first posted by alice on 2024-05-01: https://discord.com/channels/g1/c1/m1"
+	if got := embed.Fields[1].Value; !strings.Contains(got, want) {
+		t.Errorf("images field = %q, want it to contain %q", got, want)
+	}
+}
+
+func TestRenderSnailLeaderboardEmpty(t *testing.T) {
+	embed := renderSnailLeaderboard("g1", nil, nil)
+	if embed.Fields[0].Value != "No reposts yet." || embed.Fields[1].Value != "No reposted images yet." {
+		t.Errorf("empty leaderboard fields = %q / %q", embed.Fields[0].Value, embed.Fields[1].Value)
+	}
+}
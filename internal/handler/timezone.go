@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"voltgpt/internal/memory"
+	"voltgpt/internal/reminder"
+)
+
+// userLocation returns the zone a user's reminder times are read and shown in,
+// falling back to UTC when they haven't set one.
+func userLocation(discordID string) *time.Location {
+	if loc, ok := reminder.LookupLocation(memory.GetTimezone(discordID)); ok {
+		return loc
+	}
+	return time.UTC
+}
+
+// formatLocalTime renders t in loc for reminder listings, e.g. "Mon 2 Mar 09:00 CET".
+func formatLocalTime(t time.Time, loc *time.Location) string {
+	return t.In(loc).Format("Mon 2 Jan 15:04 MST")
+}
+
+// applyTimezoneOption stores zone for the user and returns the reply text. An
+// empty zone reports the current setting and "clear" removes it.
+func applyTimezoneOption(discordID, username, zone string, now time.Time) (string, error) {
+	zone = strings.TrimSpace(zone)
+	switch {
+	case zone == "":
+		current := memory.GetTimezone(discordID)
+		if current == "" {
+			return "You haven't set a timezone, so reminder times are read in UTC.", nil
+		}
+		return fmt.Sprintf("Your timezone is **%s** (local time %s).", current, formatLocalTime(now, userLocation(discordID))), nil
+	case strings.EqualFold(zone, "clear"):
+		if err := memory.SetTimezone(discordID, username, ""); err != nil {
+			return "", err
+		}
+		return "Cleared your timezone; reminder times are read in UTC again.", nil
+	}
+
+	loc, ok := reminder.LookupLocation(zone)
+	if !ok {
+		return fmt.Sprintf("Unknown timezone %q. Use an IANA name like `Europe/Berlin` or `America/New_York`.", zone), nil
+	}
+	if err := memory.SetTimezone(discordID, username, loc.String()); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Set your timezone to **%s** (local time %s).", loc, formatLocalTime(now, loc)), nil
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"voltgpt/internal/db"
+	"voltgpt/internal/memory"
+)
+
+// setupMemoryDB points the memory package at the current test database.
+func setupMemoryDB(t *testing.T) {
+	t.Helper()
+	t.Setenv("MEMORY_OPENAI_TOKEN", "")
+
+	memory.Init(db.DB)
+	t.Cleanup(func() { memory.Init(nil) })
+}
+
+func TestApplyTimezoneOption(t *testing.T) {
+	setupReminderDB(t)
+	setupMemoryDB(t)
+	now := time.Date(2026, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	msg, err := applyTimezoneOption("user-1", "alice", "", now)
+	if err != nil || !strings.Contains(msg, "UTC") {
+		t.Fatalf("show unset = %q, %v; want UTC notice", msg, err)
+	}
+
+	// Abbreviations are stored as their region so DST is applied.
+	msg, err = applyTimezoneOption("user-1", "alice", "CET", now)
+	if err != nil {
+		t.Fatalf("set CET error: %v", err)
+	}
+	if !strings.Contains(msg, "Europe/Berlin") || !strings.Contains(msg, "14:00 CEST") {
+		t.Errorf("set CET = %q, want Europe/Berlin at 14:00 CEST", msg)
+	}
+	if got := userLocation("user-1").String(); got != "Europe/Berlin" {
+		t.Errorf("userLocation() = %q, want Europe/Berlin", got)
+	}
+
+	msg, _ = applyTimezoneOption("user-1", "alice", "Mars/Olympus", now)
+	if !strings.Contains(msg, "Unknown timezone") {
+		t.Errorf("unknown zone = %q, want error message", msg)
+	}
+	if got := userLocation("user-1").String(); got != "Europe/Berlin" {
+		t.Errorf("unknown zone overwrote setting: %q", got)
+	}
+
+	if _, err := applyTimezoneOption("user-1", "alice", "clear", now); err != nil {
+		t.Fatalf("clear error: %v", err)
+	}
+	if got := userLocation("user-1"); got != time.UTC {
+		t.Errorf("userLocation() after clear = %v, want UTC", got)
+	}
+}
+
+func TestCreateReminderToolUsesUserTimezone(t *testing.T) {
+	setupReminderDB(t)
+	setupMemoryDB(t)
+
+	if err := memory.SetTimezone("user-1", "alice", "America/New_York"); err != nil {
+		t.Fatalf("SetTimezone: %v", err)
+	}
+
+	out, err := createReminderTool(t.Context(), toolMessage(), []byte(`{"when":"at 16:30","message":"call"}`))
+	if err != nil {
+		t.Fatalf("createReminderTool() error = %v", err)
+	}
+	if !strings.Contains(out, "T16:30:00-0") {
+		t.Errorf("createReminderTool() = %s, want 16:30 New York time", out)
+	}
+}
@@ -0,0 +1,354 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	openaiapi "voltgpt/internal/apis/openai"
+	"voltgpt/internal/gamble"
+	"voltgpt/internal/hasher"
+	"voltgpt/internal/memory"
+	"voltgpt/internal/permissions"
+	"voltgpt/internal/reminder"
+	"voltgpt/internal/settings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// RegisterChatTools exposes bot features to the chat model as callable tools.
+func RegisterChatTools() {
+	openaiapi.RegisterTool(openaiapi.Tool{
+		Name:        "create_reminder",
+		Description: "Schedule a reminder for the user who sent the message. It is posted in the current channel when due.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"when": map[string]any{
+					"type":        "string",
+					"description": `A relative offset starting with "in" (e.g. "in 2h30m", "in 3 days"), an absolute time starting with "at" (e.g. "at 16:30 CET", "at 2026-03-01 09:00 UTC") or a recurring schedule starting with "every" (e.g. "every weekday at 09:00 Europe/Berlin", "every 2 weeks", "every 0 9 * * 1-5"). Times without a zone use the user's /timezone setting.`,
+				},
+				"message": map[string]any{
+					"type":        "string",
+					"description": "What to remind the user about.",
+				},
+			},
+			"required": []string{"when", "message"},
+		},
+		Handler: createReminderTool,
+	})
+	openaiapi.RegisterTool(openaiapi.Tool{
+		Name:        "list_reminders",
+		Description: "List the pending reminders of the user who sent the message.",
+		Handler:     listRemindersTool,
+	})
+	openaiapi.RegisterTool(openaiapi.Tool{
+		Name:        "cancel_reminder",
+		Description: "Cancel one of the user's pending reminders by ID. Call list_reminders first to find the ID.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"id": map[string]any{
+					"type":        "integer",
+					"description": "Reminder ID from list_reminders.",
+				},
+			},
+			"required": []string{"id"},
+		},
+		Handler: cancelReminderTool,
+	})
+	openaiapi.RegisterTool(openaiapi.Tool{
+		Name:        "lookup_memory_profile",
+		Description: "Look up what the bot remembers about a server member. Defaults to the user who sent the message.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"user": map[string]any{
+					"type":        "string",
+					"description": "Username, display name, mention or Discord ID of the member. Omit for the sender.",
+				},
+			},
+		},
+		Handler: lookupMemoryProfileTool,
+	})
+	openaiapi.RegisterTool(openaiapi.Tool{
+		Name:        "wheel_status",
+		Description: "Get the current round of the movie wheel: players, money, bets and winner.",
+		Handler:     wheelStatusTool,
+	})
+	openaiapi.RegisterTool(openaiapi.Tool{
+		Name:        "check_snails",
+		Description: "Check whether the images or videos in a message were posted in this server before (a \"snail\"). Defaults to the message being replied to, or the current message.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"message_id": map[string]any{
+					"type":        "string",
+					"description": "ID of a message in the current channel. Omit to use the replied-to or current message.",
+				},
+			},
+		},
+		Handler: checkSnailsTool,
+	})
+}
+
+func toolJSON(v any) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func createReminderTool(ctx context.Context, tc openaiapi.ToolContext, args json.RawMessage) (string, error) {
+	var params struct {
+		When    string `json:"when"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	if strings.TrimSpace(params.Message) == "" {
+		return "", fmt.Errorf("message is required")
+	}
+
+	loc := userLocation(tc.Message.Author.ID)
+	fireAt, rule, _, err := reminder.ParseSchedule(params.When, time.Now().In(loc))
+	if err != nil {
+		return "", err
+	}
+	if !fireAt.After(time.Now()) {
+		return "", fmt.Errorf("reminder time %s is in the past", fireAt.Format(time.RFC3339))
+	}
+
+	m := tc.Message
+	if rule != nil {
+		err = reminder.AddRecurring(m.Author.ID, m.ChannelID, m.GuildID, params.Message, nil, fireAt, *rule)
+	} else {
+		err = reminder.Add(m.Author.ID, m.ChannelID, m.GuildID, params.Message, nil, fireAt)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	out := map[string]any{
+		"scheduled": true,
+		"fire_at":   fireAt.In(loc).Format(time.RFC3339),
+		"message":   params.Message,
+	}
+	if rule != nil {
+		out["repeats"] = rule.String()
+	}
+	return toolJSON(out)
+}
+
+type toolReminder struct {
+	ID      int64  `json:"id"`
+	Message string `json:"message"`
+	FireAt  string `json:"fire_at"`
+	Repeats string `json:"repeats,omitempty"`
+}
+
+func listRemindersTool(ctx context.Context, tc openaiapi.ToolContext, args json.RawMessage) (string, error) {
+	reminders, err := reminder.GetUserReminders(tc.Message.Author.ID)
+	if err != nil {
+		return "", err
+	}
+
+	loc := userLocation(tc.Message.Author.ID)
+	out := make([]toolReminder, 0, len(reminders))
+	for _, r := range reminders {
+		tr := toolReminder{
+			ID:      r.ID,
+			Message: r.Message,
+			FireAt:  time.Unix(r.FireAt, 0).In(loc).Format(time.RFC3339),
+		}
+		if r.Rule != nil {
+			tr.Repeats = r.Rule.String()
+		}
+		out = append(out, tr)
+	}
+	return toolJSON(map[string]any{"reminders": out})
+}
+
+func cancelReminderTool(ctx context.Context, tc openaiapi.ToolContext, args json.RawMessage) (string, error) {
+	var params struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	// Only let the sender cancel their own reminders.
+	reminders, err := reminder.GetUserReminders(tc.Message.Author.ID)
+	if err != nil {
+		return "", err
+	}
+	owned := slices.ContainsFunc(reminders, func(r reminder.Reminder) bool { return r.ID == params.ID })
+	if !owned {
+		return "", fmt.Errorf("no pending reminder with id %d for this user", params.ID)
+	}
+
+	return toolJSON(map[string]any{"cancelled": reminder.Delete(params.ID)})
+}
+
+func lookupMemoryProfileTool(ctx context.Context, tc openaiapi.ToolContext, args json.RawMessage) (string, error) {
+	var params struct {
+		User string `json:"user"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	m := tc.Message
+	if !settings.Get(m.GuildID).MemoryEnabled {
+		return "", fmt.Errorf("memory is disabled in this server")
+	}
+
+	// Members may look up themselves and the users their message mentions;
+	// anyone else needs the memory admin capability.
+	user := resolveToolUser(m, params.User)
+	if user == nil {
+		if !isSnowflake(toolUserQuery(params.User)) {
+			return "", fmt.Errorf("unknown user %q; mention them in the message or pass their Discord ID", params.User)
+		}
+		if !permissions.Has(m.GuildID, messageMember(tc.Session, m), permissions.MemoryAdmin) {
+			return "", fmt.Errorf("only memory admins can look up users who are not mentioned in the message")
+		}
+		user = &discordgo.User{ID: toolUserQuery(params.User), Username: toolUserQuery(params.User)}
+	}
+
+	if memory.IsMemoryOptedOut(user.ID) {
+		return fmt.Sprintf("%s has opted out of memory.", user.Username), nil
+	}
+	profile, err := memory.GetGuildUserProfile(m.GuildID, user.ID)
+	if err != nil {
+		return "", err
+	}
+	if profile == nil {
+		return fmt.Sprintf("No memory profile stored for %s.", user.Username), nil
+	}
+	return memory.RenderProfileMarkdown(profile, user.Username), nil
+}
+
+// resolveToolUser maps a model-supplied user reference onto the message author
+// or one of the mentioned users. It returns nil for anyone else.
+func resolveToolUser(m *discordgo.Message, query string) *discordgo.User {
+	query = toolUserQuery(query)
+	if query == "" {
+		return m.Author
+	}
+
+	candidates := append([]*discordgo.User{m.Author}, m.Mentions...)
+	for _, u := range candidates {
+		if u == nil {
+			continue
+		}
+		if u.ID == query || strings.EqualFold(u.Username, query) || strings.EqualFold(u.GlobalName, query) {
+			return u
+		}
+	}
+	return nil
+}
+
+// toolUserQuery strips mention syntax from a model-supplied user reference.
+func toolUserQuery(query string) string {
+	query = strings.TrimSpace(query)
+	query = strings.TrimPrefix(strings.TrimSuffix(query, ">"), "<@")
+	query = strings.TrimPrefix(query, "!")
+	return strings.TrimPrefix(query, "@")
+}
+
+// messageMember returns the author's guild member with its user and channel
+// permissions filled in, which message events leave out. Permissions come from
+// the guild's roles in the state cache, or from the API when it is missing.
+func messageMember(s *discordgo.Session, m *discordgo.Message) *discordgo.Member {
+	if m.Member == nil {
+		return nil
+	}
+	member := *m.Member
+	member.User = m.Author
+	if s == nil || m.Author == nil {
+		return &member
+	}
+	perms, err := s.State.MessagePermissions(m)
+	if err != nil {
+		perms, err = s.UserChannelPermissions(m.Author.ID, m.ChannelID)
+	}
+	if err != nil {
+		log.Printf("tools: permissions of %s in %s: %v", m.Author.ID, m.ChannelID, err)
+	}
+	member.Permissions = perms
+	return &member
+}
+
+func isSnowflake(s string) bool {
+	if len(s) < 15 || len(s) > 21 {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func wheelStatusTool(ctx context.Context, tc openaiapi.ToolContext, args json.RawMessage) (string, error) {
+	gamble.Mu.Lock()
+	defer gamble.Mu.Unlock()
+
+	if gamble.GameState.TotalRounds() == 0 {
+		return "The wheel has no rounds yet.", nil
+	}
+	embed := gamble.GameState.StatusEmbed(gamble.GameState.CurrentRound())
+	return embedToText(&embed), nil
+}
+
+func embedToText(embed *discordgo.MessageEmbed) string {
+	var sb strings.Builder
+	if embed.Title != "" {
+		sb.WriteString(embed.Title + "\n")
+	}
+	if embed.Description != "" {
+		sb.WriteString(embed.Description + "\n")
+	}
+	for _, field := range embed.Fields {
+		sb.WriteString(fmt.Sprintf("%s:\n%s\n", field.Name, field.Value))
+	}
+	if embed.Footer != nil && embed.Footer.Text != "" {
+		sb.WriteString(embed.Footer.Text + "\n")
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+func checkSnailsTool(ctx context.Context, tc openaiapi.ToolContext, args json.RawMessage) (string, error) {
+	var params struct {
+		MessageID string `json:"message_id"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	target := tc.Message
+	switch {
+	case params.MessageID != "":
+		fetched, err := tc.Session.ChannelMessage(tc.Message.ChannelID, params.MessageID)
+		if err != nil {
+			return "", fmt.Errorf("fetch message %s: %w", params.MessageID, err)
+		}
+		target = fetched
+	case tc.Message.ReferencedMessage != nil:
+		target = tc.Message.ReferencedMessage
+	}
+
+	content, _ := hasher.FindSnails(tc.Message.GuildID, target, hasher.HashOptions{Threshold: 8})
+	if content == "" {
+		return "No snails found in this message.", nil
+	}
+	return content, nil
+}
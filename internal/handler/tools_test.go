@@ -0,0 +1,189 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	openaiapi "voltgpt/internal/apis/openai"
+	"voltgpt/internal/db"
+	"voltgpt/internal/permissions"
+	"voltgpt/internal/reminder"
+	"voltgpt/internal/settings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func setupReminderDB(t *testing.T) {
+	t.Helper()
+
+	db.Open(":memory:")
+	reminder.Init(db.DB, nil)
+	t.Cleanup(func() {
+		reminders, _ := reminder.GetUserReminders("user-1")
+		for _, r := range reminders {
+			reminder.Delete(r.ID)
+		}
+		db.Close()
+	})
+}
+
+func toolMessage() openaiapi.ToolContext {
+	return openaiapi.ToolContext{Message: &discordgo.Message{
+		ChannelID: "chan-1",
+		GuildID:   "guild-1",
+		Author:    &discordgo.User{ID: "user-1", Username: "alice"},
+	}}
+}
+
+func TestReminderTools(t *testing.T) {
+	setupReminderDB(t)
+	tc := toolMessage()
+	ctx := context.Background()
+
+	out, err := createReminderTool(ctx, tc, json.RawMessage(`{"when":"in 2h","message":"stretch"}`))
+	if err != nil {
+		t.Fatalf("createReminderTool() error = %v", err)
+	}
+	if !strings.Contains(out, `"scheduled":true`) {
+		t.Errorf("createReminderTool() = %s, want scheduled", out)
+	}
+
+	out, err = listRemindersTool(ctx, tc, json.RawMessage(`{}`))
+	if err != nil {
+		t.Fatalf("listRemindersTool() error = %v", err)
+	}
+	var listed struct {
+		Reminders []toolReminder `json:"reminders"`
+	}
+	if err := json.Unmarshal([]byte(out), &listed); err != nil {
+		t.Fatalf("unmarshal list output: %v", err)
+	}
+	if len(listed.Reminders) != 1 || listed.Reminders[0].Message != "stretch" {
+		t.Fatalf("listed reminders = %+v, want one 'stretch'", listed.Reminders)
+	}
+
+	other := toolMessage()
+	other.Message.Author = &discordgo.User{ID: "user-2"}
+	args, _ := json.Marshal(map[string]int64{"id": listed.Reminders[0].ID})
+	if _, err := cancelReminderTool(ctx, other, args); err == nil {
+		t.Error("cancelReminderTool() by another user succeeded, want error")
+	}
+
+	out, err = cancelReminderTool(ctx, tc, args)
+	if err != nil {
+		t.Fatalf("cancelReminderTool() error = %v", err)
+	}
+	if out != `{"cancelled":true}` {
+		t.Errorf("cancelReminderTool() = %s, want cancelled", out)
+	}
+}
+
+func TestCreateReminderToolRejectsBadTime(t *testing.T) {
+	setupReminderDB(t)
+
+	_, err := createReminderTool(context.Background(), toolMessage(), json.RawMessage(`{"when":"tomorrowish","message":"x"}`))
+	if err == nil {
+		t.Error("createReminderTool() error = nil, want parse error")
+	}
+}
+
+func TestResolveToolUser(t *testing.T) {
+	author := &discordgo.User{ID: "1", Username: "alice"}
+	bob := &discordgo.User{ID: "2", Username: "bob", GlobalName: "Bobby"}
+	m := &discordgo.Message{Author: author, Mentions: []*discordgo.User{bob}}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "", want: "1"},
+		{query: "Bobby", want: "2"},
+		{query: "BOB", want: "2"},
+		{query: "<@2>", want: "2"},
+		{query: "<@!2>", want: "2"},
+		{query: "123456789012345678", want: ""},
+		{query: "carol", want: ""},
+	}
+
+	for _, tt := range tests {
+		got := resolveToolUser(m, tt.query)
+		gotID := ""
+		if got != nil {
+			gotID = got.ID
+		}
+		if gotID != tt.want {
+			t.Errorf("resolveToolUser(%q) = %q, want %q", tt.query, gotID, tt.want)
+		}
+	}
+}
+
+func TestLookupMemoryProfileToolRestrictsTargets(t *testing.T) {
+	setupReminderDB(t)
+	setupMemoryDB(t)
+	t.Setenv("LEGACY_GUILD_ID", "")
+	t.Setenv("BOT_ADMINS", "")
+	settings.Init(db.DB)
+	permissions.Init(db.DB)
+	t.Cleanup(func() {
+		settings.Init(nil)
+		permissions.Init(nil)
+	})
+	g := settings.Defaults("guild-1")
+	g.MemoryEnabled = true
+	if err := settings.Save(g); err != nil {
+		t.Fatalf("settings.Save: %v", err)
+	}
+	ctx := context.Background()
+	stranger := json.RawMessage(`{"user":"123456789012345678"}`)
+
+	tc := toolMessage()
+	tc.Message.Member = &discordgo.Member{}
+	if _, err := lookupMemoryProfileTool(ctx, tc, stranger); err == nil {
+		t.Error("lookupMemoryProfileTool() for a stranger by a non-admin succeeded, want error")
+	}
+
+	tc.Message.Mentions = []*discordgo.User{{ID: "123456789012345678", Username: "bob"}}
+	out, err := lookupMemoryProfileTool(ctx, tc, stranger)
+	if err != nil || !strings.Contains(out, "bob") {
+		t.Errorf("lookupMemoryProfileTool() for a mentioned user = %q, %v; want bob's profile", out, err)
+	}
+
+	// Message events carry the member's roles but no permission bits.
+	state := discordgo.NewState()
+	if err := state.GuildAdd(&discordgo.Guild{ID: "guild-1", OwnerID: "owner-1", Roles: []*discordgo.Role{
+		{ID: "guild-1"},
+		{ID: "role-admin", Permissions: discordgo.PermissionAdministrator},
+	}}); err != nil {
+		t.Fatalf("GuildAdd: %v", err)
+	}
+	if err := state.ChannelAdd(&discordgo.Channel{ID: "chan-1", GuildID: "guild-1"}); err != nil {
+		t.Fatalf("ChannelAdd: %v", err)
+	}
+
+	tc = toolMessage()
+	tc.Session = &discordgo.Session{State: state}
+	tc.Message.Member = &discordgo.Member{Roles: []string{"role-admin"}}
+	if _, err := lookupMemoryProfileTool(ctx, tc, stranger); err != nil {
+		t.Errorf("lookupMemoryProfileTool() for a stranger by an admin: %v", err)
+	}
+
+	tc.Message.Author = &discordgo.User{ID: "owner-1", Username: "owner"}
+	tc.Message.Member = &discordgo.Member{}
+	if _, err := lookupMemoryProfileTool(ctx, tc, stranger); err != nil {
+		t.Errorf("lookupMemoryProfileTool() for a stranger by the owner: %v", err)
+	}
+}
+
+func TestEmbedToText(t *testing.T) {
+	got := embedToText(&discordgo.MessageEmbed{
+		Title:  "Round 1",
+		Fields: []*discordgo.MessageEmbedField{{Name: "Players", Value: "alice\nbob"}},
+		Footer: &discordgo.MessageEmbedFooter{Text: "2 players"},
+	})
+	want := "Round 1\nPlayers:\nalice\nbob\n2 players"
+	if got != want {
+		t.Errorf("embedToText() = %q, want %q", got, want)
+	}
+}
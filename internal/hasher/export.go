@@ -0,0 +1,498 @@
+package hasher
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/corona10/goimagehash"
+)
+
+// Export formats.
+const (
+	FormatJSONL = "jsonl"
+	FormatCSV   = "csv"
+)
+
+// exportHeader is the CSV header. Files with only its first
+// legacyExportColumns columns, written before variants and repost links were
+// exported, still import.
+var exportHeader = []string{"hash", "guild_id", "channel_id", "message_id", "author_id", "author_name", "timestamp",
+	"canonical_hash", algoDifference, algoPerception, algoAverageMirror}
+
+const legacyExportColumns = 7
+
+// ExportedHash is one stored image or video in an export. Videos carry their
+// fingerprint in Video and have no Hash.
+type ExportedHash struct {
+	Hash       string    `json:"hash,omitempty"`
+	GuildID    string    `json:"guild_id"`
+	ChannelID  string    `json:"channel_id"`
+	MessageID  string    `json:"message_id"`
+	AuthorID   string    `json:"author_id"`
+	AuthorName string    `json:"author_name"`
+	Timestamp  time.Time `json:"timestamp"`
+	// Canonical is the stored image or video key this one is a repost of, if
+	// any.
+	Canonical string `json:"canonical_hash,omitempty"`
+	// Variants holds the image's secondary hashes keyed by algorithm.
+	Variants map[string]string `json:"variants,omitempty"`
+	Video    *ExportedVideo    `json:"video,omitempty"`
+}
+
+// ExportedVideo is a stored video's frame hashes, sampled every Step seconds.
+type ExportedVideo struct {
+	Key      string   `json:"key"`
+	Duration float64  `json:"duration"`
+	Step     float64  `json:"step"`
+	Frames   []string `json:"frames"`
+}
+
+func (h ExportedHash) message() *discordgo.Message {
+	return &discordgo.Message{
+		ID:        h.MessageID,
+		ChannelID: h.ChannelID,
+		GuildID:   h.GuildID,
+		Timestamp: h.Timestamp,
+		Author:    &discordgo.User{ID: h.AuthorID, Username: h.AuthorName},
+	}
+}
+
+// storedMessage is the part of a message kept with its hash: what
+// utility.MessageToEmbeds and FindSnails show. Its JSON uses discordgo's field
+// names, so stored rows unmarshal into a discordgo.Message.
+type storedMessage struct {
+	ID          string                    `json:"id"`
+	ChannelID   string                    `json:"channel_id"`
+	GuildID     string                    `json:"guild_id,omitempty"`
+	Content     string                    `json:"content,omitempty"`
+	Timestamp   time.Time                 `json:"timestamp"`
+	Author      *storedAuthor             `json:"author,omitempty"`
+	Attachments []storedAttachment        `json:"attachments,omitempty"`
+	Embeds      []*discordgo.MessageEmbed `json:"embeds,omitempty"`
+}
+
+type storedAuthor struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Avatar   string `json:"avatar,omitempty"`
+}
+
+type storedAttachment struct {
+	ID       string `json:"id,omitempty"`
+	URL      string `json:"url"`
+	Filename string `json:"filename,omitempty"`
+}
+
+func compactMessage(m *discordgo.Message) storedMessage {
+	sm := storedMessage{
+		ID:        m.ID,
+		ChannelID: m.ChannelID,
+		GuildID:   m.GuildID,
+		Content:   m.Content,
+		Timestamp: m.Timestamp,
+		Embeds:    m.Embeds,
+	}
+	if m.Author != nil {
+		sm.Author = &storedAuthor{ID: m.Author.ID, Username: m.Author.Username, Avatar: m.Author.Avatar}
+	}
+	for _, a := range m.Attachments {
+		sm.Attachments = append(sm.Attachments, storedAttachment{ID: a.ID, URL: a.URL, Filename: a.Filename})
+	}
+	return sm
+}
+
+func marshalMessage(m *discordgo.Message) (string, error) {
+	data, err := json.Marshal(compactMessage(m))
+	return string(data), err
+}
+
+// Export writes the guild's stored image hashes, or every guild's when
+// guildID is empty, in format. Images keep their secondary hashes and repost
+// links. JSONL also holds the video fingerprints; CSV has one image per row
+// and leaves videos out. It returns how many were written.
+func Export(w io.Writer, format, guildID string) (int, error) {
+	var write func(ExportedHash) error
+	var flush func() error
+	switch format {
+	case FormatJSONL:
+		buf := bufio.NewWriter(w)
+		enc := json.NewEncoder(buf)
+		write = func(h ExportedHash) error { return enc.Encode(h) }
+		flush = buf.Flush
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(exportHeader); err != nil {
+			return 0, err
+		}
+		write = func(h ExportedHash) error {
+			return cw.Write([]string{h.Hash, h.GuildID, h.ChannelID, h.MessageID, h.AuthorID, h.AuthorName, h.Timestamp.Format(time.RFC3339),
+				h.Canonical, h.Variants[algoDifference], h.Variants[algoPerception], h.Variants[algoAverageMirror]})
+		}
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	default:
+		return 0, fmt.Errorf("unknown export format %q", format)
+	}
+
+	n, err := exportImages(write, guildID)
+	if err != nil {
+		return n, err
+	}
+	if format == FormatJSONL {
+		videos, err := exportVideos(write, guildID)
+		n += videos
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, flush()
+}
+
+func exportImages(write func(ExportedHash) error, guildID string) (int, error) {
+	query := `SELECT hash, guild_id, channel_id,
+			COALESCE(json_extract(message_json, '$.id'), ''),
+			COALESCE(json_extract(message_json, '$.author.id'), ''),
+			COALESCE(json_extract(message_json, '$.author.username'), ''),
+			COALESCE(json_extract(message_json, '$.timestamp'), ''),
+			canonical_hash,
+			(SELECT json_group_object(algorithm, value) FROM image_hash_variants v
+				WHERE v.guild_id = image_hashes.guild_id AND v.hash = image_hashes.hash)
+		FROM image_hashes`
+	var args []any
+	if guildID != "" {
+		query += " WHERE guild_id = ?"
+		args = append(args, guildID)
+	}
+	query += " ORDER BY guild_id, hash"
+
+	rows, err := database.Query(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	n := 0
+	for rows.Next() {
+		var h ExportedHash
+		var timestamp, variants string
+		if err := rows.Scan(&h.Hash, &h.GuildID, &h.ChannelID, &h.MessageID, &h.AuthorID, &h.AuthorName, &timestamp, &h.Canonical, &variants); err != nil {
+			return n, err
+		}
+		if t, err := time.Parse(time.RFC3339Nano, timestamp); err == nil {
+			h.Timestamp = t.UTC()
+		}
+		if err := json.Unmarshal([]byte(variants), &h.Variants); err != nil {
+			return n, fmt.Errorf("hash %s: %w", h.Hash, err)
+		}
+		if len(h.Variants) == 0 {
+			h.Variants = nil
+		}
+		if err := write(h); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, rows.Err()
+}
+
+func exportVideos(write func(ExportedHash) error, guildID string) (int, error) {
+	query := `SELECT key, guild_id, channel_id, duration, step, frames, canonical_key,
+			COALESCE(json_extract(message_json, '$.id'), ''),
+			COALESCE(json_extract(message_json, '$.author.id'), ''),
+			COALESCE(json_extract(message_json, '$.author.username'), ''),
+			COALESCE(json_extract(message_json, '$.timestamp'), '')
+		FROM video_hashes`
+	var args []any
+	if guildID != "" {
+		query += " WHERE guild_id = ?"
+		args = append(args, guildID)
+	}
+	query += " ORDER BY guild_id, key"
+
+	rows, err := database.Query(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	n := 0
+	for rows.Next() {
+		h := ExportedHash{Video: &ExportedVideo{}}
+		var frames, timestamp string
+		if err := rows.Scan(&h.Video.Key, &h.GuildID, &h.ChannelID, &h.Video.Duration, &h.Video.Step, &frames, &h.Canonical,
+			&h.MessageID, &h.AuthorID, &h.AuthorName, &timestamp); err != nil {
+			return n, err
+		}
+		if t, err := time.Parse(time.RFC3339Nano, timestamp); err == nil {
+			h.Timestamp = t.UTC()
+		}
+		if err := json.Unmarshal([]byte(frames), &h.Video.Frames); err != nil {
+			return n, fmt.Errorf("video %s: %w", h.Video.Key, err)
+		}
+		if err := write(h); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, rows.Err()
+}
+
+// Import adds the hashes read from r in format. Where a guild already has a
+// hash, the older post is kept, as when hashing, along with any repost link
+// the existing row has; every imported image post is also recorded as a
+// sighting. Videos already stored are left as they are. It returns how many
+// hashes were stored.
+func Import(r io.Reader, format string) (int, error) {
+	var next func() (ExportedHash, error)
+	switch format {
+	case FormatJSONL:
+		dec := json.NewDecoder(r)
+		next = func() (ExportedHash, error) {
+			var h ExportedHash
+			err := dec.Decode(&h)
+			return h, err
+		}
+	case FormatCSV:
+		cr := csv.NewReader(r)
+		header, err := cr.Read()
+		if err != nil {
+			return 0, fmt.Errorf("failed to read CSV header: %w", err)
+		}
+		if strings.Join(header, ",") != strings.Join(exportHeader, ",") &&
+			strings.Join(header, ",") != strings.Join(exportHeader[:legacyExportColumns], ",") {
+			return 0, fmt.Errorf("unexpected CSV header %q", strings.Join(header, ","))
+		}
+		next = func() (ExportedHash, error) {
+			record, err := cr.Read()
+			if err != nil {
+				return ExportedHash{}, err
+			}
+			h := ExportedHash{Hash: record[0], GuildID: record[1], ChannelID: record[2], MessageID: record[3], AuthorID: record[4], AuthorName: record[5]}
+			if record[6] != "" {
+				if h.Timestamp, err = time.Parse(time.RFC3339, record[6]); err != nil {
+					return ExportedHash{}, fmt.Errorf("hash %s: %w", h.Hash, err)
+				}
+			}
+			if len(record) > legacyExportColumns {
+				h.Canonical = record[7]
+				for i, algo := range exportHeader[legacyExportColumns+1:] {
+					if value := record[legacyExportColumns+1+i]; value != "" {
+						if h.Variants == nil {
+							h.Variants = make(map[string]string)
+						}
+						h.Variants[algo] = value
+					}
+				}
+			}
+			return h, nil
+		}
+	default:
+		return 0, fmt.Errorf("unknown import format %q", format)
+	}
+
+	tx, err := database.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stored := 0
+	for line := 1; ; line++ {
+		h, err := next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("record %d: %w", line, err)
+		}
+		importRecord := importImage
+		if h.Video != nil {
+			importRecord = importVideo
+		}
+		ok, err := importRecord(tx, h)
+		if err != nil {
+			return 0, fmt.Errorf("record %d: %w", line, err)
+		}
+		if ok {
+			stored++
+		}
+	}
+	return stored, tx.Commit()
+}
+
+// importImage stores an exported image unless the guild has an older post of
+// it, and reports whether it did.
+func importImage(tx *sql.Tx, h ExportedHash) (bool, error) {
+	if _, err := goimagehash.ExtImageHashFromString(h.Hash); err != nil {
+		return false, fmt.Errorf("invalid hash %q: %w", h.Hash, err)
+	}
+	if h.Canonical == h.Hash {
+		h.Canonical = ""
+	}
+	if h.Canonical != "" {
+		if _, err := goimagehash.ExtImageHashFromString(h.Canonical); err != nil {
+			return false, fmt.Errorf("invalid canonical hash %q: %w", h.Canonical, err)
+		}
+	}
+	for algo, value := range h.Variants {
+		if !isVariantAlgorithm(algo) {
+			return false, fmt.Errorf("unknown hash algorithm %q", algo)
+		}
+		if _, err := goimagehash.ExtImageHashFromString(value); err != nil {
+			return false, fmt.Errorf("invalid %s hash %q: %w", algo, value, err)
+		}
+	}
+
+	msgJSON, err := marshalMessage(h.message())
+	if err != nil {
+		return false, err
+	}
+	// Keep the row that already exists unless the imported post is older,
+	// and keep its repost link either way.
+	res, err := tx.Exec(
+		`INSERT INTO image_hashes (guild_id, hash, channel_id, message_json, canonical_hash) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(guild_id, hash) DO UPDATE SET channel_id = excluded.channel_id, message_json = excluded.message_json,
+			canonical_hash = CASE image_hashes.canonical_hash WHEN '' THEN excluded.canonical_hash ELSE image_hashes.canonical_hash END
+		WHERE unixepoch(json_extract(image_hashes.message_json, '$.timestamp')) > ?`,
+		h.GuildID, h.Hash, h.ChannelID, msgJSON, h.Canonical, h.Timestamp.Unix(),
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	for algo, value := range h.Variants {
+		_, err := tx.Exec(
+			"INSERT OR IGNORE INTO image_hash_variants (guild_id, hash, algorithm, value) VALUES (?, ?, ?, ?)",
+			h.GuildID, h.Hash, algo, value,
+		)
+		if err != nil {
+			return false, err
+		}
+	}
+	_, err = tx.Exec(
+		`INSERT OR IGNORE INTO image_hash_sightings
+			(guild_id, hash, message_id, channel_id, author_id, author_name, posted_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		h.GuildID, h.Hash, h.MessageID, h.ChannelID, h.AuthorID, h.AuthorName, h.Timestamp.Unix(),
+	)
+	return n > 0, err
+}
+
+// isVariantAlgorithm reports whether name is one of the secondary hash
+// algorithms kept in image_hash_variants.
+func isVariantAlgorithm(name string) bool {
+	for _, algo := range algorithms {
+		if algo.name == name {
+			return name != algoAverage
+		}
+	}
+	return false
+}
+
+// importVideo stores an exported video unless one with its key is already
+// stored, and reports whether it did.
+func importVideo(tx *sql.Tx, h ExportedHash) (bool, error) {
+	v := h.Video
+	if v.Key == "" || len(v.Frames) == 0 || v.Step <= 0 {
+		return false, fmt.Errorf("incomplete video %q", v.Key)
+	}
+	for _, f := range v.Frames {
+		if _, err := goimagehash.ExtImageHashFromString(f); err != nil {
+			return false, fmt.Errorf("video %s: invalid frame hash %q: %w", v.Key, f, err)
+		}
+	}
+	framesJSON, err := json.Marshal(v.Frames)
+	if err != nil {
+		return false, err
+	}
+	msgJSON, err := marshalMessage(h.message())
+	if err != nil {
+		return false, err
+	}
+	if h.Canonical == v.Key {
+		h.Canonical = ""
+	}
+	res, err := tx.Exec(
+		"INSERT OR IGNORE INTO video_hashes (key, guild_id, channel_id, duration, step, frames, message_json, canonical_key) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		v.Key, h.GuildID, h.ChannelID, v.Duration, v.Step, string(framesJSON), msgJSON, h.Canonical,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// Compact rewrites the message JSON stored with image and video hashes down
+// to the fields storedMessage keeps. It returns how many rows changed and the
+// bytes saved.
+func Compact() (int, int64, error) {
+	rows, saved := 0, int64(0)
+	for _, table := range []string{"image_hashes", "video_hashes"} {
+		n, s, err := compactTable(table)
+		if err != nil {
+			return rows, saved, fmt.Errorf("%s: %w", table, err)
+		}
+		rows += n
+		saved += s
+	}
+	return rows, saved, nil
+}
+
+func compactTable(table string) (int, int64, error) {
+	tx, err := database.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("SELECT rowid, message_json FROM " + table)
+	if err != nil {
+		return 0, 0, err
+	}
+	type update struct {
+		rowid int64
+		json  string
+	}
+	var updates []update
+	var saved int64
+	for rows.Next() {
+		var rowid int64
+		var msgJSON string
+		if err := rows.Scan(&rowid, &msgJSON); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		var msg discordgo.Message
+		if err := json.Unmarshal([]byte(msgJSON), &msg); err != nil {
+			continue
+		}
+		compact, err := marshalMessage(&msg)
+		if err != nil || len(compact) >= len(msgJSON) {
+			continue
+		}
+		saved += int64(len(msgJSON) - len(compact))
+		updates = append(updates, update{rowid, compact})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	for _, u := range updates {
+		if _, err := tx.Exec("UPDATE "+table+" SET message_json = ? WHERE rowid = ?", u.json, u.rowid); err != nil {
+			return 0, 0, err
+		}
+	}
+	return len(updates), saved, tx.Commit()
+}
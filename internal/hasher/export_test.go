@@ -0,0 +1,177 @@
+package hasher
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/rand/v2"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/corona10/goimagehash"
+	"voltgpt/internal/db"
+)
+
+const exportTestHash = "a:f000f003f0fff0fff003000300ff00ff0003000300ff00ff0003000300ff00ff"
+
+func exportTestMessage(id, author string, day int) *discordgo.Message {
+	return &discordgo.Message{
+		ID:        id,
+		GuildID:   "g",
+		ChannelID: "ch",
+		Content:   "look at this",
+		Timestamp: time.Date(2024, 5, day, 12, 0, 0, 0, time.UTC),
+		Author:    &discordgo.User{ID: author + "-id", Username: author, Avatar: "abc", Bot: true},
+		Mentions:  []*discordgo.User{{ID: "x", Username: "someone"}},
+		Embeds:    []*discordgo.MessageEmbed{{Title: "preview"}},
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	for _, format := range []string{FormatJSONL, FormatCSV} {
+		t.Run(format, func(t *testing.T) {
+			setupHasherWithDB(t)
+			writeHashToDB("g", exportTestHash, exportTestMessage("10", "alice", 2))
+			writeHashToDB("other", exportTestHash, exportTestMessage("11", "bob", 3))
+
+			var buf bytes.Buffer
+			n, err := Export(&buf, format, "g")
+			if err != nil || n != 1 {
+				t.Fatalf("Export = %d, %v; want 1 hash", n, err)
+			}
+			exported := buf.String()
+
+			// Import into a fresh instance that has a newer post of the hash.
+			db.Open(":memory:")
+			database = db.DB
+			writeHashToDB("g", exportTestHash, exportTestMessage("20", "carol", 9))
+
+			n, err = Import(strings.NewReader(exported), format)
+			if err != nil || n != 1 {
+				t.Fatalf("Import = %d, %v; want 1 hash stored", n, err)
+			}
+			msg, err := readHashFromDB("g", exportTestHash)
+			if err != nil {
+				t.Fatalf("readHashFromDB: %v", err)
+			}
+			if msg.ID != "10" || msg.Author.Username != "alice" || !msg.Timestamp.Equal(time.Date(2024, 5, 2, 12, 0, 0, 0, time.UTC)) {
+				t.Errorf("stored message = %+v, want alice's older post", msg)
+			}
+			if sightings, _ := Sightings("g", exportTestHash); len(sightings) != 1 || sightings[0].AuthorName != "alice" {
+				t.Errorf("Sightings() = %+v, want the imported post", sightings)
+			}
+
+			// Importing again changes nothing.
+			if n, err := Import(strings.NewReader(exported), format); err != nil || n != 0 {
+				t.Errorf("second Import = %d, %v; want 0 stored", n, err)
+			}
+		})
+	}
+}
+
+func TestExportKeepsVariantsLinksAndVideos(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 19))
+	repost, variant, frame := randomHash(r).ToString(), randomHash(r).ToString(), randomHash(r).ToString()
+
+	for _, format := range []string{FormatJSONL, FormatCSV} {
+		t.Run(format, func(t *testing.T) {
+			setupHasherWithDB(t)
+			writeHashToDB("g", exportTestHash, exportTestMessage("10", "alice", 2))
+			writeHashToDB("g", repost, exportTestMessage("11", "bob", 3))
+			writeVariantsToDB("g", repost, fingerprint{algoDifference: stringToHash(variant)})
+			linkCanonical("g", repost, exportTestHash)
+			writeVideo("g", videoFingerprint{key: "12/clip.mp4", duration: 3, step: 1, canonical: "9/orig.mp4",
+				frames: []*goimagehash.ExtImageHash{stringToHash(frame)}}, exportTestMessage("12", "carol", 4))
+
+			var buf bytes.Buffer
+			if _, err := Export(&buf, format, "g"); err != nil {
+				t.Fatalf("Export: %v", err)
+			}
+
+			db.Open(":memory:")
+			database = db.DB
+			resetStore(t)
+			if _, err := Import(&buf, format); err != nil {
+				t.Fatalf("Import: %v", err)
+			}
+
+			if fp := readFingerprintFromDB("g", repost); fp[algoDifference] == nil || fp[algoDifference].ToString() != variant {
+				t.Errorf("imported fingerprint = %v, want the %s variant", fp, algoDifference)
+			}
+			var canonical string
+			database.QueryRow("SELECT canonical_hash FROM image_hashes WHERE hash = ?", repost).Scan(&canonical)
+			if canonical != exportTestHash {
+				t.Errorf("imported canonical_hash = %q, want %q", canonical, exportTestHash)
+			}
+
+			// CSV holds images only.
+			wantVideos := 0
+			if format == FormatJSONL {
+				wantVideos = 1
+			}
+			loadVideosFromDB()
+			if n := TotalVideoHashes(); n != wantVideos {
+				t.Errorf("TotalVideoHashes = %d after import, want %d", n, wantVideos)
+			}
+			if wantVideos > 0 {
+				database.QueryRow("SELECT canonical_key FROM video_hashes WHERE key = ?", "12/clip.mp4").Scan(&canonical)
+				if canonical != "9/orig.mp4" {
+					t.Errorf("imported canonical_key = %q, want 9/orig.mp4", canonical)
+				}
+			}
+		})
+	}
+}
+
+func TestImportRejectsBadInput(t *testing.T) {
+	setupHasherWithDB(t)
+
+	if _, err := Import(strings.NewReader(`{"hash":"nope","guild_id":"g"}`+"\n"), FormatJSONL); err == nil {
+		t.Error("Import of an invalid hash succeeded")
+	}
+	if _, err := Import(strings.NewReader("a,b\n"), FormatCSV); err == nil {
+		t.Error("Import with a wrong CSV header succeeded")
+	}
+	if _, err := Import(strings.NewReader(""), "xml"); err == nil {
+		t.Error("Import in an unknown format succeeded")
+	}
+	if n := TotalHashes(); n != 0 {
+		t.Errorf("TotalHashes = %d after failed imports, want 0", n)
+	}
+}
+
+func TestCompactKeepsWhatEmbedsNeed(t *testing.T) {
+	setupHasherWithDB(t)
+
+	full, err := json.Marshal(exportTestMessage("10", "alice", 2))
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if _, err := database.Exec("INSERT INTO image_hashes (guild_id, hash, channel_id, message_json) VALUES ('g', ?, 'ch', ?)", exportTestHash, string(full)); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	rows, saved, err := Compact()
+	if err != nil || rows != 1 || saved <= 0 {
+		t.Fatalf("Compact = %d rows, %d bytes, %v; want one row shrunk", rows, saved, err)
+	}
+	var compacted string
+	database.QueryRow("SELECT message_json FROM image_hashes").Scan(&compacted)
+	if strings.Contains(compacted, "mentions") || strings.Contains(compacted, "null") {
+		t.Errorf("compacted JSON = %s, want unused fields dropped", compacted)
+	}
+
+	msg, err := readHashFromDB("g", exportTestHash)
+	if err != nil {
+		t.Fatalf("readHashFromDB: %v", err)
+	}
+	if msg.ID != "10" || msg.ChannelID != "ch" || msg.Content != "look at this" || msg.Author.Avatar != "abc" ||
+		len(msg.Embeds) != 1 || !msg.Timestamp.Equal(time.Date(2024, 5, 2, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("compacted message = %+v, want the fields MessageToEmbeds reads", msg)
+	}
+
+	if rows, _, _ := Compact(); rows != 0 {
+		t.Errorf("second Compact changed %d rows, want 0", rows)
+	}
+}
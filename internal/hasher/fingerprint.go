@@ -0,0 +1,148 @@
+package hasher
+
+import (
+	"image"
+	"image/color"
+	"log"
+
+	"github.com/corona10/goimagehash"
+)
+
+// Perceptual hash algorithms stored per attachment. The average hash is the
+// image_hashes key; the others live in image_hash_variants.
+const (
+	algoAverage       = "average"
+	algoDifference    = "difference"
+	algoPerception    = "perception"
+	algoAverageMirror = "average_mirror"
+)
+
+// unrelatedDistance is the hash distance at which two images are treated as
+// unrelated. Random 256-bit hashes sit around 128 bits apart; near-duplicates
+// rarely get past a quarter of the bits.
+const unrelatedDistance = hashWords * 64 / 4
+
+var algorithms = []struct {
+	name    string
+	compute func(image.Image) (*goimagehash.ExtImageHash, error)
+}{
+	{algoAverage, func(img image.Image) (*goimagehash.ExtImageHash, error) {
+		return goimagehash.ExtAverageHash(img, hashWidth, hashHeight)
+	}},
+	{algoDifference, func(img image.Image) (*goimagehash.ExtImageHash, error) {
+		return goimagehash.ExtDifferenceHash(img, hashWidth, hashHeight)
+	}},
+	{algoPerception, func(img image.Image) (*goimagehash.ExtImageHash, error) {
+		return goimagehash.ExtPerceptionHash(img, hashWidth, hashHeight)
+	}},
+	{algoAverageMirror, func(img image.Image) (*goimagehash.ExtImageHash, error) {
+		return goimagehash.ExtAverageHash(mirroredImage{img}, hashWidth, hashHeight)
+	}},
+}
+
+// searchPaths pairs a query hash with the stored index it is searched in. A
+// mirrored repost's average hash lands near the original's mirrored hash.
+var searchPaths = []struct {
+	query, stored string
+}{
+	{algoAverage, algoAverage},
+	{algoDifference, algoDifference},
+	{algoPerception, algoPerception},
+	{algoAverage, algoAverageMirror},
+}
+
+// fingerprint holds every perceptual hash of one image, keyed by algorithm.
+type fingerprint map[string]*goimagehash.ExtImageHash
+
+// key is the average hash string the image is stored under.
+func (f fingerprint) key() string {
+	return f[algoAverage].ToString()
+}
+
+func newHashIndexes() map[string]*hashIndex {
+	indexes := make(map[string]*hashIndex, len(algorithms))
+	for _, algo := range algorithms {
+		indexes[algo.name] = newHashIndex(hashWords)
+	}
+	return indexes
+}
+
+// computeFingerprint hashes img with every algorithm. Only the average hash is
+// required; a failing secondary algorithm is logged and left out.
+func computeFingerprint(img image.Image) (fingerprint, error) {
+	fp := make(fingerprint, len(algorithms))
+	for _, algo := range algorithms {
+		h, err := algo.compute(img)
+		if err != nil {
+			if algo.name == algoAverage {
+				return nil, err
+			}
+			log.Printf("%s hash error: %v", algo.name, err)
+			continue
+		}
+		fp[algo.name] = h
+	}
+	return fp, nil
+}
+
+// similarity is how closely a stored image matches a query image.
+type similarity struct {
+	distance   int
+	confidence float64
+	mirrored   bool
+}
+
+// compareFingerprints scores stored against query. The direct score averages
+// the per-algorithm similarity over the hashes both sides have; the mirrored
+// score compares the query against the stored image flipped. The better of
+// the two wins.
+func compareFingerprints(query, stored fingerprint) similarity {
+	var best similarity
+	var total float64
+	var n int
+	for _, algo := range []string{algoAverage, algoDifference, algoPerception} {
+		q, s := query[algo], stored[algo]
+		if q == nil || s == nil {
+			continue
+		}
+		d := hammingDistance(q.GetHash(), s.GetHash())
+		if algo == algoAverage {
+			best.distance = d
+		}
+		total += hashSimilarity(d)
+		n++
+	}
+	if n > 0 {
+		best.confidence = total / float64(n)
+	}
+
+	if q, s := query[algoAverage], stored[algoAverageMirror]; q != nil && s != nil {
+		d := hammingDistance(q.GetHash(), s.GetHash())
+		if c := hashSimilarity(d); c > best.confidence {
+			best = similarity{distance: d, confidence: c, mirrored: true}
+		}
+	}
+	return best
+}
+
+// minConfidence is the lowest combined confidence reported for a search
+// radius. A candidate found through one algorithm must also look alike across
+// the others; hashes averaging more than twice the radius apart are a
+// coincidence in one algorithm rather than a repost.
+func minConfidence(threshold int) float64 {
+	return hashSimilarity(2 * threshold)
+}
+
+func hashSimilarity(distance int) float64 {
+	return max(0, 1-float64(distance)/unrelatedDistance)
+}
+
+// mirroredImage flips an image horizontally without copying its pixels.
+type mirroredImage struct {
+	image.Image
+}
+
+func (m mirroredImage) At(x, y int) color.Color {
+	b := m.Bounds()
+	return m.Image.At(b.Max.X-1-(x-b.Min.X), y)
+}
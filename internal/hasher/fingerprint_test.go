@@ -0,0 +1,157 @@
+package hasher
+
+import (
+	"image"
+	"image/color"
+	"math/rand/v2"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// makePatternImage returns an asymmetric test image: a horizontal gradient
+// with a bright block in the top-left corner, so mirroring changes its hashes.
+func makePatternImage(w, h int, tint func(c color.RGBA) color.RGBA) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := uint8(x * 200 / w)
+			if x < w/4 && y < h/3 {
+				v = 250
+			}
+			if (y/8)%2 == 0 && x > w/2 {
+				v /= 2
+			}
+			c := color.RGBA{R: v, G: v, B: v, A: 255}
+			if tint != nil {
+				c = tint(c)
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func mirror(img image.Image) image.Image {
+	b := img.Bounds()
+	out := image.NewRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			out.Set(x, y, mirroredImage{img}.At(x, y))
+		}
+	}
+	return out
+}
+
+func TestComputeFingerprint(t *testing.T) {
+	fp, err := computeFingerprint(makePatternImage(64, 64, nil))
+	if err != nil {
+		t.Fatalf("computeFingerprint: %v", err)
+	}
+	for _, algo := range algorithms {
+		if h := fp[algo.name]; h == nil || len(h.GetHash()) != hashWords {
+			t.Errorf("%s hash = %v, want %d words", algo.name, h, hashWords)
+		}
+	}
+	if fp.key() != fp[algoAverage].ToString() {
+		t.Errorf("key() = %q, want the average hash", fp.key())
+	}
+}
+
+func TestCompareFingerprints(t *testing.T) {
+	original, _ := computeFingerprint(makePatternImage(64, 64, nil))
+
+	if sim := compareFingerprints(original, original); sim.distance != 0 || sim.confidence != 1 || sim.mirrored {
+		t.Errorf("identical = %+v, want distance 0 and full confidence", sim)
+	}
+
+	flipped, _ := computeFingerprint(mirror(makePatternImage(64, 64, nil)))
+	sim := compareFingerprints(flipped, original)
+	if !sim.mirrored || sim.confidence < 0.8 {
+		t.Errorf("mirrored = %+v, want a confident mirrored match", sim)
+	}
+
+	// Only the average hash is known for images stored before the other algorithms.
+	legacy := fingerprint{algoAverage: original[algoAverage]}
+	if sim := compareFingerprints(original, legacy); sim.confidence != 1 {
+		t.Errorf("legacy = %+v, want full confidence from the average hash", sim)
+	}
+}
+
+func TestHashSimilarity(t *testing.T) {
+	if got := hashSimilarity(0); got != 1 {
+		t.Errorf("hashSimilarity(0) = %v, want 1", got)
+	}
+	if got := hashSimilarity(unrelatedDistance * 2); got != 0 {
+		t.Errorf("hashSimilarity(far) = %v, want 0", got)
+	}
+}
+
+func TestFindSnailsCatchesMirroredRepost(t *testing.T) {
+	setupHasherWithDB(t)
+
+	original := servePNG(t, makePatternImage(64, 64, nil))
+	defer original.Close()
+	repost := servePNG(t, mirror(makePatternImage(64, 64, func(c color.RGBA) color.RGBA {
+		c.R = uint8(min(255, int(c.R)+30)) // warm filter
+		return c
+	})))
+	defer repost.Close()
+
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	first := &discordgo.Message{
+		ID:          "first",
+		ChannelID:   "ch",
+		GuildID:     "guild",
+		Timestamp:   base,
+		Author:      &discordgo.User{Username: "alice"},
+		Attachments: []*discordgo.MessageAttachment{{URL: original.URL + "/a.png", Width: 64, Height: 64}},
+	}
+	HashAttachments(first, HashOptions{Store: true})
+
+	second := &discordgo.Message{
+		ID:          "second",
+		ChannelID:   "ch",
+		Timestamp:   base.Add(time.Hour),
+		Author:      &discordgo.User{Username: "bob"},
+		Attachments: []*discordgo.MessageAttachment{{URL: repost.URL + "/b.png", Width: 64, Height: 64}},
+	}
+	content, embeds := FindSnails("guild", second, HashOptions{Threshold: 8})
+	if len(embeds) == 0 || !strings.Contains(content, "Snail of alice") || !strings.Contains(content, "mirrored") {
+		t.Errorf("FindSnails() = %q, want a mirrored snail of alice", content)
+	}
+}
+
+func TestFindSnailsDropsSingleAlgorithmCoincidence(t *testing.T) {
+	setupHasherWithDB(t)
+
+	img := makePatternImage(64, 64, nil)
+	srv := servePNG(t, img)
+	defer srv.Close()
+	query, _ := computeFingerprint(img)
+
+	// The stored image shares the query's perception hash but nothing else.
+	r := rand.New(rand.NewPCG(1, 2))
+	stored := fingerprint{
+		algoAverage:       flipBits(r, query[algoAverage], 100),
+		algoDifference:    flipBits(r, query[algoDifference], 100),
+		algoPerception:    query[algoPerception],
+		algoAverageMirror: flipBits(r, query[algoAverageMirror], 100),
+	}
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	writeHash("guild", stored.key(), &discordgo.Message{ID: "first", ChannelID: "ch", Timestamp: base, Author: &discordgo.User{Username: "alice"}})
+	writeVariants("guild", stored.key(), stored)
+
+	repost := &discordgo.Message{
+		ID:          "second",
+		ChannelID:   "ch",
+		Timestamp:   base.Add(time.Hour),
+		Author:      &discordgo.User{Username: "bob"},
+		Attachments: []*discordgo.MessageAttachment{{URL: srv.URL + "/b.png", Width: 64, Height: 64}},
+	}
+	if content, _ := FindSnails("guild", repost, HashOptions{Threshold: 8}); content != "" {
+		t.Errorf("FindSnails() = %q, want no snail from one matching algorithm", content)
+	}
+}
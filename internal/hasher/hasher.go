@@ -3,7 +3,6 @@ package hasher
 
 import (
 	"bytes"
-	"crypto/tls"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -13,10 +12,10 @@ import (
 	_ "image/gif"
 	_ "image/jpeg"
 	_ "image/png"
-	"io"
 	"log"
-	"net/http"
+	"slices"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -25,8 +24,6 @@ import (
 	// Import image decoder packages for their side effects: registering decoder for webp formats.
 	_ "golang.org/x/image/webp"
 
-	ffmpeg "github.com/u2takey/ffmpeg-go"
-
 	"github.com/bwmarrin/discordgo"
 	"github.com/corona10/goimagehash"
 )
@@ -37,34 +34,125 @@ var database *sql.DB
 // Full message JSON is kept in SQLite and fetched on demand when a match is found.
 type hashEntry struct {
 	messageID string
+	channelID string
 	timestamp time.Time
+	// canonical is the stored image this one is a repost of, empty when it
+	// is the first of its sightings.
+	canonical string
+}
+
+const (
+	hashWidth  = 16
+	hashHeight = 16
+	// hashWords is the number of uint64 words in a stored ExtAverageHash.
+	hashWords = hashWidth * hashHeight / 64
+)
+
+// namespace holds one guild's image and video hashes with their indexes.
+// Snails are only matched within a namespace, so a meme posted in two servers
+// isn't flagged across them.
+type namespace struct {
+	images     map[string]hashEntry
+	indexes    map[string]*hashIndex
+	videos     map[string]videoFingerprint
+	videoIndex *hashIndex
 }
 
+func newNamespace() *namespace {
+	return &namespace{
+		images:     make(map[string]hashEntry),
+		indexes:    newHashIndexes(),
+		videos:     make(map[string]videoFingerprint),
+		videoIndex: newHashIndex(hashWords),
+	}
+}
+
+// hashStore maps each guild ID to its namespace.
 var hashStore = struct {
 	sync.RWMutex
-	m map[string]hashEntry
+	guilds map[string]*namespace
 }{
-	m: make(map[string]hashEntry),
+	guilds: make(map[string]*namespace),
+}
+
+// namespaceLocked returns the guild's namespace, creating it if needed. The
+// caller holds the write lock.
+func namespaceLocked(guildID string) *namespace {
+	ns, ok := hashStore.guilds[guildID]
+	if !ok {
+		ns = newNamespace()
+		hashStore.guilds[guildID] = ns
+	}
+	return ns
 }
 
 type hashResult struct {
-	distance int
-	message  *discordgo.Message
+	hash       string // stored image hash, empty for video matches
+	query      int    // index of the query image that matched
+	distance   int
+	confidence float64
+	mirrored   bool
+	clip       *clipMatch // set for video matches
+	message    *discordgo.Message
 }
 
 type HashOptions struct {
 	Store            bool
 	Threshold        int
 	IgnoreExtensions []string
+	// IgnoreURLs skips media whose URL contains any of these substrings, such
+	// as StickerURLs.
+	IgnoreURLs []string
+	// GuildID is the namespace hashes are stored in and matched against. It
+	// defaults to the message's guild.
+	GuildID string
+	// ChannelID, when set, limits matches to hashes from that channel.
+	ChannelID string
+	// MaxBytes, when set, caps the size of each downloaded image or video.
+	// Videos default to maxVideoBytes.
+	MaxBytes int64
+	// download, when set, replaces the media cache for fetching media.
+	download func(url string, limit int64) ([]byte, error)
+}
+
+// StickerURLs match stickers and custom emotes. They get reposted by design, so
+// they're left out of the store and of repost checks.
+var StickerURLs = []string{
+	"cdn.discordapp.com/emojis/",
+	"media.discordapp.net/stickers/",
+	"cdn.discordapp.com/stickers/",
+}
+
+func (o HashOptions) guildID(m *discordgo.Message) string {
+	if o.GuildID != "" {
+		return o.GuildID
+	}
+	return m.GuildID
+}
+
+// getFile downloads url with o.download, or through the media cache.
+func (o HashOptions) getFile(url string, limit int64) (bytes.Buffer, error) {
+	if o.download == nil {
+		return getFile(url, limit)
+	}
+	data, err := o.download(url, limit)
+	return *bytes.NewBuffer(data), err
+}
+
+func (o HashOptions) ignoresURL(url string) bool {
+	return slices.ContainsFunc(o.IgnoreURLs, func(pattern string) bool {
+		return strings.Contains(url, pattern)
+	})
 }
 
 func Init(db *sql.DB) {
 	database = db
 	loadFromDB()
+	loadVideosFromDB()
 }
 
 func loadFromDB() {
-	rows, err := database.Query("SELECT hash, message_json FROM image_hashes")
+	rows, err := database.Query("SELECT guild_id, hash, channel_id, message_json, canonical_hash FROM image_hashes")
 	if err != nil {
 		log.Fatalf("Failed to load image_hashes: %v", err)
 	}
@@ -74,8 +162,8 @@ func loadFromDB() {
 	defer hashStore.Unlock()
 
 	for rows.Next() {
-		var hash, msgJSON string
-		if err := rows.Scan(&hash, &msgJSON); err != nil {
+		var guildID, hash, channelID, msgJSON, canonical string
+		if err := rows.Scan(&guildID, &hash, &channelID, &msgJSON, &canonical); err != nil {
 			log.Printf("Failed to scan row: %v", err)
 			continue
 		}
@@ -87,31 +175,189 @@ func loadFromDB() {
 			log.Printf("Failed to unmarshal message for hash %s: %v", hash, err)
 			continue
 		}
-		hashStore.m[hash] = hashEntry{
+		ns := namespaceLocked(guildID)
+		ns.images[hash] = hashEntry{
 			messageID: msg.ID,
+			channelID: channelID,
 			timestamp: time.Time(msg.Timestamp),
+			canonical: canonical,
+		}
+		if !ns.indexes[algoAverage].add(hash, hash) {
+			log.Printf("Hash %s can't be indexed, it won't match near-duplicates", hash)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Failed to read image_hashes: %v", err)
+	}
+
+	loadVariantsLocked()
+}
+
+// loadVariantsLocked indexes the secondary hashes. The caller holds hashStore.
+func loadVariantsLocked() {
+	rows, err := database.Query("SELECT guild_id, hash, algorithm, value FROM image_hash_variants")
+	if err != nil {
+		log.Fatalf("Failed to load image_hash_variants: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var guildID, hash, algorithm, value string
+		if err := rows.Scan(&guildID, &hash, &algorithm, &value); err != nil {
+			log.Printf("Failed to scan row: %v", err)
+			continue
+		}
+		if index, ok := namespaceLocked(guildID).indexes[algorithm]; ok {
+			index.add(value, hash)
+		}
+	}
+}
+
+// ResolveGuilds moves hashes stored without a guild into the namespace of
+// their channel's guild. Hashes migrated from before namespaces existed, and
+// messages fetched over REST, carry no guild ID of their own. It reloads the
+// store when anything moved, so it must run before anything else hashes.
+func ResolveGuilds(s *discordgo.Session) {
+	resolveGuilds(func(channelID string) (string, error) {
+		if c, err := s.State.Channel(channelID); err == nil {
+			return c.GuildID, nil
+		}
+		c, err := s.Channel(channelID)
+		if err != nil {
+			return "", err
+		}
+		return c.GuildID, nil
+	})
+}
+
+func resolveGuilds(lookup func(channelID string) (string, error)) {
+	rows, err := database.Query(`
+		SELECT channel_id FROM image_hashes WHERE guild_id = '' AND channel_id != ''
+		UNION
+		SELECT channel_id FROM image_hash_sightings WHERE guild_id = '' AND channel_id != ''
+		UNION
+		SELECT channel_id FROM video_hashes WHERE guild_id = '' AND channel_id != ''`)
+	if err != nil {
+		log.Printf("Failed to find hashes without a guild: %v", err)
+		return
+	}
+	var channels []string
+	for rows.Next() {
+		var channelID string
+		if err := rows.Scan(&channelID); err == nil {
+			channels = append(channels, channelID)
+		}
+	}
+	rows.Close()
+
+	resolved := 0
+	for _, channelID := range channels {
+		guildID, err := lookup(channelID)
+		if err != nil || guildID == "" {
+			continue
 		}
+		if err := moveChannelToGuild(channelID, guildID); err != nil {
+			log.Printf("Failed to move hashes of channel %s to guild %s: %v", channelID, guildID, err)
+			continue
+		}
+		resolved++
 	}
+	if resolved == 0 {
+		return
+	}
+
+	hashStore.Lock()
+	hashStore.guilds = make(map[string]*namespace)
+	hashStore.Unlock()
+	loadFromDB()
+	loadVideosFromDB()
+	log.Printf("Resolved guilds of hashes in %d channels", resolved)
+}
+
+// moveChannelToGuild reassigns a channel's guildless hashes. Where the guild
+// already has the same hash, its own copy is kept.
+func moveChannelToGuild(channelID, guildID string) error {
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmts := []string{
+		`UPDATE OR IGNORE image_hash_variants SET guild_id = ?1
+		 WHERE guild_id = '' AND hash IN (SELECT hash FROM image_hashes WHERE guild_id = '' AND channel_id = ?2)`,
+		`DELETE FROM image_hash_variants
+		 WHERE guild_id = '' AND hash IN (SELECT hash FROM image_hashes WHERE guild_id = '' AND channel_id = ?2)`,
+		`UPDATE OR IGNORE image_hashes SET guild_id = ?1 WHERE guild_id = '' AND channel_id = ?2`,
+		`DELETE FROM image_hashes WHERE guild_id = '' AND channel_id = ?2`,
+		`UPDATE OR IGNORE image_hash_sightings SET guild_id = ?1 WHERE guild_id = '' AND channel_id = ?2`,
+		`DELETE FROM image_hash_sightings WHERE guild_id = '' AND channel_id = ?2`,
+		`UPDATE video_hashes SET guild_id = ?1 WHERE guild_id = '' AND channel_id = ?2`,
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt, guildID, channelID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
 }
 
-func writeHashToDB(hash string, message *discordgo.Message) {
-	msgJSON, err := json.Marshal(message)
+func writeHashToDB(guildID, hash string, message *discordgo.Message) {
+	msgJSON, err := marshalMessage(message)
 	if err != nil {
 		log.Printf("Failed to marshal message for DB write: %v", err)
 		return
 	}
 	_, err = database.Exec(
-		"INSERT OR REPLACE INTO image_hashes (hash, message_json) VALUES (?, ?)",
-		hash, string(msgJSON),
+		"INSERT OR REPLACE INTO image_hashes (guild_id, hash, channel_id, message_json) VALUES (?, ?, ?, ?)",
+		guildID, hash, message.ChannelID, msgJSON,
 	)
 	if err != nil {
 		log.Printf("Failed to write hash to DB: %v", err)
 	}
 }
 
-func readHashFromDB(hash string) (*discordgo.Message, error) {
+func writeVariantsToDB(guildID, hash string, fp fingerprint) {
+	for algo, h := range fp {
+		if algo == algoAverage {
+			continue
+		}
+		_, err := database.Exec(
+			"INSERT OR REPLACE INTO image_hash_variants (guild_id, hash, algorithm, value) VALUES (?, ?, ?, ?)",
+			guildID, hash, algo, h.ToString(),
+		)
+		if err != nil {
+			log.Printf("Failed to write %s hash to DB: %v", algo, err)
+		}
+	}
+}
+
+// readFingerprintFromDB returns every stored hash of an image. Images hashed
+// before the secondary algorithms existed only have their average hash.
+func readFingerprintFromDB(guildID, hash string) fingerprint {
+	fp := fingerprint{algoAverage: stringToHash(hash)}
+	rows, err := database.Query("SELECT algorithm, value FROM image_hash_variants WHERE guild_id = ? AND hash = ?", guildID, hash)
+	if err != nil {
+		log.Printf("Failed to read hash variants: %v", err)
+		return fp
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var algorithm, value string
+		if err := rows.Scan(&algorithm, &value); err != nil {
+			continue
+		}
+		if h, err := goimagehash.ExtImageHashFromString(value); err == nil {
+			fp[algorithm] = h
+		}
+	}
+	return fp
+}
+
+func readHashFromDB(guildID, hash string) (*discordgo.Message, error) {
 	var msgJSON string
-	err := database.QueryRow("SELECT message_json FROM image_hashes WHERE hash = ?", hash).Scan(&msgJSON)
+	err := database.QueryRow("SELECT message_json FROM image_hashes WHERE guild_id = ? AND hash = ?", guildID, hash).Scan(&msgJSON)
 	if err != nil {
 		return nil, err
 	}
@@ -122,138 +368,283 @@ func readHashFromDB(hash string) (*discordgo.Message, error) {
 	return &msg, nil
 }
 
+// TotalHashes returns the number of stored image hashes across all guilds.
 func TotalHashes() int {
 	hashStore.RLock()
 	defer hashStore.RUnlock()
-	return len(hashStore.m)
+	total := 0
+	for _, ns := range hashStore.guilds {
+		total += len(ns.images)
+	}
+	return total
 }
 
+// HashAttachments hashes the images and videos of a message, storing them when
+// options.Store is set. It returns the image hashes and how many images and
+// videos were stored.
 func HashAttachments(m *discordgo.Message, options HashOptions) ([]string, int) {
+	found := fingerprintAttachments(m, options)
+	hashes := make([]string, len(found.images))
+	for i, fp := range found.images {
+		hashes[i] = fp.key()
+	}
+	return hashes, found.stored
+}
+
+// attachmentHashes is what fingerprintAttachments found in a message.
+type attachmentHashes struct {
+	images []fingerprint
+	videos []videoFingerprint
+	stored int // images and videos written to the store
+	failed int // media that couldn't be downloaded or decoded
+}
+
+func fingerprintAttachments(m *discordgo.Message, options HashOptions) attachmentHashes {
 	images, videos, _, _ := utility.GetMessageMediaURL(m)
 	allAttachments := append(images, videos...)
-	var hashes []string
-	var count int
+	guildID := options.guildID(m)
+	var found attachmentHashes
 
 	for _, attachment := range allAttachments {
-		if utility.HasExtension(attachment, options.IgnoreExtensions) {
+		if utility.HasExtension(attachment, options.IgnoreExtensions) || options.ignoresURL(attachment) {
 			continue
 		}
 		var img image.Image
 
 		if utility.IsVideoURL(attachment) {
-			reader, err := readFrameAsJpeg(attachment, 10)
+			limit := options.MaxBytes
+			if limit <= 0 {
+				limit = maxVideoBytes
+			}
+			buf, err := options.getFile(attachment, limit)
 			if err != nil {
-				log.Printf("ffmpegSS error: %v, url: %s\n", err, attachment)
+				log.Printf("getFile error: %v, url: %s\n", err, attachment)
+				found.failed++
 				continue
 			}
-			img, _, err = image.Decode(reader)
+			v, err := fingerprintVideo(videoKey(m.ID, attachment), buf.Bytes())
 			if err != nil {
+				log.Printf("ffmpeg error: %v, url: %s\n", err, attachment)
+				found.failed++
 				continue
 			}
+			v.channelID = m.ChannelID
+			found.videos = append(found.videos, v)
+			continue
 		} else if utility.IsImageURL(attachment) {
-			buf, err := getFile(attachment)
+			buf, err := options.getFile(attachment, options.MaxBytes)
 			if err != nil {
 				log.Printf("getFile error: %v, url: %s\n", err, attachment)
+				found.failed++
 				continue
 			}
 			img, _, err = image.Decode(&buf)
 			if err != nil {
+				found.failed++
 				continue
 			}
 		} else {
 			continue
 		}
 
-		width, height := 16, 16
-		hash, err := goimagehash.ExtAverageHash(img, width, height)
+		fp, err := computeFingerprint(img)
 		if err != nil {
+			found.failed++
 			continue
 		}
+		found.images = append(found.images, fp)
+	}
 
-		hashString := hash.ToString()
+	if options.Store {
+		found.stored = storeAttachments(guildID, m, found)
+	}
+	return found
+}
 
-		if options.Store && (!checkHash(hashString) || olderHash(hashString, m)) {
-			writeHash(hashString, m)
-			count++
+// storeAttachments stores the media fingerprintAttachments found in m and
+// records each post as a sighting. It returns how many images and videos were
+// new to the store.
+func storeAttachments(guildID string, m *discordgo.Message, found attachmentHashes) int {
+	stored := 0
+	for _, fp := range found.images {
+		hashString := fp.key()
+		// Look for the image this is a repost of before storing it, or it
+		// would always be its own nearest match.
+		canonical := canonicalHash(guildID, fp)
+		if !checkHash(guildID, hashString) || olderHash(guildID, hashString, m) {
+			writeHash(guildID, hashString, m)
+			writeVariants(guildID, hashString, fp)
+			linkCanonical(guildID, hashString, canonical)
+			stored++
 			log.Printf("Stored hash: %s", hashString)
 		}
-		hashes = append(hashes, hashString)
+		recordSighting(guildID, canonical, m)
 	}
-
-	return hashes, count
-}
-
-func readFrameAsJpeg(url string, frameNum int) (io.Reader, error) {
-	outBuf := bytes.NewBuffer(nil)
-	err := ffmpeg.Input(url).
-		Filter("select", ffmpeg.Args{fmt.Sprintf("gte(n,%d)", frameNum)}).
-		Output("pipe:", ffmpeg.KwArgs{"vframes": 1, "format": "image2", "vcodec": "mjpeg"}).
-		WithOutput(outBuf).
-		Silent(true).
-		Run()
-	if err != nil {
-		return nil, err
+	for _, v := range found.videos {
+		canonical := canonicalVideo(guildID, v)
+		if !checkVideo(guildID, v.key) {
+			if canonical != v.key {
+				v.canonical = canonical
+			}
+			writeVideo(guildID, v, m)
+			stored++
+			log.Printf("Stored video hash: %s (%d frames)", v.key, len(v.frames))
+		}
+		recordSighting(guildID, canonical, m)
 	}
-	return outBuf, nil
+	return stored
 }
 
-func writeHash(hash string, message *discordgo.Message) {
+func writeHash(guildID, hash string, message *discordgo.Message) {
 	hashStore.Lock()
-	hashStore.m[hash] = hashEntry{
+	ns := namespaceLocked(guildID)
+	ns.images[hash] = hashEntry{
 		messageID: message.ID,
+		channelID: message.ChannelID,
 		timestamp: time.Time(message.Timestamp),
 	}
+	ns.indexes[algoAverage].add(hash, hash)
 	hashStore.Unlock()
-	writeHashToDB(hash, message)
+	writeHashToDB(guildID, hash, message)
 }
 
-func checkHash(hash string) bool {
+// writeVariants stores and indexes the secondary hashes of the image stored
+// under hash.
+func writeVariants(guildID, hash string, fp fingerprint) {
+	hashStore.Lock()
+	ns := namespaceLocked(guildID)
+	for algo, h := range fp {
+		if algo != algoAverage {
+			ns.indexes[algo].add(h.ToString(), hash)
+		}
+	}
+	hashStore.Unlock()
+	writeVariantsToDB(guildID, hash, fp)
+}
+
+func lookupHash(guildID, hash string) (hashEntry, bool) {
 	hashStore.RLock()
 	defer hashStore.RUnlock()
-	_, ok := hashStore.m[hash]
+	ns, ok := hashStore.guilds[guildID]
+	if !ok {
+		return hashEntry{}, false
+	}
+	entry, ok := ns.images[hash]
+	return entry, ok
+}
+
+func checkHash(guildID, hash string) bool {
+	_, ok := lookupHash(guildID, hash)
 	return ok
 }
 
-func olderHash(hash string, message *discordgo.Message) bool {
-	hashStore.RLock()
-	entry, ok := hashStore.m[hash]
-	hashStore.RUnlock()
+func olderHash(guildID, hash string, message *discordgo.Message) bool {
+	entry, ok := lookupHash(guildID, hash)
 	if !ok {
 		return true
 	}
 	return time.Time(message.Timestamp).Before(entry.timestamp)
 }
 
+// checkInHashes looks the media of m up among the stored hashes. With
+// options.Store the media is stored once the lookup is done, so a post is
+// fingerprinted once and never matches itself.
 func checkInHashes(m *discordgo.Message, options HashOptions) (bool, []hashResult) {
-	messageHashes, _ := HashAttachments(m, options)
+	store := options.Store
+	options.Store = false
+	found := fingerprintAttachments(m, options)
+	fingerprints, videoPrints := found.images, found.videos
+	guildID := options.guildID(m)
+	if store {
+		defer storeAttachments(guildID, m, found)
+	}
+
+	// Video frames are also looked up among the images, which catches stills
+	// and videos hashed before they had their own fingerprints.
+	for _, v := range videoPrints {
+		for _, f := range v.frames {
+			fingerprints = append(fingerprints, fingerprint{algoAverage: f})
+		}
+	}
 
+	// A stored image is a candidate when any of its hashes is within the
+	// threshold of the matching query hash; it is then scored on all of them.
 	type pending struct {
-		distance int
-		hash     string
+		query int
+		hash  string
 	}
 
 	hashStore.RLock()
 	var pendingMatches []pending
-	for storedHash := range hashStore.m {
-		hash1 := stringToHash(storedHash)
-		for _, messageHash := range messageHashes {
-			hash2 := stringToHash(messageHash)
-			distance, _ := hash1.Distance(hash2)
-			if distance <= options.Threshold {
-				pendingMatches = append(pendingMatches, pending{distance, storedHash})
+	if ns, ok := hashStore.guilds[guildID]; ok {
+		seen := make(map[pending]bool)
+		for qi, fp := range fingerprints {
+			for _, path := range searchPaths {
+				q := fp[path.query]
+				if q == nil {
+					continue
+				}
+				for _, match := range ns.indexes[path.stored].search(q, options.Threshold) {
+					for _, owner := range match.owners {
+						p := pending{qi, owner}
+						if seen[p] || (options.ChannelID != "" && ns.images[owner].channelID != options.ChannelID) {
+							continue
+						}
+						seen[p] = true
+						pendingMatches = append(pendingMatches, p)
+					}
+				}
 			}
 		}
 	}
 	hashStore.RUnlock()
 
-	var matchedMessages []hashResult
+	// A stored image can match several query images, most often the frames
+	// of one video; it is reported once, for its closest query.
+	type scored struct {
+		query int
+		sim   similarity
+	}
+	var hashes []string
+	best := make(map[string]scored)
+	stored := make(map[string]fingerprint)
 	for _, p := range pendingMatches {
-		msg, err := readHashFromDB(p.hash)
+		if _, ok := stored[p.hash]; !ok {
+			stored[p.hash] = readFingerprintFromDB(guildID, p.hash)
+		}
+		sim := compareFingerprints(fingerprints[p.query], stored[p.hash])
+		if sim.confidence < minConfidence(options.Threshold) {
+			continue
+		}
+		prev, ok := best[p.hash]
+		if !ok {
+			hashes = append(hashes, p.hash)
+		}
+		if !ok || sim.distance < prev.sim.distance || (sim.distance == prev.sim.distance && sim.confidence > prev.sim.confidence) {
+			best[p.hash] = scored{p.query, sim}
+		}
+	}
+
+	var matchedMessages []hashResult
+	for _, hash := range hashes {
+		msg, err := readHashFromDB(guildID, hash)
 		if err != nil {
 			log.Printf("Failed to read hash from DB: %v", err)
 			continue
 		}
-		matchedMessages = append(matchedMessages, hashResult{p.distance, msg})
+		b := best[hash]
+		matchedMessages = append(matchedMessages, hashResult{
+			hash:       hash,
+			query:      b.query,
+			distance:   b.sim.distance,
+			confidence: b.sim.confidence,
+			mirrored:   b.sim.mirrored,
+			message:    msg,
+		})
+	}
+
+	for _, v := range videoPrints {
+		matchedMessages = append(matchedMessages, checkInVideos(guildID, v, options)...)
 	}
 
 	if len(matchedMessages) > 0 {
@@ -291,10 +682,17 @@ func uniqueHashResults(results []hashResult) []hashResult {
 	return uniqueResults
 }
 
+// FindSnails reports earlier posts in the guild whose media matches message.
+// With options.Store the media is also stored, after the check.
 func FindSnails(guildID string, message *discordgo.Message, options HashOptions) (string, []*discordgo.MessageEmbed) {
+	if options.GuildID == "" {
+		options.GuildID = guildID
+	}
 	isSnail, results := checkInHashes(message, options)
 	var messageContent string
 	var embeds []*discordgo.MessageEmbed
+	var queries []int
+	matched := make(map[int][]string)
 	if isSnail {
 		for _, result := range uniqueHashResults(results) {
 			if result.message.ID == message.ID {
@@ -304,37 +702,53 @@ func FindSnails(guildID string, message *discordgo.Message, options HashOptions)
 				continue
 			}
 			timestamp := result.message.Timestamp.UTC().Format("2006-01-02")
+			mirrored := ""
+			if result.mirrored {
+				mirrored = ", mirrored"
+			}
+			clip := ""
+			if result.clip != nil {
+				queryStart, queryEnd := result.clip.queryRange()
+				clip = fmt.Sprintf(" (%s–%s of the original at %s–%s here)",
+					formatClock(result.clip.start), formatClock(result.clip.end), formatClock(queryStart), formatClock(queryEnd))
+			}
+			if result.hash != "" {
+				if _, ok := matched[result.query]; !ok {
+					queries = append(queries, result.query)
+				}
+				matched[result.query] = append(matched[result.query], result.hash)
+			}
 			embeds = append(embeds, utility.MessageToEmbeds(guildID, result.message, result.distance)...)
-			messageContent += fmt.Sprintf("%dd: %s: Snail of %s! %s\n", result.distance, timestamp, result.message.Author.Username, utility.LinkFromIMessage(guildID, result.message))
+			messageContent += fmt.Sprintf("%dd (%.0f%%%s): %s: Snail of %s!%s %s\n", result.distance, result.confidence*100, mirrored, timestamp, result.message.Author.Username, clip, utility.LinkFromIMessage(guildID, result.message))
 		}
 	}
 
-	return messageContent, embeds
-}
-
-func getFile(url string) (bytes.Buffer, error) {
-	var buf bytes.Buffer
-
-	client := &http.Client{
-		Transport: &http.Transport{
-			TLSNextProto:      make(map[string]func(string, *tls.Conn) http.RoundTripper),
-			DisableKeepAlives: true,
-		},
+	slices.Sort(queries)
+	for _, q := range queries {
+		if history := imageHistory(guildID, matched[q]); history != "" {
+			messageContent += "History: " + history + "\n"
+		}
 	}
 
-	resp, err := client.Get(url)
-	if err != nil {
-		return buf, err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return buf, fmt.Errorf("bad status: %s", resp.Status)
-	}
+	return messageContent, embeds
+}
 
-	_, err = io.Copy(&buf, resp.Body)
-	if err != nil {
-		return buf, err
+// imageHistory describes the posts of an image stored under any of hashes.
+func imageHistory(guildID string, hashes []string) string {
+	var lists [][]Sighting
+	for _, hash := range slices.Compact(slices.Sorted(slices.Values(hashes))) {
+		sightings, err := Sightings(guildID, hash)
+		if err != nil {
+			log.Printf("Failed to read sightings of %s: %v", hash, err)
+			continue
+		}
+		lists = append(lists, sightings)
 	}
+	return describeSightings(mergeSightings(lists...))
+}
 
-	return buf, nil
+// getFile downloads url, failing when a positive limit is exceeded.
+func getFile(url string, limit int64) (bytes.Buffer, error) {
+	data, err := utility.DownloadBytesLimit(url, limit)
+	return *bytes.NewBuffer(data), err
 }
@@ -3,6 +3,7 @@ package hasher
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"image"
 	"image/color"
 	"image/png"
@@ -17,11 +18,18 @@ import (
 	"voltgpt/internal/db"
 )
 
-// resetStore replaces hashStore.m with an empty map.
+// resetStore drops every guild namespace.
 func resetStore(t *testing.T) {
 	t.Helper()
 	hashStore.Lock()
-	hashStore.m = make(map[string]hashEntry)
+	hashStore.guilds = make(map[string]*namespace)
+	hashStore.Unlock()
+}
+
+// storeEntry puts an entry straight into a guild's in-memory store.
+func storeEntry(guildID, hash string, entry hashEntry) {
+	hashStore.Lock()
+	namespaceLocked(guildID).images[hash] = entry
 	hashStore.Unlock()
 }
 
@@ -131,52 +139,50 @@ func TestTotalHashes(t *testing.T) {
 		t.Errorf("TotalHashes on empty store = %d, want 0", n)
 	}
 
-	hashStore.Lock()
-	hashStore.m["h1"] = hashEntry{messageID: "1"}
-	hashStore.m["h2"] = hashEntry{messageID: "2"}
-	hashStore.Unlock()
+	storeEntry("g1", "h1", hashEntry{messageID: "1"})
+	storeEntry("g1", "h2", hashEntry{messageID: "2"})
+	storeEntry("g2", "h1", hashEntry{messageID: "3"})
 
-	if n := TotalHashes(); n != 2 {
-		t.Errorf("TotalHashes = %d, want 2", n)
+	if n := TotalHashes(); n != 3 {
+		t.Errorf("TotalHashes = %d, want 3 across guilds", n)
 	}
 }
 
 func TestCheckHash(t *testing.T) {
 	setupHasher(t)
 
-	if checkHash("missing") {
+	if checkHash("g", "missing") {
 		t.Error("checkHash on empty store = true, want false")
 	}
 
-	hashStore.Lock()
-	hashStore.m["exists"] = hashEntry{messageID: "msg"}
-	hashStore.Unlock()
+	storeEntry("g", "exists", hashEntry{messageID: "msg"})
 
-	if !checkHash("exists") {
+	if !checkHash("g", "exists") {
 		t.Error("checkHash for inserted key = false, want true")
 	}
+	if checkHash("other", "exists") {
+		t.Error("checkHash in another guild = true, want false")
+	}
 }
 
 func TestOlderHash(t *testing.T) {
 	setupHasher(t)
 
 	// Hash not in store → always considered older (should be stored).
-	if !olderHash("nonexistent", &discordgo.Message{ID: "x", Timestamp: time.Now()}) {
+	if !olderHash("g", "nonexistent", &discordgo.Message{ID: "x", Timestamp: time.Now()}) {
 		t.Error("olderHash for missing key = false, want true")
 	}
 
 	base := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
-	hashStore.Lock()
-	hashStore.m["key"] = hashEntry{messageID: "stored", timestamp: base}
-	hashStore.Unlock()
+	storeEntry("g", "key", hashEntry{messageID: "stored", timestamp: base})
 
 	// New message predates the stored one → should replace it.
-	if !olderHash("key", &discordgo.Message{ID: "older", Timestamp: base.Add(-time.Hour)}) {
+	if !olderHash("g", "key", &discordgo.Message{ID: "older", Timestamp: base.Add(-time.Hour)}) {
 		t.Error("olderHash with earlier timestamp = false, want true")
 	}
 
 	// New message is more recent → keep the stored one.
-	if olderHash("key", &discordgo.Message{ID: "newer", Timestamp: base.Add(time.Hour)}) {
+	if olderHash("g", "key", &discordgo.Message{ID: "newer", Timestamp: base.Add(time.Hour)}) {
 		t.Error("olderHash with later timestamp = true, want false")
 	}
 }
@@ -187,15 +193,18 @@ func TestWriteAndReadHash(t *testing.T) {
 	setupHasherWithDB(t)
 
 	msg := &discordgo.Message{ID: "msg1", ChannelID: "ch1"}
-	writeHash("testhash", msg)
+	writeHash("g", "testhash", msg)
 
-	got, err := readHashFromDB("testhash")
+	got, err := readHashFromDB("g", "testhash")
 	if err != nil {
 		t.Fatalf("readHashFromDB: %v", err)
 	}
 	if got.ID != "msg1" {
 		t.Errorf("message ID = %q, want %q", got.ID, "msg1")
 	}
+	if _, err := readHashFromDB("other", "testhash"); err == nil {
+		t.Error("readHashFromDB found the hash in another guild")
+	}
 }
 
 func TestLoadFromDB(t *testing.T) {
@@ -204,7 +213,7 @@ func TestLoadFromDB(t *testing.T) {
 	// Pre-populate the DB row directly, bypassing the in-memory store.
 	msg := &discordgo.Message{ID: "preloaded", ChannelID: "ch"}
 	msgJSON, _ := json.Marshal(msg)
-	db.DB.Exec("INSERT INTO image_hashes (hash, message_json) VALUES (?, ?)", "dbhash", string(msgJSON))
+	db.DB.Exec("INSERT INTO image_hashes (guild_id, hash, channel_id, message_json) VALUES (?, ?, ?, ?)", "g", "dbhash", "ch", string(msgJSON))
 
 	// Reset store then reload from DB to simulate startup.
 	resetStore(t)
@@ -213,12 +222,51 @@ func TestLoadFromDB(t *testing.T) {
 	if n := TotalHashes(); n != 1 {
 		t.Errorf("TotalHashes after loadFromDB = %d, want 1", n)
 	}
-	got, err := readHashFromDB("dbhash")
+	if !checkHash("g", "dbhash") {
+		t.Error("loaded hash is not in its guild's namespace")
+	}
+	got, err := readHashFromDB("g", "dbhash")
 	if err != nil || got.ID != "preloaded" {
 		t.Errorf("loaded message ID = %v (err=%v), want %q", got, err, "preloaded")
 	}
 }
 
+func TestResolveGuilds(t *testing.T) {
+	setupHasherWithDB(t)
+
+	writeHash("", "moved", &discordgo.Message{ID: "1", ChannelID: "ch"})
+	writeVariantsToDB("", "moved", fingerprint{algoDifference: stringToHash("d:00")})
+	writeHash("", "orphan", &discordgo.Message{ID: "2", ChannelID: "gone"})
+	// The guild already has this hash; its own copy wins.
+	writeHash("", "dup", &discordgo.Message{ID: "3", ChannelID: "ch"})
+	writeHash("g", "dup", &discordgo.Message{ID: "4", ChannelID: "ch2"})
+
+	resolveGuilds(func(channelID string) (string, error) {
+		if channelID == "ch" {
+			return "g", nil
+		}
+		return "", errors.New("unknown channel")
+	})
+
+	if !checkHash("g", "moved") || checkHash("", "moved") {
+		t.Error("hash of a resolved channel wasn't moved into its guild")
+	}
+	if !checkHash("", "orphan") {
+		t.Error("hash of an unknown channel should stay guildless")
+	}
+	if got, err := readHashFromDB("g", "dup"); err != nil || got.ID != "4" {
+		t.Errorf("duplicate hash = %v (err=%v), want the guild's own message 4", got, err)
+	}
+	if checkHash("", "dup") {
+		t.Error("guildless duplicate should be dropped")
+	}
+	var n int
+	db.DB.QueryRow("SELECT COUNT(*) FROM image_hash_variants WHERE guild_id = 'g' AND hash = 'moved'").Scan(&n)
+	if n != 1 {
+		t.Errorf("variants moved = %d, want 1", n)
+	}
+}
+
 // ── Network / hashing ─────────────────────────────────────────────────────────
 
 func TestGetFileSuccess(t *testing.T) {
@@ -228,7 +276,7 @@ func TestGetFileSuccess(t *testing.T) {
 	}))
 	defer srv.Close()
 
-	buf, err := getFile(srv.URL + "/file.png")
+	buf, err := getFile(srv.URL+"/file.png", 0)
 	if err != nil {
 		t.Fatalf("getFile: %v", err)
 	}
@@ -243,7 +291,7 @@ func TestGetFileNonOKStatus(t *testing.T) {
 	}))
 	defer srv.Close()
 
-	_, err := getFile(srv.URL + "/missing.png")
+	_, err := getFile(srv.URL+"/missing.png", 0)
 	if err == nil {
 		t.Error("expected error for 404 response, got nil")
 	}
@@ -295,6 +343,27 @@ func TestHashAttachmentsIgnoreExtension(t *testing.T) {
 	}
 }
 
+func TestHashAttachmentsIgnoreURLs(t *testing.T) {
+	setupHasher(t)
+
+	img := makeTestImage(64, 64, color.RGBA{R: 100, G: 100, B: 100, A: 255})
+	srv := servePNG(t, img)
+	defer srv.Close()
+
+	msg := &discordgo.Message{
+		ID: "testmsg",
+		Attachments: []*discordgo.MessageAttachment{
+			{URL: srv.URL + "/emojis/123.png", Width: 64, Height: 64},
+			{URL: srv.URL + "/photo.png", Width: 64, Height: 64},
+		},
+	}
+
+	hashes, _ := HashAttachments(msg, HashOptions{IgnoreURLs: []string{"/emojis/"}})
+	if len(hashes) != 1 {
+		t.Errorf("expected only the photo to be hashed, got %d hashes", len(hashes))
+	}
+}
+
 func TestHashAttachmentsWithStore(t *testing.T) {
 	setupHasherWithDB(t)
 
@@ -303,7 +372,8 @@ func TestHashAttachmentsWithStore(t *testing.T) {
 	defer srv.Close()
 
 	msg := &discordgo.Message{
-		ID: "storemsg",
+		ID:      "storemsg",
+		GuildID: "g",
 		Attachments: []*discordgo.MessageAttachment{
 			{URL: srv.URL + "/test.png", Width: 64, Height: 64},
 		},
@@ -321,6 +391,123 @@ func TestHashAttachmentsWithStore(t *testing.T) {
 	}
 }
 
+func TestFindSnailsStaysInGuild(t *testing.T) {
+	setupHasherWithDB(t)
+
+	img := makePatternImage(64, 64, nil)
+	srv := servePNG(t, img)
+	defer srv.Close()
+
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	first := &discordgo.Message{
+		ID:          "first",
+		GuildID:     "a",
+		ChannelID:   "ch",
+		Timestamp:   base,
+		Author:      &discordgo.User{Username: "alice"},
+		Attachments: []*discordgo.MessageAttachment{{URL: srv.URL + "/a.png", Width: 64, Height: 64}},
+	}
+	HashAttachments(first, HashOptions{Store: true})
+
+	repost := &discordgo.Message{
+		ID:          "second",
+		ChannelID:   "other",
+		Timestamp:   base.Add(time.Hour),
+		Author:      &discordgo.User{Username: "bob"},
+		Attachments: []*discordgo.MessageAttachment{{URL: srv.URL + "/b.png", Width: 64, Height: 64}},
+	}
+	if content, _ := FindSnails("b", repost, HashOptions{Threshold: 8}); content != "" {
+		t.Errorf("FindSnails() in another guild = %q, want nothing", content)
+	}
+	if content, _ := FindSnails("a", repost, HashOptions{Threshold: 8, ChannelID: "other"}); content != "" {
+		t.Errorf("FindSnails() limited to another channel = %q, want nothing", content)
+	}
+	if content, _ := FindSnails("a", repost, HashOptions{Threshold: 8}); !strings.Contains(content, "Snail of alice") {
+		t.Errorf("FindSnails() in the same guild = %q, want a snail of alice", content)
+	}
+}
+
+func TestFindSnailsStoresAfterCheck(t *testing.T) {
+	setupHasherWithDB(t)
+
+	srv := servePNG(t, makePatternImage(64, 64, nil))
+	defer srv.Close()
+
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	post := func(id, author string, hours int) *discordgo.Message {
+		return &discordgo.Message{
+			ID:          id,
+			GuildID:     "a",
+			ChannelID:   "ch",
+			Timestamp:   base.Add(time.Duration(hours) * time.Hour),
+			Author:      &discordgo.User{ID: author + "-id", Username: author},
+			Attachments: []*discordgo.MessageAttachment{{URL: srv.URL + "/" + id + ".png", Width: 64, Height: 64}},
+		}
+	}
+	options := HashOptions{Store: true, Threshold: 8}
+
+	// The first post doesn't match itself, but is stored for the next.
+	if content, _ := FindSnails("a", post("first", "alice", 0), options); content != "" {
+		t.Errorf("FindSnails() of the first post = %q, want nothing", content)
+	}
+	if n := TotalHashes(); n != 1 {
+		t.Fatalf("TotalHashes after the first post = %d, want 1", n)
+	}
+	if content, _ := FindSnails("a", post("second", "bob", 1), options); !strings.Contains(content, "Snail of alice") {
+		t.Errorf("FindSnails() of the repost = %q, want a snail of alice", content)
+	}
+	reposters, err := TopReposters("a", 10)
+	if err != nil || len(reposters) != 1 || reposters[0].AuthorName != "bob" {
+		t.Errorf("TopReposters() = %+v (err=%v), want bob's repost recorded", reposters, err)
+	}
+}
+
+func TestFindSnailsReportsEachStoredImageOnce(t *testing.T) {
+	setupHasherWithDB(t)
+
+	srv := servePNG(t, makePatternImage(64, 64, nil))
+	defer srv.Close()
+	brighter := servePNG(t, makePatternImage(64, 64, func(c color.RGBA) color.RGBA {
+		c.R = uint8(min(255, int(c.R)+120))
+		return c
+	}))
+	defer brighter.Close()
+
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	first := &discordgo.Message{
+		ID:          "first",
+		GuildID:     "a",
+		ChannelID:   "ch",
+		Timestamp:   base,
+		Author:      &discordgo.User{Username: "alice"},
+		Attachments: []*discordgo.MessageAttachment{{URL: srv.URL + "/a.png", Width: 64, Height: 64}},
+	}
+	HashAttachments(first, HashOptions{Store: true})
+
+	// Both attachments match alice's image at different distances, like the
+	// frames of one video.
+	repost := &discordgo.Message{
+		ID:        "second",
+		ChannelID: "ch",
+		Timestamp: base.Add(time.Hour),
+		Author:    &discordgo.User{Username: "bob"},
+		Attachments: []*discordgo.MessageAttachment{
+			{URL: srv.URL + "/b.png", Width: 64, Height: 64},
+			{URL: brighter.URL + "/c.png", Width: 64, Height: 64},
+		},
+	}
+	content, embeds := FindSnails("a", repost, HashOptions{Threshold: 8})
+	if n := strings.Count(content, "Snail of alice"); n != 1 {
+		t.Errorf("FindSnails() reported alice's image %d times, want once:\n%s", n, content)
+	}
+	if n := strings.Count(content, "History:"); n > 1 {
+		t.Errorf("FindSnails() has %d history lines, want at most one:\n%s", n, content)
+	}
+	if len(embeds) == 0 {
+		t.Error("FindSnails() returned no embeds")
+	}
+}
+
 func TestGetFile_NonOKStatusClosesBody(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusNotFound)
@@ -328,7 +515,7 @@ func TestGetFile_NonOKStatusClosesBody(t *testing.T) {
 	}))
 	defer srv.Close()
 
-	_, err := getFile(srv.URL)
+	_, err := getFile(srv.URL, 0)
 	if err == nil {
 		t.Fatal("expected error for 404 response, got nil")
 	}
@@ -336,3 +523,12 @@ func TestGetFile_NonOKStatusClosesBody(t *testing.T) {
 		t.Errorf("unexpected error: %v", err)
 	}
 }
+
+func TestGetFileLimit(t *testing.T) {
+	srv := servePNG(t, makePatternImage(64, 64, nil))
+	defer srv.Close()
+
+	if _, err := getFile(srv.URL+"/big.png", 16); err == nil {
+		t.Error("getFile over the limit succeeded")
+	}
+}
@@ -0,0 +1,170 @@
+package hasher
+
+import (
+	"math/bits"
+	"slices"
+
+	"github.com/corona10/goimagehash"
+)
+
+const (
+	// chunkBits is the width of each multi-index substring.
+	chunkBits = 16
+	// maxProbeRadius bounds how many bits of a chunk are flipped when probing
+	// buckets. Thresholds that would need a wider radius fall back to a scan.
+	maxProbeRadius = 2
+)
+
+// hashIndex is a multi-index hashing structure over ExtImageHash values of a
+// fixed size. Each hash is cut into 16-bit chunks and every chunk position has
+// its own bucket table. Two hashes within distance r must agree to within
+// r/chunks bits on at least one chunk, so a query only probes the buckets near
+// its own chunks and verifies those candidates with the full distance.
+type hashIndex struct {
+	words   int
+	entries []indexEntry
+	slots   map[string]int32
+	buckets []map[uint16][]int32
+}
+
+// indexEntry is one distinct hash value. owners lists the stored image hashes
+// (image_hashes keys) it belongs to, since different images can share, say,
+// a difference hash.
+type indexEntry struct {
+	key    string
+	kind   goimagehash.Kind
+	hash   []uint64
+	owners []string
+}
+
+type indexMatch struct {
+	key      string
+	owners   []string
+	distance int
+}
+
+func newHashIndex(words int) *hashIndex {
+	idx := &hashIndex{
+		words:   words,
+		slots:   make(map[string]int32),
+		buckets: make([]map[uint16][]int32, words*64/chunkBits),
+	}
+	for i := range idx.buckets {
+		idx.buckets[i] = make(map[uint16][]int32)
+	}
+	return idx
+}
+
+func (idx *hashIndex) len() int {
+	return len(idx.entries)
+}
+
+// add indexes the hash string key for owner. It reports false when the string
+// can't be parsed or has a different size than the index.
+func (idx *hashIndex) add(key, owner string) bool {
+	if slot, ok := idx.slots[key]; ok {
+		e := &idx.entries[slot]
+		if !slices.Contains(e.owners, owner) {
+			e.owners = append(e.owners, owner)
+		}
+		return true
+	}
+	h, err := goimagehash.ExtImageHashFromString(key)
+	if err != nil || len(h.GetHash()) != idx.words {
+		return false
+	}
+
+	slot := int32(len(idx.entries))
+	idx.entries = append(idx.entries, indexEntry{key: key, kind: h.GetKind(), hash: h.GetHash(), owners: []string{owner}})
+	idx.slots[key] = slot
+	for c := range idx.buckets {
+		v := chunkAt(h.GetHash(), c)
+		idx.buckets[c][v] = append(idx.buckets[c][v], slot)
+	}
+	return true
+}
+
+// search returns every indexed hash of the same kind within threshold of h.
+func (idx *hashIndex) search(h *goimagehash.ExtImageHash, threshold int) []indexMatch {
+	query := h.GetHash()
+	if threshold < 0 || len(query) != idx.words {
+		return nil
+	}
+
+	radius := threshold / len(idx.buckets)
+	if radius > maxProbeRadius {
+		return idx.scan(h, threshold)
+	}
+
+	var matches []indexMatch
+	seen := make(map[int32]struct{})
+	for c, bucket := range idx.buckets {
+		forEachNeighbour(chunkAt(query, c), radius, func(v uint16) {
+			for _, slot := range bucket[v] {
+				if _, ok := seen[slot]; ok {
+					continue
+				}
+				seen[slot] = struct{}{}
+				if m, ok := idx.match(slot, h, threshold); ok {
+					matches = append(matches, m)
+				}
+			}
+		})
+	}
+	return matches
+}
+
+// scan is the linear fallback for thresholds too wide to probe efficiently.
+func (idx *hashIndex) scan(h *goimagehash.ExtImageHash, threshold int) []indexMatch {
+	var matches []indexMatch
+	for slot := range idx.entries {
+		if m, ok := idx.match(int32(slot), h, threshold); ok {
+			matches = append(matches, m)
+		}
+	}
+	return matches
+}
+
+func (idx *hashIndex) match(slot int32, h *goimagehash.ExtImageHash, threshold int) (indexMatch, bool) {
+	e := idx.entries[slot]
+	if e.kind != h.GetKind() {
+		return indexMatch{}, false
+	}
+	d := hammingDistance(e.hash, h.GetHash())
+	return indexMatch{key: e.key, owners: e.owners, distance: d}, d <= threshold
+}
+
+func hammingDistance(a, b []uint64) int {
+	if len(a) != len(b) {
+		return len(a) * 64
+	}
+	d := 0
+	for i := range a {
+		d += bits.OnesCount64(a[i] ^ b[i])
+	}
+	return d
+}
+
+// chunkAt returns the c-th 16-bit chunk of hash, counting from the most
+// significant bits of the first word.
+func chunkAt(hash []uint64, c int) uint16 {
+	perWord := 64 / chunkBits
+	shift := 64 - chunkBits*(c%perWord+1)
+	return uint16(hash[c/perWord] >> shift)
+}
+
+// forEachNeighbour calls fn for v and every value that differs from it in at
+// most radius bits.
+func forEachNeighbour(v uint16, radius int, fn func(uint16)) {
+	var flip func(v uint16, from, left int)
+	flip = func(v uint16, from, left int) {
+		fn(v)
+		if left == 0 {
+			return
+		}
+		for b := from; b < chunkBits; b++ {
+			flip(v^(1<<b), b+1, left-1)
+		}
+	}
+	flip(v, 0, radius)
+}
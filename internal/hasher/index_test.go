@@ -0,0 +1,143 @@
+package hasher
+
+import (
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/corona10/goimagehash"
+)
+
+func randomHash(r *rand.Rand) *goimagehash.ExtImageHash {
+	words := make([]uint64, hashWords)
+	for i := range words {
+		words[i] = r.Uint64()
+	}
+	return goimagehash.NewExtImageHash(words, goimagehash.AHash, hashWords*64)
+}
+
+// flipBits returns a copy of h with n distinct random bits inverted.
+func flipBits(r *rand.Rand, h *goimagehash.ExtImageHash, n int) *goimagehash.ExtImageHash {
+	words := slices.Clone(h.GetHash())
+	for _, b := range r.Perm(hashWords * 64)[:n] {
+		words[b/64] ^= 1 << (b % 64)
+	}
+	return goimagehash.NewExtImageHash(words, h.GetKind(), h.Bits())
+}
+
+func bruteForce(keys []string, q *goimagehash.ExtImageHash, threshold int) []string {
+	var got []string
+	for _, k := range keys {
+		if d, err := stringToHash(k).Distance(q); err == nil && d <= threshold {
+			got = append(got, k)
+		}
+	}
+	slices.Sort(got)
+	return got
+}
+
+func matchKeys(matches []indexMatch) []string {
+	keys := make([]string, len(matches))
+	for i, m := range matches {
+		keys[i] = m.key
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func TestHashIndexMatchesBruteForce(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	idx := newHashIndex(hashWords)
+
+	// Clusters of near-duplicates around random bases, so every threshold
+	// has something to find.
+	var keys []string
+	var bases []*goimagehash.ExtImageHash
+	for range 50 {
+		base := randomHash(r)
+		bases = append(bases, base)
+		for _, n := range []int{0, 3, 8, 15, 40, 70} {
+			k := flipBits(r, base, n).ToString()
+			if idx.add(k, k) && !slices.Contains(keys, k) {
+				keys = append(keys, k)
+			}
+		}
+	}
+
+	for _, threshold := range []int{0, 8, 10, 20, 47, 60} {
+		for _, base := range bases[:10] {
+			q := flipBits(r, base, 2)
+			got := matchKeys(idx.search(q, threshold))
+			want := bruteForce(keys, q, threshold)
+			if !slices.Equal(got, want) {
+				t.Errorf("threshold %d: search found %d, brute force %d", threshold, len(got), len(want))
+			}
+		}
+	}
+}
+
+func TestHashIndexDistance(t *testing.T) {
+	r := rand.New(rand.NewPCG(3, 4))
+	idx := newHashIndex(hashWords)
+	base := randomHash(r)
+	near := flipBits(r, base, 5)
+	idx.add(near.ToString(), "near")
+
+	matches := idx.search(base, 8)
+	if len(matches) != 1 || matches[0].distance != 5 {
+		t.Errorf("search() = %+v, want one match at distance 5", matches)
+	}
+	if matches := idx.search(base, 4); len(matches) != 0 {
+		t.Errorf("search() below distance = %+v, want none", matches)
+	}
+}
+
+func TestHashIndexRejectsOtherSizesAndKinds(t *testing.T) {
+	idx := newHashIndex(hashWords)
+
+	if idx.add("not-a-hash", "x") {
+		t.Error("add() accepted an unparseable hash")
+	}
+	small := goimagehash.NewExtImageHash([]uint64{1}, goimagehash.AHash, 64)
+	if idx.add(small.ToString(), "small") {
+		t.Error("add() accepted a 64-bit hash into a 256-bit index")
+	}
+
+	h := goimagehash.NewExtImageHash(make([]uint64, hashWords), goimagehash.AHash, hashWords*64)
+	if !idx.add(h.ToString(), "a") || !idx.add(h.ToString(), "b") || idx.len() != 1 {
+		t.Errorf("re-adding a key: len = %d, want 1", idx.len())
+	}
+	if matches := idx.search(h, 0); len(matches) != 1 || !slices.Equal(matches[0].owners, []string{"a", "b"}) {
+		t.Errorf("search() = %+v, want one entry owned by a and b", matches)
+	}
+	other := goimagehash.NewExtImageHash(make([]uint64, hashWords), goimagehash.DHash, hashWords*64)
+	if matches := idx.search(other, 10); len(matches) != 0 {
+		t.Errorf("search() matched a different hash kind: %+v", matches)
+	}
+}
+
+func TestForEachNeighbour(t *testing.T) {
+	counts := map[int]int{0: 1, 1: 17, 2: 137}
+	for radius, want := range counts {
+		seen := map[uint16]bool{}
+		forEachNeighbour(0xBEEF, radius, func(v uint16) { seen[v] = true })
+		if len(seen) != want {
+			t.Errorf("radius %d visited %d values, want %d", radius, len(seen), want)
+		}
+	}
+}
+
+func TestWriteHashUpdatesIndex(t *testing.T) {
+	setupHasherWithDB(t)
+
+	h := randomHash(rand.New(rand.NewPCG(5, 6)))
+	writeHash("g", h.ToString(), &discordgo.Message{ID: "indexed"})
+
+	hashStore.RLock()
+	matches := hashStore.guilds["g"].indexes[algoAverage].search(h, 0)
+	hashStore.RUnlock()
+	if len(matches) != 1 || matches[0].key != h.ToString() {
+		t.Errorf("index search after writeHash = %+v, want the written hash", matches)
+	}
+}
@@ -0,0 +1,448 @@
+package hasher
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"voltgpt/internal/utility"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Backfill job statuses.
+const (
+	JobRunning   = "running"
+	JobDone      = "done"
+	JobCancelled = "cancelled"
+)
+
+const (
+	// jobPageSize is how many messages a job fetches per request, the most
+	// Discord returns at once.
+	jobPageSize = 100
+	// jobReportInterval is the least time between status message edits while
+	// a job runs.
+	jobReportInterval = 5 * time.Second
+	// jobRetryMax caps the wait before fetching a page again after an error.
+	jobRetryMax = 5 * time.Minute
+)
+
+// jobRetryBase is the wait after a page fetch first fails. It doubles with
+// every further failure of the same channel, up to jobRetryMax.
+var jobRetryBase = 5 * time.Second
+
+// ErrJobNotRunning is returned when cancelling a job that already finished.
+var ErrJobNotRunning = errors.New("job is not running")
+
+// downloadSlots limits how many messages are hashed at once across all jobs,
+// since each one downloads its media.
+var downloadSlots = make(chan struct{}, 4)
+
+// runningJobs holds the cancel function of each job running in this process.
+var runningJobs = struct {
+	sync.Mutex
+	cancels map[int64]context.CancelFunc
+}{
+	cancels: make(map[int64]context.CancelFunc),
+}
+
+// Job is a hash_server backfill. Each of its channels keeps a cursor, the
+// oldest message scanned so far, so a job interrupted by a restart resumes
+// where it stopped.
+type Job struct {
+	ID              int64
+	GuildID         string
+	RequestedBy     string
+	Status          string
+	Until           time.Time // messages older than this are not scanned
+	StatusChannelID string
+	StatusMessageID string
+	Channels        int
+	ChannelsDone    int
+	Messages        int
+	Hashes          int
+	Failures        int
+}
+
+type jobChannel struct {
+	channelID string
+	cursor    string
+	retries   int // failed fetches in a row
+}
+
+// jobSession is the part of a Discord session a job uses.
+type jobSession interface {
+	ChannelMessages(channelID string, limit int, beforeID, afterID, aroundID string, options ...discordgo.RequestOption) ([]*discordgo.Message, error)
+	ChannelMessageEditComplex(m *discordgo.MessageEdit, options ...discordgo.RequestOption) (*discordgo.Message, error)
+}
+
+const jobColumns = `j.id, j.guild_id, j.requested_by, j.status, j.until, j.status_channel_id, j.status_message_id,
+	(SELECT COUNT(*) FROM hash_job_channels c WHERE c.job_id = j.id),
+	(SELECT COUNT(*) FROM hash_job_channels c WHERE c.job_id = j.id AND c.done = 1),
+	j.messages, j.hashes, j.failures`
+
+func scanJob(row interface{ Scan(dest ...any) error }) (Job, error) {
+	var j Job
+	var until int64
+	err := row.Scan(&j.ID, &j.GuildID, &j.RequestedBy, &j.Status, &until, &j.StatusChannelID, &j.StatusMessageID,
+		&j.Channels, &j.ChannelsDone, &j.Messages, &j.Hashes, &j.Failures)
+	if err != nil {
+		return Job{}, err
+	}
+	if until > 0 {
+		j.Until = time.Unix(until, 0).UTC()
+	}
+	return j, nil
+}
+
+// GetJob loads a backfill job.
+func GetJob(id int64) (Job, error) {
+	return scanJob(database.QueryRow("SELECT "+jobColumns+" FROM hash_jobs j WHERE j.id = ?", id))
+}
+
+// RunningJob returns the guild's running backfill, if any.
+func RunningJob(guildID string) (Job, bool) {
+	j, err := scanJob(database.QueryRow("SELECT "+jobColumns+" FROM hash_jobs j WHERE j.guild_id = ? AND j.status = ? ORDER BY j.id LIMIT 1", guildID, JobRunning))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("Failed to look up running hash job: %v", err)
+		}
+		return Job{}, false
+	}
+	return j, true
+}
+
+// StartJob records a backfill of channelIDs back to until and runs it in the
+// background, reporting progress by editing status. Cancelling ctx stops the
+// job without finishing it, so ResumeJobs picks it up on the next start.
+func StartJob(ctx context.Context, s *discordgo.Session, guildID, requestedBy string, channelIDs []string, until time.Time, status *discordgo.Message) (Job, error) {
+	id, err := createJob(guildID, requestedBy, channelIDs, until, status)
+	if err != nil {
+		return Job{}, err
+	}
+	job, err := GetJob(id)
+	if err != nil {
+		return Job{}, err
+	}
+	go runJob(ctx, s, job)
+	return job, nil
+}
+
+func createJob(guildID, requestedBy string, channelIDs []string, until time.Time, status *discordgo.Message) (int64, error) {
+	var untilUnix int64
+	if !until.IsZero() {
+		untilUnix = until.Unix()
+	}
+	var statusChannelID, statusMessageID string
+	if status != nil {
+		statusChannelID, statusMessageID = status.ChannelID, status.ID
+	}
+
+	tx, err := database.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(
+		`INSERT INTO hash_jobs (guild_id, requested_by, status, until, status_channel_id, status_message_id)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+		guildID, requestedBy, JobRunning, untilUnix, statusChannelID, statusMessageID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	for _, channelID := range channelIDs {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO hash_job_channels (job_id, channel_id) VALUES (?, ?)", id, channelID); err != nil {
+			return 0, err
+		}
+	}
+	return id, tx.Commit()
+}
+
+// ResumeJobs restarts the backfills that were still running when the bot
+// last stopped. Must be called after the session is open.
+func ResumeJobs(ctx context.Context, s *discordgo.Session) {
+	rows, err := database.Query("SELECT "+jobColumns+" FROM hash_jobs j WHERE j.status = ? ORDER BY j.id", JobRunning)
+	if err != nil {
+		log.Printf("Failed to load running hash jobs: %v", err)
+		return
+	}
+	var jobs []Job
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			log.Printf("Failed to scan hash job: %v", err)
+			continue
+		}
+		jobs = append(jobs, j)
+	}
+	rows.Close()
+
+	for _, j := range jobs {
+		log.Printf("Resuming hash job %d in guild %s (%d/%d channels done)", j.ID, j.GuildID, j.ChannelsDone, j.Channels)
+		go runJob(ctx, s, j)
+	}
+}
+
+// CancelJob stops a running backfill of the guild. The job keeps what it has
+// hashed so far and reports its summary once the current page is done.
+func CancelJob(guildID string, id int64) error {
+	res, err := database.Exec("UPDATE hash_jobs SET status = ?, finished_at = unixepoch() WHERE id = ? AND guild_id = ? AND status = ?",
+		JobCancelled, id, guildID, JobRunning)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrJobNotRunning
+	}
+
+	runningJobs.Lock()
+	cancel, ok := runningJobs.cancels[id]
+	runningJobs.Unlock()
+	if ok {
+		cancel()
+	}
+	return nil
+}
+
+func runJob(ctx context.Context, s jobSession, job Job) {
+	ctx, cancel := context.WithCancel(ctx)
+	runningJobs.Lock()
+	runningJobs.cancels[job.ID] = cancel
+	runningJobs.Unlock()
+	defer func() {
+		runningJobs.Lock()
+		delete(runningJobs.cancels, job.ID)
+		runningJobs.Unlock()
+		cancel()
+	}()
+
+	channels, err := pendingJobChannels(job.ID)
+	if err != nil {
+		log.Printf("Failed to load channels of hash job %d: %v", job.ID, err)
+		return
+	}
+
+	var lastReport time.Time
+	for _, c := range channels {
+		for ctx.Err() == nil {
+			done := scanJobPage(ctx, s, job, &c)
+			if time.Since(lastReport) >= jobReportInterval {
+				if current, err := GetJob(job.ID); err == nil {
+					reportJob(s, current)
+				}
+				lastReport = time.Now()
+			}
+			if done {
+				break
+			}
+		}
+	}
+
+	if ctx.Err() == nil {
+		if _, err := database.Exec("UPDATE hash_jobs SET status = ?, finished_at = unixepoch() WHERE id = ? AND status = ?", JobDone, job.ID, JobRunning); err != nil {
+			log.Printf("Failed to finish hash job %d: %v", job.ID, err)
+		}
+	}
+
+	final, err := GetJob(job.ID)
+	if err != nil {
+		log.Printf("Failed to load hash job %d: %v", job.ID, err)
+		return
+	}
+	if final.Status == JobRunning {
+		// Shutting down; the job resumes on the next start.
+		return
+	}
+	log.Printf("Hash job %d %s: %d messages, %d hashes, %d failures", final.ID, final.Status, final.Messages, final.Hashes, final.Failures)
+	reportJob(s, final)
+}
+
+// scanJobPage hashes the next page of c older than its cursor and saves the
+// new cursor. It reports whether the channel is finished.
+func scanJobPage(ctx context.Context, s jobSession, job Job, c *jobChannel) bool {
+	batch, err := s.ChannelMessages(c.channelID, jobPageSize, c.cursor, "", "")
+	if err != nil {
+		if channelUnreadable(err) {
+			// A channel the bot can't read or that was deleted is skipped
+			// rather than retried forever.
+			log.Printf("Hash job %d: skipping channel %s: %v", job.ID, c.channelID, err)
+			saveJobPage(job.ID, c.channelID, c.cursor, true, 0, 0, 1)
+			return true
+		}
+		// Anything else, like a timeout or an outage, is retried from the
+		// same cursor.
+		delay := min(jobRetryBase<<min(c.retries, 10), jobRetryMax)
+		c.retries++
+		log.Printf("Hash job %d: failed to fetch messages of channel %s, retrying in %s: %v", job.ID, c.channelID, delay, err)
+		select {
+		case <-ctx.Done():
+		case <-time.After(delay):
+		}
+		return false
+	}
+	c.retries = 0
+
+	done := len(batch) < jobPageSize
+	var messages []*discordgo.Message
+	for _, m := range batch {
+		if !job.Until.IsZero() && m.Timestamp.Before(job.Until) {
+			done = true
+			break
+		}
+		messages = append(messages, m)
+	}
+
+	hashes, failures := hashJobMessages(ctx, job.GuildID, messages)
+	if ctx.Err() != nil {
+		// The page may be half hashed, so its cursor isn't saved and a resumed
+		// job scans it again. Hashing is idempotent.
+		return false
+	}
+	if len(batch) > 0 {
+		c.cursor = batch[len(batch)-1].ID
+	}
+	saveJobPage(job.ID, c.channelID, c.cursor, done, len(messages), hashes, failures)
+	return done
+}
+
+// channelUnreadable reports whether err is Discord refusing a channel's
+// history for good: missing access or an unknown channel.
+func channelUnreadable(err error) bool {
+	var restErr *discordgo.RESTError
+	if !errors.As(err, &restErr) || restErr.Response == nil {
+		return false
+	}
+	return restErr.Response.StatusCode == http.StatusForbidden || restErr.Response.StatusCode == http.StatusNotFound
+}
+
+// hashJobMessages stores the media of messages, downloading at most
+// cap(downloadSlots) at a time.
+func hashJobMessages(ctx context.Context, guildID string, messages []*discordgo.Message) (int, int) {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var hashes, failures int
+	options := HashOptions{Store: true, GuildID: guildID, IgnoreURLs: StickerURLs}
+
+	for _, m := range messages {
+		if !utility.HasImageURL(m) && !utility.HasVideoURL(m) {
+			continue
+		}
+		select {
+		case downloadSlots <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return hashes, failures
+		}
+		wg.Add(1)
+		go func(m *discordgo.Message) {
+			defer wg.Done()
+			defer func() { <-downloadSlots }()
+			found := fingerprintAttachments(m, options)
+			mu.Lock()
+			hashes += found.stored
+			failures += found.failed
+			mu.Unlock()
+		}(m)
+	}
+	wg.Wait()
+	return hashes, failures
+}
+
+func saveJobPage(jobID int64, channelID, cursor string, done bool, messages, hashes, failures int) {
+	tx, err := database.Begin()
+	if err != nil {
+		log.Printf("Failed to save hash job %d progress: %v", jobID, err)
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE hash_job_channels SET cursor = ?, done = ?, messages = messages + ? WHERE job_id = ? AND channel_id = ?",
+		cursor, done, messages, jobID, channelID); err != nil {
+		log.Printf("Failed to save hash job %d cursor: %v", jobID, err)
+		return
+	}
+	if _, err := tx.Exec("UPDATE hash_jobs SET messages = messages + ?, hashes = hashes + ?, failures = failures + ? WHERE id = ?",
+		messages, hashes, failures, jobID); err != nil {
+		log.Printf("Failed to save hash job %d counts: %v", jobID, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to save hash job %d progress: %v", jobID, err)
+	}
+}
+
+func pendingJobChannels(jobID int64) ([]jobChannel, error) {
+	rows, err := database.Query("SELECT channel_id, cursor FROM hash_job_channels WHERE job_id = ? AND done = 0 ORDER BY rowid", jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var channels []jobChannel
+	for rows.Next() {
+		var c jobChannel
+		if err := rows.Scan(&c.channelID, &c.cursor); err != nil {
+			return nil, err
+		}
+		channels = append(channels, c)
+	}
+	return channels, rows.Err()
+}
+
+// reportJob edits the job's status message to show its progress.
+func reportJob(s jobSession, job Job) {
+	if job.StatusMessageID == "" {
+		return
+	}
+	content := FormatJob(job)
+	components := JobComponents(job)
+	_, err := s.ChannelMessageEditComplex(&discordgo.MessageEdit{
+		ID:         job.StatusMessageID,
+		Channel:    job.StatusChannelID,
+		Content:    &content,
+		Components: &components,
+	})
+	if err != nil {
+		log.Printf("Failed to report hash job %d: %v", job.ID, err)
+	}
+}
+
+// FormatJob describes a job's progress or, once it stopped, its summary.
+func FormatJob(job Job) string {
+	until := "the beginning"
+	if !job.Until.IsZero() {
+		until = job.Until.Format("2006/01/02")
+	}
+	return fmt.Sprintf("Hash job #%d\nStatus: %s\nChannels: %d/%d\nHashing until: %s\nMessages scanned: %d\nHashes stored: %d\nFailures: %d",
+		job.ID, job.Status, job.ChannelsDone, job.Channels, until, job.Messages, job.Hashes, job.Failures)
+}
+
+// JobComponents returns the cancel button shown while a job runs.
+func JobComponents(job Job) []discordgo.MessageComponent {
+	if job.Status != JobRunning {
+		return []discordgo.MessageComponent{}
+	}
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Cancel",
+					Style:    discordgo.DangerButton,
+					CustomID: fmt.Sprintf("hash_cancel-%d", job.ID),
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,258 @@
+package hasher
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// fakeJobSession serves channel history newest first, like Discord.
+type fakeJobSession struct {
+	mu      sync.Mutex
+	history map[string][]*discordgo.Message
+	fetches []string // channel:beforeID of every fetch
+	edits   []*discordgo.MessageEdit
+	onFetch func(n int) error // called before the nth fetch is answered; an error fails it
+}
+
+func (f *fakeJobSession) ChannelMessages(channelID string, limit int, beforeID, _, _ string, _ ...discordgo.RequestOption) ([]*discordgo.Message, error) {
+	f.mu.Lock()
+	f.fetches = append(f.fetches, channelID+":"+beforeID)
+	n := len(f.fetches)
+	f.mu.Unlock()
+	if f.onFetch != nil {
+		if err := f.onFetch(n); err != nil {
+			return nil, err
+		}
+	}
+
+	messages, ok := f.history[channelID]
+	if !ok {
+		return nil, &discordgo.RESTError{Response: &http.Response{StatusCode: http.StatusForbidden}}
+	}
+	start := 0
+	if beforeID != "" {
+		for start < len(messages) && messages[start].ID != beforeID {
+			start++
+		}
+		start++
+	}
+	end := min(start+limit, len(messages))
+	if start >= end {
+		return nil, nil
+	}
+	return messages[start:end], nil
+}
+
+func (f *fakeJobSession) ChannelMessageEditComplex(m *discordgo.MessageEdit, _ ...discordgo.RequestOption) (*discordgo.Message, error) {
+	f.mu.Lock()
+	f.edits = append(f.edits, m)
+	f.mu.Unlock()
+	return &discordgo.Message{ID: m.ID, ChannelID: m.Channel}, nil
+}
+
+func (f *fakeJobSession) lastEdit(t *testing.T) string {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.edits) == 0 {
+		t.Fatal("status message was never edited")
+	}
+	return *f.edits[len(f.edits)-1].Content
+}
+
+// channelHistory builds count messages, newest first, an hour apart back from
+// base. Every message in withImage links imageURL.
+func channelHistory(channelID string, count int, base time.Time, imageURL string, withImage map[int]bool) []*discordgo.Message {
+	messages := make([]*discordgo.Message, count)
+	for i := range messages {
+		m := &discordgo.Message{
+			ID:        strconv.Itoa(10000 - i),
+			ChannelID: channelID,
+			Timestamp: base.Add(-time.Duration(i) * time.Hour),
+			Author:    &discordgo.User{ID: "u", Username: "user"},
+		}
+		if withImage[i] {
+			m.Attachments = []*discordgo.MessageAttachment{{URL: imageURL + "/" + m.ID + ".png", Width: 64, Height: 64}}
+		}
+		messages[i] = m
+	}
+	return messages
+}
+
+func newTestJob(t *testing.T, channelIDs []string, until time.Time) Job {
+	t.Helper()
+	id, err := createJob("g", "admin", channelIDs, until, &discordgo.Message{ID: "status", ChannelID: "cmd"})
+	if err != nil {
+		t.Fatalf("createJob: %v", err)
+	}
+	job, err := GetJob(id)
+	if err != nil {
+		t.Fatalf("GetJob: %v", err)
+	}
+	return job
+}
+
+func TestJobScansChannelsBackToUntil(t *testing.T) {
+	setupHasherWithDB(t)
+	srv := servePNG(t, makePatternImage(64, 64, nil))
+	defer srv.Close()
+
+	base := time.Date(2024, 5, 20, 0, 0, 0, 0, time.UTC)
+	s := &fakeJobSession{history: map[string][]*discordgo.Message{
+		"a": channelHistory("a", 250, base, srv.URL, map[int]bool{150: true, 240: true}),
+	}}
+	// "b" can't be read, and the last 10 messages of "a" are too old.
+	job := newTestJob(t, []string{"a", "b"}, base.Add(-239*time.Hour))
+
+	runJob(context.Background(), s, job)
+
+	final, err := GetJob(job.ID)
+	if err != nil {
+		t.Fatalf("GetJob: %v", err)
+	}
+	if final.Status != JobDone || final.ChannelsDone != 2 {
+		t.Errorf("job = %+v, want done with both channels done", final)
+	}
+	if final.Messages != 240 || final.Hashes != 1 || final.Failures != 1 {
+		t.Errorf("counts = %d messages, %d hashes, %d failures; want 240, 1, 1", final.Messages, final.Hashes, final.Failures)
+	}
+	if got, _ := TopReposters("g", 10); len(got) != 0 {
+		t.Errorf("TopReposters() = %+v, want the post before the date range skipped", got)
+	}
+
+	summary := s.lastEdit(t)
+	for _, want := range []string{"Status: done", "Channels: 2/2", "Messages scanned: 240", "Hashes stored: 1", "Failures: 1"} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("summary %q missing %q", summary, want)
+		}
+	}
+	if c := s.edits[len(s.edits)-1].Components; c == nil || len(*c) != 0 {
+		t.Errorf("summary components = %v, want the cancel button removed", c)
+	}
+}
+
+func TestJobResumesFromCursorAfterShutdown(t *testing.T) {
+	setupHasherWithDB(t)
+
+	base := time.Date(2024, 5, 20, 0, 0, 0, 0, time.UTC)
+	ctx, shutdown := context.WithCancel(context.Background())
+	s := &fakeJobSession{history: map[string][]*discordgo.Message{
+		"a": channelHistory("a", 250, base, "", nil),
+	}}
+	s.onFetch = func(n int) error {
+		if n == 2 {
+			shutdown()
+		}
+		return nil
+	}
+	job := newTestJob(t, []string{"a"}, time.Time{})
+
+	runJob(ctx, s, job)
+
+	stopped, err := GetJob(job.ID)
+	if err != nil {
+		t.Fatalf("GetJob: %v", err)
+	}
+	if stopped.Status != JobRunning || stopped.Messages != 100 {
+		t.Fatalf("after shutdown job = %+v, want still running with the first page saved", stopped)
+	}
+
+	s.onFetch = nil
+	s.fetches = nil
+	runJob(context.Background(), s, stopped)
+
+	if len(s.fetches) == 0 || s.fetches[0] != "a:9901" {
+		t.Errorf("resumed fetches = %v, want to start before the saved cursor 9901", s.fetches)
+	}
+	final, err := GetJob(job.ID)
+	if err != nil {
+		t.Fatalf("GetJob: %v", err)
+	}
+	if final.Status != JobDone || final.Messages != 250 {
+		t.Errorf("resumed job = %+v, want done with 250 messages", final)
+	}
+}
+
+func TestJobRetriesTransientFetchErrors(t *testing.T) {
+	setupHasherWithDB(t)
+	previousBase := jobRetryBase
+	jobRetryBase = time.Millisecond
+	t.Cleanup(func() { jobRetryBase = previousBase })
+
+	base := time.Date(2024, 5, 20, 0, 0, 0, 0, time.UTC)
+	s := &fakeJobSession{history: map[string][]*discordgo.Message{
+		"a": channelHistory("a", 150, base, "", nil),
+	}}
+	// The second and third fetches time out.
+	s.onFetch = func(n int) error {
+		if n == 2 || n == 3 {
+			return errors.New("i/o timeout")
+		}
+		return nil
+	}
+	job := newTestJob(t, []string{"a"}, time.Time{})
+
+	runJob(context.Background(), s, job)
+
+	final, err := GetJob(job.ID)
+	if err != nil {
+		t.Fatalf("GetJob: %v", err)
+	}
+	if final.Status != JobDone || final.Messages != 150 || final.Failures != 0 {
+		t.Errorf("job = %+v, want done with all 150 messages and no failures", final)
+	}
+	if len(s.fetches) != 4 || s.fetches[1] != "a:9901" || s.fetches[3] != "a:9901" {
+		t.Errorf("fetches = %v, want the failed page retried from cursor 9901", s.fetches)
+	}
+}
+
+func TestCancelJob(t *testing.T) {
+	setupHasherWithDB(t)
+
+	base := time.Date(2024, 5, 20, 0, 0, 0, 0, time.UTC)
+	s := &fakeJobSession{history: map[string][]*discordgo.Message{
+		"a": channelHistory("a", 250, base, "", nil),
+	}}
+	job := newTestJob(t, []string{"a"}, time.Time{})
+	s.onFetch = func(n int) error {
+		if n == 2 {
+			if err := CancelJob("other", job.ID); !errors.Is(err, ErrJobNotRunning) {
+				t.Errorf("CancelJob from another guild = %v, want ErrJobNotRunning", err)
+			}
+			if err := CancelJob("g", job.ID); err != nil {
+				t.Errorf("CancelJob: %v", err)
+			}
+		}
+		return nil
+	}
+
+	runJob(context.Background(), s, job)
+
+	final, err := GetJob(job.ID)
+	if err != nil {
+		t.Fatalf("GetJob: %v", err)
+	}
+	if final.Status != JobCancelled || final.Messages != 100 {
+		t.Errorf("job = %+v, want cancelled after the first page", final)
+	}
+	if len(s.fetches) != 2 {
+		t.Errorf("fetches = %v, want none after cancelling", s.fetches)
+	}
+	if summary := s.lastEdit(t); !strings.Contains(summary, "Status: cancelled") {
+		t.Errorf("summary = %q, want it to report the cancellation", summary)
+	}
+	if err := CancelJob("g", job.ID); !errors.Is(err, ErrJobNotRunning) {
+		t.Errorf("second CancelJob = %v, want ErrJobNotRunning", err)
+	}
+	if _, ok := RunningJob("g"); ok {
+		t.Error("RunningJob found a job after cancelling it")
+	}
+}
@@ -0,0 +1,159 @@
+package hasher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"voltgpt/internal/mediacache"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// searchCandidates is how many stored images per requested result are scored
+// on all their hashes, after ranking by the index distance alone.
+const searchCandidates = 3
+
+// searchThreshold is the search radius FindImage results are held to by
+// minConfidence; candidates are gathered further out and then scored.
+const searchThreshold = unrelatedDistance / 4
+
+// maxSearchBytes caps the size of an image downloaded to search for.
+const maxSearchBytes = 25 << 20
+
+var (
+	// ErrNoImage is returned when a search URL has no image that can be hashed.
+	ErrNoImage = errors.New("no image could be hashed from that link")
+	// ErrBadLink is returned for search URLs that aren't a single public
+	// http or https link.
+	ErrBadLink = errors.New("only public http and https links can be searched")
+)
+
+// lookupIPAddr resolves search URL hosts and searchDownload fetches them; tests
+// replace both.
+var (
+	lookupIPAddr   = net.DefaultResolver.LookupIPAddr
+	searchDownload = mediacache.FetchPublic
+)
+
+// ImageMatch is a stored image near a searched one.
+type ImageMatch struct {
+	Hash       string
+	Distance   int
+	Confidence float64
+	Mirrored   bool
+	Message    *discordgo.Message
+}
+
+// FindImage hashes the image or video at mediaURL the way HashAttachments
+// does and returns up to limit stored images of the guild nearest to it, most
+// similar first. Images too unlike it to be a repost are left out. Nothing is
+// stored. The URL is user input, so it must point at a public host, and it is
+// downloaded with a client that keeps to public hosts through redirects.
+func FindImage(guildID, mediaURL string, limit int) ([]ImageMatch, error) {
+	if err := checkSearchURL(mediaURL); err != nil {
+		return nil, err
+	}
+	query := &discordgo.Message{Content: mediaURL}
+	found := fingerprintAttachments(query, HashOptions{GuildID: guildID, MaxBytes: maxSearchBytes, download: searchDownload})
+	queries := found.images
+	for _, v := range found.videos {
+		for _, f := range v.frames {
+			queries = append(queries, fingerprint{algoAverage: f})
+		}
+	}
+	if len(queries) == 0 {
+		return nil, ErrNoImage
+	}
+
+	// Rank every stored image by its closest hash to any query, then score
+	// the best of them on all their hashes.
+	type candidate struct {
+		hash     string
+		query    int
+		distance int
+	}
+	best := make(map[string]candidate)
+	hashStore.RLock()
+	if ns, ok := hashStore.guilds[guildID]; ok {
+		for qi, fp := range queries {
+			for _, path := range searchPaths {
+				q := fp[path.query]
+				if q == nil {
+					continue
+				}
+				for _, match := range ns.indexes[path.stored].search(q, unrelatedDistance) {
+					for _, owner := range match.owners {
+						if c, ok := best[owner]; !ok || match.distance < c.distance {
+							best[owner] = candidate{owner, qi, match.distance}
+						}
+					}
+				}
+			}
+		}
+	}
+	hashStore.RUnlock()
+
+	candidates := make([]candidate, 0, len(best))
+	for _, c := range best {
+		candidates = append(candidates, c)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].hash < candidates[j].hash
+	})
+	candidates = candidates[:min(len(candidates), limit*searchCandidates)]
+
+	matches := make([]ImageMatch, 0, len(candidates))
+	for _, c := range candidates {
+		sim := compareFingerprints(queries[c.query], readFingerprintFromDB(guildID, c.hash))
+		if sim.confidence < minConfidence(searchThreshold) {
+			continue
+		}
+		msg, err := readHashFromDB(guildID, c.hash)
+		if err != nil {
+			log.Printf("Failed to read hash from DB: %v", err)
+			continue
+		}
+		matches = append(matches, ImageMatch{
+			Hash:       c.hash,
+			Distance:   sim.distance,
+			Confidence: sim.confidence,
+			Mirrored:   sim.mirrored,
+			Message:    msg,
+		})
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Confidence > matches[j].Confidence
+	})
+	return matches[:min(len(matches), limit)], nil
+}
+
+// checkSearchURL rejects anything but one http or https link to a host that
+// resolves only to public addresses.
+func checkSearchURL(rawURL string) error {
+	if len(strings.Fields(rawURL)) != 1 {
+		return ErrBadLink
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return ErrBadLink
+	}
+	addrs, err := lookupIPAddr(context.Background(), u.Hostname())
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", u.Hostname(), err)
+	}
+	for _, addr := range addrs {
+		if !mediacache.IsPublicIP(addr.IP) {
+			return ErrBadLink
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package hasher
+
+import (
+	"context"
+	"errors"
+	"image/color"
+	"net"
+	"testing"
+
+	"voltgpt/internal/utility"
+)
+
+// resolvePublic makes every search host resolve to a public address and be
+// downloaded like any other media, so FindImage accepts links to local test
+// servers.
+func resolvePublic(t *testing.T) {
+	t.Helper()
+	previousLookup, previousDownload := lookupIPAddr, searchDownload
+	lookupIPAddr = func(context.Context, string) ([]net.IPAddr, error) {
+		return []net.IPAddr{{IP: net.ParseIP("93.184.216.34")}}, nil
+	}
+	searchDownload = utility.DownloadBytesLimit
+	t.Cleanup(func() { lookupIPAddr, searchDownload = previousLookup, previousDownload })
+}
+
+func TestFindImageRanksNearestFirst(t *testing.T) {
+	setupHasherWithDB(t)
+	resolvePublic(t)
+
+	original := servePNG(t, makePatternImage(64, 64, nil))
+	defer original.Close()
+	brighter := servePNG(t, makePatternImage(64, 64, func(c color.RGBA) color.RGBA {
+		c.R = uint8(min(255, int(c.R)+60))
+		return c
+	}))
+	defer brighter.Close()
+	unrelated := servePNG(t, makeTestImage(64, 64, color.RGBA{R: 200, A: 255}))
+	defer unrelated.Close()
+
+	postImage(t, "10", "alice", original.URL+"/a.png", 0)
+	postImage(t, "20", "bob", brighter.URL+"/b.png", 1)
+	postImage(t, "30", "carol", unrelated.URL+"/c.png", 2)
+	before := TotalHashes()
+
+	matches, err := FindImage("g", original.URL+"/query.png", 10)
+	if err != nil {
+		t.Fatalf("FindImage: %v", err)
+	}
+	if len(matches) == 0 || matches[0].Message.ID != "10" || matches[0].Distance != 0 {
+		t.Fatalf("FindImage() = %+v, want alice's identical post first", matches)
+	}
+	for _, m := range matches {
+		if m.Message.ID == "30" {
+			t.Errorf("FindImage() returned carol's unrelated post at %.0f%%", m.Confidence*100)
+		}
+	}
+	for i := 1; i < len(matches); i++ {
+		if matches[i].Confidence > matches[i-1].Confidence {
+			t.Errorf("match %d is more similar than match %d", i, i-1)
+		}
+	}
+	if n := TotalHashes(); n != before {
+		t.Errorf("TotalHashes = %d after searching, want %d", n, before)
+	}
+
+	if top, _ := FindImage("g", original.URL+"/query.png", 1); len(top) != 1 {
+		t.Errorf("FindImage(limit 1) = %d matches, want 1", len(top))
+	}
+	if other, _ := FindImage("other", original.URL+"/query.png", 10); len(other) != 0 {
+		t.Errorf("FindImage() in another guild = %+v, want none", other)
+	}
+	if _, err := FindImage("g", "https://example.com/page", 10); !errors.Is(err, ErrNoImage) {
+		t.Errorf("FindImage() of a page = %v, want ErrNoImage", err)
+	}
+}
+
+func TestFindImageRejectsPrivateLinks(t *testing.T) {
+	setupHasherWithDB(t)
+	srv := servePNG(t, makePatternImage(64, 64, nil))
+	defer srv.Close()
+
+	for _, link := range []string{
+		srv.URL + "/query.png",
+		"http://localhost/query.png",
+		"http://[::1]/query.png",
+		"http://10.0.0.1/query.png",
+		"http://169.254.169.254/latest/meta-data",
+		"file:///etc/passwd",
+		"ftp://example.com/query.png",
+		"https://example.com/a.png " + srv.URL + "/query.png",
+	} {
+		if _, err := FindImage("g", link, 10); !errors.Is(err, ErrBadLink) {
+			t.Errorf("FindImage(%q) = %v, want ErrBadLink", link, err)
+		}
+	}
+}
+
+func TestFindImageDownloadsOnlyFromPublicAddresses(t *testing.T) {
+	setupHasherWithDB(t)
+	srv := servePNG(t, makePatternImage(64, 64, nil))
+	defer srv.Close()
+
+	// The host passes the lookup but connects to a local address, as after
+	// a redirect or a changed DNS answer.
+	previous := lookupIPAddr
+	lookupIPAddr = func(context.Context, string) ([]net.IPAddr, error) {
+		return []net.IPAddr{{IP: net.ParseIP("93.184.216.34")}}, nil
+	}
+	t.Cleanup(func() { lookupIPAddr = previous })
+
+	if _, err := FindImage("g", srv.URL+"/query.png", 10); !errors.Is(err, ErrNoImage) {
+		t.Errorf("FindImage() = %v, want ErrNoImage with the download refused", err)
+	}
+}
@@ -0,0 +1,242 @@
+package hasher
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// sightingThreshold is the average hash distance within which a stored post
+// counts as a sighting of an image already stored rather than a new image.
+const sightingThreshold = 8
+
+// sightingOrder ranks the posts of one image oldest first. Snowflakes of equal
+// length sort like their numbers, which breaks ties within a second.
+const sightingOrder = "ORDER BY posted_at, length(message_id), message_id"
+
+// Sighting is one post of an image.
+type Sighting struct {
+	MessageID  string
+	ChannelID  string
+	AuthorID   string
+	AuthorName string
+	Timestamp  time.Time
+}
+
+// Reposter is a user ranked by how many images they posted after someone else
+// already had.
+type Reposter struct {
+	AuthorID   string
+	AuthorName string
+	Reposts    int
+}
+
+// RepostedImage is an image ranked by how often it was posted again.
+type RepostedImage struct {
+	Hash    string
+	First   Sighting
+	Reposts int
+}
+
+// canonicalHash returns the stored image a post of fp is a sighting of: the
+// image the nearest stored average hash within sightingThreshold is itself a
+// sighting of, or fp's own hash. Following the stored link keeps a chain of
+// slightly edited reposts on the first image rather than drifting away from it.
+func canonicalHash(guildID string, fp fingerprint) string {
+	hashStore.RLock()
+	defer hashStore.RUnlock()
+
+	best, bestDistance := fp.key(), sightingThreshold+1
+	ns, ok := hashStore.guilds[guildID]
+	if !ok {
+		return best
+	}
+	for _, m := range ns.indexes[algoAverage].search(fp[algoAverage], sightingThreshold) {
+		if m.distance < bestDistance || (m.distance == bestDistance && m.key < best) {
+			best, bestDistance = m.key, m.distance
+		}
+	}
+	if canonical := ns.images[best].canonical; canonical != "" {
+		return canonical
+	}
+	return best
+}
+
+// linkCanonical records that the image stored under hash is a sighting of
+// canonical, so near-duplicates of it resolve to canonical as well.
+func linkCanonical(guildID, hash, canonical string) {
+	if hash == canonical {
+		return
+	}
+	hashStore.Lock()
+	ns := namespaceLocked(guildID)
+	if entry, ok := ns.images[hash]; ok {
+		entry.canonical = canonical
+		ns.images[hash] = entry
+	}
+	hashStore.Unlock()
+
+	_, err := database.Exec("UPDATE image_hashes SET canonical_hash = ? WHERE guild_id = ? AND hash = ?", canonical, guildID, hash)
+	if err != nil {
+		log.Printf("Failed to link %s to %s: %v", hash, canonical, err)
+	}
+}
+
+func recordSighting(guildID, hash string, message *discordgo.Message) {
+	var authorID, authorName string
+	if message.Author != nil {
+		authorID, authorName = message.Author.ID, message.Author.Username
+	}
+	_, err := database.Exec(
+		`INSERT OR IGNORE INTO image_hash_sightings
+			(guild_id, hash, message_id, channel_id, author_id, author_name, posted_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		guildID, hash, message.ID, message.ChannelID, authorID, authorName, time.Time(message.Timestamp).Unix(),
+	)
+	if err != nil {
+		log.Printf("Failed to record sighting of %s: %v", hash, err)
+	}
+}
+
+// Sightings returns every recorded post of the image stored under hash,
+// oldest first.
+func Sightings(guildID, hash string) ([]Sighting, error) {
+	rows, err := database.Query(
+		`SELECT message_id, channel_id, author_id, author_name, posted_at
+			FROM image_hash_sightings WHERE guild_id = ? AND hash = ? `+sightingOrder,
+		guildID, hash,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sightings []Sighting
+	for rows.Next() {
+		var s Sighting
+		var postedAt int64
+		if err := rows.Scan(&s.MessageID, &s.ChannelID, &s.AuthorID, &s.AuthorName, &postedAt); err != nil {
+			return nil, err
+		}
+		s.Timestamp = time.Unix(postedAt, 0).UTC()
+		sightings = append(sightings, s)
+	}
+	return sightings, rows.Err()
+}
+
+// TopReposters ranks the guild's users by how many images they posted after
+// the first sighting. Posting one's own image again is not a repost.
+func TopReposters(guildID string, limit int) ([]Reposter, error) {
+	rows, err := database.Query(`
+		WITH ranked AS (
+			SELECT author_id, author_name,
+				ROW_NUMBER() OVER (PARTITION BY hash `+sightingOrder+`) AS n,
+				FIRST_VALUE(author_id) OVER (PARTITION BY hash `+sightingOrder+`) AS first_author
+			FROM image_hash_sightings WHERE guild_id = ?
+		)
+		SELECT author_id, MAX(author_name), COUNT(*) AS reposts
+		FROM ranked WHERE n > 1 AND author_id != first_author
+		GROUP BY author_id
+		ORDER BY reposts DESC, MAX(author_name)
+		LIMIT ?`, guildID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reposters []Reposter
+	for rows.Next() {
+		var r Reposter
+		if err := rows.Scan(&r.AuthorID, &r.AuthorName, &r.Reposts); err != nil {
+			return nil, err
+		}
+		reposters = append(reposters, r)
+	}
+	return reposters, rows.Err()
+}
+
+// MostReposted ranks the guild's images by how many times they were posted
+// after the first sighting.
+func MostReposted(guildID string, limit int) ([]RepostedImage, error) {
+	rows, err := database.Query(`
+		WITH ranked AS (
+			SELECT *,
+				ROW_NUMBER() OVER (PARTITION BY hash `+sightingOrder+`) AS n,
+				COUNT(*) OVER (PARTITION BY hash) AS total
+			FROM image_hash_sightings WHERE guild_id = ?
+		)
+		SELECT hash, message_id, channel_id, author_id, author_name, posted_at, total - 1
+		FROM ranked WHERE n = 1 AND total > 1
+		ORDER BY total DESC, posted_at
+		LIMIT ?`, guildID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var images []RepostedImage
+	for rows.Next() {
+		var img RepostedImage
+		var postedAt int64
+		if err := rows.Scan(&img.Hash, &img.First.MessageID, &img.First.ChannelID, &img.First.AuthorID,
+			&img.First.AuthorName, &postedAt, &img.Reposts); err != nil {
+			return nil, err
+		}
+		img.First.Timestamp = time.Unix(postedAt, 0).UTC()
+		images = append(images, img)
+	}
+	return images, rows.Err()
+}
+
+// describeSightings summarises an image's history, e.g. "first posted by alice
+// on 2024-05-01, reposted 4 times by bob (3), carol". It returns "" when the
+// image was only posted once.
+func describeSightings(sightings []Sighting) string {
+	if len(sightings) < 2 {
+		return ""
+	}
+	first, reposts := sightings[0], sightings[1:]
+
+	counts := make(map[string]int)
+	var names []string
+	for _, s := range reposts {
+		if counts[s.AuthorName] == 0 {
+			names = append(names, s.AuthorName)
+		}
+		counts[s.AuthorName]++
+	}
+	sort.SliceStable(names, func(i, j int) bool { return counts[names[i]] > counts[names[j]] })
+	for i, name := range names {
+		if counts[name] > 1 {
+			names[i] = fmt.Sprintf("%s (%d)", name, counts[name])
+		}
+	}
+
+	times := "times"
+	if len(reposts) == 1 {
+		times = "time"
+	}
+	return fmt.Sprintf("first posted by %s on %s, reposted %d %s by %s",
+		first.AuthorName, first.Timestamp.Format("2006-01-02"), len(reposts), times, strings.Join(names, ", "))
+}
+
+// mergeSightings combines the sightings of several stored hashes of one image,
+// dropping posts seen under more than one.
+func mergeSightings(lists ...[]Sighting) []Sighting {
+	seen := make(map[string]bool)
+	var merged []Sighting
+	for _, list := range lists {
+		for _, s := range list {
+			if !seen[s.MessageID] {
+				seen[s.MessageID] = true
+				merged = append(merged, s)
+			}
+		}
+	}
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].Timestamp.Before(merged[j].Timestamp) })
+	return merged
+}
@@ -0,0 +1,191 @@
+package hasher
+
+import (
+	"image/color"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/corona10/goimagehash"
+)
+
+// postImage stores a post of the image served at url by author, hours after base.
+func postImage(t *testing.T, id, author, url string, hours int) *discordgo.Message {
+	t.Helper()
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	msg := &discordgo.Message{
+		ID:          id,
+		GuildID:     "g",
+		ChannelID:   "ch",
+		Timestamp:   base.Add(time.Duration(hours) * time.Hour),
+		Author:      &discordgo.User{ID: author + "-id", Username: author},
+		Attachments: []*discordgo.MessageAttachment{{URL: url, Width: 64, Height: 64}},
+	}
+	HashAttachments(msg, HashOptions{Store: true})
+	return msg
+}
+
+func TestSightingsKeepEveryPost(t *testing.T) {
+	setupHasherWithDB(t)
+
+	original := servePNG(t, makePatternImage(64, 64, nil))
+	defer original.Close()
+	// A brightened repost is still the same image.
+	edited := servePNG(t, makePatternImage(64, 64, func(c color.RGBA) color.RGBA {
+		c.G = uint8(min(255, int(c.G)+20))
+		return c
+	}))
+	defer edited.Close()
+
+	// Posts arrive out of order, like hash_server walking history backwards.
+	postImage(t, "30", "carol", edited.URL+"/c.png", 3)
+	postImage(t, "20", "bob", original.URL+"/b.png", 2)
+	postImage(t, "10", "alice", original.URL+"/a.png", 0)
+	postImage(t, "40", "bob", original.URL+"/d.png", 4)
+	postImage(t, "40", "bob", original.URL+"/d.png", 4) // hashed twice
+
+	if n := TotalHashes(); n != 1 {
+		t.Fatalf("TotalHashes = %d, want 1 stored image", n)
+	}
+
+	reposters, err := TopReposters("g", 10)
+	if err != nil {
+		t.Fatalf("TopReposters: %v", err)
+	}
+	if len(reposters) != 2 || reposters[0].AuthorName != "bob" || reposters[0].Reposts != 2 || reposters[1].Reposts != 1 {
+		t.Errorf("TopReposters() = %+v, want bob with 2 then carol with 1", reposters)
+	}
+
+	images, err := MostReposted("g", 10)
+	if err != nil {
+		t.Fatalf("MostReposted: %v", err)
+	}
+	if len(images) != 1 || images[0].Reposts != 3 || images[0].First.AuthorName != "alice" {
+		t.Errorf("MostReposted() = %+v, want one image first posted by alice with 3 reposts", images)
+	}
+
+	if other, _ := TopReposters("other", 10); len(other) != 0 {
+		t.Errorf("TopReposters() in another guild = %+v, want none", other)
+	}
+
+	repost := &discordgo.Message{
+		ID:          "50",
+		ChannelID:   "ch",
+		Timestamp:   time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC),
+		Author:      &discordgo.User{Username: "dave"},
+		Attachments: []*discordgo.MessageAttachment{{URL: original.URL + "/e.png", Width: 64, Height: 64}},
+	}
+	content, _ := FindSnails("g", repost, HashOptions{Threshold: 8})
+	want := "History: first posted by alice on 2024-05-01, reposted 3 times by bob (2), carol"
+	if !strings.Contains(content, want) {
+		t.Errorf("FindSnails() = %q, want it to contain %q", content, want)
+	}
+}
+
+func TestDescribeSightings(t *testing.T) {
+	day := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	alone := []Sighting{{MessageID: "1", AuthorName: "alice", Timestamp: day}}
+	if got := describeSightings(alone); got != "" {
+		t.Errorf("describeSightings(one post) = %q, want empty", got)
+	}
+
+	got := describeSightings(append(alone, Sighting{MessageID: "2", AuthorName: "bob", Timestamp: day}))
+	if got != "first posted by alice on 2024-01-02, reposted 1 time by bob" {
+		t.Errorf("describeSightings() = %q", got)
+	}
+}
+
+func TestMergeSightings(t *testing.T) {
+	day := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	a := []Sighting{{MessageID: "1", Timestamp: day}, {MessageID: "3", Timestamp: day.Add(2 * time.Hour)}}
+	b := []Sighting{{MessageID: "2", Timestamp: day.Add(time.Hour)}, {MessageID: "3", Timestamp: day.Add(2 * time.Hour)}}
+
+	merged := mergeSightings(a, b)
+	var ids []string
+	for _, s := range merged {
+		ids = append(ids, s.MessageID)
+	}
+	if strings.Join(ids, ",") != "1,2,3" {
+		t.Errorf("mergeSightings() = %v, want 1,2,3", ids)
+	}
+}
+
+func TestCanonicalHash(t *testing.T) {
+	setupHasherWithDB(t)
+
+	r := rand.New(rand.NewPCG(15, 16))
+	stored := randomHash(r)
+	writeHash("g", stored.ToString(), &discordgo.Message{ID: "1"})
+
+	near := fingerprint{algoAverage: flipBits(r, stored, 3)}
+	if got := canonicalHash("g", near); got != stored.ToString() {
+		t.Errorf("canonicalHash(near-duplicate) = %q, want the stored hash", got)
+	}
+	if got := canonicalHash("other", near); got != near.key() {
+		t.Errorf("canonicalHash() in another guild = %q, want the query's own hash", got)
+	}
+	far := fingerprint{algoAverage: randomHash(r)}
+	if got := canonicalHash("g", far); got != far.key() {
+		t.Errorf("canonicalHash(unrelated) = %q, want the query's own hash", got)
+	}
+}
+
+func TestCanonicalHashFollowsRepostChain(t *testing.T) {
+	setupHasherWithDB(t)
+
+	r := rand.New(rand.NewPCG(17, 18))
+	first := randomHash(r)
+	writeHash("g", first.ToString(), &discordgo.Message{ID: "1"})
+
+	// An edited repost is stored under its own hash but linked to the first.
+	edit := fingerprint{algoAverage: flipBits(r, first, 6)}
+	canonical := canonicalHash("g", edit)
+	writeHash("g", edit.key(), &discordgo.Message{ID: "2"})
+	linkCanonical("g", edit.key(), canonical)
+
+	// A repost nearest the edit, and too far from the first image to match
+	// it directly, still belongs to the first image.
+	words := slices.Clone(edit[algoAverage].GetHash())
+	for b, flipped := 0, 0; flipped < 6; b++ {
+		if (words[b/64]^first.GetHash()[b/64])&(1<<(b%64)) == 0 {
+			words[b/64] ^= 1 << (b % 64)
+			flipped++
+		}
+	}
+	again := fingerprint{algoAverage: goimagehash.NewExtImageHash(words, goimagehash.AHash, hashWords*64)}
+	if d := hammingDistance(words, first.GetHash()); d <= sightingThreshold {
+		t.Fatalf("test repost is %d bits from the first image, want more than %d", d, sightingThreshold)
+	}
+	if got := canonicalHash("g", again); got != first.ToString() {
+		t.Errorf("canonicalHash(repost of the edit) = %q, want the first image %q", got, first.ToString())
+	}
+
+	// The link survives a restart.
+	resetStore(t)
+	loadFromDB()
+	if got := canonicalHash("g", again); got != first.ToString() {
+		t.Errorf("canonicalHash() after reload = %q, want the first image", got)
+	}
+}
+
+func TestTopRepostersSkipsOwnReposts(t *testing.T) {
+	setupHasherWithDB(t)
+
+	img := servePNG(t, makePatternImage(64, 64, nil))
+	defer img.Close()
+
+	postImage(t, "10", "alice", img.URL+"/a.png", 0)
+	postImage(t, "20", "alice", img.URL+"/b.png", 1)
+	postImage(t, "30", "bob", img.URL+"/c.png", 2)
+
+	reposters, err := TopReposters("g", 10)
+	if err != nil {
+		t.Fatalf("TopReposters: %v", err)
+	}
+	if len(reposters) != 1 || reposters[0].AuthorName != "bob" || reposters[0].Reposts != 1 {
+		t.Errorf("TopReposters() = %+v, want only bob with 1", reposters)
+	}
+}
@@ -0,0 +1,424 @@
+package hasher
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"log"
+	"math"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/corona10/goimagehash"
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+const (
+	// videoSampleInterval is the spacing, in seconds, of the frames decoded
+	// from a video before they are thinned out to maxVideoFrames.
+	videoSampleInterval = 1.0
+	// maxVideoFrames caps the frame hashes kept per video.
+	maxVideoFrames = 60
+	// maxVideoSeconds bounds how much of a video is decoded.
+	maxVideoSeconds = 600
+	// videoFrameSize is the side of the grayscale frames ffmpeg hands back.
+	// They only feed a 16x16 average hash, so there's no point going bigger.
+	videoFrameSize = 64
+	// minVideoMatches is how many frames must line up before two clips count
+	// as the same video.
+	minVideoMatches = 2
+	// maxVideoBytes caps the size of a downloaded video when the caller sets
+	// no limit of its own.
+	maxVideoBytes = 100 << 20
+)
+
+var ffmpegDurationRe = regexp.MustCompile(`Duration: (\d{1,2}):(\d{2}):(\d{2}(?:\.\d+)?)`)
+
+// videoFingerprint is a video's frame hashes sampled every step seconds.
+type videoFingerprint struct {
+	key       string
+	channelID string
+	duration  float64
+	step      float64
+	frames    []*goimagehash.ExtImageHash
+	// canonical is the stored video this one is a repost of, empty when it
+	// is the first of its sightings.
+	canonical string
+}
+
+func (v videoFingerprint) frameTime(i int) float64 {
+	return float64(i) * v.step
+}
+
+// clipMatch is where a query clip lines up with a stored video.
+type clipMatch struct {
+	// offset maps query time to stored time: stored = query + offset.
+	offset     float64
+	start, end float64 // overlap in the stored video, seconds
+	distance   int     // median distance of the matched frames
+	confidence float64
+}
+
+// queryRange returns the overlap in the query clip's timeline.
+func (c clipMatch) queryRange() (float64, float64) {
+	return c.start - c.offset, c.end - c.offset
+}
+
+func loadVideosFromDB() {
+	rows, err := database.Query("SELECT guild_id, channel_id, key, duration, step, frames, canonical_key FROM video_hashes")
+	if err != nil {
+		log.Fatalf("Failed to load video_hashes: %v", err)
+	}
+	defer rows.Close()
+
+	hashStore.Lock()
+	defer hashStore.Unlock()
+
+	for rows.Next() {
+		var v videoFingerprint
+		var guildID, framesJSON string
+		if err := rows.Scan(&guildID, &v.channelID, &v.key, &v.duration, &v.step, &framesJSON, &v.canonical); err != nil {
+			log.Printf("Failed to scan row: %v", err)
+			continue
+		}
+		var frames []string
+		if err := json.Unmarshal([]byte(framesJSON), &frames); err != nil {
+			log.Printf("Failed to unmarshal frames for video %s: %v", v.key, err)
+			continue
+		}
+		for _, f := range frames {
+			v.frames = append(v.frames, stringToHash(f))
+		}
+		namespaceLocked(guildID).addVideo(v)
+	}
+}
+
+func (ns *namespace) addVideo(v videoFingerprint) {
+	ns.videos[v.key] = v
+	for _, f := range v.frames {
+		ns.videoIndex.add(f.ToString(), v.key)
+	}
+}
+
+// TotalVideoHashes returns the number of stored video fingerprints across all
+// guilds.
+func TotalVideoHashes() int {
+	hashStore.RLock()
+	defer hashStore.RUnlock()
+	total := 0
+	for _, ns := range hashStore.guilds {
+		total += len(ns.videos)
+	}
+	return total
+}
+
+func checkVideo(guildID, key string) bool {
+	hashStore.RLock()
+	defer hashStore.RUnlock()
+	ns, ok := hashStore.guilds[guildID]
+	if !ok {
+		return false
+	}
+	_, ok = ns.videos[key]
+	return ok
+}
+
+func writeVideo(guildID string, v videoFingerprint, message *discordgo.Message) {
+	v.channelID = message.ChannelID
+	hashStore.Lock()
+	namespaceLocked(guildID).addVideo(v)
+	hashStore.Unlock()
+
+	frames := make([]string, len(v.frames))
+	for i, f := range v.frames {
+		frames[i] = f.ToString()
+	}
+	framesJSON, _ := json.Marshal(frames)
+	msgJSON, err := marshalMessage(message)
+	if err != nil {
+		log.Printf("Failed to marshal message for DB write: %v", err)
+		return
+	}
+	_, err = database.Exec(
+		"INSERT OR REPLACE INTO video_hashes (key, guild_id, channel_id, duration, step, frames, message_json, canonical_key) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		v.key, guildID, v.channelID, v.duration, v.step, string(framesJSON), msgJSON, v.canonical,
+	)
+	if err != nil {
+		log.Printf("Failed to write video hash to DB: %v", err)
+	}
+}
+
+func readVideoMessageFromDB(key string) (*discordgo.Message, error) {
+	var msgJSON string
+	err := database.QueryRow("SELECT message_json FROM video_hashes WHERE key = ?", key).Scan(&msgJSON)
+	if err != nil {
+		return nil, err
+	}
+	var msg discordgo.Message
+	if err := json.Unmarshal([]byte(msgJSON), &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// videoKey identifies a video attachment by its message and file name, since
+// Discord CDN links carry expiring query parameters.
+func videoKey(messageID, videoURL string) string {
+	name := videoURL
+	if parsed, err := url.Parse(videoURL); err == nil {
+		name = path.Base(parsed.Path)
+	}
+	return messageID + "/" + name
+}
+
+// fingerprintVideo decodes a downloaded video into small grayscale frames once
+// a second and hashes them.
+func fingerprintVideo(key string, data []byte) (videoFingerprint, error) {
+	tempFile, err := os.CreateTemp("", "hash_video_*")
+	if err != nil {
+		return videoFingerprint{}, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tempFile.Name())
+	_, err = tempFile.Write(data)
+	tempFile.Close()
+	if err != nil {
+		return videoFingerprint{}, fmt.Errorf("failed to write video data: %w", err)
+	}
+
+	out := bytes.NewBuffer(nil)
+	stderr := bytes.NewBuffer(nil)
+	err = ffmpeg.Input(tempFile.Name()).
+		Filter("fps", ffmpeg.Args{fmt.Sprintf("1/%g", videoSampleInterval)}).
+		Filter("scale", ffmpeg.Args{fmt.Sprintf("%d:%d", videoFrameSize, videoFrameSize)}).
+		Output("pipe:", ffmpeg.KwArgs{"t": maxVideoSeconds, "format": "rawvideo", "pix_fmt": "gray"}).
+		GlobalArgs("-hide_banner").
+		WithOutput(out).
+		WithErrorOutput(stderr).
+		Silent(true).
+		Run()
+	if err != nil {
+		return videoFingerprint{}, err
+	}
+
+	frames := splitGrayFrames(out.Bytes(), videoFrameSize)
+	if len(frames) == 0 {
+		return videoFingerprint{}, fmt.Errorf("no frames decoded")
+	}
+
+	duration := min(parseFFmpegDuration(stderr.String()), maxVideoSeconds)
+	if duration <= 0 {
+		duration = float64(len(frames)) * videoSampleInterval
+	}
+	return hashVideoFrames(key, frames, duration)
+}
+
+// hashVideoFrames hashes frames sampled every videoSampleInterval seconds,
+// keeping every k-th one so at most maxVideoFrames remain.
+func hashVideoFrames(key string, frames []image.Image, duration float64) (videoFingerprint, error) {
+	k := (len(frames) + maxVideoFrames - 1) / maxVideoFrames
+	v := videoFingerprint{key: key, duration: duration, step: float64(k) * videoSampleInterval}
+	for i := 0; i < len(frames); i += k {
+		h, err := goimagehash.ExtAverageHash(frames[i], hashWidth, hashHeight)
+		if err != nil {
+			return videoFingerprint{}, err
+		}
+		v.frames = append(v.frames, h)
+	}
+	return v, nil
+}
+
+// splitGrayFrames cuts raw 8-bit grayscale video output into size×size frames.
+func splitGrayFrames(data []byte, size int) []image.Image {
+	frameLen := size * size
+	var frames []image.Image
+	for len(data) >= frameLen {
+		img := image.NewGray(image.Rect(0, 0, size, size))
+		copy(img.Pix, data[:frameLen])
+		frames = append(frames, img)
+		data = data[frameLen:]
+	}
+	return frames
+}
+
+func parseFFmpegDuration(output string) float64 {
+	m := ffmpegDurationRe.FindStringSubmatch(output)
+	if m == nil {
+		return 0
+	}
+	hours, _ := strconv.ParseFloat(m[1], 64)
+	minutes, _ := strconv.ParseFloat(m[2], 64)
+	seconds, _ := strconv.ParseFloat(m[3], 64)
+	return hours*3600 + minutes*60 + seconds
+}
+
+// checkInVideos finds videos stored in the guild that share a run of frames
+// with v.
+func checkInVideos(guildID string, v videoFingerprint, options HashOptions) []hashResult {
+	hashStore.RLock()
+	candidates := make(map[string]videoFingerprint)
+	if ns, ok := hashStore.guilds[guildID]; ok {
+		for _, f := range v.frames {
+			for _, match := range ns.videoIndex.search(f, options.Threshold) {
+				for _, owner := range match.owners {
+					stored := ns.videos[owner]
+					if owner == v.key || (options.ChannelID != "" && stored.channelID != options.ChannelID) {
+						continue
+					}
+					candidates[owner] = stored
+				}
+			}
+		}
+	}
+	hashStore.RUnlock()
+
+	var results []hashResult
+	for key, stored := range candidates {
+		clip, ok := alignVideos(v, stored, options.Threshold)
+		if !ok {
+			continue
+		}
+		msg, err := readVideoMessageFromDB(key)
+		if err != nil {
+			log.Printf("Failed to read video hash from DB: %v", err)
+			continue
+		}
+		results = append(results, hashResult{
+			distance:   clip.distance,
+			confidence: clip.confidence,
+			clip:       &clip,
+			message:    msg,
+		})
+	}
+	return results
+}
+
+// canonicalVideo returns the stored video a post of v is a sighting of: the
+// video the closest stored match within sightingThreshold is itself a sighting
+// of, or v's own key.
+func canonicalVideo(guildID string, v videoFingerprint) string {
+	hashStore.RLock()
+	defer hashStore.RUnlock()
+
+	ns, ok := hashStore.guilds[guildID]
+	if !ok {
+		return v.key
+	}
+	best, bestDistance := v.key, sightingThreshold+1
+	seen := make(map[string]bool)
+	for _, f := range v.frames {
+		for _, match := range ns.videoIndex.search(f, sightingThreshold) {
+			for _, owner := range match.owners {
+				if owner == v.key || seen[owner] {
+					continue
+				}
+				seen[owner] = true
+				clip, ok := alignVideos(v, ns.videos[owner], sightingThreshold)
+				if ok && (clip.distance < bestDistance || (clip.distance == bestDistance && owner < best)) {
+					best, bestDistance = owner, clip.distance
+				}
+			}
+		}
+	}
+	if canonical := ns.videos[best].canonical; canonical != "" {
+		return canonical
+	}
+	return best
+}
+
+// alignVideos looks for the time offset at which query lines up with stored.
+// Every pair of similar frames votes for an offset; the densest cluster of
+// votes wins and is then checked frame by frame over the overlapping range,
+// so a trimmed or re-encoded clip still matches its original.
+func alignVideos(query, stored videoFingerprint, threshold int) (clipMatch, bool) {
+	if len(query.frames) == 0 || len(stored.frames) == 0 {
+		return clipMatch{}, false
+	}
+
+	type vote struct {
+		offset float64
+		frame  int
+	}
+	var votes []vote
+	for qi, qf := range query.frames {
+		for si, sf := range stored.frames {
+			if hammingDistance(qf.GetHash(), sf.GetHash()) <= threshold {
+				votes = append(votes, vote{stored.frameTime(si) - query.frameTime(qi), qi})
+			}
+		}
+	}
+	if len(votes) == 0 {
+		return clipMatch{}, false
+	}
+	sort.Slice(votes, func(i, j int) bool { return votes[i].offset < votes[j].offset })
+
+	// Slide a window one sampling step wide over the sorted offsets and keep
+	// the one supported by the most distinct query frames.
+	tolerance := max(query.step, stored.step)
+	bestCount, bestStart, bestEnd := 0, 0, 0
+	for start := range votes {
+		frames := make(map[int]bool)
+		end := start
+		for end < len(votes) && votes[end].offset-votes[start].offset <= tolerance {
+			frames[votes[end].frame] = true
+			end++
+		}
+		if len(frames) > bestCount {
+			bestCount, bestStart, bestEnd = len(frames), start, end
+		}
+	}
+	var sum float64
+	for _, v := range votes[bestStart:bestEnd] {
+		sum += v.offset
+	}
+	offset := sum / float64(bestEnd-bestStart)
+
+	// Verify: every query frame inside the overlap should find its
+	// counterpart near the aligned position.
+	var overlapping int
+	var distances []int
+	for qi, qf := range query.frames {
+		t := query.frameTime(qi) + offset
+		if t < -tolerance/2 || t > stored.duration+tolerance/2 {
+			continue
+		}
+		overlapping++
+		si := int(math.Round(t / stored.step))
+		best := math.MaxInt
+		for j := max(0, si-1); j <= min(len(stored.frames)-1, si+1); j++ {
+			best = min(best, hammingDistance(qf.GetHash(), stored.frames[j].GetHash()))
+		}
+		if best <= threshold {
+			distances = append(distances, best)
+		}
+	}
+	needed := min(minVideoMatches, len(query.frames), len(stored.frames))
+	if len(distances) < needed || len(distances)*2 < overlapping {
+		return clipMatch{}, false
+	}
+
+	slices.Sort(distances)
+	var similarity float64
+	for _, d := range distances {
+		similarity += hashSimilarity(d)
+	}
+	return clipMatch{
+		offset:     offset,
+		start:      max(0, offset),
+		end:        min(stored.duration, query.duration+offset),
+		distance:   distances[len(distances)/2],
+		confidence: similarity / float64(overlapping),
+	}, true
+}
+
+// formatClock renders seconds as m:ss.
+func formatClock(seconds float64) string {
+	s := int(math.Round(max(0, seconds)))
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
@@ -0,0 +1,210 @@
+package hasher
+
+import (
+	"image"
+	"math"
+	"math/rand/v2"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// makeVideo returns a fingerprint of n random frames sampled every step seconds.
+func makeVideo(r *rand.Rand, key string, n int, step float64) videoFingerprint {
+	v := videoFingerprint{key: key, duration: float64(n) * step, step: step}
+	for range n {
+		v.frames = append(v.frames, randomHash(r))
+	}
+	return v
+}
+
+// trim cuts frames [from, to) out of v, re-encoding each with a few bit flips.
+func trim(r *rand.Rand, v videoFingerprint, key string, from, to, every int) videoFingerprint {
+	out := videoFingerprint{key: key, step: v.step * float64(every)}
+	for i := from; i < to; i += every {
+		out.frames = append(out.frames, flipBits(r, v.frames[i], 3))
+	}
+	out.duration = float64(to-from) * v.step
+	return out
+}
+
+func TestAlignVideosTrimmedClip(t *testing.T) {
+	r := rand.New(rand.NewPCG(7, 8))
+	original := makeVideo(r, "orig", 30, 1)
+	clip := trim(r, original, "clip", 10, 25, 1)
+
+	got, ok := alignVideos(clip, original, 8)
+	if !ok {
+		t.Fatal("alignVideos() found no match for a trimmed clip")
+	}
+	if math.Abs(got.offset-10) > 0.01 || got.start != 10 || got.end != 25 {
+		t.Errorf("alignVideos() = %+v, want offset 10 covering 10s–25s", got)
+	}
+	if qs, qe := got.queryRange(); qs != 0 || qe != 15 {
+		t.Errorf("queryRange() = %v–%v, want 0–15", qs, qe)
+	}
+	if got.confidence < 0.8 || got.distance > 3 {
+		t.Errorf("alignVideos() = %+v, want a confident low-distance match", got)
+	}
+}
+
+func TestAlignVideosDifferentSampling(t *testing.T) {
+	r := rand.New(rand.NewPCG(9, 10))
+	original := makeVideo(r, "orig", 40, 1)
+	// A longer re-upload is thinned out to every other second.
+	reupload := trim(r, original, "reupload", 4, 40, 2)
+
+	got, ok := alignVideos(reupload, original, 8)
+	if !ok || math.Abs(got.offset-4) > 1 {
+		t.Errorf("alignVideos() = %+v, %v; want a match near offset 4", got, ok)
+	}
+}
+
+func TestAlignVideosRejectsUnrelated(t *testing.T) {
+	r := rand.New(rand.NewPCG(11, 12))
+	a := makeVideo(r, "a", 20, 1)
+	b := makeVideo(r, "b", 20, 1)
+	// A single shared frame, e.g. a black intro, isn't enough.
+	b.frames[0] = a.frames[0]
+
+	if got, ok := alignVideos(b, a, 8); ok {
+		t.Errorf("alignVideos() = %+v, want no match for unrelated videos", got)
+	}
+}
+
+func TestCheckInVideos(t *testing.T) {
+	setupHasherWithDB(t)
+
+	r := rand.New(rand.NewPCG(13, 14))
+	original := makeVideo(r, "first/a.mp4", 30, 1)
+	writeVideo("g", original, &discordgo.Message{ID: "first", ChannelID: "ch", Author: &discordgo.User{Username: "alice"}})
+
+	resetStore(t)
+	loadVideosFromDB()
+	if n := TotalVideoHashes(); n != 1 {
+		t.Fatalf("TotalVideoHashes after reload = %d, want 1", n)
+	}
+
+	clip := trim(r, original, "second/b.mp4", 5, 20, 1)
+	if results := checkInVideos("other", clip, HashOptions{Threshold: 8}); len(results) != 0 {
+		t.Errorf("checkInVideos() in another guild = %+v, want none", results)
+	}
+	if results := checkInVideos("g", clip, HashOptions{Threshold: 8, ChannelID: "elsewhere"}); len(results) != 0 {
+		t.Errorf("checkInVideos() in another channel = %+v, want none", results)
+	}
+
+	results := checkInVideos("g", clip, HashOptions{Threshold: 8})
+	if len(results) != 1 || results[0].message.ID != "first" || results[0].clip == nil {
+		t.Fatalf("checkInVideos() = %+v, want a clip match of the first message", results)
+	}
+	if c := results[0].clip; c.start != 5 || c.end != 20 {
+		t.Errorf("clip = %+v, want 5s–20s", c)
+	}
+}
+
+func TestCanonicalVideoFollowsRepostChain(t *testing.T) {
+	setupHasherWithDB(t)
+
+	r := rand.New(rand.NewPCG(15, 16))
+	original := makeVideo(r, "first/a.mp4", 30, 1)
+	if got := canonicalVideo("g", original); got != original.key {
+		t.Fatalf("canonicalVideo() of a new video = %q, want its own key", got)
+	}
+	writeVideo("g", original, &discordgo.Message{ID: "first", ChannelID: "ch"})
+
+	repost := trim(r, original, "second/b.mp4", 0, 20, 1)
+	if got := canonicalVideo("g", repost); got != original.key {
+		t.Fatalf("canonicalVideo() of a trimmed repost = %q, want %q", got, original.key)
+	}
+	// The repost is stored linked to the original, so a clip that only
+	// overlaps the repost still resolves to the original.
+	repost.canonical = original.key
+	writeVideo("g", repost, &discordgo.Message{ID: "second", ChannelID: "ch"})
+	delete(hashStore.guilds["g"].videos, original.key)
+
+	clip := trim(r, repost, "third/c.mp4", 5, 15, 1)
+	if got := canonicalVideo("g", clip); got != original.key {
+		t.Errorf("canonicalVideo() of a repost of a repost = %q, want %q", got, original.key)
+	}
+	if got := canonicalVideo("other", clip); got != clip.key {
+		t.Errorf("canonicalVideo() in another guild = %q, want its own key", got)
+	}
+}
+
+func TestHashVideoFramesThinsOut(t *testing.T) {
+	frames := make([]image.Image, 150)
+	for i := range frames {
+		frames[i] = image.NewGray(image.Rect(0, 0, videoFrameSize, videoFrameSize))
+	}
+
+	v, err := hashVideoFrames("k", frames, 150)
+	if err != nil {
+		t.Fatalf("hashVideoFrames: %v", err)
+	}
+	if v.step != 3 || len(v.frames) != 50 {
+		t.Errorf("hashVideoFrames() step %v with %d frames, want step 3 with 50", v.step, len(v.frames))
+	}
+}
+
+func TestSplitGrayFrames(t *testing.T) {
+	data := make([]byte, 2*4*4+3) // two frames and a partial one
+	data[16] = 255
+
+	frames := splitGrayFrames(data, 4)
+	if len(frames) != 2 {
+		t.Fatalf("splitGrayFrames len = %d, want 2", len(frames))
+	}
+	if frames[1].(*image.Gray).Pix[0] != 255 {
+		t.Error("second frame doesn't start at its own offset")
+	}
+}
+
+func TestParseFFmpegDuration(t *testing.T) {
+	out := "Input #0, mov,mp4, from 'clip.mp4':\n  Duration: 00:01:02.50, start: 0.000000, bitrate: 900 kb/s"
+	if got := parseFFmpegDuration(out); got != 62.5 {
+		t.Errorf("parseFFmpegDuration() = %v, want 62.5", got)
+	}
+	if got := parseFFmpegDuration("no duration here"); got != 0 {
+		t.Errorf("parseFFmpegDuration() = %v, want 0", got)
+	}
+}
+
+func TestVideoKey(t *testing.T) {
+	got := videoKey("123", "https://cdn.discordapp.com/attachments/1/2/clip.mp4?ex=abc&is=def")
+	if got != "123/clip.mp4" {
+		t.Errorf("videoKey() = %q, want 123/clip.mp4", got)
+	}
+}
+
+func TestFormatClock(t *testing.T) {
+	for in, want := range map[float64]string{0: "0:00", 9.6: "0:10", 125: "2:05", -1: "0:00"} {
+		if got := formatClock(in); got != want {
+			t.Errorf("formatClock(%v) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestFingerprintVideo(t *testing.T) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		t.Skipf("ffmpeg not installed, skipping: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "clip.mp4")
+	err := exec.Command("ffmpeg", "-hide_banner", "-loglevel", "error",
+		"-f", "lavfi", "-i", "testsrc=duration=8:size=160x120:rate=10", path).Run()
+	if err != nil {
+		t.Skipf("could not generate test video: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+
+	v, err := fingerprintVideo("k", data)
+	if err != nil {
+		t.Fatalf("fingerprintVideo: %v", err)
+	}
+	if len(v.frames) < 7 || math.Abs(v.duration-8) > 0.5 {
+		t.Errorf("fingerprintVideo() = %d frames over %vs, want ~8 of each", len(v.frames), v.duration)
+	}
+}
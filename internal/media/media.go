@@ -0,0 +1,300 @@
+// Package media turns links to YouTube and other yt-dlp supported sites into
+// chat context: the video's metadata, a transcript taken from its subtitles and
+// a handful of keyframes. Results are cached so a video is processed once.
+package media
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"voltgpt/internal/utility"
+)
+
+const (
+	// Keyframes is the number of frames sampled from a video.
+	Keyframes = 6
+	// MaxTranscriptChars caps the transcript included in a prompt.
+	MaxTranscriptChars = 30000
+	// maxKeyframeDuration is the longest video, in seconds, that is downloaded
+	// for keyframes. Longer videos are described by their transcript only.
+	maxKeyframeDuration = 20 * 60
+	maxDownloadSize     = "100M"
+	downloadFormat      = "best[height<=480][ext=mp4]/worst[ext=mp4]/worst"
+	subtitleLangs       = "en.*,en"
+	ytdlpTimeout        = 3 * time.Minute
+)
+
+var (
+	database *sql.DB
+
+	ytIDRe       = regexp.MustCompile(`(?:v=|youtu\.be/|/shorts/|/embed/|/live/)([\w-]{11})`)
+	vttTimingRe  = regexp.MustCompile(`^\d{2}:\d{2}(:\d{2})?\.\d{3} -->`)
+	vttTagRe     = regexp.MustCompile(`<[^>]+>`)
+	vttCueNumber = regexp.MustCompile(`^\d+$`)
+)
+
+// Video is the ingested content of a linked video.
+type Video struct {
+	URL        string
+	Title      string
+	Uploader   string
+	Duration   float64 // seconds
+	Transcript string
+	Frames     []string // base64 JPEG keyframes
+}
+
+// Init sets the database handle used for the ingestion cache.
+func Init(db *sql.DB) {
+	database = db
+}
+
+// Ingest returns the transcript and keyframes for a video link, using the
+// cache when the video has been processed before. Results missing keyframes
+// they should have had are returned but not cached, so a failed download is
+// retried the next time the link comes up.
+func Ingest(ctx context.Context, videoURL string) (Video, error) {
+	key := cacheKey(videoURL)
+	if v, ok := loadCached(key); ok {
+		return v, nil
+	}
+
+	v, err := fetch(ctx, videoURL)
+	if err != nil {
+		return Video{}, err
+	}
+	if v.complete() {
+		storeCached(key, v)
+	}
+	return v, nil
+}
+
+// complete reports whether v has everything fetch tries to collect: videos
+// short enough for keyframes must have them.
+func (v Video) complete() bool {
+	return len(v.Frames) > 0 || !wantsKeyframes(v.Duration)
+}
+
+func wantsKeyframes(duration float64) bool {
+	return duration > 0 && duration <= maxKeyframeDuration
+}
+
+// PromptText renders the video metadata and transcript for a model prompt.
+func (v Video) PromptText() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<video url=\"%s\"", v.URL)
+	if v.Title != "" {
+		fmt.Fprintf(&sb, " title=\"%s\"", v.Title)
+	}
+	if v.Uploader != "" {
+		fmt.Fprintf(&sb, " uploader=\"%s\"", v.Uploader)
+	}
+	if v.Duration > 0 {
+		fmt.Fprintf(&sb, " duration=\"%s\"", (time.Duration(v.Duration) * time.Second).String())
+	}
+	sb.WriteString(">\n")
+	if v.Transcript != "" {
+		sb.WriteString("Transcript:\n" + v.Transcript + "\n")
+	} else {
+		sb.WriteString("(no transcript available)\n")
+	}
+	if len(v.Frames) > 0 {
+		fmt.Fprintf(&sb, "(%d evenly spaced keyframes attached as images)\n", len(v.Frames))
+	}
+	sb.WriteString("</video>")
+	return sb.String()
+}
+
+type videoInfo struct {
+	Title    string  `json:"title"`
+	Uploader string  `json:"uploader"`
+	Duration float64 `json:"duration"`
+}
+
+func fetch(ctx context.Context, videoURL string) (Video, error) {
+	ctx, cancel := context.WithTimeout(ctx, ytdlpTimeout)
+	defer cancel()
+
+	dir, err := os.MkdirTemp("", "media_*")
+	if err != nil {
+		return Video{}, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Metadata and subtitles come from one run that skips the video itself.
+	_, err = runYTDLP(ctx,
+		"--skip-download", "--no-playlist",
+		"--write-info-json",
+		"--write-subs", "--write-auto-subs", "--sub-langs", subtitleLangs, "--sub-format", "vtt",
+		"-o", filepath.Join(dir, "media.%(ext)s"),
+		videoURL,
+	)
+	if err != nil {
+		return Video{}, err
+	}
+
+	raw, err := os.ReadFile(filepath.Join(dir, "media.info.json"))
+	if err != nil {
+		return Video{}, fmt.Errorf("yt-dlp wrote no metadata: %w", err)
+	}
+	var info videoInfo
+	if err := json.Unmarshal(raw, &info); err != nil {
+		return Video{}, fmt.Errorf("failed to parse yt-dlp metadata: %w", err)
+	}
+
+	v := Video{
+		URL:      videoURL,
+		Title:    info.Title,
+		Uploader: info.Uploader,
+		Duration: info.Duration,
+	}
+
+	if subs, _ := filepath.Glob(filepath.Join(dir, "media.*.vtt")); len(subs) > 0 {
+		data, err := os.ReadFile(subs[0])
+		if err == nil {
+			v.Transcript = truncateRunes(parseVTT(string(data)), MaxTranscriptChars)
+		}
+	}
+
+	if wantsKeyframes(info.Duration) {
+		frames, err := downloadKeyframes(ctx, dir, videoURL)
+		if err != nil {
+			log.Printf("media: keyframes for %s: %v", videoURL, err)
+		}
+		v.Frames = frames
+	}
+
+	return v, nil
+}
+
+func downloadKeyframes(ctx context.Context, dir, videoURL string) ([]string, error) {
+	_, err := runYTDLP(ctx,
+		"--no-playlist",
+		"-f", downloadFormat,
+		"--max-filesize", maxDownloadSize,
+		"-o", filepath.Join(dir, "video.%(ext)s"),
+		videoURL,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "video.*"))
+	if len(files) == 0 {
+		return nil, errors.New("yt-dlp downloaded no video (over size limit?)")
+	}
+
+	frames, _, err := utility.ExtractKeyframes(files[0], Keyframes)
+	return frames, err
+}
+
+func runYTDLP(ctx context.Context, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "yt-dlp", append([]string{"--quiet", "--no-warnings"}, args...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("yt-dlp: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// parseVTT flattens a WebVTT subtitle file into plain text. Auto-generated
+// captions repeat each line while it scrolls, so consecutive duplicates are
+// dropped.
+func parseVTT(data string) string {
+	var lines []string
+	last := ""
+	for line := range strings.SplitSeq(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "",
+			line == "WEBVTT",
+			strings.HasPrefix(line, "Kind:"),
+			strings.HasPrefix(line, "Language:"),
+			strings.HasPrefix(line, "NOTE"),
+			vttCueNumber.MatchString(line),
+			vttTimingRe.MatchString(line):
+			continue
+		}
+		line = strings.TrimSpace(vttTagRe.ReplaceAllString(line, ""))
+		if line == "" || line == last {
+			continue
+		}
+		lines = append(lines, line)
+		last = line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// cacheKey identifies a video independent of tracking parameters. YouTube links
+// in any of their forms collapse to the video ID.
+func cacheKey(videoURL string) string {
+	if utility.IsYTURL(videoURL) {
+		if m := ytIDRe.FindStringSubmatch(videoURL); len(m) == 2 {
+			return "youtube:" + m[1]
+		}
+	}
+	parsed, err := url.Parse(videoURL)
+	if err != nil {
+		return videoURL
+	}
+	return strings.TrimPrefix(parsed.Host, "www.") + parsed.Path
+}
+
+func truncateRunes(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return string(runes[:limit]) + "\n…"
+}
+
+func loadCached(key string) (Video, bool) {
+	if database == nil {
+		return Video{}, false
+	}
+
+	var v Video
+	var frames string
+	err := database.QueryRow(
+		"SELECT url, title, uploader, duration, transcript, frames FROM media_ingest WHERE cache_key = ?", key,
+	).Scan(&v.URL, &v.Title, &v.Uploader, &v.Duration, &v.Transcript, &frames)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("media: load cache %s: %v", key, err)
+		}
+		return Video{}, false
+	}
+	if err := json.Unmarshal([]byte(frames), &v.Frames); err != nil {
+		log.Printf("media: decode cached frames %s: %v", key, err)
+	}
+	return v, true
+}
+
+func storeCached(key string, v Video) {
+	if database == nil {
+		return
+	}
+
+	frames, _ := json.Marshal(v.Frames)
+	_, err := database.Exec(
+		`INSERT OR REPLACE INTO media_ingest (cache_key, url, title, uploader, duration, transcript, frames)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		key, v.URL, v.Title, v.Uploader, v.Duration, v.Transcript, string(frames),
+	)
+	if err != nil {
+		log.Printf("media: store cache %s: %v", key, err)
+	}
+}
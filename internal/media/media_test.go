@@ -0,0 +1,110 @@
+package media
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"voltgpt/internal/db"
+)
+
+func setupDB(t *testing.T) {
+	t.Helper()
+
+	db.Open(":memory:")
+	Init(db.DB)
+
+	t.Cleanup(func() {
+		db.Close()
+		database = nil
+	})
+}
+
+func TestParseVTT(t *testing.T) {
+	vtt := `WEBVTT
+Kind: captions
+Language: en
+
+1
+00:00:00.000 --> 00:00:02.000 align:start position:0%
+hello <00:00:00.500><c>everyone</c>
+
+00:00:02.000 --> 00:00:04.000
+hello everyone
+
+00:00:04.000 --> 00:00:06.000
+welcome back to the channel
+`
+	got := parseVTT(vtt)
+	want := "hello everyone\nwelcome back to the channel"
+	if got != want {
+		t.Errorf("parseVTT() = %q, want %q", got, want)
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	tests := map[string]string{
+		"https://www.youtube.com/watch?v=dQw4w9WgXcQ&t=42": "youtube:dQw4w9WgXcQ",
+		"https://youtu.be/dQw4w9WgXcQ?si=tracking":         "youtube:dQw4w9WgXcQ",
+		"https://youtube.com/shorts/dQw4w9WgXcQ":           "youtube:dQw4w9WgXcQ",
+		"https://vimeo.com/76979871?share=copy":            "vimeo.com/76979871",
+	}
+	for input, want := range tests {
+		if got := cacheKey(input); got != want {
+			t.Errorf("cacheKey(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestIngestUsesCache(t *testing.T) {
+	setupDB(t)
+
+	want := Video{
+		URL:        "https://youtu.be/dQw4w9WgXcQ",
+		Title:      "cached",
+		Duration:   212,
+		Transcript: "never gonna give you up",
+		Frames:     []string{"ZnJhbWU="},
+	}
+	storeCached(cacheKey(want.URL), want)
+
+	// A different link to the same video must hit the cache rather than run yt-dlp.
+	got, err := Ingest(context.Background(), "https://www.youtube.com/watch?v=dQw4w9WgXcQ")
+	if err != nil {
+		t.Fatalf("Ingest() error: %v", err)
+	}
+	if got.Title != want.Title || got.Transcript != want.Transcript || len(got.Frames) != 1 || got.Frames[0] != want.Frames[0] {
+		t.Errorf("Ingest() = %+v, want %+v", got, want)
+	}
+}
+
+func TestVideoComplete(t *testing.T) {
+	tests := []struct {
+		name string
+		v    Video
+		want bool
+	}{
+		{"short video with frames", Video{Duration: 60, Frames: []string{"ZnJhbWU="}}, true},
+		{"short video missing frames", Video{Duration: 60}, false},
+		{"long video without frames", Video{Duration: maxKeyframeDuration + 1}, true},
+		{"unknown duration", Video{}, true},
+	}
+	for _, tt := range tests {
+		if got := tt.v.complete(); got != tt.want {
+			t.Errorf("%s: complete() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestVideoPromptText(t *testing.T) {
+	got := Video{URL: "https://vimeo.com/1", Title: "Demo", Duration: 90, Transcript: "hi", Frames: []string{"a", "b"}}.PromptText()
+	for _, want := range []string{`<video url="https://vimeo.com/1" title="Demo" duration="1m30s">`, "Transcript:\nhi", "2 evenly spaced keyframes", "</video>"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("PromptText() missing %q in:\n%s", want, got)
+		}
+	}
+
+	if got := (Video{URL: "u"}).PromptText(); !strings.Contains(got, "no transcript available") {
+		t.Errorf("PromptText() without transcript = %q", got)
+	}
+}
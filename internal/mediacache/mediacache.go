@@ -0,0 +1,536 @@
+// Package mediacache keeps downloaded media on disk so a URL fetched by the
+// hasher, a chat backend and wavespeed is only downloaded once. Bodies are
+// stored once per content hash and indexed by cleaned URL in SQLite; the least
+// recently used are evicted when the cache outgrows its size limit.
+package mediacache
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Config sets where the cache lives and how much it keeps.
+type Config struct {
+	Dir string
+	// MaxBytes caps the total size of stored bodies.
+	MaxBytes int64
+	// MaxEntryBytes is the largest body that is stored. Larger ones are
+	// still downloaded, just not kept.
+	MaxEntryBytes int64
+	// DiscordTTL is how long Discord CDN attachments stay fresh. Their
+	// content never changes, so it can be long.
+	DiscordTTL time.Duration
+	// TTL is how long any other URL stays fresh.
+	TTL time.Duration
+}
+
+// discordHosts serve attachments behind signed URLs whose ex, is and hm
+// parameters change every time the message is fetched.
+var discordHosts = []string{"cdn.discordapp.com", "media.discordapp.net"}
+
+var discordSignatureParams = []string{"ex", "is", "hm"}
+
+var (
+	database *sql.DB
+	config   Config
+
+	// mu serialises index writes and eviction.
+	mu sync.Mutex
+
+	inflight = struct {
+		sync.Mutex
+		calls map[string]*call
+	}{
+		calls: make(map[string]*call),
+	}
+
+	counters struct {
+		requests, errors, hits, misses atomic.Int64
+		downloaded, served             atomic.Int64
+	}
+)
+
+// call is a download other fetches of the same URL wait for.
+type call struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// Client is the HTTP client all media is downloaded with. It counts requests
+// and failures for Stats. HTTP/2 stays off, as it was for the hasher's own
+// client before it.
+var Client = &http.Client{
+	Timeout: 5 * time.Minute,
+	Transport: countingTransport{base: &http.Transport{
+		Proxy:        http.ProxyFromEnvironment,
+		TLSNextProto: make(map[string]func(string, *tls.Conn) http.RoundTripper),
+	}},
+}
+
+// ErrNonPublicAddress is returned by FetchPublic for connections to loopback,
+// private, link-local and other non-public addresses.
+var ErrNonPublicAddress = errors.New("refusing to connect to a non-public address")
+
+// PublicClient is Client for links users supply. Every connection, including
+// those made to follow redirects, must go to a public address, and the proxy
+// is bypassed since it would hide the address.
+var PublicClient = &http.Client{
+	Timeout: 5 * time.Minute,
+	Transport: countingTransport{base: &http.Transport{
+		DialContext:  (&net.Dialer{Timeout: 30 * time.Second, Control: dialPublicOnly}).DialContext,
+		TLSNextProto: make(map[string]func(string, *tls.Conn) http.RoundTripper),
+	}},
+}
+
+// IsPublicIP reports whether ip is a global unicast address outside the
+// private ranges.
+func IsPublicIP(ip net.IP) bool {
+	return ip.IsGlobalUnicast() && !ip.IsPrivate()
+}
+
+// dialPublicOnly checks the resolved address of each connection, so a host
+// can't pass a lookup done beforehand and then redirect or re-resolve to an
+// internal one.
+func dialPublicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !IsPublicIP(ip) {
+		return fmt.Errorf("%w: %s", ErrNonPublicAddress, host)
+	}
+	return nil
+}
+
+type countingTransport struct {
+	base http.RoundTripper
+}
+
+func (t countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	counters.requests.Add(1)
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		counters.errors.Add(1)
+	}
+	return resp, err
+}
+
+// Stats reports cache use since the process started.
+type Stats struct {
+	Requests        int64 // HTTP requests sent
+	Errors          int64 // requests that failed or didn't return 200
+	Hits            int64
+	Misses          int64
+	BytesDownloaded int64
+	BytesServed     int64 // bytes returned from the cache
+	Entries         int   // cached URLs
+	StoredBytes     int64 // size of the stored bodies
+}
+
+// ConfigFromEnv reads MEDIA_CACHE_DIR and MEDIA_CACHE_MAX_MB, falling back to
+// a 1 GB cache in ./media_cache.
+func ConfigFromEnv() Config {
+	cfg := Config{
+		Dir:           "media_cache",
+		MaxBytes:      1 << 30,
+		MaxEntryBytes: 100 << 20,
+		DiscordTTL:    7 * 24 * time.Hour,
+		TTL:           time.Hour,
+	}
+	if dir := strings.TrimSpace(os.Getenv("MEDIA_CACHE_DIR")); dir != "" {
+		cfg.Dir = dir
+	}
+	if mb, err := strconv.ParseInt(strings.TrimSpace(os.Getenv("MEDIA_CACHE_MAX_MB")), 10, 64); err == nil && mb >= 0 {
+		cfg.MaxBytes = mb << 20
+		cfg.MaxEntryBytes = min(cfg.MaxEntryBytes, cfg.MaxBytes)
+	}
+	return cfg
+}
+
+// Init opens the cache in cfg.Dir, indexed in db, and drops expired entries.
+// Until it is called Fetch downloads without caching.
+func Init(db *sql.DB, cfg Config) error {
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create media cache directory: %w", err)
+	}
+	mu.Lock()
+	database = db
+	config = cfg
+	mu.Unlock()
+
+	purgeExpired()
+	evict()
+	return nil
+}
+
+// Key returns the cache key of a URL. Discord CDN links drop their signature
+// parameters so every fetch of the same attachment shares an entry.
+func Key(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	u.Fragment = ""
+	u.Host = strings.ToLower(u.Host)
+	query := u.Query()
+	if isDiscordHost(u.Host) {
+		for _, p := range discordSignatureParams {
+			query.Del(p)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+func isDiscordHost(host string) bool {
+	for _, h := range discordHosts {
+		if host == h {
+			return true
+		}
+	}
+	return false
+}
+
+func ttl(key string) time.Duration {
+	if u, err := url.Parse(key); err == nil && isDiscordHost(u.Host) {
+		return config.DiscordTTL
+	}
+	return config.TTL
+}
+
+// Fetch returns the body at rawURL, from the cache when a fresh copy is
+// stored. A positive limit caps the size of the body.
+func Fetch(rawURL string, limit int64) ([]byte, error) {
+	key := Key(rawURL)
+	if data, ok := lookup(key); ok {
+		if limit > 0 && int64(len(data)) > limit {
+			return nil, fmt.Errorf("response exceeds %d bytes", limit)
+		}
+		counters.hits.Add(1)
+		counters.served.Add(int64(len(data)))
+		return data, nil
+	}
+
+	// Concurrent fetches of the same URL share one download.
+	inflight.Lock()
+	if c, ok := inflight.calls[key]; ok {
+		inflight.Unlock()
+		<-c.done
+		if c.err == nil && limit > 0 && int64(len(c.data)) > limit {
+			return nil, fmt.Errorf("response exceeds %d bytes", limit)
+		}
+		return c.data, c.err
+	}
+	c := &call{done: make(chan struct{})}
+	inflight.calls[key] = c
+	inflight.Unlock()
+
+	counters.misses.Add(1)
+	c.data, c.err = download(rawURL, limit)
+	if c.err == nil {
+		counters.downloaded.Add(int64(len(c.data)))
+		store(key, c.data)
+	}
+
+	inflight.Lock()
+	delete(inflight.calls, key)
+	inflight.Unlock()
+	close(c.done)
+	return c.data, c.err
+}
+
+// FetchPublic downloads a user supplied link with PublicClient. A positive
+// limit caps the size of the body. Such links are one-offs, so they bypass the
+// cache.
+func FetchPublic(rawURL string, limit int64) ([]byte, error) {
+	data, err := downloadWith(PublicClient, rawURL, limit)
+	if err == nil {
+		counters.downloaded.Add(int64(len(data)))
+	}
+	return data, err
+}
+
+func download(rawURL string, limit int64) ([]byte, error) {
+	return downloadWith(Client, rawURL, limit)
+}
+
+func downloadWith(client *http.Client, rawURL string, limit int64) ([]byte, error) {
+	resp, err := client.Get(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bad status: %s", resp.Status)
+	}
+	if limit <= 0 {
+		return io.ReadAll(resp.Body)
+	}
+	if resp.ContentLength > limit {
+		return nil, fmt.Errorf("response is %d bytes, limit is %d", resp.ContentLength, limit)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("response exceeds %d bytes", limit)
+	}
+	return data, nil
+}
+
+// lookup returns the stored body of key if it is still fresh.
+func lookup(key string) ([]byte, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	if database == nil {
+		return nil, false
+	}
+
+	var hash string
+	var fetchedAt int64
+	err := database.QueryRow("SELECT content_hash, fetched_at FROM media_cache WHERE url_key = ?", key).Scan(&hash, &fetchedAt)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("mediacache: lookup %s: %v", key, err)
+		}
+		return nil, false
+	}
+	if time.Since(time.Unix(fetchedAt, 0)) > ttl(key) {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(blobPath(hash))
+	if err != nil {
+		// The body is gone from disk; forget it and download again.
+		log.Printf("mediacache: read %s: %v", hash, err)
+		if _, err := database.Exec("DELETE FROM media_cache WHERE url_key = ?", key); err != nil {
+			log.Printf("mediacache: forget %s: %v", key, err)
+		}
+		return nil, false
+	}
+	if _, err := database.Exec("UPDATE media_cache SET accessed_at = ? WHERE url_key = ?", time.Now().UnixNano(), key); err != nil {
+		log.Printf("mediacache: touch %s: %v", key, err)
+	}
+	return data, true
+}
+
+// store writes data under its content hash and points key at it.
+func store(key string, data []byte) {
+	mu.Lock()
+	if database == nil || int64(len(data)) > config.MaxEntryBytes {
+		mu.Unlock()
+		return
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	if err := writeBlob(hash, data); err != nil {
+		mu.Unlock()
+		log.Printf("mediacache: write %s: %v", hash, err)
+		return
+	}
+	now := time.Now()
+	_, err := database.Exec(
+		`INSERT INTO media_cache (url_key, content_hash, size, fetched_at, accessed_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(url_key) DO UPDATE SET content_hash = excluded.content_hash, size = excluded.size,
+			fetched_at = excluded.fetched_at, accessed_at = excluded.accessed_at`,
+		key, hash, len(data), now.Unix(), now.UnixNano(),
+	)
+	mu.Unlock()
+	if err != nil {
+		log.Printf("mediacache: index %s: %v", key, err)
+		return
+	}
+	evict()
+}
+
+func blobPath(hash string) string {
+	return filepath.Join(config.Dir, hash[:2], hash)
+}
+
+func writeBlob(hash string, data []byte) error {
+	path := blobPath(hash)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// Write to a temporary file first so a crash never leaves a partial body
+	// under the real name.
+	tmp, err := os.CreateTemp(filepath.Dir(path), hash+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// storedBytesLocked sums the size of each stored body once, however many
+// URLs point at it.
+func storedBytesLocked() (int64, error) {
+	var total int64
+	err := database.QueryRow(`SELECT COALESCE(SUM(size), 0) FROM
+		(SELECT MAX(size) AS size FROM media_cache GROUP BY content_hash)`).Scan(&total)
+	return total, err
+}
+
+// evict drops the least recently used entries until the cache fits in
+// config.MaxBytes.
+func evict() {
+	mu.Lock()
+	defer mu.Unlock()
+	if database == nil {
+		return
+	}
+
+	total, err := storedBytesLocked()
+	if err != nil {
+		log.Printf("mediacache: size: %v", err)
+		return
+	}
+	if total <= config.MaxBytes {
+		return
+	}
+
+	rows, err := database.Query("SELECT url_key, content_hash, size FROM media_cache ORDER BY accessed_at")
+	if err != nil {
+		log.Printf("mediacache: evict: %v", err)
+		return
+	}
+	type entry struct {
+		key, hash string
+		size      int64
+	}
+	var entries []entry
+	for rows.Next() {
+		var e entry
+		if err := rows.Scan(&e.key, &e.hash, &e.size); err == nil {
+			entries = append(entries, e)
+		}
+	}
+	rows.Close()
+
+	evicted := 0
+	for _, e := range entries {
+		if total <= config.MaxBytes {
+			break
+		}
+		freed, err := removeLocked(e.key, e.hash)
+		if err != nil {
+			log.Printf("mediacache: evict %s: %v", e.key, err)
+			continue
+		}
+		if freed {
+			total -= e.size
+		}
+		evicted++
+	}
+	log.Printf("mediacache: evicted %d entries, %d bytes stored", evicted, total)
+}
+
+// removeLocked drops key and, when no other URL shares its body, the body
+// itself. It reports whether the body was removed.
+func removeLocked(key, hash string) (bool, error) {
+	if _, err := database.Exec("DELETE FROM media_cache WHERE url_key = ?", key); err != nil {
+		return false, err
+	}
+	var shared int
+	if err := database.QueryRow("SELECT COUNT(*) FROM media_cache WHERE content_hash = ?", hash).Scan(&shared); err != nil {
+		return false, err
+	}
+	if shared > 0 {
+		return false, nil
+	}
+	if err := os.Remove(blobPath(hash)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+	return true, nil
+}
+
+// purgeExpired drops entries past their TTL.
+func purgeExpired() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	rows, err := database.Query("SELECT url_key, content_hash, fetched_at FROM media_cache")
+	if err != nil {
+		log.Printf("mediacache: purge: %v", err)
+		return
+	}
+	type entry struct{ key, hash string }
+	var expired []entry
+	for rows.Next() {
+		var e entry
+		var fetchedAt int64
+		if err := rows.Scan(&e.key, &e.hash, &fetchedAt); err != nil {
+			continue
+		}
+		if time.Since(time.Unix(fetchedAt, 0)) > ttl(e.key) {
+			expired = append(expired, e)
+		}
+	}
+	rows.Close()
+
+	for _, e := range expired {
+		if _, err := removeLocked(e.key, e.hash); err != nil {
+			log.Printf("mediacache: purge %s: %v", e.key, err)
+		}
+	}
+}
+
+// CurrentStats returns the cache counters and its current size.
+func CurrentStats() Stats {
+	s := Stats{
+		Requests:        counters.requests.Load(),
+		Errors:          counters.errors.Load(),
+		Hits:            counters.hits.Load(),
+		Misses:          counters.misses.Load(),
+		BytesDownloaded: counters.downloaded.Load(),
+		BytesServed:     counters.served.Load(),
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if database == nil {
+		return s
+	}
+	if err := database.QueryRow("SELECT COUNT(*) FROM media_cache").Scan(&s.Entries); err != nil {
+		log.Printf("mediacache: count: %v", err)
+	}
+	stored, err := storedBytesLocked()
+	if err != nil {
+		log.Printf("mediacache: size: %v", err)
+	}
+	s.StoredBytes = stored
+	return s
+}
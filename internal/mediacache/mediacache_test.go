@@ -0,0 +1,249 @@
+package mediacache
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"voltgpt/internal/db"
+)
+
+// setupCache opens a cache in a temporary directory backed by an in-memory
+// database.
+func setupCache(t *testing.T, cfg Config) {
+	t.Helper()
+	db.Open(":memory:")
+	cfg.Dir = t.TempDir()
+	if cfg.MaxBytes == 0 {
+		cfg.MaxBytes = 1 << 20
+	}
+	if cfg.MaxEntryBytes == 0 {
+		cfg.MaxEntryBytes = cfg.MaxBytes
+	}
+	if cfg.TTL == 0 {
+		cfg.TTL = time.Hour
+	}
+	if err := Init(db.DB, cfg); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	t.Cleanup(func() {
+		mu.Lock()
+		database = nil
+		mu.Unlock()
+		db.Close()
+	})
+}
+
+// countingServer serves body at every path and counts the requests.
+func countingServer(t *testing.T, body func(path string) string) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+	var hits atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body(r.URL.Path)))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+func blobCount(t *testing.T) int {
+	t.Helper()
+	n := 0
+	filepath.WalkDir(config.Dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			n++
+		}
+		return nil
+	})
+	return n
+}
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{
+			"https://cdn.discordapp.com/attachments/1/2/cat.png?ex=65&is=64&hm=abc&",
+			"https://cdn.discordapp.com/attachments/1/2/cat.png",
+		},
+		{
+			"https://media.discordapp.net/attachments/1/2/cat.png?width=400&ex=65&hm=abc",
+			"https://media.discordapp.net/attachments/1/2/cat.png?width=400",
+		},
+		{
+			"https://example.com/a.png?ex=1#frag",
+			"https://example.com/a.png?ex=1",
+		},
+	}
+	for _, tt := range tests {
+		if got := Key(tt.url); got != tt.want {
+			t.Errorf("Key(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestFetchServesRepeatsFromCache(t *testing.T) {
+	setupCache(t, Config{})
+	srv, hits := countingServer(t, func(path string) string { return "body of " + path })
+
+	for range 3 {
+		data, err := Fetch(srv.URL+"/a.png#again", 0)
+		if err != nil || string(data) != "body of /a.png" {
+			t.Fatalf("Fetch = %q, %v; want the body", data, err)
+		}
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("server hits = %d, want 1", n)
+	}
+
+	if _, err := Fetch(srv.URL+"/missing", 0); err == nil {
+		t.Error("Fetch of a 404 succeeded")
+	}
+	if _, err := Fetch(srv.URL+"/missing", 0); err == nil {
+		t.Error("second Fetch of a 404 succeeded")
+	}
+	if n := hits.Load(); n != 3 {
+		t.Errorf("server hits = %d, want failures not cached", n)
+	}
+
+	if _, err := Fetch(srv.URL+"/a.png", 5); err == nil {
+		t.Error("Fetch over the limit succeeded from the cache")
+	}
+}
+
+func TestFetchSharesBodiesByContent(t *testing.T) {
+	setupCache(t, Config{})
+	srv, _ := countingServer(t, func(string) string { return "same bytes" })
+
+	for _, path := range []string{"/a.png", "/b.png", "/c.png"} {
+		if _, err := Fetch(srv.URL+path, 0); err != nil {
+			t.Fatalf("Fetch(%s): %v", path, err)
+		}
+	}
+
+	stats := CurrentStats()
+	if stats.Entries != 3 || stats.StoredBytes != int64(len("same bytes")) {
+		t.Errorf("stats = %+v, want 3 entries sharing one body", stats)
+	}
+	if n := blobCount(t); n != 1 {
+		t.Errorf("blobs on disk = %d, want 1", n)
+	}
+}
+
+func TestFetchRefetchesAfterTTL(t *testing.T) {
+	setupCache(t, Config{TTL: time.Hour})
+	srv, hits := countingServer(t, func(path string) string { return path })
+
+	if _, err := Fetch(srv.URL+"/a.png", 0); err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if _, err := db.DB.Exec("UPDATE media_cache SET fetched_at = ?", time.Now().Add(-2*time.Hour).Unix()); err != nil {
+		t.Fatalf("age entry: %v", err)
+	}
+	if _, err := Fetch(srv.URL+"/a.png", 0); err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if n := hits.Load(); n != 2 {
+		t.Errorf("server hits = %d, want the stale entry downloaded again", n)
+	}
+}
+
+func TestEvictsLeastRecentlyUsed(t *testing.T) {
+	setupCache(t, Config{MaxBytes: 250, MaxEntryBytes: 150})
+	srv, hits := countingServer(t, func(path string) string {
+		if path == "/huge" {
+			return strings.Repeat("h", 200)
+		}
+		return strings.Repeat(path[1:], 100)
+	})
+
+	fetch := func(path string) {
+		t.Helper()
+		if _, err := Fetch(srv.URL+path, 0); err != nil {
+			t.Fatalf("Fetch(%s): %v", path, err)
+		}
+	}
+	fetch("/a")
+	fetch("/b")
+	fetch("/a") // a is now more recently used than b
+	fetch("/c") // 300 bytes stored, so b goes
+	fetch("/huge")
+
+	stats := CurrentStats()
+	if stats.Entries != 2 || stats.StoredBytes != 200 {
+		t.Errorf("stats = %+v, want a and c stored in 200 bytes", stats)
+	}
+	before := hits.Load()
+	fetch("/a")
+	fetch("/c")
+	if hits.Load() != before {
+		t.Error("a or c was evicted, want b evicted")
+	}
+	fetch("/b")
+	fetch("/huge")
+	if n := hits.Load() - before; n != 2 {
+		t.Errorf("server hits = %d, want b and the oversized body downloaded again", n)
+	}
+	if n := blobCount(t); n != 2 {
+		t.Errorf("blobs on disk = %d, want 2", n)
+	}
+}
+
+func TestFetchWithoutInitDownloads(t *testing.T) {
+	srv, hits := countingServer(t, func(string) string { return "x" })
+	for range 2 {
+		if _, err := Fetch(srv.URL+"/a.png", 0); err != nil {
+			t.Fatalf("Fetch: %v", err)
+		}
+	}
+	if n := hits.Load(); n != 2 {
+		t.Errorf("server hits = %d, want every fetch downloaded without a cache", n)
+	}
+}
+
+func TestFetchPublicRefusesLocalAddresses(t *testing.T) {
+	srv, hits := countingServer(t, func(string) string { return "x" })
+	// Redirects are refused the same way, when they connect.
+	redirect := httptest.NewServer(http.RedirectHandler(srv.URL+"/a.png", http.StatusFound))
+	defer redirect.Close()
+
+	for _, link := range []string{srv.URL + "/a.png", redirect.URL} {
+		if _, err := FetchPublic(link, 0); !errors.Is(err, ErrNonPublicAddress) {
+			t.Errorf("FetchPublic(%q) error = %v, want ErrNonPublicAddress", link, err)
+		}
+	}
+	if n := hits.Load(); n != 0 {
+		t.Errorf("server hits = %d, want none", n)
+	}
+}
+
+func TestIsPublicIP(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"93.184.216.34":    true,
+		"2606:4700::1111":  true,
+		"127.0.0.1":        false,
+		"10.1.2.3":         false,
+		"192.168.0.1":      false,
+		"169.254.169.254":  false,
+		"::1":              false,
+		"fe80::1":          false,
+		"::ffff:127.0.0.1": false,
+		"0.0.0.0":          false,
+	} {
+		if got := IsPublicIP(net.ParseIP(addr)); got != want {
+			t.Errorf("IsPublicIP(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
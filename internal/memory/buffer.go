@@ -116,6 +116,9 @@ func BufferMessage(channelID, guildID, discordID, username, displayName, text, m
 	if strings.TrimSpace(channelID) == "" || strings.TrimSpace(guildID) == "" {
 		return
 	}
+	if IsMemoryOptedOut(discordID) {
+		return
+	}
 
 	msg := bufMsg{
 		DiscordID:   discordID,
@@ -303,6 +306,8 @@ func flushBufferData(buf *channelBuffer) error {
 }
 
 func processBuffer(buf *channelBuffer) error {
+	// Users may have opted out since their messages were buffered.
+	buf.Messages = withoutOptedOutMessages(buf.Messages)
 	if visibleContentLen(buf.Messages) < minBufferedContentLength {
 		return nil
 	}
@@ -466,7 +471,7 @@ func generateConversationNoteOpenAI(ctx context.Context, guildID, channelID stri
 	}
 
 	prompt := fmt.Sprintf("Guild: %s\nChannel: %s\n\nTranscript:\n%s", guildID, channelID, transcript.String())
-	responseText, err := generateJSON(ctx, noteGenerationModel, conversationNoteSystemPrompt, prompt, "note_generation", noteGenerationReasoning, conversationNoteResponseSchema)
+	responseText, err := generateJSON(ctx, phaseNoteGeneration, conversationNoteSystemPrompt, prompt, conversationNoteResponseSchema)
 	if err != nil {
 		return generatedConversationNote{}, err
 	}
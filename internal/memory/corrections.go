@@ -0,0 +1,278 @@
+package memory
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Kinds of profile_fact_overrides rows. Pinned facts are added by the user and
+// always shown; deleted and disputed facts are hidden and kept out of later
+// profile updates.
+const (
+	factOverridePinned   = "pinned"
+	factOverrideDeleted  = "deleted"
+	factOverrideDisputed = "disputed"
+	maxPinnedFacts       = 10
+)
+
+// ProfileSections are the section names facts can be pinned to.
+var ProfileSections = []string{"bio", "interests", "skills", "opinions", "relationships", "other"}
+
+var (
+	ErrFactNotFound      = errors.New("no fact with that ID in your profile")
+	ErrTooManyPinned     = fmt.Errorf("you can pin at most %d facts", maxPinnedFacts)
+	ErrInvalidSection    = errors.New("unknown profile section")
+	ErrEmptyFact         = errors.New("the fact is empty")
+	ErrFactTooLong       = fmt.Errorf("pinned facts can be at most %d words", profileMaxFactWords)
+	ErrFactAlreadyPinned = errors.New("that fact is already pinned")
+)
+
+type sectionFact struct {
+	Section string `json:"section"`
+	Text    string `json:"text"`
+}
+
+// profileCorrections are a user's overrides of their generated profile.
+type profileCorrections struct {
+	Pinned   []sectionFact `json:"pinned"`
+	Disputed []sectionFact `json:"disputed"`
+	Deleted  []sectionFact `json:"deleted"`
+}
+
+func (c profileCorrections) empty() bool {
+	return len(c.Pinned)+len(c.Disputed)+len(c.Deleted) == 0
+}
+
+// suppresses reports whether text is a pinned, deleted or disputed fact, all
+// of which are kept out of the stored profile.
+func (c profileCorrections) suppresses(text string) bool {
+	key := factKey(text)
+	for _, list := range [][]sectionFact{c.Pinned, c.Disputed, c.Deleted} {
+		for _, fact := range list {
+			if factKey(fact.Text) == key {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// FactID is the short ID users refer to a profile fact by. It is derived from
+// the fact's text, so it stays the same while the fact does.
+func FactID(text string) string {
+	sum := sha256.Sum256([]byte(factKey(text)))
+	return hex.EncodeToString(sum[:3])
+}
+
+func factKey(text string) string {
+	return strings.ToLower(strings.Join(strings.Fields(text), " "))
+}
+
+func profileSectionFacts(profile *GuildUserProfile, section string) *[]ProfileFact {
+	switch section {
+	case "bio":
+		return &profile.Bio
+	case "interests":
+		return &profile.Interests
+	case "skills":
+		return &profile.Skills
+	case "opinions":
+		return &profile.Opinions
+	case "relationships":
+		return &profile.Relationships
+	case "other":
+		return &profile.Other
+	}
+	return nil
+}
+
+func loadProfileCorrections(guildID string, userID int64) (profileCorrections, error) {
+	var c profileCorrections
+	rows, err := database.Query(`
+		SELECT kind, section, text
+		FROM profile_fact_overrides
+		WHERE guild_id = ? AND user_id = ?
+		ORDER BY id ASC
+	`, guildID, userID)
+	if err != nil {
+		return c, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var kind string
+		var fact sectionFact
+		if err := rows.Scan(&kind, &fact.Section, &fact.Text); err != nil {
+			return c, err
+		}
+		switch kind {
+		case factOverridePinned:
+			c.Pinned = append(c.Pinned, fact)
+		case factOverrideDisputed:
+			c.Disputed = append(c.Disputed, fact)
+		case factOverrideDeleted:
+			c.Deleted = append(c.Deleted, fact)
+		}
+	}
+	return c, rows.Err()
+}
+
+// applyProfileCorrections hides deleted and disputed facts and puts pinned
+// facts at the top of their sections.
+func applyProfileCorrections(profile *GuildUserProfile, c profileCorrections) {
+	stripped := stripProfileCorrections(*profile, c)
+	*profile = stripped
+	for i := len(c.Pinned) - 1; i >= 0; i-- {
+		pin := c.Pinned[i]
+		if facts := profileSectionFacts(profile, pin.Section); facts != nil {
+			*facts = append([]ProfileFact{{Text: pin.Text, Pinned: true}}, *facts...)
+		}
+	}
+}
+
+// stripProfileCorrections returns profile without pinned facts, which are
+// stored as overrides, and without facts the user deleted or disputed.
+func stripProfileCorrections(profile GuildUserProfile, c profileCorrections) GuildUserProfile {
+	stripped := cloneProfile(profile)
+	for _, section := range ProfileSections {
+		facts := profileSectionFacts(&stripped, section)
+		*facts = slices.DeleteFunc(*facts, func(fact ProfileFact) bool {
+			return fact.Pinned || c.suppresses(fact.Text)
+		})
+	}
+	return stripped
+}
+
+// findProfileFact returns the section and fact with the given FactID.
+func findProfileFact(profile *GuildUserProfile, factID string) (string, ProfileFact, bool) {
+	factID = strings.ToLower(strings.TrimSpace(factID))
+	for _, section := range ProfileSections {
+		for _, fact := range *profileSectionFacts(profile, section) {
+			if FactID(fact.Text) == factID {
+				return section, fact, true
+			}
+		}
+	}
+	return "", ProfileFact{}, false
+}
+
+// PinProfileFact adds a fact the user wrote to their profile. Pinned facts
+// are always shown and profile updates never drop or contradict them.
+func PinProfileFact(guildID, discordID, username, displayName, section, text string) (ProfileFact, error) {
+	if database == nil {
+		return ProfileFact{}, fmt.Errorf("memory system not initialized")
+	}
+	text = strings.Join(strings.Fields(text), " ")
+	if profileSectionFacts(&GuildUserProfile{}, section) == nil {
+		return ProfileFact{}, ErrInvalidSection
+	}
+	if text == "" {
+		return ProfileFact{}, ErrEmptyFact
+	}
+	if len(strings.Fields(text)) > profileMaxFactWords {
+		return ProfileFact{}, ErrFactTooLong
+	}
+
+	userID, _, err := upsertUser(discordID, username, displayName)
+	if err != nil {
+		return ProfileFact{}, err
+	}
+	c, err := loadProfileCorrections(guildID, userID)
+	if err != nil {
+		return ProfileFact{}, err
+	}
+	if len(c.Pinned) >= maxPinnedFacts {
+		return ProfileFact{}, ErrTooManyPinned
+	}
+	for _, pin := range c.Pinned {
+		if factKey(pin.Text) == factKey(text) {
+			return ProfileFact{}, ErrFactAlreadyPinned
+		}
+	}
+
+	if err := insertFactOverride(guildID, userID, factOverridePinned, section, text); err != nil {
+		return ProfileFact{}, err
+	}
+	return ProfileFact{Text: text, Pinned: true}, nil
+}
+
+// DeleteProfileFact removes a fact from the user's profile and keeps later
+// updates from adding it back.
+func DeleteProfileFact(guildID, discordID, factID string) (ProfileFact, error) {
+	fact, _, err := removeProfileFact(guildID, discordID, factID, factOverrideDeleted)
+	return fact, err
+}
+
+// DisputeProfileFact removes a fact the user says is wrong, so later updates
+// never assert it again, and pins correction in its place when given.
+func DisputeProfileFact(guildID, discordID, factID, correction string) (ProfileFact, error) {
+	if len(strings.Fields(correction)) > profileMaxFactWords {
+		return ProfileFact{}, ErrFactTooLong
+	}
+	fact, section, err := removeProfileFact(guildID, discordID, factID, factOverrideDisputed)
+	if err != nil || strings.TrimSpace(correction) == "" {
+		return fact, err
+	}
+	user, err := getUserIdentityByDiscordID(discordID)
+	if err != nil || user == nil {
+		return fact, err
+	}
+	_, err = PinProfileFact(guildID, discordID, user.Username, user.DisplayName, section, correction)
+	return fact, err
+}
+
+func removeProfileFact(guildID, discordID, factID, kind string) (ProfileFact, string, error) {
+	if database == nil {
+		return ProfileFact{}, "", fmt.Errorf("memory system not initialized")
+	}
+	user, err := getUserIdentityByDiscordID(discordID)
+	if err != nil {
+		return ProfileFact{}, "", err
+	}
+	if user == nil {
+		return ProfileFact{}, "", ErrFactNotFound
+	}
+	profile, err := getGuildUserProfileByUserID(guildID, user.UserID)
+	if err != nil {
+		return ProfileFact{}, "", err
+	}
+	if profile == nil {
+		return ProfileFact{}, "", ErrFactNotFound
+	}
+	section, fact, ok := findProfileFact(profile, factID)
+	if !ok {
+		return ProfileFact{}, "", ErrFactNotFound
+	}
+
+	// Removing a pinned fact only unpins it; the user wrote it, so there is
+	// nothing to keep out of later updates.
+	if fact.Pinned {
+		_, err := database.Exec(`
+			DELETE FROM profile_fact_overrides
+			WHERE guild_id = ? AND user_id = ? AND kind = ? AND text = ?
+		`, guildID, user.UserID, factOverridePinned, fact.Text)
+		return fact, section, err
+	}
+
+	if err := insertFactOverride(guildID, user.UserID, kind, section, fact.Text); err != nil {
+		return ProfileFact{}, "", err
+	}
+	// Rewrite the stored profile so the fact is gone from the row too.
+	if err := writeGuildUserProfile(*profile); err != nil {
+		return ProfileFact{}, "", err
+	}
+	return fact, section, nil
+}
+
+func insertFactOverride(guildID string, userID int64, kind, section, text string) error {
+	_, err := database.Exec(`
+		INSERT INTO profile_fact_overrides (guild_id, user_id, kind, section, text)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(guild_id, user_id, kind, text) DO NOTHING
+	`, guildID, userID, kind, section, text)
+	return err
+}
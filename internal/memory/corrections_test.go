@@ -0,0 +1,121 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestProfileFactCorrections(t *testing.T) {
+	setupTestDB(t)
+
+	userID, _, err := upsertUser("discord-fix", "fixer", "Fixer")
+	if err != nil {
+		t.Fatalf("upsertUser: %v", err)
+	}
+	profile := emptyProfile("guild-fix", userID)
+	profile.Bio = []ProfileFact{{Text: "Lives in Austin.", SourceNoteIDs: []int64{1}}}
+	profile.Interests = []ProfileFact{{Text: "Plays chess.", SourceNoteIDs: []int64{2}}}
+	if err := writeGuildUserProfile(profile); err != nil {
+		t.Fatalf("writeGuildUserProfile: %v", err)
+	}
+
+	if _, err := PinProfileFact("guild-fix", "discord-fix", "fixer", "Fixer", "skills", "Speaks fluent Dutch."); err != nil {
+		t.Fatalf("PinProfileFact: %v", err)
+	}
+	if _, err := PinProfileFact("guild-fix", "discord-fix", "fixer", "Fixer", "skills", "speaks fluent dutch."); !errors.Is(err, ErrFactAlreadyPinned) {
+		t.Errorf("pinning twice = %v, want ErrFactAlreadyPinned", err)
+	}
+	if _, err := PinProfileFact("guild-fix", "discord-fix", "fixer", "Fixer", "hobbies", "Knits."); !errors.Is(err, ErrInvalidSection) {
+		t.Errorf("pinning to an unknown section = %v, want ErrInvalidSection", err)
+	}
+
+	if _, err := DeleteProfileFact("guild-fix", "discord-fix", FactID("Plays chess.")); err != nil {
+		t.Fatalf("DeleteProfileFact: %v", err)
+	}
+	fact, err := DisputeProfileFact("guild-fix", "discord-fix", FactID("Lives in Austin."), "Lives in Denver.")
+	if err != nil || fact.Text != "Lives in Austin." {
+		t.Fatalf("DisputeProfileFact = %+v, %v", fact, err)
+	}
+	if _, err := DeleteProfileFact("guild-fix", "discord-fix", "ffffff"); !errors.Is(err, ErrFactNotFound) {
+		t.Errorf("deleting an unknown fact = %v, want ErrFactNotFound", err)
+	}
+
+	// A rebuild that brings the removed facts back and repeats a pin must not
+	// undo the corrections.
+	setProfileRebuilder(t, func(ctx context.Context, guildID string, target userIdentity, notes []InteractionNote) (GuildUserProfile, error) {
+		rebuilt := emptyProfile(guildID, target.UserID)
+		rebuilt.Bio = []ProfileFact{{Text: "lives in Austin.", SourceNoteIDs: []int64{1}}}
+		rebuilt.Interests = []ProfileFact{{Text: "Plays chess.", SourceNoteIDs: []int64{2}}, {Text: "Bakes bread.", SourceNoteIDs: []int64{3}}}
+		rebuilt.Skills = []ProfileFact{{Text: "Speaks fluent Dutch.", SourceNoteIDs: []int64{3}}}
+		return rebuilt, nil
+	})
+	rebuilt, _ := rebuildGuildProfile(context.Background(), "guild-fix", userIdentity{UserID: userID}, nil)
+	if err := writeGuildUserProfile(rebuilt); err != nil {
+		t.Fatalf("writeGuildUserProfile: %v", err)
+	}
+
+	loaded, err := GetGuildUserProfile("guild-fix", "discord-fix")
+	if err != nil || loaded == nil {
+		t.Fatalf("GetGuildUserProfile = %+v, %v", loaded, err)
+	}
+	if len(loaded.Bio) != 1 || loaded.Bio[0].Text != "Lives in Denver." || !loaded.Bio[0].Pinned {
+		t.Errorf("bio = %+v, want only the pinned correction", loaded.Bio)
+	}
+	if len(loaded.Interests) != 1 || loaded.Interests[0].Text != "Bakes bread." {
+		t.Errorf("interests = %+v, want the deleted fact gone", loaded.Interests)
+	}
+	if len(loaded.Skills) != 1 || !loaded.Skills[0].Pinned {
+		t.Errorf("skills = %+v, want the pin once", loaded.Skills)
+	}
+
+	rendered := RenderProfileMarkdownWithIDs(loaded, "fixer")
+	if !strings.Contains(rendered, "`"+FactID("Bakes bread.")+"` Bakes bread.") || !strings.Contains(rendered, "Lives in Denver. _(pinned)_") {
+		t.Errorf("rendered profile = %q, want fact IDs and pin markers", rendered)
+	}
+
+	// Removing a pinned fact unpins it rather than suppressing it.
+	if _, err := DeleteProfileFact("guild-fix", "discord-fix", FactID("Speaks fluent Dutch.")); err != nil {
+		t.Fatalf("DeleteProfileFact pinned: %v", err)
+	}
+	corrections, err := loadProfileCorrections("guild-fix", userID)
+	if err != nil {
+		t.Fatalf("loadProfileCorrections: %v", err)
+	}
+	if len(corrections.Pinned) != 1 || len(corrections.Deleted) != 1 || len(corrections.Disputed) != 1 {
+		t.Errorf("corrections = %+v, want one pin, one deletion and one dispute", corrections)
+	}
+
+	if err := DeleteUserMemory("guild-fix", "discord-fix"); err != nil {
+		t.Fatalf("DeleteUserMemory: %v", err)
+	}
+	if corrections, _ := loadProfileCorrections("guild-fix", userID); !corrections.empty() {
+		t.Errorf("corrections after DeleteUserMemory = %+v, want none", corrections)
+	}
+}
+
+func TestPinnedFactsShowWithoutProfile(t *testing.T) {
+	setupTestDB(t)
+
+	if _, err := PinProfileFact("guild-pin", "discord-pin", "pinner", "", "other", "Prefers they/them."); err != nil {
+		t.Fatalf("PinProfileFact: %v", err)
+	}
+	profile, err := GetGuildUserProfile("guild-pin", "discord-pin")
+	if err != nil || profile == nil || len(profile.Other) != 1 || !profileHasContent(profile) {
+		t.Fatalf("GetGuildUserProfile = %+v, %v; want the pinned fact", profile, err)
+	}
+}
+
+func TestProfilePromptsPreserveCorrections(t *testing.T) {
+	for name, prompt := range map[string]string{
+		"incremental": incrementalProfileSystemPrompt(),
+		"rebuild":     rebuildProfileSystemPrompt(),
+	} {
+		for _, fragment := range []string{"Pinned facts", "never contradict them", "disputed fact", "deleted fact"} {
+			if !strings.Contains(prompt, fragment) {
+				t.Errorf("%s prompt missing %q", name, fragment)
+			}
+		}
+	}
+}
@@ -21,6 +21,9 @@ func DeleteUserMemory(guildID, discordID string) error {
 	if err := DeleteGuildUserProfile(guildID, discordID); err != nil {
 		return err
 	}
+	if _, err := database.Exec("DELETE FROM profile_fact_overrides WHERE guild_id = ? AND user_id = ?", guildID, user.UserID); err != nil {
+		return err
+	}
 
 	noteIDs, err := getAffectedNoteIDsForUser(guildID, user.UserID)
 	if err != nil {
@@ -106,6 +109,9 @@ func DeleteAllGuildMemory(guildID string) error {
 		return err
 	}
 
+	vecTableMu.RLock()
+	defer vecTableMu.RUnlock()
+
 	tx, err := database.Begin()
 	if err != nil {
 		return err
@@ -113,7 +119,7 @@ func DeleteAllGuildMemory(guildID string) error {
 	defer tx.Rollback()
 
 	for _, noteID := range noteIDs {
-		if _, err := tx.Exec("DELETE FROM vec_notes WHERE note_id = ?", noteID); err != nil {
+		if err := deleteNoteVector(tx, noteID); err != nil {
 			return err
 		}
 	}
@@ -123,6 +129,9 @@ func DeleteAllGuildMemory(guildID string) error {
 	if _, err := tx.Exec("DELETE FROM guild_user_profiles WHERE guild_id = ?", guildID); err != nil {
 		return err
 	}
+	if _, err := tx.Exec("DELETE FROM profile_fact_overrides WHERE guild_id = ?", guildID); err != nil {
+		return err
+	}
 	if _, err := tx.Exec("DELETE FROM channel_buffers WHERE guild_id = ?", guildID); err != nil {
 		return err
 	}
@@ -140,6 +149,9 @@ func redactMixedParticipantNote(note InteractionNote) error {
 }
 
 func rewriteNote(noteID int64, title, summary string) error {
+	vecTableMu.RLock()
+	defer vecTableMu.RUnlock()
+
 	tx, err := database.Begin()
 	if err != nil {
 		return err
@@ -153,17 +165,13 @@ func rewriteNote(noteID int64, title, summary string) error {
 	`, title, summary, noteID); err != nil {
 		return err
 	}
-	if _, err := tx.Exec("DELETE FROM vec_notes WHERE note_id = ?", noteID); err != nil {
+	if err := deleteNoteVector(tx, noteID); err != nil {
 		return err
 	}
 
 	embedding, err := embedText(context.Background(), title+"\n"+summary)
 	if err == nil {
-		if _, err := tx.Exec(
-			"INSERT INTO vec_notes (note_id, embedding) VALUES (?, ?)",
-			noteID,
-			serializeFloat32(embedding),
-		); err != nil {
+		if err := insertNoteVector(tx, noteID, embedding); err != nil {
 			return err
 		}
 	}
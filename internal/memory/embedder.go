@@ -0,0 +1,148 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	openaiapi "voltgpt/internal/apis/openai"
+
+	oa "github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/option"
+)
+
+// Embedder turns note and query text into vectors for note search. Every
+// vector it returns must have Dimensions() values; notes are re-embedded when
+// the model or the dimensions change.
+type Embedder interface {
+	Embed(ctx context.Context, text string) ([]float32, error)
+	Model() string
+	Dimensions() int
+}
+
+var (
+	embedder   Embedder
+	embedderMu sync.Mutex
+)
+
+// SetEmbedder replaces the embedding backend, e.g. with a local model. It
+// must be called before Init; passing nil restores the OpenAI-compatible
+// default.
+func SetEmbedder(e Embedder) {
+	embedderMu.Lock()
+	defer embedderMu.Unlock()
+	embedder = e
+}
+
+func getEmbedder() (Embedder, error) {
+	embedderMu.Lock()
+	defer embedderMu.Unlock()
+	if embedder == nil {
+		e, err := newOpenAIEmbedder()
+		if err != nil {
+			return nil, err
+		}
+		embedder = e
+	}
+	return embedder, nil
+}
+
+func embed(ctx context.Context, text string) ([]float32, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("embed: empty text")
+	}
+	e, err := getEmbedder()
+	if err != nil {
+		return nil, fmt.Errorf("embed: %w", err)
+	}
+	return e.Embed(ctx, text)
+}
+
+type openAIEmbedder struct {
+	client     oa.Client
+	model      string
+	dimensions int
+	// sendDimensions asks the API to shorten its vectors to dimensions. Only
+	// OpenAI's text-embedding-3 models accept the parameter.
+	sendDimensions bool
+}
+
+// newOpenAIEmbedder reads MEMORY_EMBEDDING_TOKEN (falling back to
+// MEMORY_OPENAI_TOKEN), MEMORY_EMBEDDING_BASE_URL (falling back to
+// MEMORY_OPENAI_BASE_URL, never to the chat endpoint), MEMORY_EMBEDDING_MODEL
+// and MEMORY_EMBEDDING_DIMENSIONS. A token is optional when a base URL is set,
+// since local servers usually don't check it.
+func newOpenAIEmbedder() (Embedder, error) {
+	token := strings.TrimSpace(os.Getenv("MEMORY_EMBEDDING_TOKEN"))
+	if token == "" {
+		token = strings.TrimSpace(os.Getenv("MEMORY_OPENAI_TOKEN"))
+	}
+	baseURL := openaiapi.MemoryEmbeddingBaseURL()
+	if token == "" && baseURL == "" {
+		return nil, fmt.Errorf("neither MEMORY_EMBEDDING_TOKEN, MEMORY_OPENAI_TOKEN nor MEMORY_EMBEDDING_BASE_URL is set")
+	}
+	if token == "" {
+		token = "local"
+	}
+
+	model := strings.TrimSpace(os.Getenv("MEMORY_EMBEDDING_MODEL"))
+	if model == "" {
+		model = defaultEmbeddingModel
+	}
+	dimensions := defaultEmbeddingDimensions
+	if raw := strings.TrimSpace(os.Getenv("MEMORY_EMBEDDING_DIMENSIONS")); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid MEMORY_EMBEDDING_DIMENSIONS %q", raw)
+		}
+		dimensions = n
+	}
+
+	opts := []option.RequestOption{option.WithAPIKey(token)}
+	if baseURL != "" {
+		opts = append(opts, option.WithBaseURL(baseURL))
+	}
+
+	return openAIEmbedder{
+		client:         oa.NewClient(opts...),
+		model:          model,
+		dimensions:     dimensions,
+		sendDimensions: strings.HasPrefix(model, "text-embedding-3"),
+	}, nil
+}
+
+func (e openAIEmbedder) Model() string   { return e.model }
+func (e openAIEmbedder) Dimensions() int { return e.dimensions }
+
+func (e openAIEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	params := oa.EmbeddingNewParams{
+		Input: oa.EmbeddingNewParamsInputUnion{
+			OfString: oa.String(text),
+		},
+		Model:          e.model,
+		EncodingFormat: oa.EmbeddingNewParamsEncodingFormatFloat,
+	}
+	if e.sendDimensions {
+		params.Dimensions = oa.Int(int64(e.dimensions))
+	}
+
+	resp, err := e.client.Embeddings.New(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Data) == 0 {
+		return nil, fmt.Errorf("embedding API returned no embeddings")
+	}
+	if got := len(resp.Data[0].Embedding); got != e.dimensions {
+		return nil, fmt.Errorf("embedding model %s returned %d dimensions, want %d; set MEMORY_EMBEDDING_DIMENSIONS", e.model, got, e.dimensions)
+	}
+
+	values := make([]float32, len(resp.Data[0].Embedding))
+	for i, value := range resp.Data[0].Embedding {
+		values[i] = float32(value)
+	}
+	return values, nil
+}
@@ -0,0 +1,115 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func serveEmbeddings(t *testing.T, embedding []float64) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/embeddings" {
+			t.Errorf("request path = %s, want /v1/embeddings", r.URL.Path)
+		}
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		if _, ok := body["dimensions"]; ok {
+			t.Error("dimensions sent to a model that does not support it")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"object": "list",
+			"model":  body["model"],
+			"data":   []map[string]any{{"object": "embedding", "index": 0, "embedding": embedding}},
+			"usage":  map[string]any{"prompt_tokens": 1, "total_tokens": 1},
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestOpenAIEmbedderUsesBaseURL(t *testing.T) {
+	srv := serveEmbeddings(t, []float64{0.25, 0.5, 0.75})
+	t.Setenv("MEMORY_EMBEDDING_TOKEN", "")
+	t.Setenv("MEMORY_OPENAI_TOKEN", "")
+	t.Setenv("MEMORY_EMBEDDING_BASE_URL", srv.URL+"/v1")
+	t.Setenv("MEMORY_EMBEDDING_MODEL", "nomic-embed-text")
+	t.Setenv("MEMORY_EMBEDDING_DIMENSIONS", "3")
+
+	e, err := newOpenAIEmbedder()
+	if err != nil {
+		t.Fatalf("newOpenAIEmbedder: %v", err)
+	}
+	if e.Model() != "nomic-embed-text" || e.Dimensions() != 3 {
+		t.Errorf("embedder = %s/%d, want nomic-embed-text/3", e.Model(), e.Dimensions())
+	}
+	got, err := e.Embed(context.Background(), "hello")
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	if len(got) != 3 || got[1] != 0.5 {
+		t.Errorf("Embed = %v, want [0.25 0.5 0.75]", got)
+	}
+}
+
+func TestOpenAIEmbedderRejectsWrongDimensions(t *testing.T) {
+	srv := serveEmbeddings(t, []float64{0.25, 0.5, 0.75})
+	// Given without /v1, like MEMORY_OPENAI_BASE_URL may be.
+	t.Setenv("MEMORY_EMBEDDING_BASE_URL", srv.URL)
+	t.Setenv("MEMORY_EMBEDDING_MODEL", "nomic-embed-text")
+	t.Setenv("MEMORY_EMBEDDING_DIMENSIONS", "768")
+
+	e, err := newOpenAIEmbedder()
+	if err != nil {
+		t.Fatalf("newOpenAIEmbedder: %v", err)
+	}
+	if _, err := e.Embed(context.Background(), "hello"); err == nil || !strings.Contains(err.Error(), "MEMORY_EMBEDDING_DIMENSIONS") {
+		t.Errorf("Embed error = %v, want a dimensions mismatch", err)
+	}
+}
+
+func TestNewOpenAIEmbedderConfig(t *testing.T) {
+	t.Setenv("MEMORY_EMBEDDING_TOKEN", "")
+	t.Setenv("MEMORY_OPENAI_TOKEN", "")
+	t.Setenv("MEMORY_EMBEDDING_BASE_URL", "")
+	t.Setenv("MEMORY_OPENAI_BASE_URL", "")
+	t.Setenv("OPENAI_BASE", "")
+	if _, err := newOpenAIEmbedder(); err == nil {
+		t.Error("newOpenAIEmbedder succeeded without a token or base URL")
+	}
+
+	// The chat endpoint is not the memory endpoint.
+	t.Setenv("OPENAI_BASE", "https://proxy.example.com")
+	if _, err := newOpenAIEmbedder(); err == nil {
+		t.Error("newOpenAIEmbedder fell back to the chat base URL")
+	}
+	t.Setenv("OPENAI_BASE", "")
+
+	t.Setenv("MEMORY_OPENAI_BASE_URL", "http://localhost:8080")
+	if _, err := newOpenAIEmbedder(); err != nil {
+		t.Errorf("newOpenAIEmbedder with the memory base URL: %v", err)
+	}
+	t.Setenv("MEMORY_OPENAI_BASE_URL", "")
+
+	t.Setenv("MEMORY_OPENAI_TOKEN", "token")
+	t.Setenv("MEMORY_EMBEDDING_MODEL", "")
+	t.Setenv("MEMORY_EMBEDDING_DIMENSIONS", "")
+	e, err := newOpenAIEmbedder()
+	if err != nil {
+		t.Fatalf("newOpenAIEmbedder: %v", err)
+	}
+	if e.Model() != defaultEmbeddingModel || e.Dimensions() != defaultEmbeddingDimensions {
+		t.Errorf("default embedder = %s/%d", e.Model(), e.Dimensions())
+	}
+
+	t.Setenv("MEMORY_EMBEDDING_DIMENSIONS", "lots")
+	if _, err := newOpenAIEmbedder(); err == nil {
+		t.Error("newOpenAIEmbedder accepted invalid dimensions")
+	}
+}
@@ -0,0 +1,138 @@
+package memory
+
+import "fmt"
+
+// UserExport is everything memory stores about one user, across guilds.
+type UserExport struct {
+	DiscordID     string            `json:"discord_id"`
+	Username      string            `json:"username"`
+	DisplayName   string            `json:"display_name"`
+	PreferredName string            `json:"preferred_name"`
+	Timezone      string            `json:"timezone"`
+	MemoryOptOut  bool              `json:"memory_opt_out"`
+	Profiles      []ExportedProfile `json:"profiles"`
+	Notes         []ExportedNote    `json:"notes"`
+}
+
+// ExportedProfile is a user's profile in one guild, with the facts they
+// pinned, deleted or disputed.
+type ExportedProfile struct {
+	GuildID       string        `json:"guild_id"`
+	Bio           []ProfileFact `json:"bio"`
+	Interests     []ProfileFact `json:"interests"`
+	Skills        []ProfileFact `json:"skills"`
+	Opinions      []ProfileFact `json:"opinions"`
+	Relationships []ProfileFact `json:"relationships"`
+	Other         []ProfileFact `json:"other"`
+	Disputed      []sectionFact `json:"disputed"`
+	Deleted       []sectionFact `json:"deleted"`
+	UpdatedAt     string        `json:"updated_at,omitempty"`
+}
+
+// ExportedNote is a conversation note or topic digest the user took part in.
+type ExportedNote struct {
+	ID        int64  `json:"id"`
+	GuildID   string `json:"guild_id"`
+	ChannelID string `json:"channel_id,omitempty"`
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Summary   string `json:"summary"`
+	Date      string `json:"date"`
+	CreatedAt string `json:"created_at"`
+}
+
+// ExportUserData collects the user's stored memory for a data export.
+func ExportUserData(discordID string) (*UserExport, error) {
+	if database == nil {
+		return nil, fmt.Errorf("memory system not initialized")
+	}
+	user, err := getUserIdentityByDiscordID(discordID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return &UserExport{DiscordID: discordID, Profiles: []ExportedProfile{}, Notes: []ExportedNote{}}, nil
+	}
+
+	export := &UserExport{
+		DiscordID:     user.DiscordID,
+		Username:      user.Username,
+		DisplayName:   user.DisplayName,
+		PreferredName: user.PreferredName,
+		Timezone:      GetTimezone(discordID),
+		MemoryOptOut:  IsMemoryOptedOut(discordID),
+		Profiles:      []ExportedProfile{},
+		Notes:         []ExportedNote{},
+	}
+
+	guildIDs, err := listUserProfileGuilds(user.UserID)
+	if err != nil {
+		return nil, err
+	}
+	for _, guildID := range guildIDs {
+		profile, err := getGuildUserProfileByUserID(guildID, user.UserID)
+		if err != nil {
+			return nil, err
+		}
+		corrections, err := loadProfileCorrections(guildID, user.UserID)
+		if err != nil {
+			return nil, err
+		}
+		exported := ExportedProfile{GuildID: guildID, Disputed: corrections.Disputed, Deleted: corrections.Deleted}
+		if profile != nil {
+			exported.Bio = profile.Bio
+			exported.Interests = profile.Interests
+			exported.Skills = profile.Skills
+			exported.Opinions = profile.Opinions
+			exported.Relationships = profile.Relationships
+			exported.Other = profile.Other
+			exported.UpdatedAt = profile.UpdatedAt
+		}
+		export.Profiles = append(export.Profiles, exported)
+	}
+
+	rows, err := database.Query(`
+		SELECT n.id, n.guild_id, COALESCE(n.channel_id, ''), n.note_type, n.title, n.summary, n.note_date, n.created_at
+		FROM interaction_notes n
+		JOIN note_participants np ON np.note_id = n.id
+		WHERE np.participant_user_id = ?
+		ORDER BY n.note_date ASC, n.created_at ASC
+	`, user.UserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var note ExportedNote
+		if err := rows.Scan(&note.ID, &note.GuildID, &note.ChannelID, &note.Type, &note.Title, &note.Summary, &note.Date, &note.CreatedAt); err != nil {
+			return nil, err
+		}
+		export.Notes = append(export.Notes, note)
+	}
+	return export, rows.Err()
+}
+
+// listUserProfileGuilds returns the guilds with a profile or fact overrides
+// for the user.
+func listUserProfileGuilds(userID int64) ([]string, error) {
+	rows, err := database.Query(`
+		SELECT guild_id FROM guild_user_profiles WHERE user_id = ?
+		UNION
+		SELECT guild_id FROM profile_fact_overrides WHERE user_id = ?
+		ORDER BY guild_id
+	`, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var guildIDs []string
+	for rows.Next() {
+		var guildID string
+		if err := rows.Scan(&guildID); err != nil {
+			return nil, err
+		}
+		guildIDs = append(guildIDs, guildID)
+	}
+	return guildIDs, rows.Err()
+}
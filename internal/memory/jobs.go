@@ -69,6 +69,11 @@ func runClusterPhase(guildID, date string) (err error) {
 	if err != nil {
 		return err
 	}
+	optedOut, err := optedOutUserIDs()
+	if err != nil {
+		return err
+	}
+	notes = withoutOptedOutNotes(notes, optedOut)
 	if len(notes) < minClusterInputNotes {
 		return nil
 	}
@@ -205,7 +210,7 @@ func clusterGuildDayOpenAI(ctx context.Context, guildID, date string, notes []In
 	}
 
 	prompt := fmt.Sprintf("Guild: %s\nDate: %s\nConversation notes JSON:\n%s", guildID, date, jsonString(notes))
-	responseText, err := generateJSON(ctx, clusteringModel, clusterSystemPrompt, prompt, "topic_cluster", clusteringReasoning, clusterResponseSchema)
+	responseText, err := generateJSON(ctx, phaseClustering, clusterSystemPrompt, prompt, clusterResponseSchema)
 	if err != nil {
 		return nil, err
 	}
@@ -258,7 +263,7 @@ func rebuildOneGuildProfile(guildID string, userID int64) error {
 	if err != nil {
 		return err
 	}
-	if user == nil {
+	if user == nil || IsMemoryOptedOut(user.DiscordID) {
 		return nil
 	}
 
@@ -403,6 +408,11 @@ func runScheduledMaintenanceSweep() error {
 		}
 	}
 
+	// Retry notes that failed to embed, finishing an embedder migration.
+	if err := reembedNotes(context.Background()); err != nil {
+		log.Printf("memory: scheduled re-embedding failed: %v", err)
+	}
+
 	guildIDs, err := listGuildsWithDirtyProfiles()
 	if err != nil {
 		return err
@@ -16,61 +16,63 @@ import (
 	openaiapi "voltgpt/internal/apis/openai"
 
 	oa "github.com/openai/openai-go/v3"
-	"github.com/openai/openai-go/v3/responses"
 	"github.com/openai/openai-go/v3/shared"
 )
 
 const (
-	embeddingModel                            = oa.EmbeddingModelTextEmbedding3Small
-	embeddingDimensions                 int64 = 1536
-	noteGenerationModel                       = "gpt-5.4-mini"
-	noteGenerationReasoning                   = shared.ReasoningEffortMedium
-	incrementalUpdateModel                    = "gpt-5.4-mini"
-	incrementalUpdateReasoning                = shared.ReasoningEffortMedium
-	clusteringModel                           = "gpt-5.4-mini"
-	clusteringReasoning                       = shared.ReasoningEffortMedium
-	fullRebuildModel                          = "gpt-5.4-mini"
-	fullRebuildReasoning                      = shared.ReasoningEffortMedium
-	strictRetrievalDistance                   = 0.45
-	fallbackRetrievalDistance                 = 0.62
-	retrievalCandidateMultiplier              = 12
-	topicRetrievalLimit                       = 3
-	conversationRetrievalLimit                = 5
-	mentionedProfileLimit                     = 3
-	extraProfileLimit                         = 2
-	recentUserFallbackNoteLimit               = 3
-	minBufferedContentLength                  = 100
-	minClusterInputNotes                      = 3
-	bufferInactivityWindow                    = 40 * time.Minute
-	bufferMaxAge                              = 2 * time.Hour
-	bufferMaxMessages                         = 100
-	maintenanceSchedulerInterval              = 1 * time.Hour
-	profileMaxBioFacts                        = 7
-	profileMaxInterestFacts                   = 8
-	profileMaxSkillFacts                      = 8
-	profileMaxOpinionFacts                    = 7
-	profileMaxRelationshipFacts               = 8
-	profileMaxOtherFacts                      = 7
-	profileMaxTotalFacts                      = 45
-	profileMaxFactWords                       = 28
-	profileMaxSourceNoteIDs                   = 6
-	profileHysteresisExtraFacts               = 1
-	profileHysteresisExtraTotalFacts          = 4
-	profileHysteresisExtraFactWords           = 4
-	profileHysteresisExtraSourceNoteIDs       = 1
-	profileRebuildNoteLimit                   = 2000
-	noteTypeConversation                      = "conversation"
-	noteTypeTopicCluster                      = "topic_cluster"
-	jobPhaseCluster                           = "cluster"
-	jobPhaseProfileMaintenance                = "profile_maintenance"
-	jobStatusRunning                          = "running"
-	jobStatusCompleted                        = "completed"
-	jobStatusFailed                           = "failed"
+	defaultEmbeddingModel               = oa.EmbeddingModelTextEmbedding3Small
+	defaultEmbeddingDimensions          = 1536
+	defaultMemoryModel                  = "gpt-5.4-mini"
+	defaultMemoryReasoning              = shared.ReasoningEffortMedium
+	strictRetrievalDistance             = 0.45
+	fallbackRetrievalDistance           = 0.62
+	retrievalCandidateMultiplier        = 12
+	retrievalFusionK                    = 60
+	retrievalRecencyHalfLife            = 90 * 24 * time.Hour
+	retrievalRecencyFloor               = 0.5
+	lexicalQueryMaxTerms                = 12
+	lexicalMinSharedTerms               = 2
+	weakLexicalWeight                   = 0.5
+	topicRetrievalLimit                 = 3
+	conversationRetrievalLimit          = 5
+	mentionedProfileLimit               = 3
+	extraProfileLimit                   = 2
+	recentUserFallbackNoteLimit         = 3
+	minBufferedContentLength            = 100
+	minClusterInputNotes                = 3
+	bufferInactivityWindow              = 40 * time.Minute
+	bufferMaxAge                        = 2 * time.Hour
+	bufferMaxMessages                   = 100
+	maintenanceSchedulerInterval        = 1 * time.Hour
+	profileMaxBioFacts                  = 7
+	profileMaxInterestFacts             = 8
+	profileMaxSkillFacts                = 8
+	profileMaxOpinionFacts              = 7
+	profileMaxRelationshipFacts         = 8
+	profileMaxOtherFacts                = 7
+	profileMaxTotalFacts                = 45
+	profileMaxFactWords                 = 28
+	profileMaxSourceNoteIDs             = 6
+	profileHysteresisExtraFacts         = 1
+	profileHysteresisExtraTotalFacts    = 4
+	profileHysteresisExtraFactWords     = 4
+	profileHysteresisExtraSourceNoteIDs = 1
+	profileRebuildNoteLimit             = 2000
+	noteTypeConversation                = "conversation"
+	noteTypeTopicCluster                = "topic_cluster"
+	jobPhaseCluster                     = "cluster"
+	jobPhaseProfileMaintenance          = "profile_maintenance"
+	jobStatusRunning                    = "running"
+	jobStatusCompleted                  = "completed"
+	jobStatusFailed                     = "failed"
 )
 
 type ProfileFact struct {
 	Text          string  `json:"text"`
 	SourceNoteIDs []int64 `json:"source_note_ids"`
+	// Pinned marks a fact the user added themselves; it is stored in
+	// profile_fact_overrides rather than with the generated profile.
+	Pinned bool `json:"pinned,omitempty"`
 }
 
 type GuildUserProfile struct {
@@ -161,6 +163,7 @@ var (
 	lifecycleMu             sync.Mutex
 	maintenanceStopCh       chan struct{}
 	maintenanceSweepRunning bool
+	reembedCancel           context.CancelFunc
 )
 
 func Init(db *sql.DB) {
@@ -174,10 +177,29 @@ func Init(db *sql.DB) {
 		log.Printf("memory: model-backed features disabled: %v", err)
 		return
 	}
+	e, err := getEmbedder()
+	if err != nil {
+		log.Printf("memory: model-backed features disabled: %v", err)
+		return
+	}
+	if err := prepareEmbeddingIndex(e); err != nil {
+		log.Printf("memory: model-backed features disabled: embedding index: %v", err)
+		return
+	}
 
 	client = c
 	enabled = true
-	log.Println("memory: v2 initialized")
+	log.Printf("memory: v2 initialized embedder=%s dimensions=%d %s", e.Model(), e.Dimensions(), describeModelPhases())
+
+	reembedCtx, cancel := context.WithCancel(context.Background())
+	lifecycleMu.Lock()
+	reembedCancel = cancel
+	lifecycleMu.Unlock()
+	go func() {
+		if err := reembedNotes(reembedCtx); err != nil && reembedCtx.Err() == nil {
+			log.Printf("memory: re-embedding notes failed: %v", err)
+		}
+	}()
 
 	if err := loadAndRestartBuffers(); err != nil {
 		log.Printf("memory: failed to reload channel buffers: %v", err)
@@ -192,90 +214,18 @@ func Init(db *sql.DB) {
 
 func Shutdown() {
 	stopMaintenanceScheduler()
+	lifecycleMu.Lock()
+	if reembedCancel != nil {
+		reembedCancel()
+		reembedCancel = nil
+	}
+	lifecycleMu.Unlock()
 	stopAllBufferTimers()
 	enabled = false
 	client = nil
 	database = nil
 }
 
-func generateJSON(ctx context.Context, model, systemPrompt, userPrompt, responseType string, reasoning shared.ReasoningEffort, schema shared.ResponseFormatJSONSchemaJSONSchemaParam) (string, error) {
-	if client == nil {
-		return "", fmt.Errorf("responses API client is not initialized")
-	}
-
-	resp, err := client.Responses.New(ctx, responses.ResponseNewParams{
-		Input: responses.ResponseNewParamsInputUnion{
-			OfInputItemList: responses.ResponseInputParam([]responses.ResponseInputItemUnionParam{
-				responses.ResponseInputItemParamOfMessage(
-					responses.ResponseInputMessageContentListParam{
-						responses.ResponseInputContentParamOfInputText(systemPrompt),
-					},
-					responses.EasyInputMessageRoleSystem,
-				),
-				responses.ResponseInputItemParamOfMessage(
-					responses.ResponseInputMessageContentListParam{
-						responses.ResponseInputContentParamOfInputText(userPrompt),
-					},
-					responses.EasyInputMessageRoleUser,
-				),
-			}),
-		},
-		Metadata:   openaiapi.ResponseMetadata(responseType),
-		Model:      responses.ChatModel(model),
-		Reasoning:  shared.ReasoningParam{Effort: reasoning},
-		Truncation: responses.ResponseNewParamsTruncationAuto,
-		Text: responses.ResponseTextConfigParam{
-			Format: responses.ResponseFormatTextConfigUnionParam{
-				OfJSONSchema: &responses.ResponseFormatTextJSONSchemaConfigParam{
-					Name:        schema.Name,
-					Schema:      schema.Schema.(map[string]any),
-					Strict:      schema.Strict,
-					Description: schema.Description,
-				},
-			},
-		},
-	})
-	if err != nil {
-		return "", err
-	}
-
-	content := strings.TrimSpace(resp.OutputText())
-	if content == "" {
-		return "", fmt.Errorf("responses API returned empty content")
-	}
-	return content, nil
-}
-
-func embed(ctx context.Context, text string) ([]float32, error) {
-	if strings.TrimSpace(text) == "" {
-		return nil, fmt.Errorf("embed: empty text")
-	}
-	if client == nil {
-		return nil, fmt.Errorf("embed: OpenAI client is not initialized")
-	}
-
-	resp, err := client.Embeddings.New(ctx, oa.EmbeddingNewParams{
-		Input: oa.EmbeddingNewParamsInputUnion{
-			OfString: oa.String(text),
-		},
-		Model:          embeddingModel,
-		Dimensions:     oa.Int(embeddingDimensions),
-		EncodingFormat: oa.EmbeddingNewParamsEncodingFormatFloat,
-	})
-	if err != nil {
-		return nil, err
-	}
-	if len(resp.Data) == 0 {
-		return nil, fmt.Errorf("embedding API returned no embeddings")
-	}
-
-	values := make([]float32, len(resp.Data[0].Embedding))
-	for i, value := range resp.Data[0].Embedding {
-		values[i] = float32(value)
-	}
-	return values, nil
-}
-
 func serializeFloat32(v []float32) []byte {
 	buf := make([]byte, len(v)*4)
 	for i, f := range v {
@@ -324,6 +274,30 @@ func GetPreferredName(discordID string) string {
 	return name
 }
 
+// SetTimezone stores the IANA zone a user's reminder times are read in. An
+// empty zone clears it.
+func SetTimezone(discordID, username, timezone string) error {
+	if database == nil {
+		return fmt.Errorf("memory system not initialized")
+	}
+
+	_, err := database.Exec(`
+		INSERT INTO users (discord_id, username, timezone) VALUES (?, ?, ?)
+		ON CONFLICT(discord_id) DO UPDATE SET timezone = excluded.timezone
+	`, discordID, username, timezone)
+	return err
+}
+
+// GetTimezone returns the user's stored zone, or "" when none is set.
+func GetTimezone(discordID string) string {
+	if database == nil {
+		return ""
+	}
+	var timezone string
+	_ = database.QueryRow("SELECT timezone FROM users WHERE discord_id = ?", discordID).Scan(&timezone)
+	return timezone
+}
+
 func upsertUser(discordID, username, displayName string) (int64, string, error) {
 	var (
 		id            int64
@@ -539,6 +513,7 @@ func compactProfileFacts(facts []ProfileFact, maxFacts int, remainingTotal *int)
 		out = append(out, ProfileFact{
 			Text:          text,
 			SourceNoteIDs: sourceNoteIDs,
+			Pinned:        fact.Pinned,
 		})
 		if len(out) >= limit {
 			break
@@ -597,6 +572,7 @@ func normalizeProfileFacts(facts []ProfileFact) []ProfileFact {
 		out = append(out, ProfileFact{
 			Text:          text,
 			SourceNoteIDs: dedupeInt64s(fact.SourceNoteIDs),
+			Pinned:        fact.Pinned,
 		})
 	}
 	return out
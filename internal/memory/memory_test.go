@@ -19,6 +19,7 @@ func setupTestDB(t *testing.T) {
 	db.Open(":memory:")
 	database = db.DB
 	enabled = true
+	vecNotesTable = vecNotesMainTable
 	stopMaintenanceScheduler()
 	stopAllBufferTimers()
 	lifecycleMu.Lock()
@@ -79,7 +80,7 @@ func setTimeNow(t *testing.T, fn func() time.Time) {
 }
 
 func testEmbedding() []float32 {
-	vec := make([]float32, embeddingDimensions)
+	vec := make([]float32, defaultEmbeddingDimensions)
 	vec[0] = 1
 	return vec
 }
@@ -126,6 +127,35 @@ func TestUpsertUserAndPreferredName(t *testing.T) {
 	}
 }
 
+func TestSetTimezone(t *testing.T) {
+	setupTestDB(t)
+
+	if got := GetTimezone("discord-1"); got != "" {
+		t.Fatalf("GetTimezone before set = %q, want empty", got)
+	}
+
+	// Setting a zone for an unknown user creates the row.
+	if err := SetTimezone("discord-1", "alice", "Europe/Berlin"); err != nil {
+		t.Fatalf("SetTimezone: %v", err)
+	}
+	if got := GetTimezone("discord-1"); got != "Europe/Berlin" {
+		t.Fatalf("GetTimezone = %q, want %q", got, "Europe/Berlin")
+	}
+
+	if err := SetPreferredName("discord-1", "alice", "Al"); err != nil {
+		t.Fatalf("SetPreferredName: %v", err)
+	}
+	if err := SetTimezone("discord-1", "alice", ""); err != nil {
+		t.Fatalf("SetTimezone clear: %v", err)
+	}
+	if got := GetTimezone("discord-1"); got != "" {
+		t.Fatalf("GetTimezone after clear = %q, want empty", got)
+	}
+	if got := GetPreferredName("discord-1"); got != "Al" {
+		t.Fatalf("preferred name lost when clearing timezone: %q", got)
+	}
+}
+
 func TestInsertNoteAndProfileRoundTrip(t *testing.T) {
 	setupTestDB(t)
 
@@ -0,0 +1,221 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	openaiapi "voltgpt/internal/apis/openai"
+
+	oa "github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/responses"
+	"github.com/openai/openai-go/v3/shared"
+)
+
+// modelPhase is one of memory's model calls. Its value is also the response
+// type sent in the request metadata.
+type modelPhase string
+
+const (
+	phaseNoteGeneration    modelPhase = "note_generation"
+	phaseIncrementalUpdate modelPhase = "profile_cache"
+	phaseClustering        modelPhase = "topic_cluster"
+	phaseFullRebuild       modelPhase = "profile_rebuild"
+)
+
+var modelPhases = []modelPhase{phaseNoteGeneration, phaseIncrementalUpdate, phaseClustering, phaseFullRebuild}
+
+// phaseEnvNames name each phase in its MEMORY_<name>_MODEL and
+// MEMORY_<name>_REASONING settings.
+var phaseEnvNames = map[modelPhase]string{
+	phaseNoteGeneration:    "NOTE",
+	phaseIncrementalUpdate: "PROFILE_UPDATE",
+	phaseClustering:        "CLUSTER",
+	phaseFullRebuild:       "PROFILE_REBUILD",
+}
+
+// jsonModePrompt is the MEMORY_JSON_MODE value that describes the response
+// schema in the prompt instead of asking for strict JSON-schema output.
+const jsonModePrompt = "prompt"
+
+// strictJSONUnsupported is set once the endpoint has rejected a strict
+// JSON-schema request that then worked with the schema in the prompt.
+var strictJSONUnsupported atomic.Bool
+
+// phaseModel is the model and reasoning effort a phase runs with. An empty
+// Reasoning leaves the parameter out, for servers that reject it.
+type phaseModel struct {
+	Model     string
+	Reasoning shared.ReasoningEffort
+}
+
+// modelForPhase reads MEMORY_<phase>_MODEL and MEMORY_<phase>_REASONING,
+// falling back to MEMORY_MODEL and MEMORY_REASONING and then to the defaults.
+// A reasoning effort of "off" leaves the parameter out.
+func modelForPhase(phase modelPhase) phaseModel {
+	name := phaseEnvNames[phase]
+	cfg := phaseModel{
+		Model:     firstEnv(defaultMemoryModel, "MEMORY_"+name+"_MODEL", "MEMORY_MODEL"),
+		Reasoning: shared.ReasoningEffort(strings.ToLower(firstEnv(string(defaultMemoryReasoning), "MEMORY_"+name+"_REASONING", "MEMORY_REASONING"))),
+	}
+	if cfg.Reasoning == "off" {
+		cfg.Reasoning = ""
+	}
+	return cfg
+}
+
+// firstEnv returns the first of keys set in the environment, or fallback.
+func firstEnv(fallback string, keys ...string) string {
+	for _, key := range keys {
+		if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+			return value
+		}
+	}
+	return fallback
+}
+
+// describeModelPhases summarizes the model settings for the startup log.
+func describeModelPhases() string {
+	parts := make([]string, 0, len(modelPhases)+1)
+	for _, phase := range modelPhases {
+		cfg := modelForPhase(phase)
+		reasoning := string(cfg.Reasoning)
+		if reasoning == "" {
+			reasoning = "off"
+		}
+		parts = append(parts, fmt.Sprintf("%s=%s/%s", phase, cfg.Model, reasoning))
+	}
+	if !useStrictJSON() {
+		parts = append(parts, "json_mode="+jsonModePrompt)
+	}
+	return strings.Join(parts, " ")
+}
+
+func useStrictJSON() bool {
+	return !strictJSONUnsupported.Load() && !strings.EqualFold(strings.TrimSpace(os.Getenv("MEMORY_JSON_MODE")), jsonModePrompt)
+}
+
+// generateJSON runs one phase's model call and returns its JSON reply. When
+// the endpoint rejects strict JSON-schema output, the call is retried with
+// the schema described in the prompt, and later calls skip the strict attempt.
+func generateJSON(ctx context.Context, phase modelPhase, systemPrompt, userPrompt string, schema shared.ResponseFormatJSONSchemaJSONSchemaParam) (string, error) {
+	if client == nil {
+		return "", fmt.Errorf("responses API client is not initialized")
+	}
+
+	cfg := modelForPhase(phase)
+	if !useStrictJSON() {
+		return requestJSON(ctx, phase, cfg, systemPrompt, userPrompt, schema, false)
+	}
+
+	content, err := requestJSON(ctx, phase, cfg, systemPrompt, userPrompt, schema, true)
+	if !isStrictJSONRejection(err) {
+		return content, err
+	}
+	log.Printf("memory: %s request with strict JSON output failed, retrying with the schema in the prompt: %v", phase, err)
+	content, err = requestJSON(ctx, phase, cfg, systemPrompt, userPrompt, schema, false)
+	if err == nil && !strictJSONUnsupported.Swap(true) {
+		log.Printf("memory: endpoint does not support strict JSON output; describing schemas in prompts from now on")
+	}
+	return content, err
+}
+
+func requestJSON(ctx context.Context, phase modelPhase, cfg phaseModel, systemPrompt, userPrompt string, schema shared.ResponseFormatJSONSchemaJSONSchemaParam, strict bool) (string, error) {
+	var format responses.ResponseFormatTextConfigUnionParam
+	if strict {
+		format.OfJSONSchema = &responses.ResponseFormatTextJSONSchemaConfigParam{
+			Name:        schema.Name,
+			Schema:      schema.Schema.(map[string]any),
+			Strict:      schema.Strict,
+			Description: schema.Description,
+		}
+	} else {
+		encoded, err := json.MarshalIndent(schema.Schema, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		systemPrompt += "\n\nReply with only a JSON object matching this JSON schema, with no other text:\n" + string(encoded)
+	}
+
+	resp, err := client.Responses.New(ctx, responses.ResponseNewParams{
+		Input: responses.ResponseNewParamsInputUnion{
+			OfInputItemList: responses.ResponseInputParam([]responses.ResponseInputItemUnionParam{
+				responses.ResponseInputItemParamOfMessage(
+					responses.ResponseInputMessageContentListParam{
+						responses.ResponseInputContentParamOfInputText(systemPrompt),
+					},
+					responses.EasyInputMessageRoleSystem,
+				),
+				responses.ResponseInputItemParamOfMessage(
+					responses.ResponseInputMessageContentListParam{
+						responses.ResponseInputContentParamOfInputText(userPrompt),
+					},
+					responses.EasyInputMessageRoleUser,
+				),
+			}),
+		},
+		Metadata:   openaiapi.ResponseMetadata(string(phase)),
+		Model:      responses.ChatModel(cfg.Model),
+		Reasoning:  shared.ReasoningParam{Effort: cfg.Reasoning},
+		Truncation: responses.ResponseNewParamsTruncationAuto,
+		Text:       responses.ResponseTextConfigParam{Format: format},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	content := strings.TrimSpace(resp.OutputText())
+	if content == "" {
+		return "", fmt.Errorf("responses API returned empty content")
+	}
+	if strict {
+		return content, nil
+	}
+	return extractJSONObject(content)
+}
+
+// strictJSONErrorHints are what servers without JSON-schema support name in
+// their error when refusing a strict request.
+var strictJSONErrorHints = []string{"response_format", "text.format", "json_schema", "structured output"}
+
+// isStrictJSONRejection reports whether err is the endpoint refusing strict
+// JSON-schema output: a 400 or 422 whose parameter or message points at the
+// response format. Other bad requests, like an over-long prompt, are not.
+func isStrictJSONRejection(err error) bool {
+	var apiErr *oa.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	if apiErr.StatusCode != http.StatusBadRequest && apiErr.StatusCode != http.StatusUnprocessableEntity {
+		return false
+	}
+	detail := strings.ToLower(apiErr.Param + " " + apiErr.Message)
+	return slices.ContainsFunc(strictJSONErrorHints, func(hint string) bool {
+		return strings.Contains(detail, hint)
+	})
+}
+
+// extractJSONObject pulls the JSON object out of a free-form reply, skipping
+// the reasoning and code fences local models often wrap it in.
+func extractJSONObject(text string) (string, error) {
+	if end := strings.LastIndex(text, "</think>"); end >= 0 {
+		text = text[end+len("</think>"):]
+	}
+	start := strings.Index(text, "{")
+	end := strings.LastIndex(text, "}")
+	if start < 0 || end < start {
+		return "", fmt.Errorf("model reply contains no JSON object")
+	}
+	text = text[start : end+1]
+	if !json.Valid([]byte(text)) {
+		return "", fmt.Errorf("model reply is not valid JSON")
+	}
+	return text, nil
+}
@@ -0,0 +1,139 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	oa "github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/option"
+	"github.com/openai/openai-go/v3/shared"
+)
+
+func TestModelForPhase(t *testing.T) {
+	t.Setenv("MEMORY_MODEL", "")
+	t.Setenv("MEMORY_REASONING", "")
+	t.Setenv("MEMORY_CLUSTER_MODEL", "")
+	t.Setenv("MEMORY_CLUSTER_REASONING", "")
+	if got := modelForPhase(phaseClustering); got.Model != defaultMemoryModel || got.Reasoning != defaultMemoryReasoning {
+		t.Errorf("default = %+v", got)
+	}
+
+	t.Setenv("MEMORY_MODEL", "qwen3")
+	t.Setenv("MEMORY_REASONING", "OFF")
+	t.Setenv("MEMORY_CLUSTER_MODEL", "qwen3-large")
+	t.Setenv("MEMORY_CLUSTER_REASONING", "high")
+	if got := modelForPhase(phaseClustering); got.Model != "qwen3-large" || got.Reasoning != shared.ReasoningEffortHigh {
+		t.Errorf("cluster = %+v, want its own settings", got)
+	}
+	if got := modelForPhase(phaseNoteGeneration); got.Model != "qwen3" || got.Reasoning != "" {
+		t.Errorf("note generation = %+v, want the shared model without reasoning", got)
+	}
+}
+
+func TestExtractJSONObject(t *testing.T) {
+	got, err := extractJSONObject("<think>The user wants {json}.</think>\n```json\n{\"title\": \"a\"}\n```")
+	if err != nil || got != `{"title": "a"}` {
+		t.Errorf("extractJSONObject = %q, %v", got, err)
+	}
+	if _, err := extractJSONObject("no json here"); err == nil {
+		t.Error("extractJSONObject accepted a reply without JSON")
+	}
+	if _, err := extractJSONObject("{not json}"); err == nil {
+		t.Error("extractJSONObject accepted invalid JSON")
+	}
+}
+
+func TestIsStrictJSONRejection(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"format param", &oa.Error{StatusCode: http.StatusBadRequest, Param: "text.format"}, true},
+		{"format message", &oa.Error{StatusCode: http.StatusUnprocessableEntity, Message: "json_schema is not supported"}, true},
+		{"other bad request", &oa.Error{StatusCode: http.StatusBadRequest, Param: "input", Message: "context length exceeded"}, false},
+		{"server error", &oa.Error{StatusCode: http.StatusInternalServerError, Message: "response_format failed"}, false},
+		{"not an API error", errors.New("response_format"), false},
+	}
+	for _, tt := range tests {
+		if got := isStrictJSONRejection(tt.err); got != tt.want {
+			t.Errorf("%s: isStrictJSONRejection = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateJSONFallsBackWithoutStrictSchemas(t *testing.T) {
+	var strictRequests, promptRequests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Text struct {
+				Format struct {
+					Type string `json:"type"`
+				} `json:"format"`
+			} `json:"text"`
+			Input     json.RawMessage `json:"input"`
+			Reasoning json.RawMessage `json:"reasoning"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if body.Text.Format.Type == "json_schema" {
+			strictRequests.Add(1)
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": {"message": "response_format json_schema is not supported", "type": "invalid_request_error"}}`))
+			return
+		}
+		promptRequests.Add(1)
+		if !strings.Contains(string(body.Input), "JSON schema") {
+			t.Error("prompt mode request does not describe the schema")
+		}
+		if len(body.Reasoning) > 0 && string(body.Reasoning) != "{}" {
+			t.Errorf("reasoning = %s, want it left out", body.Reasoning)
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"id":         "resp_1",
+			"object":     "response",
+			"created_at": 0,
+			"status":     "completed",
+			"model":      "local",
+			"output": []map[string]any{{
+				"type":    "message",
+				"id":      "msg_1",
+				"role":    "assistant",
+				"status":  "completed",
+				"content": []map[string]any{{"type": "output_text", "text": "```json\n{\"title\": \"Local\"}\n```", "annotations": []any{}}},
+			}},
+		})
+	}))
+	defer srv.Close()
+
+	t.Setenv("MEMORY_JSON_MODE", "")
+	t.Setenv("MEMORY_REASONING", "off")
+	previousClient := client
+	c := oa.NewClient(option.WithBaseURL(srv.URL+"/v1/"), option.WithAPIKey("local"), option.WithMaxRetries(0))
+	client = &c
+	t.Cleanup(func() {
+		client = previousClient
+		strictJSONUnsupported.Store(false)
+	})
+
+	for i := 0; i < 2; i++ {
+		got, err := generateJSON(context.Background(), phaseNoteGeneration, "Summarize.", "hello", conversationNoteResponseSchema)
+		if err != nil {
+			t.Fatalf("generateJSON: %v", err)
+		}
+		if got != `{"title": "Local"}` {
+			t.Errorf("generateJSON = %q", got)
+		}
+	}
+	if strictRequests.Load() != 1 || promptRequests.Load() != 2 {
+		t.Errorf("requests strict=%d prompt=%d, want one strict attempt then prompts", strictRequests.Load(), promptRequests.Load())
+	}
+}
@@ -15,6 +15,9 @@ func insertNote(note InteractionNote, participantUserIDs []int64, embedding []fl
 		return 0, fmt.Errorf("memory system not initialized")
 	}
 
+	vecTableMu.RLock()
+	defer vecTableMu.RUnlock()
+
 	tx, err := database.Begin()
 	if err != nil {
 		return 0, err
@@ -48,10 +51,7 @@ func insertNote(note InteractionNote, participantUserIDs []int64, embedding []fl
 		}
 	}
 
-	if _, err := tx.Exec(
-		"INSERT INTO vec_notes (note_id, embedding) VALUES (?, ?)",
-		noteID, serializeFloat32(embedding),
-	); err != nil {
+	if err := insertNoteVector(tx, noteID, embedding); err != nil {
 		return 0, err
 	}
 
@@ -129,13 +129,16 @@ func listNoteParticipants(noteID int64) ([]int64, error) {
 }
 
 func deleteNoteAndVector(noteID int64) error {
+	vecTableMu.RLock()
+	defer vecTableMu.RUnlock()
+
 	tx, err := database.Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	if _, err := tx.Exec("DELETE FROM vec_notes WHERE note_id = ?", noteID); err != nil {
+	if err := deleteNoteVector(tx, noteID); err != nil {
 		return err
 	}
 	if _, err := tx.Exec("DELETE FROM interaction_notes WHERE id = ?", noteID); err != nil {
@@ -0,0 +1,98 @@
+package memory
+
+import (
+	"fmt"
+	"log"
+)
+
+// SetMemoryOptOut records whether the user has opted out of memory. An
+// opted-out user's messages are no longer buffered or summarized, their
+// profile is not used or rebuilt, and notes they took part in are left out
+// of prompts and topic clusters. Nothing already stored is deleted.
+func SetMemoryOptOut(discordID, username string, optOut bool) error {
+	if database == nil {
+		return fmt.Errorf("memory system not initialized")
+	}
+
+	_, err := database.Exec(`
+		INSERT INTO users (discord_id, username, memory_opt_out) VALUES (?, ?, ?)
+		ON CONFLICT(discord_id) DO UPDATE SET memory_opt_out = excluded.memory_opt_out
+	`, discordID, username, boolToInt(optOut))
+	if err != nil {
+		return err
+	}
+	if optOut {
+		purgeBufferedUserMessages("", discordID)
+	}
+	log.Printf("memory: opt_out discord_id=%s opted_out=%t", discordID, optOut)
+	return nil
+}
+
+// IsMemoryOptedOut reports whether the user has opted out of memory.
+func IsMemoryOptedOut(discordID string) bool {
+	if database == nil {
+		return false
+	}
+	var optOut bool
+	_ = database.QueryRow("SELECT memory_opt_out FROM users WHERE discord_id = ?", discordID).Scan(&optOut)
+	return optOut
+}
+
+func optedOutUserIDs() (map[int64]struct{}, error) {
+	rows, err := database.Query("SELECT id FROM users WHERE memory_opt_out = 1")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[int64]struct{})
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids[id] = struct{}{}
+	}
+	return ids, rows.Err()
+}
+
+// withoutOptedOutMessages drops buffered messages from opted-out users, so
+// they are not summarized into anyone's notes.
+func withoutOptedOutMessages(msgs []bufMsg) []bufMsg {
+	optedOut := make(map[string]bool)
+	out := make([]bufMsg, 0, len(msgs))
+	for _, msg := range msgs {
+		skip, ok := optedOut[msg.DiscordID]
+		if !ok {
+			skip = IsMemoryOptedOut(msg.DiscordID)
+			optedOut[msg.DiscordID] = skip
+		}
+		if !skip {
+			out = append(out, msg)
+		}
+	}
+	return out
+}
+
+// withoutOptedOutNotes drops notes that an opted-out user took part in.
+func withoutOptedOutNotes(notes []InteractionNote, optedOut map[int64]struct{}) []InteractionNote {
+	if len(optedOut) == 0 {
+		return notes
+	}
+	out := make([]InteractionNote, 0, len(notes))
+	for _, note := range notes {
+		if !hasOptedOutParticipant(note, optedOut) {
+			out = append(out, note)
+		}
+	}
+	return out
+}
+
+func hasOptedOutParticipant(note InteractionNote, optedOut map[int64]struct{}) bool {
+	for _, userID := range note.ParticipantUserIDs {
+		if _, ok := optedOut[userID]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package memory
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestOptedOutUserIsNotBuffered(t *testing.T) {
+	setupTestDB(t)
+
+	BufferMessage("channel-opt", "guild-opt", "discord-in", "inny", "", "a message worth remembering", "m1")
+	BufferMessage("channel-opt", "guild-opt", "discord-out", "outy", "", "a message to leave alone", "m2")
+	if err := SetMemoryOptOut("discord-out", "outy", true); err != nil {
+		t.Fatalf("SetMemoryOptOut: %v", err)
+	}
+	BufferMessage("channel-opt", "guild-opt", "discord-out", "outy", "", "another message to leave alone", "m3")
+
+	buffersMu.Lock()
+	var ids []string
+	for _, msg := range buffers["channel-opt"].Messages {
+		ids = append(ids, msg.MessageID)
+	}
+	buffersMu.Unlock()
+	if strings.Join(ids, ",") != "m1" {
+		t.Errorf("buffered messages = %v, want only m1", ids)
+	}
+
+	if !IsMemoryOptedOut("discord-out") || IsMemoryOptedOut("discord-in") {
+		t.Error("IsMemoryOptedOut does not match the stored choices")
+	}
+	if err := SetMemoryOptOut("discord-out", "outy", false); err != nil || IsMemoryOptedOut("discord-out") {
+		t.Errorf("opting back in = %v, still opted out: %t", err, IsMemoryOptedOut("discord-out"))
+	}
+}
+
+func TestOptedOutUserIsLeftOutOfNotesAndPrompts(t *testing.T) {
+	setupTestDB(t)
+
+	setEmbedText(t, func(context.Context, string) ([]float32, error) {
+		return testEmbedding(), nil
+	})
+	var summarized []bufMsg
+	setConversationNoteGenerator(t, func(_ context.Context, _, _ string, msgs []bufMsg) (generatedConversationNote, error) {
+		summarized = msgs
+		return generatedConversationNote{Title: "Garden chat", Summary: "Ann talked about tomatoes."}, nil
+	})
+	// Giving Ann a profile keeps BuildPromptContext from queueing a
+	// background rebuild that would outlive the test database.
+	setIncrementalProfileUpdater(t, func(_ context.Context, current GuildUserProfile, _ InteractionNote, _ userIdentity) (profileUpdateResult, error) {
+		current.Interests = []ProfileFact{{Text: "Gardening."}}
+		return profileUpdateResult{Profile: current}, nil
+	})
+
+	bobID, _, err := upsertUser("discord-bob", "bob", "Bob")
+	if err != nil {
+		t.Fatalf("upsertUser: %v", err)
+	}
+	if _, err := insertNote(InteractionNote{
+		GuildID:   "guild-1",
+		ChannelID: "channel-1",
+		NoteType:  noteTypeConversation,
+		Title:     "Tomato talk",
+		Summary:   "Bob shared his tomato secrets.",
+		NoteDate:  "2026-02-25",
+	}, []int64{bobID}, testEmbedding()); err != nil {
+		t.Fatalf("insertNote: %v", err)
+	}
+	bobProfile := emptyProfile("guild-1", bobID)
+	bobProfile.Interests = []ProfileFact{{Text: "Grows tomatoes."}}
+	if err := writeGuildUserProfile(bobProfile); err != nil {
+		t.Fatalf("writeGuildUserProfile: %v", err)
+	}
+	if err := SetMemoryOptOut("discord-bob", "bob", true); err != nil {
+		t.Fatalf("SetMemoryOptOut: %v", err)
+	}
+
+	// Bob's messages were buffered before he opted out.
+	long := strings.Repeat("tomatoes need sun and water every day ", 4)
+	err = flushBufferData(&channelBuffer{
+		ChannelID: "channel-1",
+		GuildID:   "guild-1",
+		StartedAt: contextDeadlineTime(),
+		UpdatedAt: contextDeadlineTime(),
+		Messages: []bufMsg{
+			{DiscordID: "discord-ann", Username: "ann", Text: long, MessageID: "m1"},
+			{DiscordID: "discord-bob", Username: "bob", Text: long, MessageID: "m2"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("flushBufferData: %v", err)
+	}
+	for _, msg := range summarized {
+		if msg.DiscordID == "discord-bob" {
+			t.Error("an opted-out user's message was summarized")
+		}
+	}
+	notes, err := GetRecentConversationNotesForUser("guild-1", "discord-ann", 5)
+	if err != nil || len(notes) != 1 || len(notes[0].ParticipantUserIDs) != 1 {
+		t.Fatalf("Ann's notes = %+v, %v; want one note without Bob", notes, err)
+	}
+
+	got := BuildPromptContext(RetrieveRequest{
+		GuildID:           "guild-1",
+		ChannelID:         "channel-1",
+		Query:             "tomatoes",
+		ConversationUsers: map[string]string{"discord-ann": "ann", "discord-bob": "bob"},
+	})
+	if strings.Contains(got, "Grows tomatoes.") || strings.Contains(got, "tomato secrets") {
+		t.Errorf("prompt context used an opted-out user's memory: %s", got)
+	}
+	if !strings.Contains(got, "Ann talked about tomatoes.") {
+		t.Errorf("prompt context dropped other users' notes: %s", got)
+	}
+}
+
+func TestExportUserData(t *testing.T) {
+	setupTestDB(t)
+
+	userID, _, err := upsertUser("discord-exp", "exporter", "Exporter")
+	if err != nil {
+		t.Fatalf("upsertUser: %v", err)
+	}
+	if _, err := insertNote(InteractionNote{
+		GuildID:  "guild-1",
+		NoteType: noteTypeConversation,
+		Title:    "Exported",
+		Summary:  "Exporter asked for their data.",
+		NoteDate: "2026-02-25",
+	}, []int64{userID}, testEmbedding()); err != nil {
+		t.Fatalf("insertNote: %v", err)
+	}
+	profile := emptyProfile("guild-1", userID)
+	profile.Bio = []ProfileFact{{Text: "Values privacy."}, {Text: "Lives on a boat."}}
+	if err := writeGuildUserProfile(profile); err != nil {
+		t.Fatalf("writeGuildUserProfile: %v", err)
+	}
+	if _, err := PinProfileFact("guild-2", "discord-exp", "exporter", "Exporter", "other", "Likes tea."); err != nil {
+		t.Fatalf("PinProfileFact: %v", err)
+	}
+	if _, err := DisputeProfileFact("guild-1", "discord-exp", FactID("Lives on a boat."), ""); err != nil {
+		t.Fatalf("DisputeProfileFact: %v", err)
+	}
+
+	export, err := ExportUserData("discord-exp")
+	if err != nil {
+		t.Fatalf("ExportUserData: %v", err)
+	}
+	if len(export.Notes) != 1 || export.Notes[0].Title != "Exported" {
+		t.Errorf("notes = %+v, want the one note", export.Notes)
+	}
+	if len(export.Profiles) != 2 {
+		t.Fatalf("profiles = %+v, want one per guild", export.Profiles)
+	}
+	if p := export.Profiles[0]; len(p.Bio) != 1 || len(p.Disputed) != 1 {
+		t.Errorf("guild-1 profile = %+v, want the kept and the disputed fact", p)
+	}
+	if p := export.Profiles[1]; len(p.Other) != 1 || !p.Other[0].Pinned {
+		t.Errorf("guild-2 profile = %+v, want the pinned fact", p)
+	}
+
+	empty, err := ExportUserData("discord-unknown")
+	if err != nil || empty.DiscordID != "discord-unknown" || len(empty.Notes) != 0 {
+		t.Errorf("ExportUserData(unknown) = %+v, %v; want an empty export", empty, err)
+	}
+}
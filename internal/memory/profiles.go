@@ -91,7 +91,13 @@ func getGuildUserProfileByUserID(guildID string, userID int64) (*GuildUserProfil
 		&isDirty, &profile.UpdatedAt, &lastRebuild,
 	)
 	if err == sql.ErrNoRows {
-		return nil, nil
+		// Facts the user pinned are shown even before a profile is generated.
+		corrections, err := loadProfileCorrections(guildID, userID)
+		if err != nil || len(corrections.Pinned) == 0 {
+			return nil, err
+		}
+		applyProfileCorrections(&profile, corrections)
+		return &profile, nil
 	}
 	if err != nil {
 		return nil, err
@@ -119,10 +125,24 @@ func getGuildUserProfileByUserID(guildID string, userID int64) (*GuildUserProfil
 	if lastRebuild.Valid {
 		profile.LastFullRebuildAt = lastRebuild.String
 	}
+
+	corrections, err := loadProfileCorrections(guildID, userID)
+	if err != nil {
+		return nil, err
+	}
+	applyProfileCorrections(&profile, corrections)
 	return &profile, nil
 }
 
 func writeGuildUserProfile(profile GuildUserProfile) error {
+	// Pinned facts live in profile_fact_overrides, and deleted or disputed
+	// ones must not come back however the profile was produced.
+	corrections, err := loadProfileCorrections(profile.GuildID, profile.UserID)
+	if err != nil {
+		return err
+	}
+	profile = stripProfileCorrections(profile, corrections)
+
 	profile, compaction := compactProfileWithStats(profile)
 	if compaction.changed() {
 		log.Printf(
@@ -142,7 +162,7 @@ func writeGuildUserProfile(profile GuildUserProfile) error {
 		lastFullRebuild = profile.LastFullRebuildAt
 	}
 
-	_, err := database.Exec(`
+	_, err = database.Exec(`
 		INSERT INTO guild_user_profiles (
 			guild_id, user_id, bio, interests, skills, opinions, relationships, other, is_dirty, updated_at, last_full_rebuild_at
 		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, ?)
@@ -282,6 +302,16 @@ func listDirtyProfileUserIDs(guildID string) ([]int64, error) {
 }
 
 func RenderProfileMarkdown(profile *GuildUserProfile, fallbackName string) string {
+	return renderProfileMarkdown(profile, fallbackName, false)
+}
+
+// RenderProfileMarkdownWithIDs renders the profile for its owner, with the
+// FactID of each fact so they can delete, dispute or unpin it.
+func RenderProfileMarkdownWithIDs(profile *GuildUserProfile, fallbackName string) string {
+	return renderProfileMarkdown(profile, fallbackName, true)
+}
+
+func renderProfileMarkdown(profile *GuildUserProfile, fallbackName string, withIDs bool) string {
 	if profile == nil {
 		return ""
 	}
@@ -313,23 +343,30 @@ func RenderProfileMarkdown(profile *GuildUserProfile, fallbackName string) strin
 		sb.WriteString("_Profile is marked dirty; recent notes may be fresher until maintenance rebuilds it._\n\n")
 	}
 
-	renderProfileSectionMarkdown(&sb, "Bio", profile.Bio, noteRefs)
-	renderProfileSectionMarkdown(&sb, "Interests", profile.Interests, noteRefs)
-	renderProfileSectionMarkdown(&sb, "Skills", profile.Skills, noteRefs)
-	renderProfileSectionMarkdown(&sb, "Opinions", profile.Opinions, noteRefs)
-	renderProfileSectionMarkdown(&sb, "Relationships", profile.Relationships, noteRefs)
-	renderProfileSectionMarkdown(&sb, "Other", profile.Other, noteRefs)
+	renderProfileSectionMarkdown(&sb, "Bio", profile.Bio, noteRefs, withIDs)
+	renderProfileSectionMarkdown(&sb, "Interests", profile.Interests, noteRefs, withIDs)
+	renderProfileSectionMarkdown(&sb, "Skills", profile.Skills, noteRefs, withIDs)
+	renderProfileSectionMarkdown(&sb, "Opinions", profile.Opinions, noteRefs, withIDs)
+	renderProfileSectionMarkdown(&sb, "Relationships", profile.Relationships, noteRefs, withIDs)
+	renderProfileSectionMarkdown(&sb, "Other", profile.Other, noteRefs, withIDs)
 
 	return strings.TrimSpace(sb.String())
 }
 
-func renderProfileSectionMarkdown(sb *strings.Builder, title string, facts []ProfileFact, noteRefs map[int64]InteractionNote) {
+func renderProfileSectionMarkdown(sb *strings.Builder, title string, facts []ProfileFact, noteRefs map[int64]InteractionNote, withIDs bool) {
 	if len(facts) == 0 {
 		return
 	}
 	sb.WriteString("**" + title + "**\n")
 	for _, fact := range facts {
-		sb.WriteString("- " + fact.Text)
+		sb.WriteString("- ")
+		if withIDs {
+			sb.WriteString("`" + FactID(fact.Text) + "` ")
+		}
+		sb.WriteString(fact.Text)
+		if fact.Pinned {
+			sb.WriteString(" _(pinned)_")
+		}
 		if citation := renderCitation(noteRefs, fact.SourceNoteIDs); citation != "" {
 			sb.WriteString(" [" + citation + "]")
 		}
@@ -379,6 +416,27 @@ func profileCoverageRule() string {
 - Do not collapse several supported details into one generic fact just to be sparse.`
 }
 
+func profileCorrectionsRule() string {
+	return `- The user's own corrections are authoritative and must be preserved.
+- Pinned facts were written by the user and are always kept in the profile as-is: never contradict them and do not repeat them in your output.
+- Never reassert a disputed fact or anything implying it, even if a note supports it.
+- Never re-add a deleted fact.`
+}
+
+// profileCorrectionsPrompt returns the user's corrections for a profile
+// prompt, or "" when they have none.
+func profileCorrectionsPrompt(guildID string, userID int64) string {
+	corrections, err := loadProfileCorrections(guildID, userID)
+	if err != nil {
+		log.Printf("memory: failed to load profile corrections for user %d: %v", userID, err)
+		return ""
+	}
+	if corrections.empty() {
+		return ""
+	}
+	return "\n\nUser corrections JSON:\n" + jsonString(corrections)
+}
+
 func incrementalProfileSystemPrompt() string {
 	return `You update one guild-scoped user profile using a single new conversation note.` + profileSectionGuidance + `
 
@@ -393,6 +451,7 @@ Rules:
 ` + profileCoverageRule() + `
 ` + profileCompactnessRule("Keep the profile") + `
 ` + profileFactLengthRule() + `
+` + profileCorrectionsRule() + `
 - If a new note is weak, one-off, or ambiguous, prefer not to promote it into the profile.
 - If the note is too ambiguous or the updated profile would exceed those limits, set mark_dirty=true and leave the sections unchanged.
 - Do not invent facts.`
@@ -412,6 +471,7 @@ Rules:
 ` + profileCoverageRule() + `
 ` + profileCompactnessRule("Keep the rebuilt profile") + `
 ` + profileFactLengthRule() + `
+` + profileCorrectionsRule() + `
 - Prefer recurring patterns and stable traits over isolated anecdotes.
 - If the notes are empty, return empty arrays.
 - Do not infer facts that are not supported by the notes.`
@@ -419,14 +479,15 @@ Rules:
 
 func incrementalProfileUpdateOpenAI(ctx context.Context, current GuildUserProfile, note InteractionNote, target userIdentity) (profileUpdateResult, error) {
 	prompt := fmt.Sprintf(
-		"Target user: %s (%s)\nCurrent profile JSON:\n%s\n\nConversation note JSON:\n%s",
+		"Target user: %s (%s)\nCurrent profile JSON:\n%s\n\nConversation note JSON:\n%s%s",
 		target.EffectiveName(),
 		target.DiscordID,
-		jsonString(current),
+		jsonString(stripProfileCorrections(current, profileCorrections{})),
 		jsonString(note),
+		profileCorrectionsPrompt(current.GuildID, current.UserID),
 	)
 
-	responseText, err := generateJSON(ctx, incrementalUpdateModel, incrementalProfileSystemPrompt(), prompt, "profile_cache", incrementalUpdateReasoning, profileResponseSchema)
+	responseText, err := generateJSON(ctx, phaseIncrementalUpdate, incrementalProfileSystemPrompt(), prompt, profileResponseSchema)
 	if err != nil {
 		return profileUpdateResult{}, err
 	}
@@ -459,13 +520,14 @@ func rebuildGuildProfileOpenAI(ctx context.Context, guildID string, target userI
 	}
 
 	prompt := fmt.Sprintf(
-		"Target user: %s (%s)\nConversation notes JSON:\n%s",
+		"Target user: %s (%s)\nConversation notes JSON:\n%s%s",
 		target.EffectiveName(),
 		target.DiscordID,
 		jsonString(notes),
+		profileCorrectionsPrompt(guildID, target.UserID),
 	)
 
-	responseText, err := generateJSON(ctx, fullRebuildModel, rebuildProfileSystemPrompt(), prompt, "profile_rebuild", fullRebuildReasoning, profileResponseSchema)
+	responseText, err := generateJSON(ctx, phaseFullRebuild, rebuildProfileSystemPrompt(), prompt, profileResponseSchema)
 	if err != nil {
 		return GuildUserProfile{}, err
 	}
@@ -0,0 +1,341 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	vecNotesMainTable = "vec_notes"
+	vecNotesNextTable = "vec_notes_next"
+	reembedBatchSize  = 100
+)
+
+var (
+	// vecTableMu guards vecNotesTable, the table note vectors are written to
+	// and searched. It is vec_notes except while notes are re-embedded into
+	// vec_notes_next for a new embedder. Holders of the read lock may keep it
+	// for a whole transaction; swapping the tables takes the write lock.
+	vecTableMu    sync.RWMutex
+	vecNotesTable = vecNotesMainTable
+
+	reembedMu      sync.Mutex
+	reembedRunning bool
+)
+
+var vecDimensionsPattern = regexp.MustCompile(`float\[(\d+)\]`)
+
+// embeddingState records which embedder produced the vectors in vec_notes and,
+// during a migration, which one vec_notes_next is being filled for.
+type embeddingState struct {
+	Model            string
+	Dimensions       int
+	TargetModel      string
+	TargetDimensions int
+}
+
+// loadEmbeddingState reads the embedding state. Databases from before the
+// state was recorded only ever held the default model's vectors.
+func loadEmbeddingState() (embeddingState, error) {
+	var state embeddingState
+	err := database.QueryRow(`
+		SELECT model, dimensions, target_model, target_dimensions
+		FROM memory_embedding_state
+		WHERE id = 1
+	`).Scan(&state.Model, &state.Dimensions, &state.TargetModel, &state.TargetDimensions)
+	if err != sql.ErrNoRows {
+		return state, err
+	}
+
+	dimensions, err := vecTableDimensions(vecNotesMainTable)
+	if err != nil {
+		return state, err
+	}
+	state = embeddingState{Model: defaultEmbeddingModel, Dimensions: dimensions}
+	_, err = database.Exec(`
+		INSERT INTO memory_embedding_state (id, model, dimensions) VALUES (1, ?, ?)
+	`, state.Model, state.Dimensions)
+	return state, err
+}
+
+// vecTableDimensions reads the vector size a vec0 table was created with.
+func vecTableDimensions(table string) (int, error) {
+	var sqlText string
+	if err := database.QueryRow("SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&sqlText); err != nil {
+		return 0, fmt.Errorf("read %s schema: %w", table, err)
+	}
+	match := vecDimensionsPattern.FindStringSubmatch(sqlText)
+	if match == nil {
+		return 0, fmt.Errorf("%s has no float vector column", table)
+	}
+	return strconv.Atoi(match[1])
+}
+
+func createVecTable(exec interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}, table string, dimensions int) error {
+	_, err := exec.Exec(fmt.Sprintf(
+		"CREATE VIRTUAL TABLE %s USING vec0(note_id INTEGER PRIMARY KEY, embedding float[%d] distance_metric=cosine)",
+		table, dimensions,
+	))
+	return err
+}
+
+// prepareEmbeddingIndex points note search at the vectors made by e. When e
+// differs from the embedder behind vec_notes, new vectors go to
+// vec_notes_next until reembedNotes has filled it in and swapped it in.
+func prepareEmbeddingIndex(e Embedder) error {
+	state, err := loadEmbeddingState()
+	if err != nil {
+		return err
+	}
+	model, dimensions := e.Model(), e.Dimensions()
+
+	vecTableMu.Lock()
+	defer vecTableMu.Unlock()
+
+	switch {
+	case state.Model == model && state.Dimensions == dimensions:
+		vecNotesTable = vecNotesMainTable
+		if state.TargetModel == "" {
+			return nil
+		}
+		// The embedder was switched back before a migration finished. Notes
+		// written meanwhile only have vectors in vec_notes_next, so
+		// reembedNotes fills them in again.
+		log.Printf("memory: abandoning re-embedding for %s; back on %s", state.TargetModel, model)
+		if _, err := database.Exec("DROP TABLE IF EXISTS " + vecNotesNextTable); err != nil {
+			return err
+		}
+		_, err := database.Exec("UPDATE memory_embedding_state SET target_model = '', target_dimensions = 0 WHERE id = 1")
+		return err
+
+	case state.TargetModel == model && state.TargetDimensions == dimensions:
+		vecNotesTable = vecNotesNextTable
+		return nil
+	}
+
+	log.Printf("memory: embedder changed from %s (%d dimensions) to %s (%d dimensions); re-embedding notes in the background",
+		state.Model, state.Dimensions, model, dimensions)
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DROP TABLE IF EXISTS " + vecNotesNextTable); err != nil {
+		return err
+	}
+	if err := createVecTable(tx, vecNotesNextTable, dimensions); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+		UPDATE memory_embedding_state SET target_model = ?, target_dimensions = ? WHERE id = 1
+	`, model, dimensions); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	vecNotesTable = vecNotesNextTable
+	return nil
+}
+
+// insertNoteVector stores a note's vector in the table it is searched in. The
+// caller must hold vecTableMu for reading.
+func insertNoteVector(tx *sql.Tx, noteID int64, embedding []float32) error {
+	_, err := tx.Exec(
+		"INSERT INTO "+vecNotesTable+" (note_id, embedding) VALUES (?, ?)",
+		noteID, serializeFloat32(embedding),
+	)
+	return err
+}
+
+// deleteNoteVector removes a note's vectors, including one made for a
+// migration in progress. The caller must hold vecTableMu for reading.
+func deleteNoteVector(tx *sql.Tx, noteID int64) error {
+	if _, err := tx.Exec("DELETE FROM "+vecNotesMainTable+" WHERE note_id = ?", noteID); err != nil {
+		return err
+	}
+	if vecNotesTable == vecNotesMainTable {
+		return nil
+	}
+	_, err := tx.Exec("DELETE FROM "+vecNotesTable+" WHERE note_id = ?", noteID)
+	return err
+}
+
+// reembedNotes embeds every note without a vector in the searched table and,
+// when that table is vec_notes_next, swaps it in as vec_notes once every note
+// has a vector. Notes that fail to embed are retried on the next run.
+func reembedNotes(ctx context.Context) error {
+	reembedMu.Lock()
+	if reembedRunning {
+		reembedMu.Unlock()
+		return nil
+	}
+	reembedRunning = true
+	reembedMu.Unlock()
+	defer func() {
+		reembedMu.Lock()
+		reembedRunning = false
+		reembedMu.Unlock()
+	}()
+
+	if database == nil {
+		return fmt.Errorf("memory system not initialized")
+	}
+
+	startedAt := time.Now()
+	var embedded, failed int
+	var afterID int64
+	for {
+		batch, err := notesWithoutVectors(afterID)
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			break
+		}
+		for _, note := range batch {
+			afterID = note.ID
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			embedding, err := embedText(ctx, note.Title+"\n"+note.Summary)
+			if err == nil {
+				err = storeReembeddedVector(note.ID, embedding)
+			}
+			if err != nil {
+				failed++
+				log.Printf("memory: re-embedding note %d failed: %v", note.ID, err)
+				continue
+			}
+			embedded++
+		}
+	}
+
+	if embedded > 0 || failed > 0 {
+		log.Printf("memory: reembed notes=%d failed=%d duration_ms=%d", embedded, failed, time.Since(startedAt).Milliseconds())
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d notes could not be re-embedded", failed)
+	}
+	return swapInReembeddedVectors()
+}
+
+// notesWithoutVectors returns the next batch of notes after afterID that
+// have no vector in the searched table.
+func notesWithoutVectors(afterID int64) ([]InteractionNote, error) {
+	vecTableMu.RLock()
+	defer vecTableMu.RUnlock()
+
+	rows, err := database.Query(`
+		SELECT id, title, summary
+		FROM interaction_notes
+		WHERE id > ?
+		  AND id NOT IN (SELECT note_id FROM `+vecNotesTable+`)
+		ORDER BY id
+		LIMIT ?
+	`, afterID, reembedBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notes []InteractionNote
+	for rows.Next() {
+		var note InteractionNote
+		if err := rows.Scan(&note.ID, &note.Title, &note.Summary); err != nil {
+			return nil, err
+		}
+		notes = append(notes, note)
+	}
+	return notes, rows.Err()
+}
+
+// storeReembeddedVector adds a vector unless the note was deleted or given
+// one by a concurrent write while it was being embedded.
+func storeReembeddedVector(noteID int64, embedding []float32) error {
+	vecTableMu.RLock()
+	defer vecTableMu.RUnlock()
+
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var missing bool
+	if err := tx.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM interaction_notes WHERE id = ?)
+		   AND NOT EXISTS (SELECT 1 FROM `+vecNotesTable+` WHERE note_id = ?)
+	`, noteID, noteID).Scan(&missing); err != nil {
+		return err
+	}
+	if !missing {
+		return nil
+	}
+	if err := insertNoteVector(tx, noteID, embedding); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// swapInReembeddedVectors replaces vec_notes with vec_notes_next and records
+// the new embedder. It does nothing when no migration is in progress.
+func swapInReembeddedVectors() error {
+	vecTableMu.Lock()
+	defer vecTableMu.Unlock()
+
+	if vecNotesTable == vecNotesMainTable {
+		return nil
+	}
+
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var model string
+	var dimensions int
+	if err := tx.QueryRow(`
+		SELECT target_model, target_dimensions FROM memory_embedding_state WHERE id = 1
+	`).Scan(&model, &dimensions); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DROP TABLE " + vecNotesMainTable); err != nil {
+		return err
+	}
+	if err := createVecTable(tx, vecNotesMainTable, dimensions); err != nil {
+		return err
+	}
+	for _, stmt := range []string{
+		"INSERT INTO " + vecNotesMainTable + " (note_id, embedding) SELECT note_id, embedding FROM " + vecNotesNextTable,
+		"DROP TABLE " + vecNotesNextTable,
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`
+		UPDATE memory_embedding_state
+		SET model = ?, dimensions = ?, target_model = '', target_dimensions = 0
+		WHERE id = 1
+	`, model, dimensions); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	vecNotesTable = vecNotesMainTable
+	log.Printf("memory: notes re-embedded with %s (%d dimensions)", model, dimensions)
+	return nil
+}
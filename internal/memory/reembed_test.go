@@ -0,0 +1,176 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type fakeEmbedder struct {
+	model      string
+	dimensions int
+	fail       string
+}
+
+func (e fakeEmbedder) Model() string   { return e.model }
+func (e fakeEmbedder) Dimensions() int { return e.dimensions }
+
+func (e fakeEmbedder) Embed(_ context.Context, text string) ([]float32, error) {
+	if e.fail != "" && strings.Contains(text, e.fail) {
+		return nil, fmt.Errorf("cannot embed %q", text)
+	}
+	vec := make([]float32, e.dimensions)
+	vec[0] = 1
+	return vec, nil
+}
+
+func setEmbedder(t *testing.T, e Embedder) {
+	t.Helper()
+	embedderMu.Lock()
+	previous := embedder
+	embedderMu.Unlock()
+	SetEmbedder(e)
+	t.Cleanup(func() { SetEmbedder(previous) })
+}
+
+func insertTestNote(t *testing.T, title string, embedding []float32) int64 {
+	t.Helper()
+	id, err := insertNote(InteractionNote{
+		GuildID:  "guild-1",
+		NoteType: noteTypeConversation,
+		Title:    title,
+		Summary:  title + " summary.",
+		NoteDate: "2026-02-25",
+	}, nil, embedding)
+	if err != nil {
+		t.Fatalf("insertNote: %v", err)
+	}
+	return id
+}
+
+func TestPrepareEmbeddingIndexKeepsMatchingVectors(t *testing.T) {
+	setupTestDB(t)
+	insertTestNote(t, "Kept", testEmbedding())
+
+	if err := prepareEmbeddingIndex(fakeEmbedder{model: defaultEmbeddingModel, dimensions: defaultEmbeddingDimensions}); err != nil {
+		t.Fatalf("prepareEmbeddingIndex: %v", err)
+	}
+	if vecNotesTable != vecNotesMainTable {
+		t.Errorf("searched table = %s, want %s", vecNotesTable, vecNotesMainTable)
+	}
+	state, err := loadEmbeddingState()
+	if err != nil || state.Model != defaultEmbeddingModel || state.Dimensions != defaultEmbeddingDimensions || state.TargetModel != "" {
+		t.Errorf("state = %+v, %v; want the default embedder and no migration", state, err)
+	}
+}
+
+func TestReembedNotesMigratesToNewDimensions(t *testing.T) {
+	setupTestDB(t)
+	local := fakeEmbedder{model: "local-embed", dimensions: 8}
+	setEmbedder(t, local)
+
+	kept := insertTestNote(t, "Before the switch", testEmbedding())
+	deleted := insertTestNote(t, "Deleted mid-migration", testEmbedding())
+
+	if err := prepareEmbeddingIndex(local); err != nil {
+		t.Fatalf("prepareEmbeddingIndex: %v", err)
+	}
+	if vecNotesTable != vecNotesNextTable {
+		t.Fatalf("searched table = %s, want %s during the migration", vecNotesTable, vecNotesNextTable)
+	}
+	vec, _ := local.Embed(context.Background(), "x")
+	written := insertTestNote(t, "During the migration", vec)
+	if err := deleteNoteAndVector(deleted); err != nil {
+		t.Fatalf("deleteNoteAndVector: %v", err)
+	}
+
+	if err := reembedNotes(context.Background()); err != nil {
+		t.Fatalf("reembedNotes: %v", err)
+	}
+
+	if vecNotesTable != vecNotesMainTable {
+		t.Errorf("searched table = %s after the migration, want %s", vecNotesTable, vecNotesMainTable)
+	}
+	if dims, err := vecTableDimensions(vecNotesMainTable); err != nil || dims != 8 {
+		t.Errorf("vec_notes dimensions = %d, %v; want 8", dims, err)
+	}
+	var leftover int
+	if err := database.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = ?", vecNotesNextTable).Scan(&leftover); err != nil || leftover != 0 {
+		t.Errorf("%s still exists after the migration", vecNotesNextTable)
+	}
+	state, err := loadEmbeddingState()
+	if err != nil || state.Model != "local-embed" || state.Dimensions != 8 || state.TargetModel != "" {
+		t.Errorf("state = %+v, %v; want the local embedder", state, err)
+	}
+
+	notes, err := vectorNoteCandidates("guild-1", "", noteTypeConversation, vec, 10)
+	if err != nil {
+		t.Fatalf("vectorNoteCandidates: %v", err)
+	}
+	var ids []int64
+	for _, note := range notes {
+		ids = append(ids, note.ID)
+	}
+	if len(ids) != 2 || ids[0] == deleted || ids[1] == deleted || (ids[0] != kept && ids[1] != kept) || (ids[0] != written && ids[1] != written) {
+		t.Errorf("searchable notes = %v, want %d and %d", ids, kept, written)
+	}
+}
+
+func TestReembedNotesWaitsForFailedNotes(t *testing.T) {
+	setupTestDB(t)
+	flaky := fakeEmbedder{model: "local-embed", dimensions: 8, fail: "Stubborn"}
+	setEmbedder(t, flaky)
+
+	insertTestNote(t, "Easy", testEmbedding())
+	insertTestNote(t, "Stubborn", testEmbedding())
+	if err := prepareEmbeddingIndex(flaky); err != nil {
+		t.Fatalf("prepareEmbeddingIndex: %v", err)
+	}
+
+	if err := reembedNotes(context.Background()); err == nil {
+		t.Fatal("reembedNotes succeeded with a note that cannot be embedded")
+	}
+	if vecNotesTable != vecNotesNextTable {
+		t.Fatalf("vectors were swapped in with a note missing")
+	}
+
+	setEmbedder(t, fakeEmbedder{model: "local-embed", dimensions: 8})
+	if err := reembedNotes(context.Background()); err != nil {
+		t.Fatalf("retrying reembedNotes: %v", err)
+	}
+	var count int
+	if err := database.QueryRow("SELECT COUNT(*) FROM vec_notes").Scan(&count); err != nil || count != 2 {
+		t.Errorf("vec_notes rows = %d, %v; want 2", count, err)
+	}
+}
+
+func TestPrepareEmbeddingIndexAbandonsMigration(t *testing.T) {
+	setupTestDB(t)
+	original := fakeEmbedder{model: defaultEmbeddingModel, dimensions: defaultEmbeddingDimensions}
+	setEmbedder(t, original)
+	insertTestNote(t, "Old note", testEmbedding())
+
+	if err := prepareEmbeddingIndex(fakeEmbedder{model: "local-embed", dimensions: 8}); err != nil {
+		t.Fatalf("prepareEmbeddingIndex: %v", err)
+	}
+	vec := make([]float32, 8)
+	vec[0] = 1
+	insertTestNote(t, "Written during the migration", vec)
+
+	// Switching back drops the half-built index; the note written meanwhile
+	// is embedded again with the original embedder.
+	if err := prepareEmbeddingIndex(original); err != nil {
+		t.Fatalf("prepareEmbeddingIndex: %v", err)
+	}
+	if err := reembedNotes(context.Background()); err != nil {
+		t.Fatalf("reembedNotes: %v", err)
+	}
+	var count int
+	if err := database.QueryRow("SELECT COUNT(*) FROM vec_notes").Scan(&count); err != nil || count != 2 {
+		t.Errorf("vec_notes rows = %d, %v; want 2", count, err)
+	}
+	if state, _ := loadEmbeddingState(); state.TargetModel != "" {
+		t.Errorf("state = %+v, want no migration", state)
+	}
+}
@@ -31,22 +31,33 @@ func BuildPromptContext(req RetrieveRequest) string {
 		return ""
 	}
 
-	topics, err := searchRelevantNotes(req.GuildID, req.ChannelID, noteTypeTopicCluster, embedding, topicRetrievalLimit)
+	topics, err := searchRelevantNotes(req.GuildID, req.ChannelID, noteTypeTopicCluster, req.Query, embedding, topicRetrievalLimit)
 	if err != nil {
 		log.Printf("memory: topic retrieval failed: %v", err)
 	}
 
-	notes, err := searchRelevantNotes(req.GuildID, req.ChannelID, noteTypeConversation, embedding, conversationRetrievalLimit)
+	notes, err := searchRelevantNotes(req.GuildID, req.ChannelID, noteTypeConversation, req.Query, embedding, conversationRetrievalLimit)
 	if err != nil {
 		log.Printf("memory: conversation retrieval failed: %v", err)
 	}
 
+	optedOut, err := optedOutUserIDs()
+	if err != nil {
+		log.Printf("memory: failed to load opted-out users: %v", err)
+		return ""
+	}
+	topics = withoutOptedOutNotes(topics, optedOut)
+	notes = withoutOptedOutNotes(notes, optedOut)
+
 	selectedNotes := make(map[int64]InteractionNote)
 	for _, note := range notes {
 		selectedNotes[note.ID] = note
 	}
 
 	selectedUsers := collectRequestedUsers(req.ConversationUsers, req.MentionedUsers)
+	for userID := range optedOut {
+		delete(selectedUsers, userID)
+	}
 	extraCandidates := collectExtraUserCandidates(notes, topics, selectedUsers)
 	for _, userID := range extraCandidates {
 		if len(selectedUsers) >= len(req.ConversationUsers)+mentionedProfileLimit+extraProfileLimit {
@@ -86,7 +97,7 @@ func BuildPromptContext(req RetrieveRequest) string {
 			log.Printf("memory: failed to load fallback notes for user %d: %v", userID, err)
 			continue
 		}
-		for _, note := range fallbackNotes {
+		for _, note := range withoutOptedOutNotes(fallbackNotes, optedOut) {
 			selectedNotes[note.ID] = note
 		}
 		if enabled {
@@ -128,11 +139,35 @@ func BuildPromptContext(req RetrieveRequest) string {
 	return contextText
 }
 
-func searchRelevantNotes(guildID, channelID, noteType string, embedding []float32, limit int) ([]InteractionNote, error) {
+// searchRelevantNotes ranks the guild's notes of noteType against the query
+// twice, by embedding distance and by the words it shares with them, and
+// fuses the two rankings so exact names and titles are found even when the
+// embedding misses them. Notes found only by sharing a single word with a
+// longer query count for less. Older notes are decayed towards half weight.
+func searchRelevantNotes(guildID, channelID, noteType, query string, embedding []float32, limit int) ([]InteractionNote, error) {
+	vector, err := vectorNoteCandidates(guildID, channelID, noteType, embedding, limit*retrievalCandidateMultiplier)
+	if err != nil {
+		return nil, err
+	}
+	lexical, err := lexicalNoteCandidates(guildID, noteType, query, limit*retrievalCandidateMultiplier)
+	if err != nil {
+		return nil, err
+	}
+	weights := weakLexicalHits(lexical, vector, query)
+	return attachNoteParticipants(fuseNoteRankings(timeNow(), limit, weights, vector, lexical))
+}
+
+// vectorNoteCandidates returns notes within fallbackRetrievalDistance of the
+// embedding, same-channel and nearest first. When any note is within
+// strictRetrievalDistance only those are returned.
+func vectorNoteCandidates(guildID, channelID, noteType string, embedding []float32, limit int) ([]InteractionNote, error) {
+	vecTableMu.RLock()
+	defer vecTableMu.RUnlock()
+
 	rows, err := database.Query(`
 		SELECT n.id, n.guild_id, COALESCE(n.channel_id, ''), n.note_type, n.title, n.summary, n.source_note_ids, n.note_date, n.created_at,
 		       vec_distance_cosine(v.embedding, ?) AS distance
-		FROM vec_notes v
+		FROM `+vecNotesTable+` v
 		JOIN interaction_notes n ON n.id = v.note_id
 		WHERE n.guild_id = ?
 		  AND n.note_type = ?
@@ -142,41 +177,35 @@ func searchRelevantNotes(guildID, channelID, noteType string, embedding []float3
 			n.note_date DESC,
 			n.created_at DESC
 		LIMIT ?
-	`, serializeFloat32(embedding), guildID, noteType, channelID, limit*retrievalCandidateMultiplier)
+	`, serializeFloat32(embedding), guildID, noteType, channelID, limit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var strictMatches []noteMatch
-	var fallbackMatches []noteMatch
+	var strictMatches []InteractionNote
+	var fallbackMatches []InteractionNote
 	for rows.Next() {
 		note, distance, err := scanNoteMatch(rows)
 		if err != nil {
 			return nil, err
 		}
-		if distance <= strictRetrievalDistance && len(strictMatches) < limit {
-			strictMatches = append(strictMatches, noteMatch{Note: note, Distance: distance})
+		if distance <= strictRetrievalDistance {
+			strictMatches = append(strictMatches, note)
 			continue
 		}
-		if distance <= fallbackRetrievalDistance && len(fallbackMatches) < limit {
-			fallbackMatches = append(fallbackMatches, noteMatch{Note: note, Distance: distance})
+		if distance <= fallbackRetrievalDistance {
+			fallbackMatches = append(fallbackMatches, note)
 		}
 	}
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
-	matches := strictMatches
-	if len(matches) == 0 {
-		matches = fallbackMatches
-	}
-
-	notes := make([]InteractionNote, 0, len(matches))
-	for _, match := range matches {
-		notes = append(notes, match.Note)
+	if len(strictMatches) == 0 {
+		return fallbackMatches, nil
 	}
-	return attachNoteParticipants(notes)
+	return strictMatches, nil
 }
 
 func scanNoteMatch(scanner interface {
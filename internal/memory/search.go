@@ -0,0 +1,219 @@
+package memory
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// lexicalStopwords are common words that would match most notes and drown out
+// the names and terms a query is actually about.
+var lexicalStopwords = map[string]struct{}{
+	"the": {}, "and": {}, "for": {}, "are": {}, "but": {}, "not": {}, "you": {}, "your": {},
+	"all": {}, "any": {}, "can": {}, "had": {}, "has": {}, "have": {}, "her": {}, "his": {},
+	"him": {}, "she": {}, "they": {}, "them": {}, "their": {}, "was": {}, "were": {}, "one": {},
+	"our": {}, "out": {}, "who": {}, "what": {}, "when": {}, "where": {}, "why": {}, "how": {},
+	"which": {}, "this": {}, "that": {}, "these": {}, "those": {}, "with": {}, "from": {},
+	"about": {}, "into": {}, "than": {}, "then": {}, "there": {}, "here": {}, "been": {},
+	"being": {}, "does": {}, "did": {}, "doing": {}, "just": {}, "like": {}, "know": {},
+	"remember": {}, "tell": {}, "said": {}, "say": {}, "says": {}, "get": {}, "got": {},
+	"some": {}, "more": {}, "most": {}, "very": {}, "also": {}, "too": {}, "its": {},
+	"would": {}, "could": {}, "should": {}, "will": {}, "shall": {}, "may": {}, "might": {},
+	"must": {}, "over": {}, "again": {}, "ever": {}, "still": {}, "yes": {}, "yeah": {},
+	"okay": {}, "please": {}, "thanks": {}, "think": {}, "want": {}, "need": {},
+}
+
+// lexicalQueryTerms splits a query into the lowercase words worth matching
+// against note titles and summaries: at least three letters or digits, not a
+// stopword, deduplicated and capped at lexicalQueryMaxTerms.
+func lexicalQueryTerms(query string) []string {
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	seen := make(map[string]struct{}, len(words))
+	var terms []string
+	for _, word := range words {
+		if len([]rune(word)) < 3 {
+			continue
+		}
+		if _, ok := lexicalStopwords[word]; ok {
+			continue
+		}
+		if _, ok := seen[word]; ok {
+			continue
+		}
+		seen[word] = struct{}{}
+		terms = append(terms, word)
+		if len(terms) == lexicalQueryMaxTerms {
+			break
+		}
+	}
+	return terms
+}
+
+// lexicalNoteCandidates returns the guild's notes of noteType whose title or
+// summary share words with the query, best match first. It uses the FTS5
+// index when SQLite has it and falls back to LIKE matching otherwise.
+func lexicalNoteCandidates(guildID, noteType, query string, limit int) ([]InteractionNote, error) {
+	terms := lexicalQueryTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	hasIndex, err := hasNotesSearchIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	var args []any
+	var stmt string
+	if hasIndex {
+		quoted := make([]string, len(terms))
+		for i, term := range terms {
+			quoted[i] = `"` + term + `"`
+		}
+		stmt = `
+			SELECT n.id, n.guild_id, COALESCE(n.channel_id, ''), n.note_type, n.title, n.summary, n.source_note_ids, n.note_date, n.created_at,
+			       bm25(interaction_notes_fts) AS score
+			FROM interaction_notes_fts
+			JOIN interaction_notes n ON n.id = interaction_notes_fts.rowid
+			WHERE interaction_notes_fts MATCH ?
+			  AND n.guild_id = ?
+			  AND n.note_type = ?
+			ORDER BY score ASC, n.note_date DESC, n.created_at DESC
+			LIMIT ?
+		`
+		args = []any{strings.Join(quoted, " OR "), guildID, noteType, limit}
+	} else {
+		matches := make([]string, len(terms))
+		for i, term := range terms {
+			matches[i] = `(LOWER(n.title || ' ' || n.summary) LIKE ?)`
+			args = append(args, "%"+term+"%")
+		}
+		stmt = fmt.Sprintf(`
+			SELECT n.id, n.guild_id, COALESCE(n.channel_id, ''), n.note_type, n.title, n.summary, n.source_note_ids, n.note_date, n.created_at,
+			       CAST(%s AS REAL) AS score
+			FROM interaction_notes n
+			WHERE n.guild_id = ?
+			  AND n.note_type = ?
+			  AND score > 0
+			ORDER BY score DESC, n.note_date DESC, n.created_at DESC
+			LIMIT ?
+		`, strings.Join(matches, " + "))
+		args = append(args, guildID, noteType, limit)
+	}
+
+	rows, err := database.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notes []InteractionNote
+	for rows.Next() {
+		note, _, err := scanNoteMatch(rows)
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, note)
+	}
+	return notes, rows.Err()
+}
+
+// hasNotesSearchIndex reports whether the FTS5 index over interaction notes
+// can be queried. It is missing when SQLite was built without FTS5, and left
+// unusable when an FTS5 build created it.
+func hasNotesSearchIndex() (bool, error) {
+	var count int
+	err := database.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'interaction_notes_fts'
+		AND sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&count)
+	return count > 0, err
+}
+
+// weakLexicalHits weighs down the lexical hits the vector ranking didn't find
+// that share fewer than lexicalMinSharedTerms words with the query, or fewer
+// than all of them for shorter queries. They keep weakLexicalWeight of their
+// fused score: one shared word, such as a name the embedding misses, still
+// finds a note, but ranks it below notes matched on more than that.
+func weakLexicalHits(lexical, vector []InteractionNote, query string) map[int64]float64 {
+	terms := lexicalQueryTerms(query)
+	minShared := min(len(terms), lexicalMinSharedTerms)
+	found := make(map[int64]struct{}, len(vector))
+	for _, note := range vector {
+		found[note.ID] = struct{}{}
+	}
+
+	weights := make(map[int64]float64)
+	for _, note := range lexical {
+		if _, ok := found[note.ID]; ok {
+			continue
+		}
+		text := strings.ToLower(note.Title + " " + note.Summary)
+		shared := 0
+		for _, term := range terms {
+			if strings.Contains(text, term) {
+				shared++
+			}
+		}
+		if shared < minShared {
+			weights[note.ID] = weakLexicalWeight
+		}
+	}
+	return weights
+}
+
+// fuseNoteRankings merges ranked note lists with reciprocal-rank fusion,
+// weights each note by its age and by weights, where it has one, and returns
+// the best limit notes.
+func fuseNoteRankings(now time.Time, limit int, weights map[int64]float64, rankings ...[]InteractionNote) []InteractionNote {
+	scores := make(map[int64]float64)
+	notes := make(map[int64]InteractionNote)
+	for _, ranking := range rankings {
+		for rank, note := range ranking {
+			scores[note.ID] += 1 / float64(retrievalFusionK+rank+1)
+			notes[note.ID] = note
+		}
+	}
+
+	fused := make([]InteractionNote, 0, len(notes))
+	for id, note := range notes {
+		scores[id] *= noteRecencyWeight(now, note.NoteDate)
+		if w, ok := weights[id]; ok {
+			scores[id] *= w
+		}
+		fused = append(fused, note)
+	}
+	sort.Slice(fused, func(i, j int) bool {
+		if scores[fused[i].ID] != scores[fused[j].ID] {
+			return scores[fused[i].ID] > scores[fused[j].ID]
+		}
+		if fused[i].NoteDate != fused[j].NoteDate {
+			return fused[i].NoteDate > fused[j].NoteDate
+		}
+		return fused[i].ID > fused[j].ID
+	})
+	if len(fused) > limit {
+		fused = fused[:limit]
+	}
+	return fused
+}
+
+// noteRecencyWeight halves the part of a note's weight above
+// retrievalRecencyFloor every retrievalRecencyHalfLife. Notes with no
+// readable date are not decayed.
+func noteRecencyWeight(now time.Time, noteDate string) float64 {
+	date, err := time.Parse("2006-01-02", safeDate(noteDate))
+	if err != nil {
+		return 1
+	}
+	age := now.Sub(date)
+	if age <= 0 {
+		return 1
+	}
+	decay := math.Pow(0.5, float64(age)/float64(retrievalRecencyHalfLife))
+	return retrievalRecencyFloor + (1-retrievalRecencyFloor)*decay
+}
@@ -0,0 +1,109 @@
+package memory
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLexicalQueryTerms(t *testing.T) {
+	got := lexicalQueryTerms("What did Zoë say about the Kubernetes migration? kubernetes, ok")
+	want := "zoë,kubernetes,migration"
+	if strings.Join(got, ",") != want {
+		t.Errorf("lexicalQueryTerms = %v, want %s", got, want)
+	}
+	if got := lexicalQueryTerms("is it ok?"); len(got) != 0 {
+		t.Errorf("lexicalQueryTerms(stopwords) = %v, want none", got)
+	}
+}
+
+func TestSearchRelevantNotesFindsProperNouns(t *testing.T) {
+	setupTestDB(t)
+	setTimeNow(t, func() time.Time { return time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC) })
+
+	// The embedding misses the note about Quetzal entirely.
+	far := make([]float32, defaultEmbeddingDimensions)
+	far[1] = 1
+	quetzal, err := insertNote(InteractionNote{
+		GuildID:  "guild-1",
+		NoteType: noteTypeConversation,
+		Title:    "Project Quetzal kickoff",
+		Summary:  "The team picked a launch date for Quetzal.",
+		NoteDate: "2026-02-20",
+	}, nil, far)
+	if err != nil {
+		t.Fatalf("insertNote: %v", err)
+	}
+	if _, err := insertNote(InteractionNote{
+		GuildID:  "guild-1",
+		NoteType: noteTypeConversation,
+		Title:    "Lunch plans",
+		Summary:  "Everyone wanted noodles.",
+		NoteDate: "2026-02-21",
+	}, nil, testEmbedding()); err != nil {
+		t.Fatalf("insertNote: %v", err)
+	}
+
+	notes, err := searchRelevantNotes("guild-1", "", noteTypeConversation, "when does quetzal launch?", testEmbedding(), 2)
+	if err != nil {
+		t.Fatalf("searchRelevantNotes: %v", err)
+	}
+	var found bool
+	for _, note := range notes {
+		found = found || note.ID == quetzal
+	}
+	if !found {
+		t.Errorf("notes = %+v, want the Quetzal note", notes)
+	}
+}
+
+func TestWeakLexicalHits(t *testing.T) {
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	both := InteractionNote{ID: 1, Title: "Lunch plans", Summary: "Everyone wanted noodles.", NoteDate: "2026-02-28"}
+	strong := InteractionNote{ID: 2, Title: "Project Quetzal kickoff", Summary: "The team picked a launch date.", NoteDate: "2026-02-28"}
+	weak := InteractionNote{ID: 3, Title: "Launch party", Summary: "Cake was had.", NoteDate: "2026-02-28"}
+	name := InteractionNote{ID: 4, Title: "Hollowknight", Summary: "Mira keeps dying to the same boss.", NoteDate: "2026-02-28"}
+
+	lexical := []InteractionNote{both, strong, weak}
+	weights := weakLexicalHits(lexical, []InteractionNote{both}, "when does quetzal launch?")
+	if len(weights) != 1 || weights[weak.ID] != weakLexicalWeight {
+		t.Errorf("weights = %v, want only the note sharing one word weighed down", weights)
+	}
+	got := fuseNoteRankings(now, 3, weights, []InteractionNote{both}, lexical)
+	if len(got) != 3 || got[2].ID != weak.ID {
+		t.Errorf("fused = %+v, want the weak hit kept, last", got)
+	}
+
+	// A name the embedding missed is still found on its own.
+	query := "anyone playing Hollowknight tonight"
+	got = fuseNoteRankings(now, 3, weakLexicalHits([]InteractionNote{name}, nil, query), nil, []InteractionNote{name})
+	if len(got) != 1 || got[0].ID != name.ID {
+		t.Errorf("fused = %+v for %q, want the Hollowknight note", got, query)
+	}
+
+	// A one-word query needs only that word.
+	if weights := weakLexicalHits([]InteractionNote{weak}, nil, "launch?"); len(weights) != 0 {
+		t.Errorf("weights = %v for a one-word query, want none", weights)
+	}
+}
+
+func TestFuseNoteRankingsDecaysOldNotes(t *testing.T) {
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	old := InteractionNote{ID: 1, NoteDate: "2025-03-01"}
+	recent := InteractionNote{ID: 2, NoteDate: "2026-02-28"}
+	other := InteractionNote{ID: 3, NoteDate: "2026-02-27"}
+
+	// The old note ranks first in both lists but a year of decay outweighs
+	// that lead.
+	got := fuseNoteRankings(now, 2, nil, []InteractionNote{old, recent, other}, []InteractionNote{old, recent})
+	if len(got) != 2 || got[0].ID != recent.ID || got[1].ID != old.ID {
+		t.Errorf("fused = %+v, want the recent note before the old one", got)
+	}
+
+	if w := noteRecencyWeight(now, "2025-12-01"); w < 0.74 || w > 0.76 {
+		t.Errorf("weight after one half-life = %f, want 0.75", w)
+	}
+	if w := noteRecencyWeight(now, "not a date"); w != 1 {
+		t.Errorf("weight without a date = %f, want 1", w)
+	}
+}
@@ -0,0 +1,175 @@
+// Package permissions resolves named bot capabilities from guild roles and
+// Discord permission bits, and records privileged actions in an audit log.
+package permissions
+
+import (
+	"database/sql"
+	"log"
+	"slices"
+
+	"github.com/bwmarrin/discordgo"
+
+	"voltgpt/internal/settings"
+)
+
+var database *sql.DB
+
+// Capability is a named privilege that can be granted to a guild role.
+type Capability string
+
+const (
+	MemoryAdmin Capability = "memory_admin"
+	WheelHost   Capability = "wheel_host"
+	HasherAdmin Capability = "hasher_admin"
+)
+
+// Capabilities lists every capability that can be granted.
+var Capabilities = []Capability{MemoryAdmin, WheelHost, HasherAdmin}
+
+// AuditEntry is a single privileged action recorded in the audit log.
+type AuditEntry struct {
+	ID        int64
+	GuildID   string
+	UserID    string
+	Action    string
+	Details   string
+	CreatedAt int64
+}
+
+func Init(db *sql.DB) {
+	database = db
+}
+
+// Valid reports whether c is a known capability.
+func Valid(c Capability) bool {
+	return slices.Contains(Capabilities, c)
+}
+
+// Has reports whether the member holds the capability in the guild. Guild
+// admins (Administrator permission or a configured admin role) hold every
+// capability; everyone else needs a role the capability was granted to.
+func Has(guildID string, member *discordgo.Member, c Capability) bool {
+	if member == nil || guildID == "" {
+		return false
+	}
+	if settings.IsAdmin(guildID, member) {
+		return true
+	}
+	if database == nil || len(member.Roles) == 0 {
+		return false
+	}
+
+	roles, err := rolesWith(guildID, c)
+	if err != nil {
+		log.Printf("permissions: load roles for %s/%s: %v", guildID, c, err)
+		return false
+	}
+	for _, role := range member.Roles {
+		if slices.Contains(roles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+func rolesWith(guildID string, c Capability) ([]string, error) {
+	rows, err := database.Query(
+		"SELECT role_id FROM role_capabilities WHERE guild_id = ? AND capability = ?",
+		guildID, string(c),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []string
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+// Grant gives every member of the role the capability in the guild.
+func Grant(guildID, roleID string, c Capability) error {
+	_, err := database.Exec(
+		"INSERT OR IGNORE INTO role_capabilities (guild_id, role_id, capability) VALUES (?, ?, ?)",
+		guildID, roleID, string(c),
+	)
+	return err
+}
+
+// Revoke removes a capability from a role. It reports whether a grant existed.
+func Revoke(guildID, roleID string, c Capability) (bool, error) {
+	result, err := database.Exec(
+		"DELETE FROM role_capabilities WHERE guild_id = ? AND role_id = ? AND capability = ?",
+		guildID, roleID, string(c),
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// Grants returns the roles holding each capability in the guild.
+func Grants(guildID string) (map[Capability][]string, error) {
+	rows, err := database.Query(
+		"SELECT capability, role_id FROM role_capabilities WHERE guild_id = ? ORDER BY capability, role_id",
+		guildID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	grants := map[Capability][]string{}
+	for rows.Next() {
+		var c, role string
+		if err := rows.Scan(&c, &role); err != nil {
+			return nil, err
+		}
+		grants[Capability(c)] = append(grants[Capability(c)], role)
+	}
+	return grants, rows.Err()
+}
+
+// Audit records a privileged action. Failures are logged rather than returned
+// so that auditing never blocks the action itself.
+func Audit(guildID, userID, action, details string) {
+	if database == nil {
+		return
+	}
+	_, err := database.Exec(
+		"INSERT INTO audit_log (guild_id, user_id, action, details) VALUES (?, ?, ?, ?)",
+		guildID, userID, action, details,
+	)
+	if err != nil {
+		log.Printf("permissions: audit %s by %s in %s: %v", action, userID, guildID, err)
+	}
+}
+
+// RecentAudit returns the newest audit entries for a guild.
+func RecentAudit(guildID string, limit int) ([]AuditEntry, error) {
+	rows, err := database.Query(
+		"SELECT id, guild_id, user_id, action, details, created_at FROM audit_log WHERE guild_id = ? ORDER BY id DESC LIMIT ?",
+		guildID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []AuditEntry
+	for rows.Next() {
+		var e AuditEntry
+		if err := rows.Scan(&e.ID, &e.GuildID, &e.UserID, &e.Action, &e.Details, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
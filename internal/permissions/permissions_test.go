@@ -0,0 +1,139 @@
+package permissions
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+
+	"voltgpt/internal/db"
+	"voltgpt/internal/settings"
+)
+
+func setupDB(t *testing.T) {
+	t.Helper()
+
+	db.Open(":memory:")
+	settings.Init(db.DB)
+	Init(db.DB)
+
+	t.Cleanup(func() {
+		db.Close()
+		database = nil
+	})
+}
+
+func TestHasViaGrantedRole(t *testing.T) {
+	setupDB(t)
+
+	if err := Grant("guild-1", "hosts", WheelHost); err != nil {
+		t.Fatalf("Grant() error = %v", err)
+	}
+
+	host := &discordgo.Member{Roles: []string{"hosts"}}
+	if !Has("guild-1", host, WheelHost) {
+		t.Error("Has(WheelHost) = false, want true for granted role")
+	}
+	if Has("guild-1", host, MemoryAdmin) {
+		t.Error("Has(MemoryAdmin) = true, want false for ungranted capability")
+	}
+	if Has("guild-2", host, WheelHost) {
+		t.Error("Has() = true in another guild, want false")
+	}
+}
+
+func TestHasAdministratorHoldsEverything(t *testing.T) {
+	setupDB(t)
+
+	admin := &discordgo.Member{Permissions: discordgo.PermissionAdministrator}
+	for _, c := range Capabilities {
+		if !Has("guild-1", admin, c) {
+			t.Errorf("Has(%s) = false, want true for administrator", c)
+		}
+	}
+}
+
+func TestHasSettingsAdminRole(t *testing.T) {
+	setupDB(t)
+
+	if err := settings.Save(settings.Guild{GuildID: "guild-1", AdminRoles: []string{"mods"}}); err != nil {
+		t.Fatalf("settings.Save() error = %v", err)
+	}
+	if !Has("guild-1", &discordgo.Member{Roles: []string{"mods"}}, HasherAdmin) {
+		t.Error("Has(HasherAdmin) = false, want true for settings admin role")
+	}
+}
+
+func TestHasNilMember(t *testing.T) {
+	setupDB(t)
+
+	if Has("guild-1", nil, MemoryAdmin) {
+		t.Error("Has(nil member) = true, want false")
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	setupDB(t)
+
+	if err := Grant("guild-1", "mods", MemoryAdmin); err != nil {
+		t.Fatalf("Grant() error = %v", err)
+	}
+	removed, err := Revoke("guild-1", "mods", MemoryAdmin)
+	if err != nil || !removed {
+		t.Fatalf("Revoke() = %v, %v; want true, nil", removed, err)
+	}
+	removed, err = Revoke("guild-1", "mods", MemoryAdmin)
+	if err != nil || removed {
+		t.Fatalf("second Revoke() = %v, %v; want false, nil", removed, err)
+	}
+	if Has("guild-1", &discordgo.Member{Roles: []string{"mods"}}, MemoryAdmin) {
+		t.Error("Has() = true after revoke, want false")
+	}
+}
+
+func TestGrants(t *testing.T) {
+	setupDB(t)
+
+	Grant("guild-1", "a", WheelHost)
+	Grant("guild-1", "b", WheelHost)
+	Grant("guild-1", "a", HasherAdmin)
+	Grant("guild-2", "c", WheelHost)
+
+	grants, err := Grants("guild-1")
+	if err != nil {
+		t.Fatalf("Grants() error = %v", err)
+	}
+	if len(grants[WheelHost]) != 2 || len(grants[HasherAdmin]) != 1 || len(grants[MemoryAdmin]) != 0 {
+		t.Errorf("Grants() = %v, want 2 wheel hosts and 1 hasher admin", grants)
+	}
+}
+
+func TestAuditAndRecent(t *testing.T) {
+	setupDB(t)
+
+	Audit("guild-1", "user-1", "reset_wheel", "Wheel reset!")
+	Audit("guild-1", "user-2", "hash_server", "channels=0")
+	Audit("guild-2", "user-3", "reset_wheel", "")
+
+	entries, err := RecentAudit("guild-1", 10)
+	if err != nil {
+		t.Fatalf("RecentAudit() error = %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("RecentAudit() returned %d entries, want 2", len(entries))
+	}
+	if entries[0].Action != "hash_server" || entries[0].UserID != "user-2" {
+		t.Errorf("newest entry = %+v, want hash_server by user-2", entries[0])
+	}
+	if entries[1].CreatedAt == 0 {
+		t.Error("CreatedAt = 0, want populated timestamp")
+	}
+}
+
+func TestValid(t *testing.T) {
+	if !Valid(MemoryAdmin) {
+		t.Error("Valid(memory_admin) = false, want true")
+	}
+	if Valid("root") {
+		t.Error("Valid(root) = true, want false")
+	}
+}
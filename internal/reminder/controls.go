@@ -0,0 +1,151 @@
+package reminder
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// firedRetention is how long a sent one-shot reminder can still be snoozed.
+const firedRetention = 7 * 24 * time.Hour
+
+// ErrNotFound is returned when a reminder no longer exists.
+var ErrNotFound = errors.New("reminder not found")
+
+// SnoozeOption is one of the snooze buttons attached to a sent reminder.
+type SnoozeOption struct {
+	Key   string
+	Label string
+}
+
+// SnoozeOptions lists the snooze buttons in display order.
+var SnoozeOptions = []SnoozeOption{
+	{Key: "10m", Label: "10 min"},
+	{Key: "1h", Label: "1 hour"},
+	{Key: "tomorrow", Label: "Tomorrow"},
+}
+
+// SnoozeUntil resolves a snooze option key relative to now. "tomorrow" keeps
+// the wall-clock time in now's location.
+func SnoozeUntil(key string, now time.Time) (time.Time, bool) {
+	switch key {
+	case "10m":
+		return now.Add(10 * time.Minute), true
+	case "1h":
+		return now.Add(time.Hour), true
+	case "tomorrow":
+		return now.AddDate(0, 0, 1), true
+	default:
+		return time.Time{}, false
+	}
+}
+
+// FiredComponents returns the snooze and done buttons for a sent reminder. The
+// custom IDs are routed by the reminder_snooze and reminder_done handlers.
+func FiredComponents(id int64) []discordgo.MessageComponent {
+	buttons := make([]discordgo.MessageComponent, 0, len(SnoozeOptions)+1)
+	for _, opt := range SnoozeOptions {
+		buttons = append(buttons, discordgo.Button{
+			Style:    discordgo.SecondaryButton,
+			Label:    opt.Label,
+			Emoji:    &discordgo.ComponentEmoji{Name: "⏰"},
+			CustomID: fmt.Sprintf("reminder_snooze-%d-%s", id, opt.Key),
+		})
+	}
+	buttons = append(buttons, discordgo.Button{
+		Style:    discordgo.SuccessButton,
+		Label:    "Done",
+		Emoji:    &discordgo.ComponentEmoji{Name: "✅"},
+		CustomID: fmt.Sprintf("reminder_done-%d", id),
+	})
+	return []discordgo.MessageComponent{discordgo.ActionsRow{Components: buttons}}
+}
+
+// Get loads a reminder by ID, including sent one-shot reminders.
+func Get(id int64) (Reminder, error) {
+	r, err := scanReminder(database.QueryRow("SELECT "+reminderColumns+" FROM reminders WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Reminder{}, ErrNotFound
+	}
+	return r, err
+}
+
+// Snooze fires the reminder again at until. A sent one-shot reminder is moved
+// back to pending; a recurring one gets a one-off copy so its schedule is kept.
+func Snooze(id int64, until time.Time) error {
+	r, err := Get(id)
+	if err != nil {
+		return err
+	}
+	if r.Rule != nil {
+		return insert(r.UserID, r.ChannelID, r.GuildID, r.Message, r.Images, until, nil)
+	}
+
+	stopTimer(id)
+	if _, err := database.Exec("UPDATE reminders SET fire_at = ?, fired = 0 WHERE id = ?", until.Unix(), id); err != nil {
+		return fmt.Errorf("snooze reminder: %w", err)
+	}
+	r.FireAt = until.Unix()
+	r.Fired = false
+	schedule(r)
+	return nil
+}
+
+// Done acknowledges a sent reminder. One-shot reminders are removed; recurring
+// ones keep their schedule.
+func Done(id int64) error {
+	r, err := Get(id)
+	if err != nil {
+		return err
+	}
+	if r.Rule == nil {
+		Delete(id)
+	}
+	return nil
+}
+
+// Update changes a reminder's text and schedule and reschedules its timer.
+// rule is nil for a one-shot reminder.
+func Update(id int64, message string, fireAt time.Time, rule *Rule) error {
+	r, err := Get(id)
+	if err != nil {
+		return err
+	}
+
+	stopTimer(id)
+	_, err = database.Exec(
+		"UPDATE reminders SET message = ?, fire_at = ?, recurrence = ?, fired = 0 WHERE id = ?",
+		message, fireAt.Unix(), encodeRule(rule), id,
+	)
+	if err != nil {
+		return fmt.Errorf("update reminder: %w", err)
+	}
+
+	r.Message = message
+	r.FireAt = fireAt.Unix()
+	r.Rule = rule
+	r.Fired = false
+	schedule(r)
+	return nil
+}
+
+func stopTimer(id int64) {
+	mu.Lock()
+	defer mu.Unlock()
+	if t, ok := timers[id]; ok {
+		t.Stop()
+		delete(timers, id)
+	}
+}
+
+// purgeFired drops sent one-shot reminders whose buttons have gone stale.
+func purgeFired() {
+	cutoff := time.Now().Add(-firedRetention).Unix()
+	if _, err := database.Exec("DELETE FROM reminders WHERE fired = 1 AND fire_at < ?", cutoff); err != nil {
+		log.Printf("reminder: purge fired reminders: %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+package reminder
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func markFired(t *testing.T, id int64) {
+	t.Helper()
+	stopTimer(id)
+	if _, err := database.Exec("UPDATE reminders SET fired = 1, fire_at = ? WHERE id = ?", time.Now().Add(-time.Minute).Unix(), id); err != nil {
+		t.Fatalf("mark fired: %v", err)
+	}
+}
+
+func TestSnoozeUntil(t *testing.T) {
+	loc, _ := time.LoadLocation("Europe/Berlin")
+	now := time.Date(2026, 3, 28, 9, 0, 0, 0, loc)
+
+	tests := map[string]time.Time{
+		"10m":      now.Add(10 * time.Minute),
+		"1h":       now.Add(time.Hour),
+		"tomorrow": time.Date(2026, 3, 29, 9, 0, 0, 0, loc), // across the DST switch
+	}
+	for key, want := range tests {
+		got, ok := SnoozeUntil(key, now)
+		if !ok || !got.Equal(want) {
+			t.Errorf("SnoozeUntil(%q) = %v, %v; want %v", key, got, ok, want)
+		}
+	}
+	if _, ok := SnoozeUntil("forever", now); ok {
+		t.Error("SnoozeUntil(forever) ok = true, want false")
+	}
+}
+
+func TestFiredComponents(t *testing.T) {
+	row, ok := FiredComponents(42)[0].(discordgo.ActionsRow)
+	if !ok {
+		t.Fatalf("FiredComponents()[0] = %T, want ActionsRow", FiredComponents(42)[0])
+	}
+	var ids []string
+	for _, c := range row.Components {
+		ids = append(ids, c.(discordgo.Button).CustomID)
+	}
+	want := []string{"reminder_snooze-42-10m", "reminder_snooze-42-1h", "reminder_snooze-42-tomorrow", "reminder_done-42"}
+	if !slices.Equal(ids, want) {
+		t.Errorf("custom IDs = %q, want %q", ids, want)
+	}
+}
+
+func TestFiredReminderIsHiddenButSnoozable(t *testing.T) {
+	setupDB(t)
+
+	Add("user1", "chan1", "guild1", "stretch", nil, time.Now().Add(time.Hour))
+	reminders, _ := GetUserReminders("user1")
+	id := reminders[0].ID
+	markFired(t, id)
+
+	if got, _ := GetUserReminders("user1"); len(got) != 0 {
+		t.Fatalf("fired reminder still listed: %+v", got)
+	}
+	if r, err := Get(id); err != nil || !r.Fired {
+		t.Fatalf("Get() = %+v, %v; want fired reminder", r, err)
+	}
+
+	until := time.Now().Add(10 * time.Minute).Truncate(time.Second)
+	if err := Snooze(id, until); err != nil {
+		t.Fatalf("Snooze() error: %v", err)
+	}
+	got, _ := GetUserReminders("user1")
+	if len(got) != 1 || got[0].ID != id || got[0].FireAt != until.Unix() || got[0].Fired {
+		t.Errorf("after snooze = %+v, want pending reminder %d at %d", got, id, until.Unix())
+	}
+	if TotalActive() != 1 {
+		t.Errorf("TotalActive() = %d, want 1", TotalActive())
+	}
+}
+
+func TestSnoozeRecurringAddsOneOff(t *testing.T) {
+	setupDB(t)
+
+	fireAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	AddRecurring("user1", "chan1", "guild1", "standup", nil, fireAt, Rule{Every: 1, Unit: "day"})
+	reminders, _ := GetUserReminders("user1")
+
+	until := time.Now().Add(10 * time.Minute).Truncate(time.Second)
+	if err := Snooze(reminders[0].ID, until); err != nil {
+		t.Fatalf("Snooze() error: %v", err)
+	}
+
+	got, _ := GetUserReminders("user1")
+	if len(got) != 2 {
+		t.Fatalf("expected recurring reminder plus snoozed copy, got %+v", got)
+	}
+	if got[0].FireAt != until.Unix() || got[0].Rule != nil {
+		t.Errorf("snoozed copy = %+v, want one-shot at %d", got[0], until.Unix())
+	}
+	if got[1].FireAt != fireAt.Unix() || got[1].Rule == nil {
+		t.Errorf("recurring reminder = %+v, want unchanged schedule", got[1])
+	}
+}
+
+func TestDone(t *testing.T) {
+	setupDB(t)
+
+	Add("user1", "chan1", "guild1", "once", nil, time.Now().Add(time.Hour))
+	AddRecurring("user1", "chan1", "guild1", "daily", nil, time.Now().Add(2*time.Hour), Rule{Every: 1, Unit: "day"})
+	reminders, _ := GetUserReminders("user1")
+	once, daily := reminders[0].ID, reminders[1].ID
+	markFired(t, once)
+
+	if err := Done(once); err != nil {
+		t.Fatalf("Done(one-shot) error: %v", err)
+	}
+	if _, err := Get(once); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(one-shot) error = %v, want ErrNotFound", err)
+	}
+
+	if err := Done(daily); err != nil {
+		t.Fatalf("Done(recurring) error: %v", err)
+	}
+	if _, err := Get(daily); err != nil {
+		t.Errorf("recurring reminder removed by Done: %v", err)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	setupDB(t)
+
+	Add("user1", "chan1", "guild1", "old text", nil, time.Now().Add(time.Hour))
+	reminders, _ := GetUserReminders("user1")
+	id := reminders[0].ID
+
+	fireAt := time.Now().Add(3 * time.Hour).Truncate(time.Second)
+	rule := &Rule{Every: 1, Unit: "week"}
+	if err := Update(id, "new text", fireAt, rule); err != nil {
+		t.Fatalf("Update() error: %v", err)
+	}
+
+	r, err := Get(id)
+	if err != nil {
+		t.Fatalf("Get() error: %v", err)
+	}
+	if r.Message != "new text" || r.FireAt != fireAt.Unix() || r.Rule == nil || r.Rule.Unit != "week" {
+		t.Errorf("updated reminder = %+v", r)
+	}
+	if TotalActive() != 1 {
+		t.Errorf("TotalActive() = %d, want 1", TotalActive())
+	}
+
+	if err := Update(9999, "x", fireAt, nil); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Update(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestPurgeFired(t *testing.T) {
+	setupDB(t)
+
+	Add("user1", "chan1", "guild1", "old", nil, time.Now().Add(time.Hour))
+	reminders, _ := GetUserReminders("user1")
+	id := reminders[0].ID
+	database.Exec("UPDATE reminders SET fired = 1, fire_at = ? WHERE id = ?", time.Now().Add(-firedRetention-time.Hour).Unix(), id)
+
+	purgeFired()
+
+	if _, err := Get(id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() after purge error = %v, want ErrNotFound", err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	_ "time/tzdata" // zone rules for LookupLocation on hosts without a tz database
 )
 
 // unitRe matches one duration component: a number followed by a unit.
@@ -16,25 +17,31 @@ var unitRe = regexp.MustCompile(
 		`(years?|yr|months?|mo|weeks?|wk|days?|hours?|hr|minutes?|mins?|seconds?|secs?|[ymwdhs])`,
 )
 
-// tzAbbrevs maps common timezone abbreviations to fixed-offset locations.
-// time.LoadLocation handles IANA names (e.g. "America/New_York"); this map
-// covers short abbreviations that LoadLocation does not recognise.
-var tzAbbrevs = map[string]*time.Location{
-	"UTC":  time.UTC,
-	"GMT":  time.UTC,
-	"EST":  time.FixedZone("EST", -5*3600),
-	"EDT":  time.FixedZone("EDT", -4*3600),
-	"CST":  time.FixedZone("CST", -6*3600),
-	"CDT":  time.FixedZone("CDT", -5*3600),
-	"MST":  time.FixedZone("MST", -7*3600),
-	"MDT":  time.FixedZone("MDT", -6*3600),
-	"PST":  time.FixedZone("PST", -8*3600),
-	"PDT":  time.FixedZone("PDT", -7*3600),
-	"CET":  time.FixedZone("CET", 1*3600),
-	"CEST": time.FixedZone("CEST", 2*3600),
-	"BST":  time.FixedZone("BST", 1*3600),
-	"JST":  time.FixedZone("JST", 9*3600),
-	"AEST": time.FixedZone("AEST", 10*3600),
+// tzAbbrevs maps common timezone abbreviations to the IANA zone people usually
+// mean by them. Both the standard and daylight forms map to the same region so
+// "16:30 CET" stays correct when Europe is on summer time; time.LoadLocation
+// would otherwise resolve some of them ("EST", "MST") to fixed offsets.
+var tzAbbrevs = map[string]string{
+	"UTC":  "UTC",
+	"GMT":  "UTC",
+	"ET":   "America/New_York",
+	"EST":  "America/New_York",
+	"EDT":  "America/New_York",
+	"CT":   "America/Chicago",
+	"CST":  "America/Chicago",
+	"CDT":  "America/Chicago",
+	"MT":   "America/Denver",
+	"MST":  "America/Denver",
+	"MDT":  "America/Denver",
+	"PT":   "America/Los_Angeles",
+	"PST":  "America/Los_Angeles",
+	"PDT":  "America/Los_Angeles",
+	"CET":  "Europe/Berlin",
+	"CEST": "Europe/Berlin",
+	"BST":  "Europe/London",
+	"JST":  "Asia/Tokyo",
+	"AEST": "Australia/Sydney",
+	"AEDT": "Australia/Sydney",
 }
 
 // Trigger reports whether content starts with a reminder trigger phrase.
@@ -50,19 +57,32 @@ func Trigger(content string) (int, bool) {
 }
 
 // ParseTime parses a time expression from the start of s.
-// s must begin with "in " (relative offset) or "at " (absolute datetime).
+// s must begin with "in " (relative offset), "at " (absolute datetime) or
+// "every " (recurring schedule, of which only the first occurrence is returned).
+// Times without an explicit zone are read in now's location.
 // Returns the resolved fire time, the remainder of s as the reminder message,
 // and any parse error.
 func ParseTime(s string, now time.Time) (time.Time, string, error) {
+	t, _, msg, err := ParseSchedule(s, now)
+	return t, msg, err
+}
+
+// ParseSchedule is ParseTime that also returns the recurrence rule for "every"
+// expressions. The rule is nil for one-shot reminders.
+func ParseSchedule(s string, now time.Time) (time.Time, *Rule, string, error) {
 	s = strings.TrimSpace(s)
 	lower := strings.ToLower(s)
 	switch {
 	case strings.HasPrefix(lower, "in "):
-		return parseOffset(s[3:], now)
+		t, msg, err := parseOffset(s[3:], now)
+		return t, nil, msg, err
 	case strings.HasPrefix(lower, "at "):
-		return parseAbsolute(s[3:], now)
+		t, msg, err := parseAbsolute(s[3:], now)
+		return t, nil, msg, err
+	case strings.HasPrefix(lower, "every "):
+		return parseRecurring(s[6:], now)
 	default:
-		return time.Time{}, s, fmt.Errorf("time expression must start with 'in' or 'at'")
+		return time.Time{}, nil, s, fmt.Errorf("time expression must start with 'in', 'at' or 'every'")
 	}
 }
 
@@ -131,14 +151,10 @@ func parseAbsolute(s string, now time.Time) (time.Time, string, error) {
 		timeStr := strings.Join(words[:numTimeWords], " ")
 
 		// Check whether the next word is a recognized timezone.
-		loc := time.UTC
+		loc := now.Location()
 		tzWords := 0
 		if numTimeWords < len(words) {
-			candidate := words[numTimeWords]
-			if l, err := time.LoadLocation(candidate); err == nil {
-				loc = l
-				tzWords = 1
-			} else if l, ok := tzAbbrevs[strings.ToUpper(candidate)]; ok {
+			if l, ok := zoneWord(words[numTimeWords], true); ok {
 				loc = l
 				tzWords = 1
 			}
@@ -171,6 +187,35 @@ func parseAbsolute(s string, now time.Time) (time.Time, string, error) {
 	return time.Time{}, s, fmt.Errorf("could not parse time from %q", s)
 }
 
+// LookupLocation resolves one of tzAbbrevs or an IANA zone name such as
+// "Europe/Berlin".
+func LookupLocation(name string) (*time.Location, bool) {
+	if name == "" || name == "Local" {
+		return nil, false
+	}
+	if zone, ok := tzAbbrevs[strings.ToUpper(name)]; ok {
+		name = zone
+	}
+	l, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, false
+	}
+	return l, true
+}
+
+// zoneWord resolves a timezone word read from a reminder message. The
+// two-letter abbreviations ("et", "pt", "ct", "mt") double as ordinary words,
+// so they only count when written in capitals right after a clock time, as in
+// "17:00 ET".
+func zoneWord(word string, afterTime bool) (*time.Location, bool) {
+	if _, ok := tzAbbrevs[strings.ToUpper(word)]; ok && len(word) == 2 {
+		if !afterTime || word != strings.ToUpper(word) {
+			return nil, false
+		}
+	}
+	return LookupLocation(word)
+}
+
 // cleanRemaining strips leading punctuation and optional "to " prefix from
 // the remainder string, which becomes the reminder message text.
 func cleanRemaining(s string) string {
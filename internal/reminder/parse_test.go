@@ -136,3 +136,52 @@ func TestParseTimeErrors(t *testing.T) {
 		t.Error("expected error for unparseable absolute time")
 	}
 }
+
+func TestParseTimeAbbreviationFollowsDST(t *testing.T) {
+	// "CET" in July means central European summer time, UTC+2.
+	got, _, err := ParseTime("at 2026-07-01 15:00 CET meeting", testNow)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := time.Date(2026, 7, 1, 13, 0, 0, 0, time.UTC)
+	if !got.Equal(want) {
+		t.Errorf("time: got %v, want %v", got.UTC(), want)
+	}
+}
+
+func TestParseTimeShortZoneNeedsCapitals(t *testing.T) {
+	// 12:00 ET in February is 17:00 UTC.
+	got, msg, err := ParseTime("at 12:00 ET standup", testNow)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := time.Date(2026, 2, 22, 17, 0, 0, 0, time.UTC); !got.Equal(want) || msg != "standup" {
+		t.Errorf("ET: got %v %q, want %v standup", got.UTC(), msg, want)
+	}
+
+	// Lowercase "pt" is a word of the message, not Pacific time.
+	got, msg, err = ParseTime("at 13:00 pt 2 of the review", testNow)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := time.Date(2026, 2, 22, 13, 0, 0, 0, time.UTC); !got.Equal(want) || msg != "pt 2 of the review" {
+		t.Errorf("pt: got %v %q, want %v with pt kept in the message", got.UTC(), msg, want)
+	}
+}
+
+func TestParseTimeDefaultsToNowLocation(t *testing.T) {
+	tokyo, ok := LookupLocation("Asia/Tokyo")
+	if !ok {
+		t.Fatal("LookupLocation(Asia/Tokyo) failed")
+	}
+
+	// 12:00 UTC is 21:00 in Tokyo, so "at 22:00" is later the same local day.
+	got, _, err := ParseTime("at 22:00 sleep", testNow.In(tokyo))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := time.Date(2026, 2, 22, 22, 0, 0, 0, tokyo)
+	if !got.Equal(want) {
+		t.Errorf("time: got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,461 @@
+package reminder
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// minRecurInterval keeps recurring reminders from flooding a channel.
+const minRecurInterval = 5 * time.Minute
+
+// intervalRe matches "2 weeks", "other day", "hour" and similar after "every".
+var intervalRe = regexp.MustCompile(
+	`(?i)^(?:(\d+)\s*|other\s+)?(years?|months?|weeks?|days?|hours?|minutes?|mins?)\b`,
+)
+
+var cronFieldRe = regexp.MustCompile(`^[\d*/,\-]+$`)
+
+var weekdayNames = map[string][]time.Weekday{
+	"sunday": {time.Sunday}, "sun": {time.Sunday},
+	"monday": {time.Monday}, "mon": {time.Monday},
+	"tuesday": {time.Tuesday}, "tue": {time.Tuesday},
+	"wednesday": {time.Wednesday}, "wed": {time.Wednesday},
+	"thursday": {time.Thursday}, "thu": {time.Thursday}, "thur": {time.Thursday},
+	"friday": {time.Friday}, "fri": {time.Friday},
+	"saturday": {time.Saturday}, "sat": {time.Saturday},
+	"weekday": {time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+	"weekend": {time.Saturday, time.Sunday},
+}
+
+// Rule describes how a recurring reminder repeats. Exactly one of the interval
+// (Every and Unit), Weekdays or Cron forms is set. Interval and weekday rules
+// keep the wall-clock time of the previous occurrence in Location.
+type Rule struct {
+	Every    int            `json:"every,omitempty"`
+	Unit     string         `json:"unit,omitempty"` // minute, hour, day, week, month or year
+	Weekdays []time.Weekday `json:"weekdays,omitempty"`
+	Cron     string         `json:"cron,omitempty"` // minute hour day-of-month month day-of-week
+	Location string         `json:"location,omitempty"`
+}
+
+// Next returns the first occurrence after both prev and now. Occurrences missed
+// while the bot was offline are skipped rather than fired in a burst.
+func (r Rule) Next(prev, now time.Time) time.Time {
+	loc := r.location()
+	prev = prev.In(loc)
+	switch {
+	case r.Cron != "":
+		spec, err := parseCron(r.Cron)
+		if err != nil {
+			return time.Time{}
+		}
+		return spec.next(latest(prev, now).In(loc))
+	case len(r.Weekdays) > 0:
+		from := latest(prev, now).In(loc)
+		y, m, d := from.Date()
+		for offset := 0; offset <= 7; offset++ {
+			t := time.Date(y, m, d+offset, prev.Hour(), prev.Minute(), prev.Second(), 0, loc)
+			if t.After(prev) && t.After(now) && slices.Contains(r.Weekdays, t.Weekday()) {
+				return t
+			}
+		}
+		return time.Time{}
+	default:
+		t := r.step(prev)
+		for !t.After(now) {
+			t = r.step(t)
+		}
+		return t
+	}
+}
+
+func (r Rule) step(t time.Time) time.Time {
+	n := max(r.Every, 1)
+	switch r.Unit {
+	case "year":
+		return t.AddDate(n, 0, 0)
+	case "month":
+		return t.AddDate(0, n, 0)
+	case "week":
+		return t.AddDate(0, 0, 7*n)
+	case "day":
+		return t.AddDate(0, 0, n)
+	case "hour":
+		return t.Add(time.Duration(n) * time.Hour)
+	default:
+		return t.Add(time.Duration(n) * time.Minute)
+	}
+}
+
+func (r Rule) location() *time.Location {
+	if r.Location == "" {
+		return time.UTC
+	}
+	if loc, ok := LookupLocation(r.Location); ok {
+		return loc
+	}
+	return time.UTC
+}
+
+// String describes the rule for reminder listings, e.g. "every 2 weeks".
+func (r Rule) String() string {
+	var desc string
+	switch {
+	case r.Cron != "":
+		desc = fmt.Sprintf("cron `%s`", r.Cron)
+	case len(r.Weekdays) > 0:
+		desc = "every " + describeWeekdays(r.Weekdays)
+	case r.Every > 1:
+		desc = fmt.Sprintf("every %d %ss", r.Every, r.Unit)
+	default:
+		desc = "every " + r.Unit
+	}
+	if r.Location != "" && r.Location != "UTC" {
+		desc += " (" + r.Location + ")"
+	}
+	return desc
+}
+
+func describeWeekdays(days []time.Weekday) string {
+	sorted := slices.Clone(days)
+	slices.Sort(sorted)
+	switch {
+	case slices.Equal(sorted, weekdayNames["weekday"]):
+		return "weekday"
+	case slices.Equal(sorted, []time.Weekday{time.Sunday, time.Saturday}):
+		return "weekend"
+	}
+	names := make([]string, len(sorted))
+	for i, d := range sorted {
+		names[i] = d.String()[:3]
+	}
+	return strings.Join(names, ", ")
+}
+
+func encodeRule(r *Rule) string {
+	if r == nil {
+		return ""
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+func decodeRule(s string) (*Rule, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var r Rule
+	if err := json.Unmarshal([]byte(s), &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// parseRecurring handles the text after "every": a cron spec
+// ("0 9 * * 1-5 Europe/Berlin standup"), weekdays ("weekday at 09:00 CET
+// standup", "mon, wed and fri at 18:00 gym") or an interval ("2 weeks at 10:00
+// payroll", "other day water the plants", "hour stretch").
+func parseRecurring(s string, now time.Time) (time.Time, *Rule, string, error) {
+	words := strings.Fields(s)
+	if len(words) == 0 {
+		return time.Time{}, nil, s, fmt.Errorf("empty recurring schedule")
+	}
+
+	if len(words) >= 5 && isCronSpec(words[:5]) {
+		rule := &Rule{Cron: strings.Join(words[:5], " ")}
+		rest := words[5:]
+		loc := now.Location()
+		if len(rest) > 0 {
+			if l, ok := zoneWord(rest[0], false); ok {
+				loc = l
+				rest = rest[1:]
+			}
+		}
+		rule.Location = loc.String()
+		first := rule.Next(now, now)
+		return finishRecurring(first, rule, strings.Join(rest, " "))
+	}
+
+	rule := &Rule{}
+	rest := words
+	if days, consumed := parseWeekdays(words); consumed > 0 {
+		rule.Weekdays = days
+		rest = words[consumed:]
+	} else {
+		joined := strings.Join(words, " ")
+		m := intervalRe.FindStringSubmatch(joined)
+		if m == nil {
+			return time.Time{}, nil, s, fmt.Errorf("could not parse recurring schedule from %q", s)
+		}
+		rule.Every = 1
+		if m[1] != "" {
+			rule.Every, _ = strconv.Atoi(m[1])
+		} else if strings.HasPrefix(strings.ToLower(m[0]), "other") {
+			rule.Every = 2
+		}
+		if rule.Every < 1 {
+			return time.Time{}, nil, s, fmt.Errorf("interval must be at least 1")
+		}
+		rule.Unit = normalizeUnit(m[2])
+		rest = strings.Fields(joined[len(m[0]):])
+	}
+
+	clock, loc, consumed, hasClock := parseClock(rest, now)
+	rest = rest[consumed:]
+	rule.Location = loc.String()
+
+	var first time.Time
+	switch {
+	case len(rule.Weekdays) > 0:
+		if !hasClock {
+			return time.Time{}, nil, s, fmt.Errorf("weekday schedules need a time, e.g. 'every monday at 09:00'")
+		}
+		first = clock
+		if !first.After(now) || !slices.Contains(rule.Weekdays, first.Weekday()) {
+			first = rule.Next(clock, now)
+		}
+	case hasClock:
+		first = clock
+	default:
+		first = rule.step(now)
+	}
+
+	return finishRecurring(first, rule, strings.Join(rest, " "))
+}
+
+func finishRecurring(first time.Time, rule *Rule, remaining string) (time.Time, *Rule, string, error) {
+	if first.IsZero() {
+		return time.Time{}, nil, remaining, fmt.Errorf("schedule never fires")
+	}
+	if second := rule.Next(first, first); second.IsZero() || second.Sub(first) < minRecurInterval {
+		return time.Time{}, nil, remaining, fmt.Errorf("recurring reminders must be at least %s apart", minRecurInterval)
+	}
+	return first, rule, cleanRemaining(remaining), nil
+}
+
+// parseWeekdays consumes leading day names such as "weekdays", "mon,wed" or
+// "tuesday and thursday". It returns the days and the number of words used.
+func parseWeekdays(words []string) ([]time.Weekday, int) {
+	var days []time.Weekday
+	consumed := 0
+	for _, word := range words {
+		ok := true
+		for piece := range strings.SplitSeq(strings.ToLower(word), ",") {
+			// Plurals ("mondays", "weekends") and "thurs"/"tues" share a stem.
+			piece = strings.TrimSuffix(piece, "s")
+			if piece == "" || piece == "and" {
+				continue
+			}
+			matched, found := weekdayNames[piece]
+			if !found {
+				ok = false
+				break
+			}
+			for _, d := range matched {
+				if !slices.Contains(days, d) {
+					days = append(days, d)
+				}
+			}
+		}
+		if !ok {
+			break
+		}
+		consumed++
+	}
+	// A trailing "and" belongs to the message, not the schedule.
+	for consumed > 0 && strings.EqualFold(words[consumed-1], "and") {
+		consumed--
+	}
+	if len(days) == 0 {
+		return nil, 0
+	}
+	return days, consumed
+}
+
+// parseClock reads an optional "at 15:04 [zone]" and returns its next
+// occurrence after now. The zone defaults to now's location.
+func parseClock(words []string, now time.Time) (time.Time, *time.Location, int, bool) {
+	if len(words) < 2 || !strings.EqualFold(words[0], "at") {
+		return time.Time{}, now.Location(), 0, false
+	}
+
+	loc := now.Location()
+	consumed := 2
+	if len(words) > 2 {
+		if l, ok := zoneWord(words[2], true); ok {
+			loc = l
+			consumed = 3
+		}
+	}
+
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		t, err := time.ParseInLocation(layout, words[1], loc)
+		if err != nil {
+			continue
+		}
+		y, mo, d := now.In(loc).Date()
+		t = time.Date(y, mo, d, t.Hour(), t.Minute(), t.Second(), 0, loc)
+		if !t.After(now) {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, loc, consumed, true
+	}
+	return time.Time{}, now.Location(), 0, false
+}
+
+func normalizeUnit(unit string) string {
+	unit = strings.ToLower(unit)
+	switch {
+	case strings.HasPrefix(unit, "y"):
+		return "year"
+	case strings.HasPrefix(unit, "mo"):
+		return "month"
+	case strings.HasPrefix(unit, "w"):
+		return "week"
+	case strings.HasPrefix(unit, "d"):
+		return "day"
+	case strings.HasPrefix(unit, "h"):
+		return "hour"
+	default:
+		return "minute"
+	}
+}
+
+func latest(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// cronSpec holds the allowed values of each cron field as bitsets.
+type cronSpec struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool
+}
+
+func isCronSpec(fields []string) bool {
+	for _, f := range fields {
+		if !cronFieldRe.MatchString(f) {
+			return false
+		}
+	}
+	_, err := parseCron(strings.Join(fields, " "))
+	return err == nil
+}
+
+func parseCron(spec string) (cronSpec, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return cronSpec{}, fmt.Errorf("cron spec needs 5 fields, got %d", len(fields))
+	}
+
+	var c cronSpec
+	var err error
+	if c.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return cronSpec{}, err
+	}
+	if c.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return cronSpec{}, err
+	}
+	if c.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return cronSpec{}, err
+	}
+	if c.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return cronSpec{}, err
+	}
+	if c.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return cronSpec{}, err
+	}
+	// 7 is an alias for Sunday.
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.domAny = fields[2] == "*"
+	c.dowAny = fields[4] == "*"
+	return c, nil
+}
+
+func parseCronField(field string, lo, hi int) (uint64, error) {
+	var bits uint64
+	for part := range strings.SplitSeq(field, ",") {
+		rangePart, step := part, 1
+		if before, after, ok := strings.Cut(part, "/"); ok {
+			n, err := strconv.Atoi(after)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid cron step %q", part)
+			}
+			rangePart, step = before, n
+		}
+
+		start, end := lo, hi
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			a, b, _ := strings.Cut(rangePart, "-")
+			var errA, errB error
+			start, errA = strconv.Atoi(a)
+			end, errB = strconv.Atoi(b)
+			if errA != nil || errB != nil {
+				return 0, fmt.Errorf("invalid cron range %q", part)
+			}
+		default:
+			n, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return 0, fmt.Errorf("invalid cron value %q", part)
+			}
+			start, end = n, n
+			if step > 1 {
+				end = hi
+			}
+		}
+		if start < lo || end > hi || start > end {
+			return 0, fmt.Errorf("cron value %q out of range %d-%d", part, lo, hi)
+		}
+		for v := start; v <= end; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+func (c cronSpec) matchesDay(t time.Time) bool {
+	if c.month&(1<<int(t.Month())) == 0 {
+		return false
+	}
+	domOK := c.dom&(1<<t.Day()) != 0
+	dowOK := c.dow&(1<<int(t.Weekday())) != 0
+	// Like cron, when both day fields are restricted either one may match.
+	if !c.domAny && !c.dowAny {
+		return domOK || dowOK
+	}
+	return domOK && dowOK
+}
+
+// next returns the first matching minute strictly after t, searching at most
+// a little over a year ahead.
+func (c cronSpec) next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(1, 0, 2)
+	for t.Before(limit) {
+		if !c.matchesDay(t) {
+			y, m, d := t.Date()
+			t = time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if c.hour&(1<<t.Hour()) != 0 && c.minute&(1<<t.Minute()) != 0 {
+			return t
+		}
+		t = t.Add(time.Minute)
+	}
+	return time.Time{}
+}
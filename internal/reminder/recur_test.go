@@ -0,0 +1,168 @@
+package reminder
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseScheduleRecurring(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("tzdata unavailable: %v", err)
+	}
+
+	// testNow is Sunday 2026-02-22 12:00 UTC.
+	tests := []struct {
+		input    string
+		wantFire time.Time
+		wantRule string
+		wantMsg  string
+		wantNext time.Time
+	}{
+		{
+			input:    "every weekday at 09:00 Europe/Berlin standup",
+			wantFire: time.Date(2026, 2, 23, 9, 0, 0, 0, berlin),
+			wantRule: "every weekday (Europe/Berlin)",
+			wantMsg:  "standup",
+			wantNext: time.Date(2026, 2, 24, 9, 0, 0, 0, berlin),
+		},
+		{
+			input:    "every 2 weeks to water the plants",
+			wantFire: testNow.AddDate(0, 0, 14),
+			wantRule: "every 2 weeks",
+			wantMsg:  "water the plants",
+			wantNext: testNow.AddDate(0, 0, 28),
+		},
+		{
+			input:    "every other day at 18:00 gym",
+			wantFire: time.Date(2026, 2, 22, 18, 0, 0, 0, time.UTC),
+			wantRule: "every 2 days",
+			wantMsg:  "gym",
+			wantNext: time.Date(2026, 2, 24, 18, 0, 0, 0, time.UTC),
+		},
+		{
+			input:    "every mon, wed and fri at 07:30 run",
+			wantFire: time.Date(2026, 2, 23, 7, 30, 0, 0, time.UTC),
+			wantRule: "every Mon, Wed, Fri",
+			wantMsg:  "run",
+			wantNext: time.Date(2026, 2, 25, 7, 30, 0, 0, time.UTC),
+		},
+		{
+			input:    "every 0 9 1 * * rent",
+			wantFire: time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC),
+			wantRule: "cron `0 9 1 * *`",
+			wantMsg:  "rent",
+			wantNext: time.Date(2026, 4, 1, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			input:    "every hour stretch",
+			wantFire: testNow.Add(time.Hour),
+			wantRule: "every hour",
+			wantMsg:  "stretch",
+			wantNext: testNow.Add(2 * time.Hour),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			fire, rule, msg, err := ParseSchedule(tt.input, testNow)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if rule == nil {
+				t.Fatal("rule is nil, want a recurrence")
+			}
+			if !fire.Equal(tt.wantFire) {
+				t.Errorf("fire: got %v, want %v", fire, tt.wantFire)
+			}
+			if got := rule.String(); got != tt.wantRule {
+				t.Errorf("rule: got %q, want %q", got, tt.wantRule)
+			}
+			if msg != tt.wantMsg {
+				t.Errorf("message: got %q, want %q", msg, tt.wantMsg)
+			}
+			if next := rule.Next(fire, fire); !next.Equal(tt.wantNext) {
+				t.Errorf("next: got %v, want %v", next, tt.wantNext)
+			}
+		})
+	}
+}
+
+func TestParseScheduleOneShotHasNoRule(t *testing.T) {
+	_, rule, _, err := ParseSchedule("in 2h check the oven", testNow)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rule != nil {
+		t.Errorf("rule: got %+v, want nil", rule)
+	}
+}
+
+func TestParseScheduleRecurringErrors(t *testing.T) {
+	for _, input := range []string{
+		"every monday standup",     // weekday rule without a time
+		"every 2 minutes spam",     // below the minimum interval
+		"every * * * * * spam",     // cron firing every minute
+		"every blue moon whatever", // not a schedule
+	} {
+		if _, _, _, err := ParseSchedule(input, testNow); err == nil {
+			t.Errorf("ParseSchedule(%q): expected error", input)
+		}
+	}
+}
+
+func TestRuleNextSkipsMissedOccurrences(t *testing.T) {
+	rule := Rule{Every: 1, Unit: "day"}
+	prev := time.Date(2026, 2, 1, 9, 0, 0, 0, time.UTC)
+
+	got := rule.Next(prev, testNow)
+	want := time.Date(2026, 2, 23, 9, 0, 0, 0, time.UTC)
+	if !got.Equal(want) {
+		t.Errorf("Next: got %v, want %v", got, want)
+	}
+}
+
+func TestRuleNextKeepsWallClockAcrossDST(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("tzdata unavailable: %v", err)
+	}
+
+	// Europe switches to summer time on 2026-03-29.
+	rule := Rule{Weekdays: []time.Weekday{time.Friday, time.Monday}, Location: "Europe/Berlin"}
+	prev := time.Date(2026, 3, 27, 9, 0, 0, 0, berlin)
+
+	got := rule.Next(prev, prev)
+	want := time.Date(2026, 3, 30, 9, 0, 0, 0, berlin)
+	if !got.Equal(want) {
+		t.Errorf("Next: got %v, want %v", got, want)
+	}
+}
+
+func TestParseCronField(t *testing.T) {
+	tests := []struct {
+		field string
+		lo    int
+		hi    int
+		want  uint64
+	}{
+		{"*", 0, 3, 0b1111},
+		{"1-3", 0, 6, 0b1110},
+		{"*/2", 0, 5, 0b010101},
+		{"1,4", 0, 6, 0b10010},
+		{"2/3", 0, 8, 0b100100100},
+	}
+	for _, tt := range tests {
+		got, err := parseCronField(tt.field, tt.lo, tt.hi)
+		if err != nil {
+			t.Errorf("parseCronField(%q): unexpected error %v", tt.field, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseCronField(%q) = %b, want %b", tt.field, got, tt.want)
+		}
+	}
+
+	if _, err := parseCronField("60", 0, 59); err == nil {
+		t.Error("parseCronField(60): expected out of range error")
+	}
+}
@@ -37,6 +37,36 @@ type Reminder struct {
 	Images    []Image
 	FireAt    int64 // Unix timestamp (seconds)
 	CreatedAt int64 // Unix timestamp (seconds)
+	Rule      *Rule // nil for one-shot reminders
+	// Fired is set once a one-shot reminder has been sent. The row is kept for
+	// a while so the snooze and done buttons on the sent message keep working.
+	Fired bool
+}
+
+const reminderColumns = "id, user_id, channel_id, guild_id, message, images, fire_at, created_at, recurrence, fired"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanReminder(row rowScanner) (Reminder, error) {
+	var r Reminder
+	var imagesJSON sql.NullString
+	var recurrence string
+	if err := row.Scan(&r.ID, &r.UserID, &r.ChannelID, &r.GuildID, &r.Message, &imagesJSON, &r.FireAt, &r.CreatedAt, &recurrence, &r.Fired); err != nil {
+		return Reminder{}, err
+	}
+	if imagesJSON.Valid && imagesJSON.String != "" {
+		if err := json.Unmarshal([]byte(imagesJSON.String), &r.Images); err != nil {
+			log.Printf("reminder: unmarshal images for id %d: %v", r.ID, err)
+		}
+	}
+	rule, err := decodeRule(recurrence)
+	if err != nil {
+		log.Printf("reminder: decode recurrence for id %d: %v", r.ID, err)
+	}
+	r.Rule = rule
+	return r, nil
 }
 
 // Init loads all pending reminders from SQLite and schedules them.
@@ -48,9 +78,9 @@ func Init(db *sql.DB, s *discordgo.Session) {
 }
 
 func loadAndSchedule() {
-	rows, err := database.Query(
-		"SELECT id, user_id, channel_id, guild_id, message, images, fire_at, created_at FROM reminders",
-	)
+	purgeFired()
+
+	rows, err := database.Query("SELECT " + reminderColumns + " FROM reminders WHERE fired = 0")
 	if err != nil {
 		log.Printf("reminder: failed to load pending reminders: %v", err)
 		return
@@ -58,17 +88,11 @@ func loadAndSchedule() {
 	defer rows.Close()
 
 	for rows.Next() {
-		var r Reminder
-		var imagesJSON sql.NullString
-		if err := rows.Scan(&r.ID, &r.UserID, &r.ChannelID, &r.GuildID, &r.Message, &imagesJSON, &r.FireAt, &r.CreatedAt); err != nil {
+		r, err := scanReminder(rows)
+		if err != nil {
 			log.Printf("reminder: scan error: %v", err)
 			continue
 		}
-		if imagesJSON.Valid && imagesJSON.String != "" {
-			if err := json.Unmarshal([]byte(imagesJSON.String), &r.Images); err != nil {
-				log.Printf("reminder: unmarshal images for id %d: %v", r.ID, err)
-			}
-		}
 		schedule(r)
 	}
 	if err := rows.Err(); err != nil {
@@ -91,11 +115,15 @@ func fire(r Reminder) {
 	mu.Unlock()
 
 	msg := fmt.Sprintf("<@%s> Reminder set <t:%d:f>: %s", r.UserID, r.CreatedAt, r.Message)
+	if r.Rule != nil {
+		msg = fmt.Sprintf("<@%s> Reminder (%s): %s", r.UserID, r.Rule, r.Message)
+	}
 
-	var sendErr error
-	if len(r.Images) == 0 {
-		_, sendErr = session.ChannelMessageSend(r.ChannelID, msg)
-	} else {
+	send := &discordgo.MessageSend{
+		Content:    msg,
+		Components: FiredComponents(r.ID),
+	}
+	if len(r.Images) > 0 {
 		files := make([]*discordgo.File, 0, len(r.Images))
 		for _, img := range r.Images {
 			data, err := base64.StdEncoding.DecodeString(img.Data)
@@ -108,22 +136,60 @@ func fire(r Reminder) {
 				Reader: bytes.NewReader(data),
 			})
 		}
-		_, sendErr = session.ChannelMessageSendComplex(r.ChannelID, &discordgo.MessageSend{
-			Content: msg,
-			Files:   files,
-		})
+		send.Files = files
 	}
-	if sendErr != nil {
+	if _, sendErr := session.ChannelMessageSendComplex(r.ChannelID, send); sendErr != nil {
 		log.Printf("reminder: failed to send reminder %d: %v", r.ID, sendErr)
 	}
 
-	if _, err := database.Exec("DELETE FROM reminders WHERE id = ?", r.ID); err != nil {
-		log.Printf("reminder: failed to delete reminder %d after firing: %v", r.ID, err)
+	if r.Rule != nil {
+		reschedule(r)
+		return
+	}
+
+	if _, err := database.Exec("UPDATE reminders SET fired = 1 WHERE id = ?", r.ID); err != nil {
+		log.Printf("reminder: failed to mark reminder %d as fired: %v", r.ID, err)
 	}
+	purgeFired()
+}
+
+// reschedule moves a recurring reminder to its next occurrence. A reminder
+// that was cancelled while firing is left deleted.
+func reschedule(r Reminder) {
+	next := r.Rule.Next(time.Unix(r.FireAt, 0), time.Now())
+	if next.IsZero() {
+		log.Printf("reminder: recurring reminder %d has no next occurrence, deleting", r.ID)
+		if _, err := database.Exec("DELETE FROM reminders WHERE id = ?", r.ID); err != nil {
+			log.Printf("reminder: failed to delete reminder %d: %v", r.ID, err)
+		}
+		return
+	}
+
+	res, err := database.Exec("UPDATE reminders SET fire_at = ? WHERE id = ?", next.Unix(), r.ID)
+	if err != nil {
+		log.Printf("reminder: failed to reschedule reminder %d: %v", r.ID, err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return
+	}
+
+	r.FireAt = next.Unix()
+	schedule(r)
 }
 
 // Add inserts a new reminder into SQLite and schedules its timer.
 func Add(userID, channelID, guildID, message string, images []Image, fireAt time.Time) error {
+	return insert(userID, channelID, guildID, message, images, fireAt, nil)
+}
+
+// AddRecurring inserts a reminder that first fires at fireAt and then repeats
+// according to rule.
+func AddRecurring(userID, channelID, guildID, message string, images []Image, fireAt time.Time, rule Rule) error {
+	return insert(userID, channelID, guildID, message, images, fireAt, &rule)
+}
+
+func insert(userID, channelID, guildID, message string, images []Image, fireAt time.Time, rule *Rule) error {
 	var imagesJSON sql.NullString
 	if len(images) > 0 {
 		b, err := json.Marshal(images)
@@ -135,8 +201,8 @@ func Add(userID, channelID, guildID, message string, images []Image, fireAt time
 
 	createdAt := time.Now().Unix()
 	result, err := database.Exec(
-		"INSERT INTO reminders (user_id, channel_id, guild_id, message, images, fire_at, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
-		userID, channelID, guildID, message, imagesJSON, fireAt.Unix(), createdAt,
+		"INSERT INTO reminders (user_id, channel_id, guild_id, message, images, fire_at, created_at, recurrence) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		userID, channelID, guildID, message, imagesJSON, fireAt.Unix(), createdAt, encodeRule(rule),
 	)
 	if err != nil {
 		return fmt.Errorf("insert reminder: %w", err)
@@ -156,18 +222,14 @@ func Add(userID, channelID, guildID, message string, images []Image, fireAt time
 		Images:    images,
 		FireAt:    fireAt.Unix(),
 		CreatedAt: createdAt,
+		Rule:      rule,
 	})
 	return nil
 }
 
 // Delete cancels and removes a reminder by ID. Returns true if a row was deleted.
 func Delete(id int64) bool {
-	mu.Lock()
-	if t, ok := timers[id]; ok {
-		t.Stop()
-		delete(timers, id)
-	}
-	mu.Unlock()
+	stopTimer(id)
 
 	res, err := database.Exec("DELETE FROM reminders WHERE id = ?", id)
 	if err != nil {
@@ -181,7 +243,7 @@ func Delete(id int64) bool {
 // GetUserReminders returns all future reminders for userID, ordered by fire time.
 func GetUserReminders(userID string) ([]Reminder, error) {
 	rows, err := database.Query(
-		"SELECT id, user_id, channel_id, guild_id, message, images, fire_at, created_at FROM reminders WHERE user_id = ? AND fire_at > ? ORDER BY fire_at ASC",
+		"SELECT "+reminderColumns+" FROM reminders WHERE user_id = ? AND fired = 0 AND fire_at > ? ORDER BY fire_at ASC",
 		userID, time.Now().Unix(),
 	)
 	if err != nil {
@@ -191,17 +253,11 @@ func GetUserReminders(userID string) ([]Reminder, error) {
 
 	var reminders []Reminder
 	for rows.Next() {
-		var r Reminder
-		var imagesJSON sql.NullString
-		if err := rows.Scan(&r.ID, &r.UserID, &r.ChannelID, &r.GuildID, &r.Message, &imagesJSON, &r.FireAt, &r.CreatedAt); err != nil {
+		r, err := scanReminder(rows)
+		if err != nil {
 			log.Printf("reminder: scan: %v", err)
 			continue
 		}
-		if imagesJSON.Valid && imagesJSON.String != "" {
-			if err := json.Unmarshal([]byte(imagesJSON.String), &r.Images); err != nil {
-				log.Printf("reminder: unmarshal images for id %d: %v", r.ID, err)
-			}
-		}
 		reminders = append(reminders, r)
 	}
 	if err := rows.Err(); err != nil {
@@ -133,3 +133,43 @@ func TestTotalActive(t *testing.T) {
 		t.Errorf("expected TotalActive to increase by 1")
 	}
 }
+
+func TestAddRecurringReschedules(t *testing.T) {
+	setupDB(t)
+
+	fireAt := time.Now().Add(1 * time.Hour).Truncate(time.Second)
+	if err := AddRecurring("user1", "chan1", "guild1", "standup", nil, fireAt, Rule{Every: 1, Unit: "day", Location: "UTC"}); err != nil {
+		t.Fatalf("AddRecurring failed: %v", err)
+	}
+
+	reminders, _ := GetUserReminders("user1")
+	if len(reminders) != 1 || reminders[0].Rule == nil {
+		t.Fatalf("expected 1 recurring reminder, got %+v", reminders)
+	}
+
+	reschedule(reminders[0])
+
+	after, _ := GetUserReminders("user1")
+	if len(after) != 1 {
+		t.Fatalf("expected reminder to survive rescheduling, got %d", len(after))
+	}
+	if want := fireAt.AddDate(0, 0, 1).Unix(); after[0].FireAt != want {
+		t.Errorf("fire_at: got %d, want %d", after[0].FireAt, want)
+	}
+}
+
+func TestRescheduleDeletedReminder(t *testing.T) {
+	setupDB(t)
+
+	fireAt := time.Now().Add(1 * time.Hour)
+	AddRecurring("user1", "chan1", "guild1", "standup", nil, fireAt, Rule{Every: 1, Unit: "day"})
+	reminders, _ := GetUserReminders("user1")
+	Delete(reminders[0].ID)
+	before := TotalActive()
+
+	reschedule(reminders[0])
+
+	if TotalActive() != before {
+		t.Error("cancelled recurring reminder was scheduled again")
+	}
+}
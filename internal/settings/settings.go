@@ -0,0 +1,294 @@
+// Package settings stores per-guild bot configuration.
+package settings
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var (
+	database *sql.DB
+
+	mu           sync.RWMutex
+	cache        = map[string]Guild{}
+	channelCache = map[string]string{}
+	snailCache   = map[string]SnailWatch{}
+)
+
+var (
+	// legacyGuildID is the server the bot was originally hardcoded to, read
+	// from LEGACY_GUILD_ID. It is seeded on first start with memory on and the
+	// channels in LEGACY_BLACKLIST blacklisted, so nothing changes there when
+	// other guilds default to memory off.
+	legacyGuildID   string
+	legacyBlacklist []string
+	// botAdmins are users who are admins in every guild, read from BOT_ADMINS
+	// as a comma-separated list. It replaces the old hardcoded admin list.
+	botAdmins []string
+)
+
+// Guild holds the settings for a single guild.
+type Guild struct {
+	GuildID             string
+	MemoryEnabled       bool
+	HashingEnabled      bool
+	BlacklistedChannels []string
+	AdminRoles          []string
+	// ChatProvider is the chat backend for the guild; empty means the default.
+	ChatProvider string
+}
+
+// Defaults returns the settings used for a guild that has never been
+// configured. Memory is off until a guild admin turns it on.
+func Defaults(guildID string) Guild {
+	return Guild{
+		GuildID:             guildID,
+		MemoryEnabled:       false,
+		HashingEnabled:      true,
+		BlacklistedChannels: []string{},
+		AdminRoles:          []string{},
+	}
+}
+
+// ChannelBlacklisted reports whether memory capture is disabled for the channel.
+func (g Guild) ChannelBlacklisted(channelID string) bool {
+	return slices.Contains(g.BlacklistedChannels, channelID)
+}
+
+// MemoryAllowed reports whether messages in the channel may be remembered.
+func (g Guild) MemoryAllowed(channelID string) bool {
+	return g.MemoryEnabled && !g.ChannelBlacklisted(channelID)
+}
+
+// Init sets the database handle, reads LEGACY_GUILD_ID, LEGACY_BLACKLIST and
+// BOT_ADMINS, and seeds the legacy guild's settings.
+func Init(db *sql.DB) {
+	database = db
+	mu.Lock()
+	cache = map[string]Guild{}
+	channelCache = map[string]string{}
+	snailCache = map[string]SnailWatch{}
+	mu.Unlock()
+
+	legacyGuildID = strings.TrimSpace(os.Getenv("LEGACY_GUILD_ID"))
+	legacyBlacklist = envIDs("LEGACY_BLACKLIST")
+	botAdmins = envIDs("BOT_ADMINS")
+
+	if legacyGuildID == "" {
+		return
+	}
+	blacklist, _ := json.Marshal(legacyBlacklist)
+	_, err := database.Exec(
+		"INSERT OR IGNORE INTO guild_settings (guild_id, memory_enabled, blacklisted_channels) VALUES (?, 1, ?)",
+		legacyGuildID, string(blacklist),
+	)
+	if err != nil {
+		log.Printf("settings: seed legacy guild: %v", err)
+	}
+}
+
+// envIDs reads a comma-separated list of IDs from the environment.
+func envIDs(key string) []string {
+	ids := []string{}
+	for _, id := range strings.Split(os.Getenv(key), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Get returns the settings for a guild, falling back to defaults when the guild
+// has no stored row or the database is unavailable.
+func Get(guildID string) Guild {
+	if guildID == "" {
+		return Guild{}
+	}
+
+	mu.RLock()
+	g, ok := cache[guildID]
+	mu.RUnlock()
+	if ok {
+		return g
+	}
+
+	g = Defaults(guildID)
+	if database == nil {
+		return g
+	}
+
+	var memoryEnabled, hashingEnabled bool
+	var blacklist, roles, provider string
+	err := database.QueryRow(
+		"SELECT memory_enabled, hashing_enabled, blacklisted_channels, admin_roles, chat_provider FROM guild_settings WHERE guild_id = ?",
+		guildID,
+	).Scan(&memoryEnabled, &hashingEnabled, &blacklist, &roles, &provider)
+	switch err {
+	case nil:
+		g.MemoryEnabled = memoryEnabled
+		g.HashingEnabled = hashingEnabled
+		g.ChatProvider = provider
+		if err := json.Unmarshal([]byte(blacklist), &g.BlacklistedChannels); err != nil {
+			log.Printf("settings: decode blacklist for %s: %v", guildID, err)
+		}
+		if err := json.Unmarshal([]byte(roles), &g.AdminRoles); err != nil {
+			log.Printf("settings: decode admin roles for %s: %v", guildID, err)
+		}
+	case sql.ErrNoRows:
+	default:
+		log.Printf("settings: load %s: %v", guildID, err)
+		return g
+	}
+
+	mu.Lock()
+	cache[guildID] = g
+	mu.Unlock()
+	return g
+}
+
+// Save persists the settings for a guild and refreshes the cache.
+func Save(g Guild) error {
+	if g.BlacklistedChannels == nil {
+		g.BlacklistedChannels = []string{}
+	}
+	if g.AdminRoles == nil {
+		g.AdminRoles = []string{}
+	}
+	blacklist, err := json.Marshal(g.BlacklistedChannels)
+	if err != nil {
+		return err
+	}
+	roles, err := json.Marshal(g.AdminRoles)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Exec(`
+		INSERT INTO guild_settings (guild_id, memory_enabled, hashing_enabled, blacklisted_channels, admin_roles, chat_provider)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(guild_id) DO UPDATE SET
+			memory_enabled = excluded.memory_enabled,
+			hashing_enabled = excluded.hashing_enabled,
+			blacklisted_channels = excluded.blacklisted_channels,
+			admin_roles = excluded.admin_roles,
+			chat_provider = excluded.chat_provider`,
+		g.GuildID, g.MemoryEnabled, g.HashingEnabled, string(blacklist), string(roles), g.ChatProvider,
+	)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	cache[g.GuildID] = g
+	mu.Unlock()
+	return nil
+}
+
+// ChatProvider returns the chat backend for a channel: the channel override if
+// one is set, otherwise the guild's choice. Empty means the default backend.
+func ChatProvider(guildID, channelID string) string {
+	if provider := channelProvider(channelID); provider != "" {
+		return provider
+	}
+	return Get(guildID).ChatProvider
+}
+
+func channelProvider(channelID string) string {
+	if channelID == "" || database == nil {
+		return ""
+	}
+
+	mu.RLock()
+	provider, ok := channelCache[channelID]
+	mu.RUnlock()
+	if ok {
+		return provider
+	}
+
+	err := database.QueryRow("SELECT chat_provider FROM channel_settings WHERE channel_id = ?", channelID).Scan(&provider)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("settings: load channel %s: %v", channelID, err)
+		return ""
+	}
+
+	mu.Lock()
+	channelCache[channelID] = provider
+	mu.Unlock()
+	return provider
+}
+
+// SetChannelProvider overrides the chat backend for one channel. An empty
+// provider removes the override so the guild setting applies again.
+func SetChannelProvider(guildID, channelID, provider string) error {
+	_, err := database.Exec(`
+		INSERT INTO channel_settings (channel_id, guild_id, chat_provider) VALUES (?, ?, ?)
+		ON CONFLICT(channel_id) DO UPDATE SET chat_provider = excluded.chat_provider`,
+		channelID, guildID, provider,
+	)
+	if err != nil {
+		return err
+	}
+	if err := pruneChannel(channelID); err != nil {
+		return err
+	}
+
+	mu.Lock()
+	channelCache[channelID] = provider
+	mu.Unlock()
+	return nil
+}
+
+// ChannelProviders returns the per-channel chat backend overrides in a guild.
+func ChannelProviders(guildID string) (map[string]string, error) {
+	rows, err := database.Query("SELECT channel_id, chat_provider FROM channel_settings WHERE guild_id = ? AND chat_provider != ''", guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	providers := map[string]string{}
+	for rows.Next() {
+		var channelID, provider string
+		if err := rows.Scan(&channelID, &provider); err != nil {
+			return nil, err
+		}
+		providers[channelID] = provider
+	}
+	return providers, rows.Err()
+}
+
+// pruneChannel drops a channel's row once none of its overrides are set.
+func pruneChannel(channelID string) error {
+	_, err := database.Exec("DELETE FROM channel_settings WHERE channel_id = ? AND chat_provider = '' AND snail_mode = ''", channelID)
+	return err
+}
+
+// IsAdmin reports whether the member may run admin commands in the guild:
+// they are listed in BOT_ADMINS, hold the Administrator permission or have
+// one of the guild's admin roles.
+func IsAdmin(guildID string, member *discordgo.Member) bool {
+	if member == nil || guildID == "" {
+		return false
+	}
+	if member.User != nil && slices.Contains(botAdmins, member.User.ID) {
+		return true
+	}
+	if member.Permissions&discordgo.PermissionAdministrator != 0 {
+		return true
+	}
+
+	g := Get(guildID)
+	for _, role := range member.Roles {
+		if slices.Contains(g.AdminRoles, role) {
+			return true
+		}
+	}
+	return false
+}
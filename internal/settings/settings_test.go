@@ -0,0 +1,213 @@
+package settings
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+
+	"voltgpt/internal/db"
+)
+
+const testLegacyGuildID = "legacy-guild"
+
+func setupDB(t *testing.T) {
+	t.Helper()
+
+	t.Setenv("LEGACY_GUILD_ID", testLegacyGuildID)
+	t.Setenv("LEGACY_BLACKLIST", "chan-1,chan-2")
+	t.Setenv("BOT_ADMINS", "owner-1, owner-2")
+	db.Open(":memory:")
+	Init(db.DB)
+
+	t.Cleanup(func() {
+		db.Close()
+		database = nil
+		mu.Lock()
+		cache = map[string]Guild{}
+		channelCache = map[string]string{}
+		snailCache = map[string]SnailWatch{}
+		mu.Unlock()
+	})
+}
+
+func TestGetDefaults(t *testing.T) {
+	setupDB(t)
+
+	g := Get("guild-1")
+	if g.MemoryEnabled || !g.HashingEnabled {
+		t.Errorf("defaults = %+v, want memory off and hashing enabled", g)
+	}
+	if len(g.BlacklistedChannels) != 0 || len(g.AdminRoles) != 0 {
+		t.Errorf("defaults = %+v, want empty lists", g)
+	}
+}
+
+func TestGetEmptyGuild(t *testing.T) {
+	setupDB(t)
+
+	g := Get("")
+	if g.MemoryEnabled || g.HashingEnabled {
+		t.Errorf("Get(\"\") = %+v, want everything disabled outside guilds", g)
+	}
+}
+
+func TestSaveRoundTrip(t *testing.T) {
+	setupDB(t)
+
+	want := Guild{
+		GuildID:             "guild-1",
+		MemoryEnabled:       false,
+		HashingEnabled:      true,
+		BlacklistedChannels: []string{"chan-1"},
+		AdminRoles:          []string{"role-1"},
+	}
+	if err := Save(want); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	mu.Lock()
+	cache = map[string]Guild{}
+	mu.Unlock()
+
+	got := Get("guild-1")
+	if got.MemoryEnabled != want.MemoryEnabled || got.HashingEnabled != want.HashingEnabled {
+		t.Errorf("Get() = %+v, want %+v", got, want)
+	}
+	if !got.ChannelBlacklisted("chan-1") {
+		t.Error("ChannelBlacklisted(chan-1) = false, want true")
+	}
+	if len(got.AdminRoles) != 1 || got.AdminRoles[0] != "role-1" {
+		t.Errorf("AdminRoles = %v, want [role-1]", got.AdminRoles)
+	}
+}
+
+func TestLegacyGuildSeeded(t *testing.T) {
+	setupDB(t)
+
+	g := Get(testLegacyGuildID)
+	if !g.MemoryEnabled {
+		t.Error("MemoryEnabled = false, want the legacy guild to keep memory on")
+	}
+	for _, id := range []string{"chan-1", "chan-2"} {
+		if g.MemoryAllowed(id) {
+			t.Errorf("MemoryAllowed(%s) = true, want false for seeded blacklist", id)
+		}
+	}
+	if !g.MemoryAllowed("some-other-channel") {
+		t.Error("MemoryAllowed(some-other-channel) = false, want true")
+	}
+}
+
+func TestLegacyGuildUnset(t *testing.T) {
+	setupDB(t)
+	t.Setenv("LEGACY_GUILD_ID", "")
+	Init(db.DB)
+
+	var count int
+	if err := database.QueryRow("SELECT COUNT(*) FROM guild_settings").Scan(&count); err != nil || count != 1 {
+		t.Errorf("guild_settings rows = %d, %v; want only the earlier seed", count, err)
+	}
+}
+
+func TestLegacySeedDoesNotOverwrite(t *testing.T) {
+	setupDB(t)
+
+	if err := Save(Guild{GuildID: testLegacyGuildID, MemoryEnabled: true}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	Init(db.DB)
+
+	if g := Get(testLegacyGuildID); len(g.BlacklistedChannels) != 0 {
+		t.Errorf("BlacklistedChannels = %v, want empty after re-init", g.BlacklistedChannels)
+	}
+}
+
+func TestIsAdmin(t *testing.T) {
+	setupDB(t)
+
+	if err := Save(Guild{GuildID: "guild-1", AdminRoles: []string{"mods"}}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		member *discordgo.Member
+		want   bool
+	}{
+		{
+			name:   "administrator permission",
+			member: &discordgo.Member{Permissions: discordgo.PermissionAdministrator},
+			want:   true,
+		},
+		{
+			name:   "admin role",
+			member: &discordgo.Member{Roles: []string{"everyone", "mods"}},
+			want:   true,
+		},
+		{
+			name:   "bot admin",
+			member: &discordgo.Member{User: &discordgo.User{ID: "owner-2"}},
+			want:   true,
+		},
+		{
+			name:   "regular member",
+			member: &discordgo.Member{User: &discordgo.User{ID: "user-1"}, Roles: []string{"everyone"}},
+			want:   false,
+		},
+		{
+			name:   "nil member",
+			member: nil,
+			want:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsAdmin("guild-1", tt.member); got != tt.want {
+				t.Errorf("IsAdmin() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChatProviderResolution(t *testing.T) {
+	setupDB(t)
+
+	if got := ChatProvider("guild-1", "chan-1"); got != "" {
+		t.Errorf("ChatProvider() = %q, want default", got)
+	}
+
+	g := Get("guild-1")
+	g.ChatProvider = "gemini"
+	if err := Save(g); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if got := ChatProvider("guild-1", "chan-1"); got != "gemini" {
+		t.Errorf("ChatProvider() = %q, want guild provider gemini", got)
+	}
+
+	if err := SetChannelProvider("guild-1", "chan-1", "openai"); err != nil {
+		t.Fatalf("SetChannelProvider() error = %v", err)
+	}
+	if got := ChatProvider("guild-1", "chan-1"); got != "openai" {
+		t.Errorf("ChatProvider() = %q, want channel override openai", got)
+	}
+	if got := ChatProvider("guild-1", "chan-2"); got != "gemini" {
+		t.Errorf("ChatProvider(chan-2) = %q, want guild provider gemini", got)
+	}
+
+	providers, err := ChannelProviders("guild-1")
+	if err != nil {
+		t.Fatalf("ChannelProviders() error = %v", err)
+	}
+	if providers["chan-1"] != "openai" || len(providers) != 1 {
+		t.Errorf("ChannelProviders() = %v, want chan-1=openai", providers)
+	}
+
+	if err := SetChannelProvider("guild-1", "chan-1", ""); err != nil {
+		t.Fatalf("SetChannelProvider(clear) error = %v", err)
+	}
+	if got := ChatProvider("guild-1", "chan-1"); got != "gemini" {
+		t.Errorf("ChatProvider() after clear = %q, want gemini", got)
+	}
+}
@@ -0,0 +1,125 @@
+package settings
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// Snail callout modes for a channel.
+const (
+	SnailOff   = ""
+	SnailReact = "react"
+	SnailReply = "reply"
+	SnailBoth  = "both"
+)
+
+// DefaultSnailThreshold is the hash distance under which a new post counts as
+// a repost when a channel doesn't set its own.
+const DefaultSnailThreshold = 8
+
+// SnailWatch is a channel's automatic repost callout setting.
+type SnailWatch struct {
+	Mode string
+	// Threshold is the largest hash distance called out; zero means
+	// DefaultSnailThreshold.
+	Threshold int
+}
+
+// Enabled reports whether new posts in the channel are checked for reposts.
+func (w SnailWatch) Enabled() bool {
+	return w.Mode != SnailOff
+}
+
+// React reports whether reposts get an emoji reaction.
+func (w SnailWatch) React() bool {
+	return w.Mode == SnailReact || w.Mode == SnailBoth
+}
+
+// Reply reports whether reposts get a reply linking the original.
+func (w SnailWatch) Reply() bool {
+	return w.Mode == SnailReply || w.Mode == SnailBoth
+}
+
+// MaxDistance returns the threshold in effect.
+func (w SnailWatch) MaxDistance() int {
+	if w.Threshold <= 0 {
+		return DefaultSnailThreshold
+	}
+	return w.Threshold
+}
+
+func (w SnailWatch) String() string {
+	if !w.Enabled() {
+		return "off"
+	}
+	return fmt.Sprintf("%s (≤%dd)", w.Mode, w.MaxDistance())
+}
+
+// SnailWatchFor returns the channel's callout setting. Channels are off unless
+// opted in.
+func SnailWatchFor(channelID string) SnailWatch {
+	if channelID == "" || database == nil {
+		return SnailWatch{}
+	}
+
+	mu.RLock()
+	w, ok := snailCache[channelID]
+	mu.RUnlock()
+	if ok {
+		return w
+	}
+
+	err := database.QueryRow("SELECT snail_mode, snail_threshold FROM channel_settings WHERE channel_id = ?", channelID).Scan(&w.Mode, &w.Threshold)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("settings: load snail watch %s: %v", channelID, err)
+		return SnailWatch{}
+	}
+
+	mu.Lock()
+	snailCache[channelID] = w
+	mu.Unlock()
+	return w
+}
+
+// SetSnailWatch stores the channel's callout setting.
+func SetSnailWatch(guildID, channelID string, w SnailWatch) error {
+	_, err := database.Exec(`
+		INSERT INTO channel_settings (channel_id, guild_id, snail_mode, snail_threshold) VALUES (?, ?, ?, ?)
+		ON CONFLICT(channel_id) DO UPDATE SET
+			snail_mode = excluded.snail_mode,
+			snail_threshold = excluded.snail_threshold`,
+		channelID, guildID, w.Mode, w.Threshold,
+	)
+	if err != nil {
+		return err
+	}
+	if err := pruneChannel(channelID); err != nil {
+		return err
+	}
+
+	mu.Lock()
+	snailCache[channelID] = w
+	mu.Unlock()
+	return nil
+}
+
+// SnailWatches returns the channels in a guild with callouts enabled.
+func SnailWatches(guildID string) (map[string]SnailWatch, error) {
+	rows, err := database.Query("SELECT channel_id, snail_mode, snail_threshold FROM channel_settings WHERE guild_id = ? AND snail_mode != ''", guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	watches := map[string]SnailWatch{}
+	for rows.Next() {
+		var channelID string
+		var w SnailWatch
+		if err := rows.Scan(&channelID, &w.Mode, &w.Threshold); err != nil {
+			return nil, err
+		}
+		watches[channelID] = w
+	}
+	return watches, rows.Err()
+}
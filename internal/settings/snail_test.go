@@ -0,0 +1,85 @@
+package settings
+
+import "testing"
+
+func TestSnailWatchDefaults(t *testing.T) {
+	var w SnailWatch
+	if w.Enabled() || w.React() || w.Reply() {
+		t.Errorf("zero SnailWatch = %+v, want callouts off", w)
+	}
+	if w.MaxDistance() != DefaultSnailThreshold {
+		t.Errorf("MaxDistance() = %d, want default %d", w.MaxDistance(), DefaultSnailThreshold)
+	}
+
+	both := SnailWatch{Mode: SnailBoth, Threshold: 5}
+	if !both.React() || !both.Reply() || both.MaxDistance() != 5 {
+		t.Errorf("SnailWatch %+v should react, reply and use its own threshold", both)
+	}
+	if got := both.String(); got != "both (≤5d)" {
+		t.Errorf("String() = %q, want %q", got, "both (≤5d)")
+	}
+}
+
+func TestSetSnailWatch(t *testing.T) {
+	setupDB(t)
+
+	if w := SnailWatchFor("chan-1"); w.Enabled() {
+		t.Errorf("SnailWatchFor() = %+v, want channels off by default", w)
+	}
+
+	if err := SetSnailWatch("guild-1", "chan-1", SnailWatch{Mode: SnailReact, Threshold: 5}); err != nil {
+		t.Fatalf("SetSnailWatch() error = %v", err)
+	}
+	if w := SnailWatchFor("chan-1"); w.Mode != SnailReact || w.Threshold != 5 {
+		t.Errorf("SnailWatchFor() = %+v, want react with threshold 5", w)
+	}
+
+	// A cold cache reads the stored row.
+	mu.Lock()
+	snailCache = map[string]SnailWatch{}
+	mu.Unlock()
+	if w := SnailWatchFor("chan-1"); w.Mode != SnailReact || w.Threshold != 5 {
+		t.Errorf("SnailWatchFor() from DB = %+v, want react with threshold 5", w)
+	}
+
+	watches, err := SnailWatches("guild-1")
+	if err != nil {
+		t.Fatalf("SnailWatches() error = %v", err)
+	}
+	if len(watches) != 1 || watches["chan-1"].Mode != SnailReact {
+		t.Errorf("SnailWatches() = %v, want chan-1 reacting", watches)
+	}
+}
+
+func TestChannelOverridesShareRow(t *testing.T) {
+	setupDB(t)
+
+	if err := SetSnailWatch("guild-1", "chan-1", SnailWatch{Mode: SnailReply}); err != nil {
+		t.Fatalf("SetSnailWatch() error = %v", err)
+	}
+	if err := SetChannelProvider("guild-1", "chan-1", "openai"); err != nil {
+		t.Fatalf("SetChannelProvider() error = %v", err)
+	}
+	// Clearing the provider must not drop the snail setting.
+	if err := SetChannelProvider("guild-1", "chan-1", ""); err != nil {
+		t.Fatalf("SetChannelProvider(clear) error = %v", err)
+	}
+	if providers, _ := ChannelProviders("guild-1"); len(providers) != 0 {
+		t.Errorf("ChannelProviders() = %v, want none after clearing", providers)
+	}
+	mu.Lock()
+	snailCache = map[string]SnailWatch{}
+	mu.Unlock()
+	if w := SnailWatchFor("chan-1"); w.Mode != SnailReply {
+		t.Errorf("SnailWatchFor() after clearing provider = %+v, want reply", w)
+	}
+
+	if err := SetSnailWatch("guild-1", "chan-1", SnailWatch{}); err != nil {
+		t.Fatalf("SetSnailWatch(off) error = %v", err)
+	}
+	var n int
+	database.QueryRow("SELECT COUNT(*) FROM channel_settings").Scan(&n)
+	if n != 0 {
+		t.Errorf("channel_settings rows = %d, want the empty row pruned", n)
+	}
+}
@@ -4,18 +4,11 @@ import (
 	"fmt"
 	"log"
 	"regexp"
-	"slices"
 	"strings"
 
 	"github.com/bwmarrin/discordgo"
-
-	"voltgpt/internal/config"
 )
 
-func IsAdmin(id string) bool {
-	return slices.Contains(config.Admins, id)
-}
-
 func LinkFromIMessage(guildID string, m *discordgo.Message) string {
 	return fmt.Sprintf("https://discord.com/channels/%s/%s/%s", guildID, m.ChannelID, m.ID)
 }
@@ -145,7 +138,7 @@ func GetMessageMediaURL(m *discordgo.Message) (images []string, videos []string,
 		if IsPDFURL(u) {
 			addIfNotSeen(u, &pdfs)
 		}
-		if IsYTURL(u) {
+		if IsYTURL(u) || MatchYTDLPWebsites(u) {
 			addIfNotSeen(u, &ytURLs)
 		}
 	}
@@ -8,48 +8,8 @@ import (
 	"github.com/ewohltman/discordgo-mock/mocksession"
 	"github.com/ewohltman/discordgo-mock/mockstate"
 	"github.com/ewohltman/discordgo-mock/mockuser"
-
-	"voltgpt/internal/config"
 )
 
-func TestIsAdmin(t *testing.T) {
-	tests := []struct {
-		name string
-		id   string
-		want bool
-	}{
-		{
-			name: "first admin",
-			id:   config.Admins[0],
-			want: true,
-		},
-		{
-			name: "second admin",
-			id:   config.Admins[1],
-			want: true,
-		},
-		{
-			name: "not an admin",
-			id:   "9999999999999999999",
-			want: false,
-		},
-		{
-			name: "empty id",
-			id:   "",
-			want: false,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := IsAdmin(tt.id)
-			if got != tt.want {
-				t.Errorf("IsAdmin(%q) = %v, want %v", tt.id, got, tt.want)
-			}
-		})
-	}
-}
-
 func TestLinkFromIMessage(t *testing.T) {
 	m := &discordgo.Message{
 		ID:        "111",
@@ -274,6 +234,16 @@ func TestGetMessageMediaURL(t *testing.T) {
 		}
 	})
 
+	t.Run("yt-dlp supported URL in content", func(t *testing.T) {
+		m := &discordgo.Message{
+			Content: "watch https://vimeo.com/76979871",
+		}
+		_, _, _, ytURLs := GetMessageMediaURL(m)
+		if len(ytURLs) != 1 {
+			t.Errorf("expected 1 yt-dlp URL, got %v", ytURLs)
+		}
+	})
+
 	t.Run("tenor embed thumbnail excluded", func(t *testing.T) {
 		m := &discordgo.Message{
 			Embeds: []*discordgo.MessageEmbed{
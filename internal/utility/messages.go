@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"log"
 	"strings"
-	"time"
 
 	"github.com/bwmarrin/discordgo"
 
@@ -213,81 +212,26 @@ func GetChannelMessages(s *discordgo.Session, channelID string, count int) []*di
 	return messages
 }
 
-func GetAllServerMessages(s *discordgo.Session, statusMessage *discordgo.Message, channels []*discordgo.Channel, threads bool, endDate time.Time, c chan []*discordgo.Message) {
-	defer close(c)
-
+// WithThreads returns channels followed by each one's active and archived
+// threads.
+func WithThreads(s *discordgo.Session, channels []*discordgo.Channel) []*discordgo.Channel {
 	var channelCollection []*discordgo.Channel
-
-	if threads {
-		discord.EditMessage(s, statusMessage, fmt.Sprintf("Fetching threads for %d channels...", len(channels)))
-		for _, channel := range channels {
-			activeThreads, err := s.ThreadsActive(channel.ID)
-			if err != nil {
-				log.Printf("Error getting active threads for channel %s: %s\n", channel.Name, err)
-			}
-			archivedThreads, err := s.ThreadsArchived(channel.ID, nil, 0)
-			if err != nil {
-				log.Printf("Error getting archived threads for channel %s: %s\n", channel.Name, err)
-			}
-			channelCollection = append(channelCollection, channel)
-			channelCollection = append(channelCollection, activeThreads.Threads...)
-			channelCollection = append(channelCollection, archivedThreads.Threads...)
-		}
-	} else {
-		channelCollection = channels
-	}
-
-	for _, channel := range channelCollection {
-		var outputMessage string
-		var err error
-		if channel.IsThread() {
-			outputMessage = fmt.Sprintf("Fetching messages for thread: <#%s>", channel.ID)
-		} else {
-			outputMessage = fmt.Sprintf("Fetching messages for channel: <#%s>", channel.ID)
-		}
-		if len(statusMessage.Content) > 1800 {
-			statusMessage, err = discord.SendMessage(s, statusMessage, outputMessage)
-		} else {
-			statusMessage, err = discord.EditMessage(s, statusMessage, fmt.Sprintf("%s\n%s", statusMessage.Content, outputMessage))
-		}
+	for _, channel := range channels {
+		channelCollection = append(channelCollection, channel)
+		activeThreads, err := s.ThreadsActive(channel.ID)
 		if err != nil {
-			log.Println(err)
-			return
-		}
-
-		messagesRetrieved := 100
-		count := 0
-		lastMessage := &discordgo.Message{
-			ID: "",
-		}
-		for messagesRetrieved == 100 {
-			batch, err := GetMessagesBefore(s, channel.ID, 100, lastMessage.ID)
-			if err != nil {
-				log.Printf("Error getting messages: %s\nClosest message: %s", err, lastMessage.Timestamp.String())
-			}
-			if len(batch) == 0 || batch == nil {
-				log.Println("getAllChannelMessages: no messages retrieved")
-				break
-			}
-			if batch[0].Timestamp.Before(endDate) {
-				break
-			}
-			lastMessage = batch[len(batch)-1]
-			messagesRetrieved = len(batch)
-			count += messagesRetrieved
-			_, err = discord.EditMessage(s, statusMessage, fmt.Sprintf("%s\n- Retrieved %d messages", statusMessage.Content, count))
-			if err != nil {
-				log.Println(err)
-			}
-			c <- batch
+			log.Printf("Error getting active threads for channel %s: %s\n", channel.Name, err)
+		} else {
+			channelCollection = append(channelCollection, activeThreads.Threads...)
 		}
-		statusMessage, err = discord.EditMessage(s, statusMessage, fmt.Sprintf("%s\n- Retrieved %d messages", statusMessage.Content, count))
+		archivedThreads, err := s.ThreadsArchived(channel.ID, nil, 0)
 		if err != nil {
-			log.Println(err)
+			log.Printf("Error getting archived threads for channel %s: %s\n", channel.Name, err)
+		} else {
+			channelCollection = append(channelCollection, archivedThreads.Threads...)
 		}
 	}
-
-	log.Println("getAllChannelThreadMessages: done")
+	return channelCollection
 }
 
 func GetReferencedMessage(s *discordgo.Session, message *discordgo.Message, cache []*discordgo.Message) *discordgo.Message {
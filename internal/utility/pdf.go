@@ -0,0 +1,241 @@
+package utility
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PDF extraction shells out to poppler-utils (pdfinfo, pdftotext, pdftoppm).
+const (
+	MaxPDFBytes       = 20 << 20 // refuse documents larger than this
+	MaxPDFPages       = 30       // pages of text extracted per document
+	MaxPDFImagePages  = 4        // scanned pages rendered to images per document
+	MaxPDFTextChars   = 60000    // extracted text is cut off after this many characters
+	MaxPDFsPerMessage = 3        // documents read from a single message
+
+	// maxCachedPDFs is how many extracted documents are kept in memory, so a
+	// reply chain doesn't run poppler on the same attachments every turn.
+	maxCachedPDFs = 16
+
+	// minPageTextChars is the amount of text below which a page is treated as
+	// scanned and rendered to an image instead.
+	minPageTextChars = 40
+	pdfRenderDPI     = 110
+	pdfToolTimeout   = 30 * time.Second
+)
+
+var pdfPagesRe = regexp.MustCompile(`(?m)^Pages:\s+(\d+)`)
+
+// PDFContent is the locally extracted content of a PDF document.
+type PDFContent struct {
+	Name      string
+	Pages     int      // total pages in the document
+	Text      string   // extracted text with page markers
+	Images    []string // base64 PNGs of pages without a text layer
+	Truncated bool     // true when page, image or text caps cut content
+}
+
+// pdfCache holds extracted documents by the SHA-256 of their bytes, oldest
+// first in order.
+var pdfCache = struct {
+	sync.Mutex
+	entries map[string]PDFContent
+	order   []string
+}{entries: map[string]PDFContent{}}
+
+// PDFToContent downloads a PDF and extracts its text and scanned pages.
+// Documents extracted recently are served from memory.
+func PDFToContent(urlStr string) (PDFContent, error) {
+	data, err := DownloadBytesLimit(urlStr, MaxPDFBytes)
+	if err != nil {
+		return PDFContent{}, err
+	}
+
+	sum := sha256.Sum256(data)
+	key := hex.EncodeToString(sum[:])
+	pdfCache.Lock()
+	content, ok := pdfCache.entries[key]
+	pdfCache.Unlock()
+	if !ok {
+		if content, err = ExtractPDF(data); err != nil {
+			return PDFContent{}, err
+		}
+		cachePDF(key, content)
+	}
+	content.Name = pdfName(urlStr)
+	return content, nil
+}
+
+func cachePDF(key string, content PDFContent) {
+	pdfCache.Lock()
+	defer pdfCache.Unlock()
+	if _, ok := pdfCache.entries[key]; ok {
+		return
+	}
+	if len(pdfCache.order) >= maxCachedPDFs {
+		delete(pdfCache.entries, pdfCache.order[0])
+		pdfCache.order = pdfCache.order[1:]
+	}
+	pdfCache.entries[key] = content
+	pdfCache.order = append(pdfCache.order, key)
+}
+
+// ExtractPDF extracts text from up to MaxPDFPages pages and renders up to
+// MaxPDFImagePages pages that have no usable text layer.
+func ExtractPDF(data []byte) (PDFContent, error) {
+	if len(data) > MaxPDFBytes {
+		return PDFContent{}, fmt.Errorf("pdf is %d bytes, limit is %d", len(data), MaxPDFBytes)
+	}
+
+	dir, err := os.MkdirTemp("", "pdf_*")
+	if err != nil {
+		return PDFContent{}, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	pdfPath := filepath.Join(dir, "input.pdf")
+	if err := os.WriteFile(pdfPath, data, 0o600); err != nil {
+		return PDFContent{}, fmt.Errorf("failed to write pdf data: %w", err)
+	}
+
+	info, err := runPDFTool("pdfinfo", pdfPath)
+	if err != nil {
+		return PDFContent{}, err
+	}
+	pages, err := parsePDFPages(info)
+	if err != nil {
+		return PDFContent{}, err
+	}
+
+	content := PDFContent{Pages: pages}
+	lastPage := min(pages, MaxPDFPages)
+	if pages > lastPage {
+		content.Truncated = true
+	}
+
+	text, err := runPDFTool("pdftotext", "-f", "1", "-l", strconv.Itoa(lastPage), "-layout", "-enc", "UTF-8", pdfPath, "-")
+	if err != nil {
+		return PDFContent{}, err
+	}
+
+	// pdftotext separates pages with form feeds.
+	pageTexts := strings.Split(string(text), "\f")
+	var sb strings.Builder
+	for i := range lastPage {
+		pageText := ""
+		if i < len(pageTexts) {
+			pageText = strings.TrimSpace(pageTexts[i])
+		}
+
+		if len([]rune(pageText)) < minPageTextChars {
+			if len(content.Images) >= MaxPDFImagePages {
+				content.Truncated = true
+				continue
+			}
+			img, err := renderPDFPage(pdfPath, dir, i+1)
+			if err != nil {
+				log.Printf("pdf: failed to render page %d: %v", i+1, err)
+				continue
+			}
+			content.Images = append(content.Images, img)
+			if pageText == "" {
+				continue
+			}
+		}
+
+		fmt.Fprintf(&sb, "[page %d]\n%s\n\n", i+1, pageText)
+	}
+
+	content.Text = strings.TrimSpace(sb.String())
+	if runes := []rune(content.Text); len(runes) > MaxPDFTextChars {
+		content.Text = string(runes[:MaxPDFTextChars])
+		content.Truncated = true
+	}
+
+	return content, nil
+}
+
+// PromptText renders the extracted text for inclusion in a model prompt.
+func (c PDFContent) PromptText() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<pdf name=\"%s\" pages=\"%d\"", c.Name, c.Pages)
+	if c.Truncated {
+		sb.WriteString(" truncated=\"true\"")
+	}
+	sb.WriteString(">\n")
+	if c.Text != "" {
+		sb.WriteString(c.Text + "\n")
+	}
+	if len(c.Images) > 0 {
+		fmt.Fprintf(&sb, "(%d page(s) without a text layer attached as images)\n", len(c.Images))
+	}
+	sb.WriteString("</pdf>")
+	return sb.String()
+}
+
+func parsePDFPages(info []byte) (int, error) {
+	matches := pdfPagesRe.FindSubmatch(info)
+	if len(matches) < 2 {
+		return 0, fmt.Errorf("could not parse page count from pdfinfo output")
+	}
+	pages, err := strconv.Atoi(string(matches[1]))
+	if err != nil || pages < 1 {
+		return 0, fmt.Errorf("invalid page count %q", matches[1])
+	}
+	return pages, nil
+}
+
+func renderPDFPage(pdfPath, dir string, page int) (string, error) {
+	root := filepath.Join(dir, fmt.Sprintf("page-%d", page))
+	p := strconv.Itoa(page)
+	if _, err := runPDFTool("pdftoppm", "-f", p, "-l", p, "-r", strconv.Itoa(pdfRenderDPI), "-png", "-singlefile", pdfPath, root); err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(root + ".png")
+	if err != nil {
+		return "", fmt.Errorf("failed to read rendered page: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+func runPDFTool(name string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), pdfToolTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %w: %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+func pdfName(urlStr string) string {
+	parsed, err := url.Parse(urlStr)
+	if err != nil {
+		return "document.pdf"
+	}
+	name := path.Base(parsed.Path)
+	if name == "." || name == "/" {
+		return "document.pdf"
+	}
+	return name
+}
@@ -0,0 +1,157 @@
+package utility
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// minimalPDF builds a single-page PDF whose page shows text.
+func minimalPDF(text string) []byte {
+	stream := fmt.Sprintf("BT /F1 24 Tf 72 720 Td (%s) Tj ET", text)
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(stream), stream),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+func requirePoppler(t *testing.T) {
+	t.Helper()
+	for _, tool := range []string{"pdfinfo", "pdftotext", "pdftoppm"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not installed, skipping: %v", tool, err)
+		}
+	}
+}
+
+func TestExtractPDFText(t *testing.T) {
+	requirePoppler(t)
+
+	content, err := ExtractPDF(minimalPDF("Quarterly receipt total is forty two dollars and ten cents"))
+	if err != nil {
+		t.Fatalf("ExtractPDF() error: %v", err)
+	}
+	if content.Pages != 1 {
+		t.Errorf("Pages = %d, want 1", content.Pages)
+	}
+	if !strings.Contains(content.Text, "forty two dollars") {
+		t.Errorf("Text = %q, want extracted page text", content.Text)
+	}
+	if len(content.Images) != 0 {
+		t.Errorf("Images = %d, want 0 for a page with a text layer", len(content.Images))
+	}
+}
+
+func TestExtractPDFRendersSparsePages(t *testing.T) {
+	requirePoppler(t)
+
+	content, err := ExtractPDF(minimalPDF("Hi"))
+	if err != nil {
+		t.Fatalf("ExtractPDF() error: %v", err)
+	}
+	if len(content.Images) != 1 {
+		t.Fatalf("Images = %d, want 1 rendered page for sparse text", len(content.Images))
+	}
+}
+
+func TestExtractPDFTooLarge(t *testing.T) {
+	_, err := ExtractPDF(make([]byte, MaxPDFBytes+1))
+	if err == nil {
+		t.Fatal("ExtractPDF() error = nil, want size limit error")
+	}
+}
+
+func TestParsePDFPages(t *testing.T) {
+	pages, err := parsePDFPages([]byte("Producer:       test\nPages:          12\nEncrypted:      no\n"))
+	if err != nil || pages != 12 {
+		t.Errorf("parsePDFPages() = %d, %v; want 12, nil", pages, err)
+	}
+
+	if _, err := parsePDFPages([]byte("Producer: test\n")); err == nil {
+		t.Error("parsePDFPages() error = nil, want error without Pages line")
+	}
+}
+
+func TestPDFName(t *testing.T) {
+	tests := map[string]string{
+		"https://cdn.discordapp.com/attachments/1/2/paper.pdf?ex=abc": "paper.pdf",
+		"https://example.com/": "document.pdf",
+	}
+	for input, want := range tests {
+		if got := pdfName(input); got != want {
+			t.Errorf("pdfName(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestPDFToContentCachesByContent(t *testing.T) {
+	body := []byte("not really a pdf")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	// The body isn't a PDF poppler could read, so only the cache can answer.
+	sum := sha256.Sum256(body)
+	cachePDF(hex.EncodeToString(sum[:]), PDFContent{Pages: 2, Text: "[page 1]\ncached"})
+
+	content, err := PDFToContent(srv.URL + "/other-name.pdf")
+	if err != nil || content.Text != "[page 1]\ncached" || content.Name != "other-name.pdf" {
+		t.Errorf("PDFToContent() = %+v, %v; want the cached text under the new name", content, err)
+	}
+
+	for i := range maxCachedPDFs {
+		cachePDF(fmt.Sprint(i), PDFContent{})
+	}
+	if len(pdfCache.entries) != maxCachedPDFs {
+		t.Errorf("cached documents = %d, want at most %d", len(pdfCache.entries), maxCachedPDFs)
+	}
+}
+
+func TestPDFContentPromptText(t *testing.T) {
+	got := PDFContent{Name: "a.pdf", Pages: 3, Text: "[page 1]\nhello", Images: []string{"x"}, Truncated: true}.PromptText()
+	for _, want := range []string{`<pdf name="a.pdf" pages="3" truncated="true">`, "[page 1]\nhello", "1 page(s) without a text layer", "</pdf>"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("PromptText() missing %q in:\n%s", want, got)
+		}
+	}
+}
+
+func TestDownloadBytesLimit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(bytes.Repeat([]byte("a"), 100))
+	}))
+	defer srv.Close()
+
+	data, err := DownloadBytesLimit(srv.URL, 100)
+	if err != nil || len(data) != 100 {
+		t.Fatalf("DownloadBytesLimit(100) = %d bytes, %v; want 100, nil", len(data), err)
+	}
+	if _, err := DownloadBytesLimit(srv.URL, 99); err == nil {
+		t.Error("DownloadBytesLimit(99) error = nil, want limit error")
+	}
+}
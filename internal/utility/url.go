@@ -1,27 +1,22 @@
 package utility
 
 import (
-	"fmt"
-	"io"
-	"net/http"
 	"net/url"
 	"path/filepath"
 	"regexp"
 	"strings"
+
+	"voltgpt/internal/mediacache"
 )
 
+// DownloadBytes fetches url through the shared media cache.
 func DownloadBytes(url string) ([]byte, error) {
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("bad status: %s", resp.Status)
-	}
+	return mediacache.Fetch(url, 0)
+}
 
-	return io.ReadAll(resp.Body)
+// DownloadBytesLimit is DownloadBytes with a cap on the response size.
+func DownloadBytesLimit(url string, limit int64) ([]byte, error) {
+	return mediacache.Fetch(url, limit)
 }
 
 func URLToExt(urlStr string) (string, error) {
@@ -136,16 +131,35 @@ func HasExtension(URL string, extensions []string) bool {
 	return false
 }
 
+// ytdlpWebsites matches links to video pages on sites yt-dlp can download,
+// other than YouTube which IsYTURL covers. yt-dlp supports far more sites, but
+// only paths that always point at a video are listed so ordinary links (text
+// posts, profiles, articles) are not sent through it.
+var ytdlpWebsites = []*regexp.Regexp{
+	// vimeo
+	regexp.MustCompile(`^((?:https?:)?\/\/)?((?:www|m)\.)?((?:vimeo\.com))(\/)([\w\-]+)(\S+)?$`),
+	// twitch clips and past broadcasts
+	regexp.MustCompile(`^(?:https?://)?(?:clips\.twitch\.tv/[\w-]+|(?:www\.|m\.)?twitch\.tv/(?:videos/\d+|[\w-]+/clip/[\w-]+))`),
+	// tiktok
+	regexp.MustCompile(`^(?:https?://)?(?:(?:www|m)\.tiktok\.com/@[\w.-]+/video/\d+|(?:vm|vt)\.tiktok\.com/\w+)`),
+	// instagram reels
+	regexp.MustCompile(`^(?:https?://)?(?:www\.)?instagram\.com/reels?/[\w-]+`),
+	// streamable
+	regexp.MustCompile(`^(?:https?://)?(?:www\.)?streamable\.com/\w+`),
+	// dailymotion
+	regexp.MustCompile(`^(?:https?://)?(?:(?:www\.)?dailymotion\.com/video/|dai\.ly/)\w+`),
+	// reddit hosted video
+	regexp.MustCompile(`^(?:https?://)?v\.redd\.it/\w+`),
+	// bilibili
+	regexp.MustCompile(`^(?:https?://)?(?:(?:www|m)\.)?bilibili\.com/video/\w+`),
+}
+
 func MatchYTDLPWebsites(urlStr string) bool {
 	if urlStr == "" {
 		return false
 	}
-	// vimeo
-	urlRegexes := []*regexp.Regexp{
-		regexp.MustCompile(`^((?:https?:)?\/\/)?((?:www|m)\.)?((?:vimeo\.com))(\/)([\w\-]+)(\S+)?$`),
-	}
 
-	for _, r := range urlRegexes {
+	for _, r := range ytdlpWebsites {
 		if r.MatchString(urlStr) {
 			return true
 		}
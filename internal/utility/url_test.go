@@ -271,6 +271,31 @@ func TestMatchYTDLPWebsites(t *testing.T) {
 			urlStr: "https://www.vimeo.com/123456789",
 			want:   true,
 		},
+		{
+			name:   "twitch clip",
+			urlStr: "https://clips.twitch.tv/FunnyClipName-abc123",
+			want:   true,
+		},
+		{
+			name:   "tiktok video",
+			urlStr: "https://www.tiktok.com/@someone/video/7234567890123456789",
+			want:   true,
+		},
+		{
+			name:   "streamable",
+			urlStr: "https://streamable.com/abc12",
+			want:   true,
+		},
+		{
+			name:   "reddit video",
+			urlStr: "https://v.redd.it/abcdef123",
+			want:   true,
+		},
+		{
+			name:   "tiktok profile not matched",
+			urlStr: "https://www.tiktok.com/@someone",
+			want:   false,
+		},
 		{
 			name:   "youtube not matched",
 			urlStr: "https://youtube.com/watch?v=abc",
@@ -215,3 +215,44 @@ func extractVideoFrameAtTime(videoPath string, timestamp float64) (io.Reader, er
 
 	return outBuf, nil
 }
+
+// ExtractKeyframes returns count evenly spaced frames from a local video file as
+// base64 JPEGs together with the video duration in seconds.
+func ExtractKeyframes(videoPath string, count int) ([]string, float64, error) {
+	duration, err := getVideoDuration(videoPath)
+	if err != nil {
+		return nil, 0, err
+	}
+	if count < 1 {
+		count = 1
+	}
+
+	var frames []string
+	for _, ts := range keyframeTimestamps(duration, count) {
+		frame, err := extractVideoFrameAtTime(videoPath, ts)
+		if err != nil {
+			log.Printf("Failed to extract keyframe at %.2fs: %v", ts, err)
+			continue
+		}
+		data, err := io.ReadAll(frame)
+		if err != nil {
+			continue
+		}
+		frames = append(frames, base64.StdEncoding.EncodeToString(data))
+	}
+
+	if len(frames) == 0 {
+		return nil, duration, fmt.Errorf("failed to extract any keyframes from video")
+	}
+	return frames, duration, nil
+}
+
+// keyframeTimestamps spaces count timestamps across the video, skipping the
+// very start and end where intros and end cards usually sit.
+func keyframeTimestamps(duration float64, count int) []float64 {
+	timestamps := make([]float64, count)
+	for i := range count {
+		timestamps[i] = duration * (float64(i) + 0.5) / float64(count)
+	}
+	return timestamps
+}
@@ -112,3 +112,16 @@ func TestVideoToBase64ImagesFromServer(t *testing.T) {
 		t.Fatal("VideoToBase64Images() returned no results")
 	}
 }
+
+func TestKeyframeTimestamps(t *testing.T) {
+	got := keyframeTimestamps(100, 4)
+	want := []float64{12.5, 37.5, 62.5, 87.5}
+	if len(got) != len(want) {
+		t.Fatalf("keyframeTimestamps() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("keyframeTimestamps()[%d] = %f, want %f", i, got[i], want[i])
+		}
+	}
+}
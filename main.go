@@ -1,4 +1,8 @@
 // Package main is the entry point for the application.
+//
+// FTS5 is optional. Building with -tags sqlite_fts5 gives memory search a
+// full-text index over notes; a plain go build works too and matches note
+// words with LIKE instead.
 package main
 
 import (
@@ -18,26 +22,48 @@ import (
 	"voltgpt/internal/gamble"
 	"voltgpt/internal/handler"
 	"voltgpt/internal/hasher"
+	"voltgpt/internal/media"
+	"voltgpt/internal/mediacache"
 	"voltgpt/internal/memory"
+	"voltgpt/internal/permissions"
 	"voltgpt/internal/reminder"
+	"voltgpt/internal/settings"
 )
 
-func init() {
+// setup loads the environment and opens the database and every subsystem the
+// bot needs.
+func setup() {
 	if err := godotenv.Load(); err != nil {
 		log.Print("No .env file found")
 	}
 
 	db.Open("voltgpt.db")
 
+	settings.Init(db.DB)
+	permissions.Init(db.DB)
 	hasher.Init(db.DB)
 	gamble.Init(db.DB)
+	media.Init(db.DB)
+	if err := mediacache.Init(db.DB, mediacache.ConfigFromEnv()); err != nil {
+		log.Printf("Warning: media cache disabled: %v", err)
+	}
 	memory.Init(db.DB)
+	handler.RegisterChatTools()
 	if _, err := openaiapi.GetClient(); err != nil {
 		log.Printf("Warning: OpenAI client init failed: %v", err)
 	}
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "hashes" {
+		if err := runHashes(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	setup()
+
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
 	defer stop()
 
@@ -56,6 +82,10 @@ func main() {
 	dg.ShouldReconnectOnError = true
 	dg.ShouldRetryOnRateLimit = true
 
+	// Resolving reloads the hash store, so it has to finish before messages
+	// and hash jobs start writing to it.
+	hasher.ResolveGuilds(dg)
+
 	dg.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
 		switch i.Type {
 		case discordgo.InteractionApplicationCommand:
@@ -81,10 +111,12 @@ func main() {
 
 	dg.AddHandler(func(s *discordgo.Session, _ *discordgo.Ready) {
 		log.Printf("Logged in as: %v#%v", s.State.User.Username, s.State.User.Discriminator)
-		log.Printf("Hashes: %d", hasher.TotalHashes())
+		log.Printf("Hashes: %d, video hashes: %d", hasher.TotalHashes(), hasher.TotalVideoHashes())
 		log.Printf("Rounds: %d", gamble.GameState.TotalRounds())
 		log.Printf("Stored notes: %d", memory.TotalNotes())
 		log.Printf("Active reminders: %d", reminder.TotalActive())
+		stats := mediacache.CurrentStats()
+		log.Printf("Media cache: %d entries, %d MB", stats.Entries, stats.StoredBytes>>20)
 	})
 
 	err = dg.Open()
@@ -94,6 +126,7 @@ func main() {
 	}
 
 	reminder.Init(db.DB, dg)
+	go hasher.ResumeJobs(ctx, dg)
 
 	for _, guild := range dg.State.Guilds {
 		log.Printf("Loading commands for %s", guild.ID)